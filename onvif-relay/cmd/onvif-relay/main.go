@@ -5,17 +5,25 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"net"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strconv"
 	"syscall"
 	"time"
 
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/auth"
 	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/camera"
 	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/config"
 	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/discovery"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/eventbus"
 	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/mediamtx"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/mqtt"
 	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/onvif/soap"
 	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/onvif"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/onvif/ptz"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/talk"
 )
 
 func main() {
@@ -41,6 +49,24 @@ func main() {
 		log.Printf("WARNING: Using default credentials (admin/admin) - CHANGE THESE IN PRODUCTION!")
 	}
 
+	// Relay accounts: auth and users from the config file plus users created via ONVIF
+	users, err := auth.NewStore(cfg.Server, filepath.Join(cfg.Server.StateDir, "users.json"))
+	if err != nil {
+		log.Fatalf("Failed to load users: %v", err)
+	}
+
+	// Discovery scopes: fixed scopes plus those set via SetScopes, also served by GetScopes
+	scopes, err := discovery.NewScopes(cfg.Server.DeviceName, filepath.Join(cfg.Server.StateDir, "scopes.json"))
+	if err != nil {
+		log.Fatalf("Failed to load scopes: %v", err)
+	}
+
+	// PTZ presets saved via ONVIF SetPreset
+	ptzStore, err := ptz.NewStore(filepath.Join(cfg.Server.StateDir, "ptz.json"))
+	if err != nil {
+		log.Fatalf("Failed to load PTZ state: %v", err)
+	}
+
 	// Create camera registry
 	registry, err := camera.NewRegistry(cfg)
 	if err != nil {
@@ -58,7 +84,7 @@ func main() {
 		log.Printf("mediamtx API ready")
 
 		log.Printf("Configuring mediamtx paths...")
-		if err := configureMediamtxPaths(cfg, mtxClient); err != nil {
+		if err := mtxClient.SyncPaths(nil, mediamtx.BuildPathConfigs(cfg)); err != nil {
 			log.Fatalf("Failed to configure mediamtx paths: %v", err)
 		}
 		log.Printf("All mediamtx paths configured")
@@ -66,16 +92,25 @@ func main() {
 		log.Printf("mediamtx disabled (api not set); streams must specify rtsp_url directly")
 	}
 
+	// Event bus shared by webhook ingestion, health checks and event consumers
+	bus := eventbus.New()
+
 	// Start health checker
-	healthChecker := camera.NewHealthChecker(registry, 30*time.Second)
+	healthChecker := camera.NewHealthChecker(registry, bus, 30*time.Second)
 	healthChecker.Start()
 	log.Printf("Health checker started")
 
+	// Keep cached PTZ positions in line with the cameras (fast while moving, slow when idle)
+	positionReconciler := camera.NewPositionReconciler(registry, bus, 500*time.Millisecond, 30*time.Second)
+	positionReconciler.Start()
+
 	// Start WS-Discovery responder if enabled
 	var discoveryResponder *discovery.Responder
 	if cfg.Server.Discovery {
-		baseURL := fmt.Sprintf("http://localhost:%d", cfg.Server.OnvifPort)
-		discoveryResponder, err = discovery.NewResponder(cfg.Server.DeviceName, baseURL)
+		// XAddrs use the address of the interface that received the probe unless advertise_host is set
+		discoveryResponder, err = discovery.NewResponder(cfg.Server.DeviceName, scopes, func(host string) []string {
+			return cfg.Server.BaseURLs(cfg.Server.AdvertisedHost(host))
+		})
 		if err != nil {
 			log.Fatalf("Failed to create WS-Discovery responder: %v", err)
 		}
@@ -85,8 +120,48 @@ func main() {
 		log.Printf("WS-Discovery responder started")
 	}
 
+	// Start the RTSP audio backchannel if enabled
+	var backchannelServer *talk.BackchannelServer
+	if cfg.Server.BackchannelPort != 0 {
+		// Streams of mediamtx are proxied with the backchannel track added
+		var upstream string
+		if host := cfg.Server.Mediamtx.Host(); host != "" {
+			upstream = net.JoinHostPort(host, strconv.Itoa(cfg.Server.Mediamtx.RTSPPort))
+		}
+		backchannelServer = talk.NewBackchannelServer(registry, users, upstream)
+		if err := backchannelServer.Start(fmt.Sprintf(":%d", cfg.Server.BackchannelPort)); err != nil {
+			log.Fatalf("Failed to start audio backchannel: %v", err)
+		}
+		log.Printf("Audio backchannel listening on RTSP port %d", cfg.Server.BackchannelPort)
+	}
+
 	// Create and start ONVIF server
-	onvifServer := onvif.NewServer(cfg, registry)
+	onvifServer := onvif.NewServer(cfg, registry, bus, users, scopes, ptzStore)
+
+	// Start MQTT bridge if enabled (broker is set)
+	var mqttBridge *mqtt.Bridge
+	if cfg.Server.MQTT.Broker != "" {
+		mqttBridge = mqtt.NewBridge(cfg.Server.MQTT, registry, bus, onvifServer.MQTTCommander())
+		onvifServer.SetMQTTPublisher(mqttBridge)
+		mqttBridge.Start()
+		log.Printf("MQTT bridge started")
+	}
+
+	// Reload configuration on SIGHUP or when the file changes
+	configReloader := newReloader(*configPath, cfg, registry, onvifServer, bus, users)
+	reloadDone := make(chan struct{})
+	go configReloader.Watch(reloadDone)
+
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+	go func() {
+		for range hupChan {
+			log.Printf("Received SIGHUP, reloading configuration")
+			if err := configReloader.Reload(); err != nil {
+				log.Printf("Configuration reload failed: %v", err)
+			}
+		}
+	}()
 
 	// Handle graceful shutdown
 	sigChan := make(chan os.Signal, 1)
@@ -114,12 +189,23 @@ func main() {
 		registry.Close()
 
 		// Stop other services
+		close(reloadDone)
 		healthChecker.Stop()
+		positionReconciler.Stop()
+		if mqttBridge != nil {
+			mqttBridge.Stop()
+		}
 		if discoveryResponder != nil {
 			discoveryResponder.Stop()
 		}
+		if backchannelServer != nil {
+			backchannelServer.Stop()
+		}
 
 		log.Printf("Shutdown complete")
+		if onvifServer.RestartRequested() {
+			os.Exit(onvif.RestartExitCode)
+		}
 		os.Exit(0)
 	}()
 
@@ -128,32 +214,3 @@ func main() {
 		log.Fatalf("ONVIF server failed: %v", err)
 	}
 }
-
-// configureMediamtxPaths configures all camera stream paths in mediamtx
-func configureMediamtxPaths(cfg *config.Config, client *mediamtx.Client) error {
-	for _, cam := range cfg.Cameras {
-		for _, stream := range cam.Streams {
-			pathName := fmt.Sprintf("%s/%s", cam.Name, stream.Path)
-			log.Printf("Configuring path: %s (codec: %s)", pathName, stream.Codec)
-
-			// Build ffmpeg command
-			ffmpegCmd := mediamtx.BuildFFmpegCommand(&cam, &stream, &cfg.Server.Mediamtx)
-
-			// Create path configuration
-			pathConfig := mediamtx.PathConfig{
-				RunOnDemand:           ffmpegCmd,
-				RunOnDemandRestart:    true,
-				RunOnDemandCloseAfter: "60s",
-			}
-
-			// Configure path with idempotent logic
-			if err := client.ConfigurePath(pathName, pathConfig); err != nil {
-				return fmt.Errorf("failed to configure path %s: %w", pathName, err)
-			}
-
-			log.Printf("Path configured: %s", pathName)
-		}
-	}
-
-	return nil
-}
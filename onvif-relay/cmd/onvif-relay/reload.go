@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/auth"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/camera"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/config"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/eventbus"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/mediamtx"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/onvif"
+)
+
+// configPollInterval is how often the configuration file is checked for changes
+const configPollInterval = 5 * time.Second
+
+// reloader applies changes of the configuration file to the running relay
+type reloader struct {
+	path     string
+	registry *camera.Registry
+	server   *onvif.Server
+	bus      *eventbus.Bus
+	users    *auth.Store
+
+	mu      sync.Mutex
+	cfg     *config.Config
+	modTime time.Time
+}
+
+// newReloader creates a reloader for the configuration currently in use
+func newReloader(path string, cfg *config.Config, registry *camera.Registry, server *onvif.Server, bus *eventbus.Bus, users *auth.Store) *reloader {
+	r := &reloader{
+		path:     path,
+		registry: registry,
+		server:   server,
+		bus:      bus,
+		users:    users,
+		cfg:      cfg,
+	}
+	if info, err := os.Stat(path); err == nil {
+		r.modTime = info.ModTime()
+	}
+	return r
+}
+
+// Reload loads the configuration file and applies the difference to the running relay:
+// cameras are added, updated or removed in the registry, only the changed mediamtx paths
+// are reconfigured or deleted, and the proxy rules and accounts are rebuilt.
+// The running configuration is kept if the new one fails to load or validate.
+func (r *reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if info, err := os.Stat(r.path); err == nil {
+		r.modTime = info.ModTime()
+	}
+
+	newCfg, err := config.LoadConfig(r.path)
+	if err != nil {
+		return fmt.Errorf("keeping current configuration: %w", err)
+	}
+	if err := newCfg.Validate(); err != nil {
+		return fmt.Errorf("keeping current configuration: validation failed: %w", err)
+	}
+
+	// Server settings other than proxies and accounts are bound at startup
+	oldServer, newServer := r.cfg.Server, newCfg.Server
+	oldServer.Proxies, newServer.Proxies = nil, nil
+	oldServer.Auth, newServer.Auth = config.AuthConfig{}, config.AuthConfig{}
+	oldServer.Users, newServer.Users = nil, nil
+	if !reflect.DeepEqual(oldServer, newServer) {
+		log.Printf("WARNING: server settings other than proxies, auth and users changed; restart the relay to apply them")
+	}
+
+	// Update mediamtx paths before the registry so that new cameras have streams when they appear
+	if api := r.cfg.Server.Mediamtx.API; api != "" {
+		if newCfg.Server.Mediamtx.API != api {
+			log.Printf("WARNING: mediamtx.api changed; restart the relay to apply it")
+		} else {
+			mtxClient := mediamtx.NewClient(api)
+			if err := mtxClient.SyncPaths(mediamtx.BuildPathConfigs(r.cfg), mediamtx.BuildPathConfigs(newCfg)); err != nil {
+				return fmt.Errorf("keeping current configuration: %w", err)
+			}
+		}
+	}
+
+	added, updated, removed := r.registry.Update(newCfg)
+	for _, name := range append(added, updated...) {
+		r.bus.Publish(camera.ConfigChangedEvent{Camera: name})
+	}
+	for _, name := range removed {
+		r.bus.Publish(camera.ConfigChangedEvent{Camera: name, Removed: true})
+	}
+
+	r.server.ReloadProxies(newCfg)
+	if err := r.users.SetFixed(newCfg.Server); err != nil {
+		log.Printf("WARNING: failed to reload users: %v", err)
+	}
+
+	r.cfg = newCfg
+	log.Printf("Configuration reloaded: %d cameras", len(newCfg.Cameras))
+	return nil
+}
+
+// Watch polls the configuration file and reloads it when its modification time changes
+func (r *reloader) Watch(done <-chan struct{}) {
+	ticker := time.NewTicker(configPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			info, err := os.Stat(r.path)
+			if err != nil {
+				continue
+			}
+
+			r.mu.Lock()
+			changed := !info.ModTime().Equal(r.modTime)
+			r.mu.Unlock()
+			if !changed {
+				continue
+			}
+
+			log.Printf("Configuration file %s changed, reloading", r.path)
+			if err := r.Reload(); err != nil {
+				log.Printf("Configuration reload failed: %v", err)
+			}
+		}
+	}
+}
@@ -2,10 +2,12 @@ module github.com/mooglejp/atomcam_tools/onvif-relay
 
 go 1.23
 
-require gopkg.in/yaml.v3 v3.0.1
+require (
+	github.com/eclipse/paho.mqtt.golang v1.4.3
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
-	github.com/eclipse/paho.mqtt.golang v1.4.3 // indirect
 	github.com/gorilla/websocket v1.5.0 // indirect
 	golang.org/x/net v0.8.0 // indirect
 	golang.org/x/sync v0.1.0 // indirect
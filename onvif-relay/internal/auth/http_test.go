@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"crypto/md5"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mooglejp/atomcam_tools/onvif-relay/pkg/digest"
+)
+
+// digestGet requests url and answers the MD5 Digest challenge of the 401 response
+func digestGet(t *testing.T, url, username, password string) *http.Response {
+	t.Helper()
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("request to %s failed: %v", url, err)
+	}
+	resp.Body.Close()
+
+	var challenge *digest.Credentials
+	for _, value := range resp.Header.Values("WWW-Authenticate") {
+		if strings.Contains(value, "algorithm="+digest.AlgorithmMD5) {
+			// The challenge has the same fields as an Authorization header apart from the response
+			challenge, err = digest.ParseAuthorization(value + `, username="-", response="-"`)
+			if err != nil {
+				t.Fatalf("invalid challenge %q: %v", value, err)
+			}
+		}
+	}
+	if challenge == nil {
+		t.Fatalf("no MD5 challenge in %v", resp.Header.Values("WWW-Authenticate"))
+	}
+
+	hash := func(s string) string { return fmt.Sprintf("%x", md5.Sum([]byte(s))) }
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	uri := req.URL.RequestURI()
+	ha1 := hash(username + ":" + challenge.Realm + ":" + password)
+	ha2 := hash("GET:" + uri)
+	response := hash(fmt.Sprintf("%s:%s:00000001:abc:auth:%s", ha1, challenge.Nonce, ha2))
+	req.Header.Set("Authorization", fmt.Sprintf(`Digest username="%s", realm="%s", nonce="%s", uri="%s", `+
+		`algorithm=MD5, qop=auth, nc=00000001, cnonce="abc", opaque="%s", response="%s"`,
+		username, challenge.Realm, challenge.Nonce, uri, challenge.Opaque, response))
+
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request to %s failed: %v", url, err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+func TestAuthorizeRequestAcceptsDigest(t *testing.T) {
+	store := newTestStore(t, filepath.Join(t.TempDir(), "users.json"))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		level := LevelUser
+		if r.URL.Path == "/talk/garden" {
+			level = LevelOperator
+		}
+		if _, ok := store.AuthorizeRequest(w, r, "test", level, "garden"); !ok {
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	for path, want := range map[string]int{
+		"/snapshot/garden": http.StatusNoContent,
+		"/talk/garden":     http.StatusForbidden,
+	} {
+		if resp := digestGet(t, server.URL+path, "viewer", "view"); resp.StatusCode != want {
+			t.Fatalf("status of %s = %d, want %d", path, resp.StatusCode, want)
+		}
+	}
+
+	resp, err := http.Get(server.URL + "/snapshot/garden")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized || len(resp.Header.Values("WWW-Authenticate")) != 3 {
+		t.Fatalf("status = %d, challenges = %v; want 401 with Digest and Basic challenges", resp.StatusCode, resp.Header.Values("WWW-Authenticate"))
+	}
+}
@@ -0,0 +1,299 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/config"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/state"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/pkg/digest"
+)
+
+const (
+	// maxManagedUsers limits the number of users created via ONVIF
+	maxManagedUsers = 32
+	// maxUsernameLength limits usernames of users created via ONVIF
+	maxUsernameLength = 32
+	// maxPasswordLength limits passwords of users created via ONVIF
+	maxPasswordLength = 64
+)
+
+// User management errors; the ONVIF device service maps them to fault subcodes
+var (
+	ErrInvalidCredentials  = errors.New("invalid credentials")
+	ErrUsernameClash       = errors.New("username already exists")
+	ErrUsernameMissing     = errors.New("username not found")
+	ErrUsernameTooLong     = errors.New("username too long")
+	ErrPasswordTooLong     = errors.New("password too long")
+	ErrPasswordTooWeak     = errors.New("password too weak")
+	ErrTooManyUsers        = errors.New("maximum number of users reached")
+	ErrFixedUser           = errors.New("user is defined in the configuration file")
+	ErrAnonymousNotAllowed = errors.New("anonymous user level is not allowed")
+)
+
+// Store holds the relay accounts: fixed users from config.yaml (auth and users)
+// and managed users created via ONVIF, which are persisted in a state file.
+type Store struct {
+	path   string
+	digest *digest.Authenticator
+
+	mu             sync.RWMutex
+	fixed          []User
+	managed        []User
+	managedCameras []string // Cameras managed users may access (server.onvif_user_cameras; empty allows all)
+}
+
+// NewStore creates a user store from the server configuration and loads managed users from path
+func NewStore(cfg config.ServerConfig, path string) (*Store, error) {
+	s := &Store{
+		path:   path,
+		digest: digest.NewAuthenticator(),
+	}
+	if err := s.SetFixed(cfg); err != nil {
+		return nil, err
+	}
+
+	var managed []User
+	if err := state.Load(path, &managed); err != nil {
+		return nil, err
+	}
+	for _, u := range managed {
+		if err := validateManaged(u); err != nil {
+			log.Printf("WARNING: ignoring user %s created via ONVIF: %v", u.Username, err)
+			continue
+		}
+		s.managed = append(s.managed, u)
+	}
+
+	return s, nil
+}
+
+// SetFixed replaces the users defined in the configuration file (on startup and reload)
+func (s *Store) SetFixed(cfg config.ServerConfig) error {
+	var fixed []User
+	if cfg.Auth.Username != "" {
+		fixed = append(fixed, User{
+			Username: cfg.Auth.Username,
+			Password: cfg.Auth.Password,
+			Level:    LevelAdministrator,
+			Fixed:    true,
+		})
+	}
+	for _, u := range cfg.Users {
+		level, err := ParseLevel(u.Level)
+		if err != nil {
+			return fmt.Errorf("user %s: %w", u.Username, err)
+		}
+		fixed = append(fixed, User{
+			Username:    u.Username,
+			Password:    u.Password,
+			Level:       level,
+			Cameras:     append([]string(nil), u.Cameras...),
+			Fixed:       true,
+			PTZPriority: u.PTZPriority,
+		})
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.fixed = fixed
+	s.managedCameras = append([]string(nil), cfg.OnvifUserCameras...)
+	for _, u := range s.managed {
+		if s.findFixedLocked(u.Username) != nil {
+			log.Printf("WARNING: user %s created via ONVIF is shadowed by the configuration file", u.Username)
+		}
+	}
+	return nil
+}
+
+// Lookup returns a copy of a user by name; fixed users take precedence over managed ones
+func (s *Store) Lookup(username string) (*User, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u := s.findLocked(username)
+	if u == nil {
+		return nil, false
+	}
+	user := *u
+	if !user.Fixed {
+		user.Cameras = s.managedCameras
+	}
+	return &user, true
+}
+
+// Authenticate checks a username and plain text password
+func (s *Store) Authenticate(username, password string) (*User, error) {
+	user, ok := s.Lookup(username)
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+	// Use constant-time comparison to prevent timing attacks
+	if subtle.ConstantTimeCompare([]byte(password), []byte(user.Password)) != 1 {
+		return nil, ErrInvalidCredentials
+	}
+	return user, nil
+}
+
+// List returns all users sorted by name
+func (s *Store) List() []User {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := make([]User, 0, len(s.fixed)+len(s.managed))
+	users = append(users, s.fixed...)
+	for _, u := range s.managed {
+		if s.findFixedLocked(u.Username) == nil {
+			users = append(users, u)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+	return users
+}
+
+// Create adds managed users; either all users are created or none
+func (s *Store) Create(users []User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.managed)+len(users) > maxManagedUsers {
+		return ErrTooManyUsers
+	}
+
+	seen := make(map[string]bool)
+	for _, u := range users {
+		if err := validateManaged(u); err != nil {
+			return fmt.Errorf("%s: %w", u.Username, err)
+		}
+		if seen[u.Username] || s.findLocked(u.Username) != nil {
+			return fmt.Errorf("%s: %w", u.Username, ErrUsernameClash)
+		}
+		seen[u.Username] = true
+	}
+
+	managed := append(append([]User(nil), s.managed...), users...)
+	return s.saveLocked(managed)
+}
+
+// Delete removes managed users; either all users are deleted or none
+func (s *Store) Delete(usernames []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	remove := make(map[string]bool)
+	for _, name := range usernames {
+		if s.findFixedLocked(name) != nil {
+			return fmt.Errorf("%s: %w", name, ErrFixedUser)
+		}
+		if s.findManagedLocked(name) == nil {
+			return fmt.Errorf("%s: %w", name, ErrUsernameMissing)
+		}
+		remove[name] = true
+	}
+
+	var managed []User
+	for _, u := range s.managed {
+		if !remove[u.Username] {
+			managed = append(managed, u)
+		}
+	}
+	return s.saveLocked(managed)
+}
+
+// Update changes the password and level of managed users; either all users are updated or none
+func (s *Store) Update(users []User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	managed := append([]User(nil), s.managed...)
+	for _, u := range users {
+		if s.findFixedLocked(u.Username) != nil {
+			return fmt.Errorf("%s: %w", u.Username, ErrFixedUser)
+		}
+		if err := validateManaged(u); err != nil {
+			return fmt.Errorf("%s: %w", u.Username, err)
+		}
+
+		found := false
+		for i := range managed {
+			if managed[i].Username == u.Username {
+				managed[i].Password = u.Password
+				managed[i].Level = u.Level
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: %w", u.Username, ErrUsernameMissing)
+		}
+	}
+	return s.saveLocked(managed)
+}
+
+// saveLocked persists managed users and makes them current
+func (s *Store) saveLocked(managed []User) error {
+	if managed == nil {
+		managed = []User{}
+	}
+	if err := state.Save(s.path, managed); err != nil {
+		return err
+	}
+	s.managed = managed
+	return nil
+}
+
+// findLocked returns a user by name; fixed users take precedence over managed ones
+func (s *Store) findLocked(username string) *User {
+	if u := s.findFixedLocked(username); u != nil {
+		return u
+	}
+	return s.findManagedLocked(username)
+}
+
+// findFixedLocked returns a user defined in the configuration file
+func (s *Store) findFixedLocked(username string) *User {
+	for i := range s.fixed {
+		if s.fixed[i].Username == username {
+			return &s.fixed[i]
+		}
+	}
+	return nil
+}
+
+// findManagedLocked returns a user created via ONVIF
+func (s *Store) findManagedLocked(username string) *User {
+	for i := range s.managed {
+		if s.managed[i].Username == username {
+			return &s.managed[i]
+		}
+	}
+	return nil
+}
+
+// validateManaged checks a user received from an ONVIF client or loaded from the state file
+func validateManaged(u User) error {
+	if u.Username == "" {
+		return ErrUsernameMissing
+	}
+	if len(u.Username) > maxUsernameLength {
+		return ErrUsernameTooLong
+	}
+	if u.Password == "" {
+		return ErrPasswordTooWeak
+	}
+	if len(u.Password) > maxPasswordLength {
+		return ErrPasswordTooLong
+	}
+	if u.Level == LevelAnonymous {
+		return ErrAnonymousNotAllowed
+	}
+
+	// The same rules as users in config.yaml, which keep the Digest headers well-formed
+	cfg := config.UserConfig{Username: u.Username, Password: u.Password, Level: u.Level.String()}
+	return cfg.Validate()
+}
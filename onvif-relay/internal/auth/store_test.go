@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/config"
+)
+
+func newTestStore(t *testing.T, path string) *Store {
+	t.Helper()
+
+	store, err := NewStore(config.ServerConfig{
+		Auth: config.AuthConfig{Username: "admin", Password: "secret"},
+		Users: []config.UserConfig{
+			{Username: "viewer", Password: "view", Level: config.UserLevelUser, Cameras: []string{"garden"}},
+		},
+	}, path)
+	if err != nil {
+		t.Fatalf("failed to create user store: %v", err)
+	}
+	return store
+}
+
+func TestStoreAuthenticate(t *testing.T) {
+	store := newTestStore(t, filepath.Join(t.TempDir(), "users.json"))
+
+	user, err := store.Authenticate("viewer", "view")
+	if err != nil {
+		t.Fatalf("Authenticate returned an error: %v", err)
+	}
+	if user.Level != LevelUser || !user.CanAccess("garden") || user.CanAccess("swing") {
+		t.Fatalf("user = %+v, want User level restricted to garden", user)
+	}
+
+	if _, err := store.Authenticate("viewer", "wrong"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("Authenticate with wrong password = %v, want ErrInvalidCredentials", err)
+	}
+	if admin, err := store.Authenticate("admin", "secret"); err != nil || !admin.Allows(LevelAdministrator) {
+		t.Fatalf("auth user = %+v, %v; want Administrator", admin, err)
+	}
+}
+
+func TestStoreManagedUsersArePersisted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.json")
+	store := newTestStore(t, path)
+
+	if err := store.Create([]User{{Username: "operator", Password: "op", Level: LevelOperator}}); err != nil {
+		t.Fatalf("Create returned an error: %v", err)
+	}
+	if err := store.Create([]User{{Username: "viewer", Password: "x", Level: LevelUser}}); !errors.Is(err, ErrUsernameClash) {
+		t.Fatalf("Create with existing username = %v, want ErrUsernameClash", err)
+	}
+	if err := store.Update([]User{{Username: "operator", Password: "op2", Level: LevelUser}}); err != nil {
+		t.Fatalf("Update returned an error: %v", err)
+	}
+
+	reloaded := newTestStore(t, path)
+	user, err := reloaded.Authenticate("operator", "op2")
+	if err != nil {
+		t.Fatalf("managed user was not persisted: %v", err)
+	}
+	if user.Level != LevelUser {
+		t.Fatalf("level = %s, want User", user.Level)
+	}
+	if len(reloaded.List()) != 3 {
+		t.Fatalf("List = %+v, want admin, operator and viewer", reloaded.List())
+	}
+}
+
+func TestStoreRejectsChangesToFixedUsers(t *testing.T) {
+	store := newTestStore(t, filepath.Join(t.TempDir(), "users.json"))
+
+	if err := store.Delete([]string{"admin"}); !errors.Is(err, ErrFixedUser) {
+		t.Fatalf("Delete of config user = %v, want ErrFixedUser", err)
+	}
+	if err := store.Update([]User{{Username: "viewer", Password: "x", Level: LevelAdministrator}}); !errors.Is(err, ErrFixedUser) {
+		t.Fatalf("Update of config user = %v, want ErrFixedUser", err)
+	}
+	if err := store.Delete([]string{"nobody"}); !errors.Is(err, ErrUsernameMissing) {
+		t.Fatalf("Delete of unknown user = %v, want ErrUsernameMissing", err)
+	}
+	if err := store.Create([]User{{Username: "guest", Password: "x", Level: LevelAnonymous}}); !errors.Is(err, ErrAnonymousNotAllowed) {
+		t.Fatalf("Create of anonymous user = %v, want ErrAnonymousNotAllowed", err)
+	}
+}
+
+func TestStoreValidatesManagedUsers(t *testing.T) {
+	store := newTestStore(t, filepath.Join(t.TempDir(), "users.json"))
+
+	for _, u := range []User{
+		{Username: `nvr"x`, Password: "pass", Level: LevelUser},
+		{Username: "nvr", Password: "a,b", Level: LevelUser},
+	} {
+		if err := store.Create([]User{u}); err == nil {
+			t.Fatalf("Create(%q, %q) succeeded, want an invalid credential error", u.Username, u.Password)
+		}
+	}
+	if err := store.Create([]User{{Username: "nvr", Password: "pass", Level: LevelUser}}); err != nil {
+		t.Fatalf("Create returned an error: %v", err)
+	}
+	if err := store.Update([]User{{Username: "nvr", Password: "pa ss", Level: LevelUser}}); err == nil {
+		t.Fatal("Update with an invalid password succeeded")
+	}
+}
+
+func TestStoreRestrictsManagedUsersToOnvifUserCameras(t *testing.T) {
+	store, err := NewStore(config.ServerConfig{
+		Auth:             config.AuthConfig{Username: "admin", Password: "secret"},
+		OnvifUserCameras: []string{"garden"},
+	}, filepath.Join(t.TempDir(), "users.json"))
+	if err != nil {
+		t.Fatalf("failed to create user store: %v", err)
+	}
+	if err := store.Create([]User{{Username: "nvr", Password: "pass", Level: LevelOperator}}); err != nil {
+		t.Fatalf("Create returned an error: %v", err)
+	}
+
+	user, err := store.Authenticate("nvr", "pass")
+	if err != nil {
+		t.Fatalf("Authenticate returned an error: %v", err)
+	}
+	if !user.CanAccess("garden") || user.CanAccess("swing") {
+		t.Fatalf("cameras = %v, want only garden", user.Cameras)
+	}
+	if admin, _ := store.Lookup("admin"); !admin.CanAccess("swing") {
+		t.Fatal("onvif_user_cameras restricted a config user")
+	}
+}
@@ -0,0 +1,97 @@
+// Package auth manages relay accounts and their ONVIF user levels.
+package auth
+
+import (
+	"fmt"
+
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/config"
+)
+
+// Level is an ONVIF user level; higher levels include the rights of lower ones
+type Level int
+
+const (
+	// LevelAnonymous may only call actions that require no authentication
+	LevelAnonymous Level = iota
+	// LevelUser may read settings, stream and fetch snapshots
+	LevelUser
+	// LevelOperator may additionally move the PTZ, change imaging settings and talk
+	LevelOperator
+	// LevelAdministrator may additionally manage users
+	LevelAdministrator
+)
+
+// String returns the ONVIF name of the level
+func (l Level) String() string {
+	switch l {
+	case LevelAdministrator:
+		return config.UserLevelAdministrator
+	case LevelOperator:
+		return config.UserLevelOperator
+	case LevelUser:
+		return config.UserLevelUser
+	default:
+		return config.UserLevelAnonymous
+	}
+}
+
+// ParseLevel parses an ONVIF user level name
+func ParseLevel(s string) (Level, error) {
+	switch s {
+	case config.UserLevelAdministrator:
+		return LevelAdministrator, nil
+	case config.UserLevelOperator:
+		return LevelOperator, nil
+	case config.UserLevelUser:
+		return LevelUser, nil
+	case config.UserLevelAnonymous:
+		return LevelAnonymous, nil
+	}
+	return LevelAnonymous, fmt.Errorf("invalid user level: %s", s)
+}
+
+// MarshalText encodes the level by its ONVIF name
+func (l Level) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// UnmarshalText decodes an ONVIF level name
+func (l *Level) UnmarshalText(text []byte) error {
+	level, err := ParseLevel(string(text))
+	if err != nil {
+		return err
+	}
+	*l = level
+	return nil
+}
+
+// User is a relay account
+type User struct {
+	Username    string   `json:"username"`
+	Password    string   `json:"password"`
+	Level       Level    `json:"level"`
+	Cameras     []string `json:"cameras,omitempty"` // Allowed cameras; empty allows all
+	Fixed       bool     `json:"-"`                 // Defined in config.yaml; cannot be changed via ONVIF
+	PTZPriority int      `json:"-"`                 // PTZ lease priority from config.yaml (0 = by level)
+}
+
+// Anonymous is the identity of requests without credentials
+var Anonymous = &User{Level: LevelAnonymous}
+
+// Allows reports whether the user has at least the given level
+func (u *User) Allows(level Level) bool {
+	return u.Level >= level
+}
+
+// CanAccess reports whether the user may access a camera
+func (u *User) CanAccess(cameraName string) bool {
+	if len(u.Cameras) == 0 {
+		return true
+	}
+	for _, name := range u.Cameras {
+		if name == cameraName {
+			return true
+		}
+	}
+	return false
+}
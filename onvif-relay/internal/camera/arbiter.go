@@ -0,0 +1,180 @@
+package camera
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// defaultPTZLeaseTime is how long a controller keeps the PTZ lease after its last command
+// without ptz.lease_time
+const defaultPTZLeaseTime = 10 * time.Second
+
+// PTZ arbitration errors
+var (
+	// ErrPTZLocked is returned for a PTZ command while another controller holds the PTZ lease
+	ErrPTZLocked = errors.New("PTZ is locked by another controller")
+	// ErrPTZSuperseded is returned for a queued PTZ command that was dropped before it ran:
+	// replaced by a newer ContinuousMove, or discarded when another controller took the lease
+	ErrPTZSuperseded = errors.New("PTZ command superseded")
+)
+
+// PTZController identifies who sends PTZ commands to a camera (a user, a preset tour, ...)
+type PTZController struct {
+	Name     string
+	Priority int // A higher priority takes the PTZ lease from a lower one
+}
+
+// PTZLease is the PTZ lease of a camera: only its holder may move the camera until it
+// expires, unless a controller with a higher priority takes it over
+type PTZLease struct {
+	Holder  PTZController
+	Expires time.Time
+}
+
+// ptzCommand is a PTZ command waiting in the queue of a camera
+type ptzCommand struct {
+	controller PTZController
+	continuous bool // ContinuousMove; a newer one replaces it while it is waiting
+	run        func() error
+	err        error
+	done       chan struct{}
+}
+
+// ptzArbiter serializes the PTZ commands of a camera and grants the PTZ lease. The queued
+// commands are run by a worker goroutine that exits when the queue is empty.
+type ptzArbiter struct {
+	mu      sync.Mutex
+	lease   *PTZLease
+	pending []*ptzCommand
+	running bool // The worker is running the queued commands
+}
+
+// ArbitratePTZ runs a PTZ command for a controller and waits for it. Commands of a camera
+// run one at a time in the order they arrive, so a command must not submit another one to
+// the same camera. The controller takes the PTZ lease unless another controller with the
+// same or a higher priority holds it, in which case it fails with ErrPTZLocked. Taking the
+// lease from a lower priority drops that controller's waiting commands with ErrPTZSuperseded.
+func (c *Camera) ArbitratePTZ(ctl PTZController, command func() error) error {
+	return c.arbiter.submit(c.Config.Name, &ptzCommand{controller: ctl, run: command}, c.ptzLeaseTime())
+}
+
+// ArbitrateContinuousMove runs ContinuousMove for a controller like ArbitratePTZ. The lease
+// is held for the whole move. A waiting ContinuousMove of the same controller is replaced
+// by the newer one (and fails with ErrPTZSuperseded), so a burst of joystick updates does
+// not pile up behind a slow command.
+func (c *Camera) ArbitrateContinuousMove(ctl PTZController, panRate, tiltRate float64, speed int, timeout time.Duration) error {
+	return c.arbiter.submit(c.Config.Name, &ptzCommand{
+		controller: ctl,
+		continuous: true,
+		run: func() error {
+			c.ContinuousMove(panRate, tiltRate, speed, timeout)
+			return nil
+		},
+	}, timeout+c.ptzLeaseTime())
+}
+
+// PTZLease returns the PTZ lease of the camera; ok is false when nobody holds it
+func (c *Camera) PTZLease() (lease PTZLease, ok bool) {
+	c.arbiter.mu.Lock()
+	defer c.arbiter.mu.Unlock()
+	if c.arbiter.lease == nil || !time.Now().Before(c.arbiter.lease.Expires) {
+		return PTZLease{}, false
+	}
+	return *c.arbiter.lease, true
+}
+
+// ReleasePTZ gives up the PTZ lease of a controller, if it holds it
+func (c *Camera) ReleasePTZ(ctl PTZController) {
+	c.arbiter.mu.Lock()
+	defer c.arbiter.mu.Unlock()
+	if l := c.arbiter.lease; l != nil && l.Holder.Name == ctl.Name {
+		c.arbiter.lease = nil
+	}
+}
+
+// ptzLeaseTime returns how long a controller keeps the PTZ lease after its last command
+func (c *Camera) ptzLeaseTime() time.Duration {
+	if c.Config.PTZ.LeaseTime > 0 {
+		return time.Duration(c.Config.PTZ.LeaseTime * float64(time.Second))
+	}
+	return defaultPTZLeaseTime
+}
+
+// submit queues a command and waits for it; the controller holds the lease for hold
+func (a *ptzArbiter) submit(cameraName string, cmd *ptzCommand, hold time.Duration) error {
+	cmd.done = make(chan struct{})
+
+	a.mu.Lock()
+	if err := a.acquireLocked(cameraName, cmd.controller, hold); err != nil {
+		a.mu.Unlock()
+		return err
+	}
+	if n := len(a.pending); cmd.continuous && n > 0 {
+		if last := a.pending[n-1]; last.continuous && last.controller.Name == cmd.controller.Name {
+			// Superseded before it ran
+			a.pending = a.pending[:n-1]
+			last.err = fmt.Errorf("%w: replaced by a newer ContinuousMove on %s", ErrPTZSuperseded, cameraName)
+			close(last.done)
+		}
+	}
+	a.pending = append(a.pending, cmd)
+	if !a.running {
+		a.running = true
+		go a.work()
+	}
+	a.mu.Unlock()
+
+	<-cmd.done
+	return cmd.err
+}
+
+// work runs the queued commands until the queue is empty
+func (a *ptzArbiter) work() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for len(a.pending) > 0 {
+		next := a.pending[0]
+		a.pending = a.pending[1:]
+		a.mu.Unlock()
+		next.err = next.run()
+		close(next.done)
+		a.mu.Lock()
+	}
+	a.running = false
+}
+
+// acquireLocked grants the lease to a controller for hold from now; a.mu must be held
+func (a *ptzArbiter) acquireLocked(cameraName string, ctl PTZController, hold time.Duration) error {
+	now := time.Now()
+	if l := a.lease; l != nil && now.Before(l.Expires) && l.Holder.Name != ctl.Name {
+		if ctl.Priority <= l.Holder.Priority {
+			return fmt.Errorf("%w: %s holds %s until %s",
+				ErrPTZLocked, l.Holder.Name, cameraName, l.Expires.Format(time.TimeOnly))
+		}
+		log.Printf("PTZ %s: %s (priority %d) took the PTZ lease from %s (priority %d)",
+			cameraName, ctl.Name, ctl.Priority, l.Holder.Name, l.Holder.Priority)
+		a.dropPendingLocked(ctl.Name, cameraName)
+	}
+
+	a.lease = &PTZLease{Holder: ctl, Expires: now.Add(hold)}
+	return nil
+}
+
+// dropPendingLocked fails the waiting commands of all controllers but one; a.mu must be held
+func (a *ptzArbiter) dropPendingLocked(keep, cameraName string) {
+	kept := a.pending[:0]
+	for _, cmd := range a.pending {
+		if cmd.controller.Name == keep {
+			kept = append(kept, cmd)
+			continue
+		}
+		cmd.err = fmt.Errorf("%w: %s took over %s", ErrPTZSuperseded, keep, cameraName)
+		close(cmd.done)
+	}
+	clear(a.pending[len(kept):])
+	a.pending = kept
+}
@@ -0,0 +1,209 @@
+package camera
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestArbitratePTZLease(t *testing.T) {
+	cam, _, cleanup := newContinuousTestCamera(t)
+	defer cleanup()
+
+	operator := PTZController{Name: "operator", Priority: 30}
+	frigate := PTZController{Name: "frigate", Priority: 30}
+	admin := PTZController{Name: "admin", Priority: 40}
+	ran := func() error { return nil }
+
+	if err := cam.ArbitratePTZ(operator, ran); err != nil {
+		t.Fatalf("first command returned an error: %v", err)
+	}
+	if err := cam.ArbitratePTZ(frigate, ran); !errors.Is(err, ErrPTZLocked) {
+		t.Fatalf("command of the same priority: error = %v, want %v", err, ErrPTZLocked)
+	}
+	if err := cam.ArbitratePTZ(admin, ran); err != nil {
+		t.Fatalf("command of a higher priority returned an error: %v", err)
+	}
+	if lease, ok := cam.PTZLease(); !ok || lease.Holder != admin {
+		t.Fatalf("lease = %+v (held %t), want held by admin", lease, ok)
+	}
+	if err := cam.ArbitratePTZ(operator, ran); !errors.Is(err, ErrPTZLocked) {
+		t.Fatalf("command of a lower priority: error = %v, want %v", err, ErrPTZLocked)
+	}
+
+	cam.ReleasePTZ(admin)
+	if lease, ok := cam.PTZLease(); ok {
+		t.Fatalf("lease = %+v after release, want none", lease)
+	}
+	if err := cam.ArbitratePTZ(frigate, ran); err != nil {
+		t.Fatalf("command after release returned an error: %v", err)
+	}
+}
+
+func TestArbitratePTZLeaseExpires(t *testing.T) {
+	cam, _, cleanup := newContinuousTestCamera(t)
+	defer cleanup()
+	cam.Config.PTZ.LeaseTime = 0.05
+
+	operator := PTZController{Name: "operator", Priority: 30}
+	tour := PTZController{Name: "tour", Priority: 0}
+	if err := cam.ArbitratePTZ(operator, func() error { return nil }); err != nil {
+		t.Fatalf("command returned an error: %v", err)
+	}
+	if err := cam.ArbitratePTZ(tour, func() error { return nil }); !errors.Is(err, ErrPTZLocked) {
+		t.Fatalf("command during the lease: error = %v, want %v", err, ErrPTZLocked)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if err := cam.ArbitratePTZ(tour, func() error { return nil }); err != nil {
+		t.Fatalf("command after the lease expired returned an error: %v", err)
+	}
+}
+
+func TestArbitrateContinuousMoveCoalescesWaitingMoves(t *testing.T) {
+	cam, moves, cleanup := newContinuousTestCamera(t)
+	defer cleanup()
+
+	joystick := PTZController{Name: "joystick", Priority: 30}
+	release := make(chan struct{})
+	blocked := make(chan error, 1)
+	go func() {
+		blocked <- cam.ArbitratePTZ(joystick, func() error {
+			<-release
+			return nil
+		})
+	}()
+	waitPending := func(n int) {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for {
+			cam.arbiter.mu.Lock()
+			pending, running := len(cam.arbiter.pending), cam.arbiter.running
+			cam.arbiter.mu.Unlock()
+			if running && pending == n {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("%d commands waiting, want %d", pending, n)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	waitPending(0)
+
+	first := make(chan error, 1)
+	go func() { first <- cam.ArbitrateContinuousMove(joystick, 50, 0, 9, 10*time.Second) }()
+	waitPending(1)
+	second := make(chan error, 1)
+	go func() { second <- cam.ArbitrateContinuousMove(joystick, 0, -50, 7, 10*time.Second) }()
+
+	// The waiting move is replaced before it runs
+	select {
+	case err := <-first:
+		if !errors.Is(err, ErrPTZSuperseded) {
+			t.Fatalf("replaced move: error = %v, want %v", err, ErrPTZSuperseded)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("replaced move did not return")
+	}
+	waitPending(1)
+
+	close(release)
+	if err := <-blocked; err != nil {
+		t.Fatalf("blocking command returned an error: %v", err)
+	}
+	if err := <-second; err != nil {
+		t.Fatalf("continuous move returned an error: %v", err)
+	}
+	time.Sleep(continuousStepInterval + continuousStepInterval/2)
+	if got := moves(); len(got) == 0 || got[0] != "move 100 70 7" {
+		t.Fatalf("moves = %q, want only the latest move to run from (100, 80)", got)
+	}
+}
+
+func TestArbitratePTZReturnsWithoutRunningLaterCommands(t *testing.T) {
+	cam, _, cleanup := newContinuousTestCamera(t)
+	defer cleanup()
+
+	operator := PTZController{Name: "operator", Priority: 30}
+	admin := PTZController{Name: "admin", Priority: 40}
+	waitPending := func(n int) {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for {
+			cam.arbiter.mu.Lock()
+			pending := len(cam.arbiter.pending)
+			cam.arbiter.mu.Unlock()
+			if pending == n {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("%d commands waiting, want %d", pending, n)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	// blocking submits a command that runs until release is closed and waits until it runs
+	blocking := func(release <-chan struct{}) <-chan error {
+		t.Helper()
+		started, result := make(chan struct{}), make(chan error, 1)
+		go func() {
+			result <- cam.ArbitratePTZ(operator, func() error {
+				close(started)
+				<-release
+				return nil
+			})
+		}()
+		select {
+		case <-started:
+		case <-time.After(time.Second):
+			t.Fatal("command did not start")
+		}
+		return result
+	}
+
+	releaseFirst, releaseSecond := make(chan struct{}), make(chan struct{})
+	first := blocking(releaseFirst)
+	second := make(chan error, 1)
+	go func() {
+		second <- cam.ArbitratePTZ(operator, func() error {
+			<-releaseSecond
+			return nil
+		})
+	}()
+	waitPending(1)
+
+	// The first caller returns once its own command is done, not after the queue
+	close(releaseFirst)
+	select {
+	case err := <-first:
+		if err != nil {
+			t.Fatalf("first command returned an error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("first caller is blocked by the command queued after it")
+	}
+	close(releaseSecond)
+	if err := <-second; err != nil {
+		t.Fatalf("second command returned an error: %v", err)
+	}
+
+	// Waiting commands dropped by a takeover fail with ErrPTZSuperseded
+	release := make(chan struct{})
+	blocked := blocking(release)
+	dropped := make(chan error, 1)
+	go func() { dropped <- cam.ArbitratePTZ(operator, func() error { return nil }) }()
+	waitPending(1)
+	go func() { _ = cam.ArbitratePTZ(admin, func() error { return nil }) }()
+	select {
+	case err := <-dropped:
+		if !errors.Is(err, ErrPTZSuperseded) {
+			t.Fatalf("dropped command: error = %v, want %v", err, ErrPTZSuperseded)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("dropped command did not return")
+	}
+	close(release)
+	if err := <-blocked; err != nil {
+		t.Fatalf("running command returned an error: %v", err)
+	}
+}
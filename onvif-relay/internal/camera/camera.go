@@ -16,6 +16,16 @@ type Camera struct {
 	ptzMu    sync.RWMutex
 	ptzPan   int // Current pan position (0-355)
 	ptzTilt  int // Current tilt position (0-180)
+	flip     *MotorStatus // Picture flip state last reported by the camera (nil = not read yet)
+	moveMu   sync.Mutex
+	moveSeq  uint64 // Incremented by each move; a settle watcher only reports its own move
+	moving   bool
+	movePan  int // Target of the last move
+	moveTilt int
+	motionMu sync.Mutex
+	motion   *continuousMotion // Running continuous move (nil = none)
+	arbiter  *ptzArbiter       // PTZ command queue and lease, handed to the camera replacing this one
+	snapshot snapshotCache     // Last good snapshot and the fetch in progress
 }
 
 // NewCamera creates a new camera instance
@@ -34,6 +44,7 @@ func NewCamera(cfg *config.CameraConfig) *Camera {
 		health:  true,
 		ptzPan:  pan,
 		ptzTilt: tilt,
+		arbiter: &ptzArbiter{},
 	}
 
 	// Debug: Log initialization
@@ -93,15 +104,92 @@ func (c *Camera) SetPTZPosition(pan, tilt int) {
 
 // SyncPTZPosition refreshes the cached PTZ position from the camera.
 func (c *Camera) SyncPTZPosition() (pan, tilt int, err error) {
-	pan, tilt, err = c.Client.PTZGetPosition()
+	status, err := c.Client.PTZGetStatus()
 	if err != nil {
 		return 0, 0, err
 	}
-	c.SetPTZPosition(pan, tilt)
-	return pan, tilt, nil
+	c.SetPTZPosition(status.Pan, status.Tilt)
+	if status.FlipKnown {
+		c.ptzMu.Lock()
+		c.flip = &status
+		c.ptzMu.Unlock()
+	}
+	return status.Pan, status.Tilt, nil
+}
+
+// ViewMirror reports whether pan and tilt must be mirrored to map the viewer's frame of
+// reference to the camera's. The firmware already mirrors them for the camera's own flip
+// setting, so only a ceiling mount whose picture is turned upright outside the camera
+// (not by flip_mirror) needs it. The flip state is read from the camera on first use.
+func (c *Camera) ViewMirror() (pan, tilt bool) {
+	if c.Config.PTZ.Mount != config.MountCeiling {
+		return false, false
+	}
+
+	c.ptzMu.RLock()
+	flip := c.flip
+	c.ptzMu.RUnlock()
+	if flip == nil {
+		if _, _, err := c.SyncPTZPosition(); err != nil {
+			log.Printf("Camera %s: failed to read flip state, assuming none: %v", c.Config.Name, err)
+		}
+		c.ptzMu.RLock()
+		flip = c.flip
+		c.ptzMu.RUnlock()
+	}
+	if flip == nil {
+		return true, true
+	}
+	return !flip.HFlip, !flip.VFlip
 }
 
 // Close stops the camera client's background goroutines
 func (c *Camera) Close() {
+	c.endContinuousMove()
 	c.Client.Close()
 }
+
+// takeOver carries the state of the camera this one replaces on a configuration reload
+// over to it: health, PTZ position and the PTZ lease with the queued commands. A move in
+// progress follows with resumeMove.
+func (c *Camera) takeOver(old *Camera) {
+	c.SetHealth(old.GetHealth())
+	c.SetPTZPosition(old.GetPTZPosition())
+	c.arbiter = old.arbiter
+}
+
+// resumeMove goes on with the move in progress of the camera this one replaced: a running
+// continuous move continues with the new configuration, and a move to a target is watched
+// until it settles.
+func (c *Camera) resumeMove(old *Camera) {
+	if params, ok := old.handOverContinuousMove(); ok {
+		c.ContinuousMove(params.panRate, params.tiltRate, params.speed, params.timeout)
+		return
+	}
+
+	old.moveMu.Lock()
+	moving, pan, tilt := old.moving, old.movePan, old.moveTilt
+	old.moveSeq++ // Stops the settle watcher of the old camera
+	old.moving = false
+	old.moveMu.Unlock()
+	if moving {
+		go c.watchMove(c.beginMove(pan, tilt), pan, tilt)
+	}
+}
+
+// VideoSourceToken returns the ONVIF video source token of the camera.
+// Each camera is one video source shared by all of its stream profiles.
+func (c *Camera) VideoSourceToken() string {
+	return c.Config.Name + "_VideoSource"
+}
+
+// PTZNodeToken returns the ONVIF PTZ node token of the camera.
+// Each camera has its own node, since its pan/tilt limits may differ.
+func (c *Camera) PTZNodeToken() string {
+	return c.Config.Name + "_PTZNode"
+}
+
+// AudioOutputToken returns the ONVIF audio output token of the camera's speaker
+func (c *Camera) AudioOutputToken() string {
+	return c.Config.Name + "_AudioOutput"
+}
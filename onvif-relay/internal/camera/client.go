@@ -7,6 +7,7 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/config"
@@ -18,6 +19,13 @@ type Client struct {
 	cfg             *config.CameraConfig
 	httpClient      *http.Client
 	digestTransport *digest.Transport // for cleanup
+
+	imagingMu      sync.Mutex
+	imaging        *ImagingSettings // Cached imaging settings (nil = not fetched)
+	imagingFetched time.Time
+
+	encoderMu sync.Mutex
+	encoder   map[int]cachedEncoderSettings // Cached encoder settings per channel
 }
 
 // CommandRequest represents a cmd.cgi command request
@@ -54,6 +62,32 @@ func (c *Client) Close() {
 
 // SendCommand sends a command to the camera via cmd.cgi
 func (c *Client) SendCommand(command string) error {
+	_, err := c.execCommand(command)
+	return err
+}
+
+// QueryCommand sends a command to the camera via cmd.cgi and returns its trimmed output.
+// It is used for getter commands such as "property motionDet" that print the current value.
+func (c *Client) QueryCommand(command string) (string, error) {
+	output, err := c.execCommand(command)
+	if err != nil {
+		return "", err
+	}
+
+	value := strings.TrimSpace(string(output))
+	if value == "" || value == "error" {
+		return "", fmt.Errorf("command %q returned no value", command)
+	}
+	return value, nil
+}
+
+// GetProperty returns the current value of a camera property (e.g. "on" or "off")
+func (c *Client) GetProperty(name string) (string, error) {
+	return c.QueryCommand("property " + name)
+}
+
+// execCommand posts a whitelisted command to cmd.cgi and returns the response body
+func (c *Client) execCommand(command string) ([]byte, error) {
 	// Whitelist validation: only allow specific command prefixes
 	allowedPrefixes := []string{"move ", "video ", "property ", "alarm "}
 	allowed := false
@@ -65,10 +99,28 @@ func (c *Client) SendCommand(command string) error {
 	}
 
 	if !allowed {
-		return fmt.Errorf("command not allowed (must start with move/video/property/alarm)")
+		return nil, fmt.Errorf("command not allowed (must start with move/video/property/alarm)")
 	}
 
-	url := fmt.Sprintf("http://%s:%d/cgi-bin/cmd.cgi?port=socket", c.cfg.Host, c.cfg.HTTPPort)
+	return c.postCommand("?port=socket", command)
+}
+
+// Reboot restarts the camera. Unlike the socket commands, reboot is handled by the
+// webcmd interface of cmd.cgi, which answers "reboot  OK" before the camera goes down.
+func (c *Client) Reboot() error {
+	output, err := c.postCommand("", "reboot")
+	if err != nil {
+		return err
+	}
+	if !strings.HasSuffix(strings.TrimSpace(string(output)), "OK") {
+		return fmt.Errorf("reboot failed: %s", strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// postCommand posts a command to cmd.cgi with the given query string and returns the response body
+func (c *Client) postCommand(query, command string) ([]byte, error) {
+	url := fmt.Sprintf("http://%s:%d/cgi-bin/cmd.cgi%s", c.cfg.Host, c.cfg.HTTPPort, query)
 
 	req := CommandRequest{
 		Exec: command,
@@ -76,12 +128,12 @@ func (c *Client) SendCommand(command string) error {
 
 	body, err := json.Marshal(req)
 	if err != nil {
-		return fmt.Errorf("failed to marshal command: %w", err)
+		return nil, fmt.Errorf("failed to marshal command: %w", err)
 	}
 
 	httpReq, err := http.NewRequest(http.MethodPost, url, nil)
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
 	httpReq.ContentLength = int64(len(body))
@@ -95,16 +147,19 @@ func (c *Client) SendCommand(command string) error {
 	// Digest auth is handled by the http.Client.Transport
 	resp, err := c.httpClient.Do(httpReq)
 	if err != nil {
-		return fmt.Errorf("failed to send command: %w", err)
+		return nil, fmt.Errorf("failed to send command: %w", err)
 	}
 	defer resp.Body.Close()
 
+	bodyBytes, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20)) // 1MB limit
 	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20)) // 1MB limit
-		return fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode, string(bodyBytes))
+		return nil, fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode, string(bodyBytes))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read command response: %w", err)
 	}
 
-	return nil
+	return bodyBytes, nil
 }
 
 // Ping checks if the camera is reachable
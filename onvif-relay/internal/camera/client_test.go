@@ -0,0 +1,36 @@
+package camera
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestReboot(t *testing.T) {
+	client, closeClient := newPTZTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/cgi-bin/cmd.cgi" || r.URL.RawQuery != "" {
+			t.Fatalf("unexpected request URL: %s", r.URL.String())
+		}
+		var req CommandRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Exec != "reboot" {
+			t.Fatalf("unexpected command: %+v (%v)", req, err)
+		}
+		_, _ = w.Write([]byte("reboot  OK\n"))
+	})
+	defer closeClient()
+
+	if err := client.Reboot(); err != nil {
+		t.Fatalf("Reboot returned an error: %v", err)
+	}
+}
+
+func TestRebootFailure(t *testing.T) {
+	client, closeClient := newPTZTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("error\n"))
+	})
+	defer closeClient()
+
+	if err := client.Reboot(); err == nil {
+		t.Fatal("Reboot returned nil for a failed command")
+	}
+}
@@ -0,0 +1,231 @@
+package camera
+
+import (
+	"log"
+	"math"
+	"sync"
+	"time"
+)
+
+// continuousStepInterval is how often a continuous move advances the motor target
+const continuousStepInterval = 200 * time.Millisecond
+
+// continuousMotion is a running continuous move
+type continuousMotion struct {
+	stop chan struct{}
+	done chan struct{}
+
+	mu       sync.Mutex
+	params   continuousParams  // Direction and speed the move runs with
+	deadline time.Time         // When the move times out
+	update   *continuousParams // New direction and speed not yet taken by the move (nil = none)
+	stopped  bool              // The move has ended and cannot be retargeted
+}
+
+// continuousParams are the direction, speed and timeout of a continuous move
+type continuousParams struct {
+	panRate, tiltRate float64
+	speed             int
+	timeout           time.Duration
+}
+
+// ContinuousMove moves the camera until timeout, Stop or another move: the motor target
+// advances by panRate and tiltRate degrees per second (signed, in AtomCam pan/tilt
+// directions). A running continuous move takes the new direction, speed and timeout
+// without being restarted, so repeated calls (a joystick) keep the motor moving smoothly.
+func (c *Camera) ContinuousMove(panRate, tiltRate float64, speed int, timeout time.Duration) {
+	c.motionMu.Lock()
+	defer c.motionMu.Unlock()
+
+	params := continuousParams{panRate: panRate, tiltRate: tiltRate, speed: speed, timeout: timeout}
+	if c.motion != nil && c.motion.retarget(params) {
+		return
+	}
+	c.stopMotionLocked()
+	m := &continuousMotion{
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+		params:   params,
+		deadline: time.Now().Add(params.timeout),
+	}
+	c.motion = m
+	go c.runContinuousMove(m, params)
+}
+
+// retarget hands new parameters to the move; it returns false if the move has ended
+func (m *continuousMotion) retarget(params continuousParams) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stopped {
+		return false
+	}
+	m.update = &params
+	return true
+}
+
+// takeUpdate returns the parameters handed to the move since the last call, if any
+func (m *continuousMotion) takeUpdate() (continuousParams, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.update == nil {
+		return continuousParams{}, false
+	}
+	params := *m.update
+	m.update = nil
+	m.params = params
+	m.deadline = time.Now().Add(params.timeout)
+	return params, true
+}
+
+// remaining returns the parameters of a running move with the time it has left
+func (m *continuousMotion) remaining() (continuousParams, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stopped {
+		return continuousParams{}, false
+	}
+	if m.update != nil {
+		return *m.update, true
+	}
+	params := m.params
+	params.timeout = time.Until(m.deadline)
+	return params, params.timeout > 0
+}
+
+// end marks the move as ended unless new parameters were handed to it meanwhile
+func (m *continuousMotion) end() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.update != nil {
+		return false
+	}
+	m.stopped = true
+	return true
+}
+
+// abandon marks the move as ended, dropping new parameters handed to it
+func (m *continuousMotion) abandon() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.update = nil
+	m.stopped = true
+}
+
+// endContinuousMove stops a running continuous move without halting the motor
+func (c *Camera) endContinuousMove() {
+	c.motionMu.Lock()
+	defer c.motionMu.Unlock()
+	c.stopMotionLocked()
+}
+
+// handOverContinuousMove stops a running continuous move without halting the motor and
+// returns what it has left to run, so that the camera replacing this one can go on with it
+func (c *Camera) handOverContinuousMove() (continuousParams, bool) {
+	c.motionMu.Lock()
+	defer c.motionMu.Unlock()
+	if c.motion == nil {
+		return continuousParams{}, false
+	}
+	params, ok := c.motion.remaining()
+	c.stopMotionLocked()
+	return params, ok
+}
+
+// stopMotionLocked stops the running continuous move and waits for it; c.motionMu must be held
+func (c *Camera) stopMotionLocked() {
+	if c.motion == nil {
+		return
+	}
+	close(c.motion.stop)
+	<-c.motion.done
+	c.motion = nil
+}
+
+// runContinuousMove steps the motor target until the move is stopped, times out or
+// reaches the pan/tilt limits. It does not step into a no-go zone.
+func (c *Camera) runContinuousMove(m *continuousMotion, params continuousParams) {
+	defer close(m.done)
+
+	pan, tilt, err := c.SyncPTZPosition()
+	if err != nil {
+		pan, tilt = c.GetPTZPosition()
+	}
+	targetPan, targetTilt := float64(pan), float64(tilt)
+	seq := c.beginMove(pan, tilt)
+
+	ticker := time.NewTicker(continuousStepInterval)
+	defer ticker.Stop()
+	timer := time.NewTimer(params.timeout)
+	defer timer.Stop()
+
+	// finish ends the move at the current position unless it was retargeted meanwhile
+	finish := func() bool {
+		if !m.end() {
+			return false
+		}
+		go c.watchMove(seq, pan, tilt)
+		return true
+	}
+
+	panMin, panMax, tiltMin, tiltMax := c.PTZBounds()
+	step := continuousStepInterval.Seconds()
+	for {
+		select {
+		case <-m.stop:
+			m.abandon()
+			return
+		case <-timer.C:
+			if finish() {
+				return
+			}
+		case <-ticker.C:
+		}
+		if update, ok := m.takeUpdate(); ok {
+			params = update
+			timer.Reset(params.timeout)
+		}
+		panRate, tiltRate := params.panRate, params.tiltRate
+
+		targetPan = math.Max(float64(panMin), math.Min(float64(panMax), targetPan+panRate*step))
+		targetTilt = math.Max(float64(tiltMin), math.Min(float64(tiltMax), targetTilt+tiltRate*step))
+		nextPan, nextTilt := int(math.Round(targetPan)), int(math.Round(targetTilt))
+		if c.inNoGoZone(nextPan, nextTilt) && !c.inNoGoZone(pan, tilt) {
+			// Slide along the edge of the no-go zone on the axis that stays out of it
+			switch {
+			case !c.inNoGoZone(nextPan, tilt):
+				nextTilt, targetTilt = tilt, float64(tilt)
+			case !c.inNoGoZone(pan, nextTilt):
+				nextPan, targetPan = pan, float64(pan)
+			default:
+				targetPan, targetTilt = float64(pan), float64(tilt)
+				if finish() {
+					log.Printf("PTZ %s: continuous move stopped at a no-go zone", c.Config.Name)
+					return
+				}
+				continue
+			}
+		}
+		if nextPan == pan && nextTilt == tilt {
+			if atLimit(targetPan, panRate, float64(panMin), float64(panMax)) &&
+				atLimit(targetTilt, tiltRate, float64(tiltMin), float64(tiltMax)) && finish() {
+				return
+			}
+			continue
+		}
+
+		if err := c.Client.PTZMove(nextPan, nextTilt, params.speed); err != nil {
+			log.Printf("PTZ %s: continuous move failed: %v", c.Config.Name, err)
+			m.abandon()
+			go c.watchMove(seq, pan, tilt)
+			return
+		}
+		pan, tilt = nextPan, nextTilt
+		c.SetPTZPosition(pan, tilt)
+		c.retargetMove(seq, pan, tilt)
+	}
+}
+
+// atLimit reports whether an axis moving at rate cannot advance any further
+func atLimit(position, rate, min, max float64) bool {
+	return rate == 0 || (rate < 0 && position <= min) || (rate > 0 && position >= max)
+}
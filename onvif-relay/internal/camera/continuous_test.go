@@ -0,0 +1,129 @@
+package camera
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/config"
+)
+
+// newContinuousTestCamera returns a camera reporting MOTORPOS=100 80 and a function
+// returning the move commands it received
+func newContinuousTestCamera(t *testing.T) (*Camera, func() []string, func()) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var moves []string
+	client, closeClient := newPTZTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("name") == "status" {
+			_, _ = w.Write([]byte("MOTORPOS=100.0 80.0\n"))
+			return
+		}
+		var req CommandRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err == nil {
+			mu.Lock()
+			moves = append(moves, req.Exec)
+			mu.Unlock()
+		}
+		_, _ = w.Write([]byte("ok\n"))
+	})
+
+	cam := &Camera{Config: &config.CameraConfig{Name: "swing"}, Client: client, arbiter: &ptzArbiter{}}
+	return cam, func() []string {
+			mu.Lock()
+			defer mu.Unlock()
+			return append([]string(nil), moves...)
+		}, func() {
+			cam.endContinuousMove()
+			closeClient()
+		}
+}
+
+func TestContinuousMoveStepsUntilStop(t *testing.T) {
+	cam, moves, cleanup := newContinuousTestCamera(t)
+	defer cleanup()
+
+	cam.ContinuousMove(50, 0, 9, 10*time.Second)
+	time.Sleep(3*continuousStepInterval + continuousStepInterval/2)
+	if !cam.PTZMoving() {
+		t.Fatal("camera is not moving during a continuous move")
+	}
+	got := moves()
+	if len(got) < 2 || got[0] != "move 110 80 9" || got[1] != "move 120 80 9" {
+		t.Fatalf("moves = %q, want steps of 10 degrees from (100, 80)", got)
+	}
+
+	if err := cam.StopPTZ(); err != nil {
+		t.Fatalf("StopPTZ returned an error: %v", err)
+	}
+	stopped := moves()
+	if last := stopped[len(stopped)-1]; last != "move 100 80 9" {
+		t.Fatalf("last move = %q, want a move to the current MOTORPOS", last)
+	}
+	time.Sleep(2 * continuousStepInterval)
+	if len(moves()) != len(stopped) {
+		t.Fatalf("moves continued after Stop: %q", moves()[len(stopped):])
+	}
+}
+
+func TestContinuousMoveRetargetsRunningMove(t *testing.T) {
+	cam, moves, cleanup := newContinuousTestCamera(t)
+	defer cleanup()
+
+	cam.ContinuousMove(50, 0, 9, 10*time.Second)
+	time.Sleep(continuousStepInterval + continuousStepInterval/2)
+	cam.ContinuousMove(0, -50, 7, 10*time.Second)
+	time.Sleep(continuousStepInterval + continuousStepInterval/2)
+
+	// The running move turns where it is instead of restarting from MOTORPOS (100, 80)
+	got := moves()
+	if len(got) < 2 || got[0] != "move 110 80 9" || got[1] != "move 110 70 7" {
+		t.Fatalf("moves = %q, want the running move to tilt up from (110, 80)", got)
+	}
+}
+
+func TestContinuousMoveEndsAfterTimeout(t *testing.T) {
+	cam, moves, cleanup := newContinuousTestCamera(t)
+	defer cleanup()
+
+	cam.ContinuousMove(50, 0, 9, continuousStepInterval+continuousStepInterval/2)
+	time.Sleep(3 * continuousStepInterval)
+	n := len(moves())
+	if n != 1 {
+		t.Fatalf("moves = %q, want a single step before the timeout", moves())
+	}
+	time.Sleep(2 * continuousStepInterval)
+	if len(moves()) != n {
+		t.Fatalf("moves continued after the timeout: %q", moves())
+	}
+}
+
+func TestContinuousMoveStopsAtLimitsAndNoGoZones(t *testing.T) {
+	cam, moves, cleanup := newContinuousTestCamera(t)
+	defer cleanup()
+	cam.Config.PTZ.Limits = &config.PTZLimits{PanMin: 0, PanMax: 115, TiltMin: 0, TiltMax: 180}
+
+	cam.ContinuousMove(50, 0, 9, 10*time.Second)
+	time.Sleep(4 * continuousStepInterval)
+	if got := moves(); len(got) != 2 || got[1] != "move 115 80 9" {
+		t.Fatalf("moves = %q, want to stop at pan_max 115", got)
+	}
+
+	// Moving down-left slides along the zone below the camera
+	cam.endContinuousMove()
+	cam.Config.PTZ.Limits = nil
+	cam.Config.PTZ.NoGoZones = []config.PTZZone{{Name: "window", Points: []config.PTZPoint{
+		{Pan: 0, Tilt: 95}, {Pan: 90, Tilt: 95}, {Pan: 90, Tilt: 180}, {Pan: 0, Tilt: 180},
+	}}}
+	cam.SetPTZPosition(100, 80)
+	before := len(moves())
+	cam.ContinuousMove(-50, 50, 9, 10*time.Second)
+	time.Sleep(6 * continuousStepInterval)
+	got := moves()[before:]
+	if len(got) < 2 || got[0] != "move 90 90 9" || got[1] != "move 80 90 9" {
+		t.Fatalf("moves = %q, want to slide along the no-go zone at tilt 90", got)
+	}
+}
@@ -0,0 +1,117 @@
+package camera
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Encoder limits accepted by the firmware's "video bitrate" and "video fps" commands
+const (
+	MinBitrate   = 10   // kbps
+	MaxBitrate   = 3000 // kbps
+	MinFrameRate = 1
+	MaxFrameRate = 30
+)
+
+// EncoderSettings holds the current rate control of one encoder channel
+type EncoderSettings struct {
+	Bitrate       int  // Target bitrate in kbps
+	BitrateAuto   bool // Bitrate is chosen by the camera
+	FrameRate     int  // Frame rate shared by all channels
+	FrameRateAuto bool // Frame rate follows the sensor
+}
+
+// GetEncoderSettings returns the bitrate of an encoder channel and the camera frame rate,
+// cached per channel with the imaging cache TTL. The cache is invalidated by the setters.
+func (c *Client) GetEncoderSettings(channel int) (EncoderSettings, error) {
+	c.encoderMu.Lock()
+	defer c.encoderMu.Unlock()
+
+	if settings, ok := c.encoder[channel]; ok && time.Since(settings.fetched) < imagingCacheTTL {
+		return settings.EncoderSettings, nil
+	}
+
+	var settings EncoderSettings
+	var err error
+	if settings.Bitrate, settings.BitrateAuto, err = c.queryAutoValue(fmt.Sprintf("video bitrate %d", channel)); err != nil {
+		return EncoderSettings{}, err
+	}
+	if settings.FrameRate, settings.FrameRateAuto, err = c.queryAutoValue("video fps"); err != nil {
+		return EncoderSettings{}, err
+	}
+
+	if c.encoder == nil {
+		c.encoder = make(map[int]cachedEncoderSettings)
+	}
+	c.encoder[channel] = cachedEncoderSettings{EncoderSettings: settings, fetched: time.Now()}
+	return settings, nil
+}
+
+// SetBitrate sets the target bitrate of an encoder channel in kbps
+func (c *Client) SetBitrate(channel, kbps int) error {
+	if kbps < MinBitrate || kbps > MaxBitrate {
+		return fmt.Errorf("bitrate %d out of range (%d-%d kbps)", kbps, MinBitrate, MaxBitrate)
+	}
+	defer c.invalidateEncoder()
+	return c.setValue(fmt.Sprintf("video bitrate %d %d", channel, kbps))
+}
+
+// SetFrameRate sets the frame rate of all encoder channels.
+// The camera restarts its encoders, so streams pause briefly.
+func (c *Client) SetFrameRate(fps int) error {
+	if fps < MinFrameRate || fps > MaxFrameRate {
+		return fmt.Errorf("frame rate %d out of range (%d-%d fps)", fps, MinFrameRate, MaxFrameRate)
+	}
+	defer c.invalidateEncoder()
+	return c.setValue(fmt.Sprintf("video fps %d", fps))
+}
+
+// cachedEncoderSettings is a cache entry of GetEncoderSettings
+type cachedEncoderSettings struct {
+	EncoderSettings
+	fetched time.Time
+}
+
+// invalidateEncoder drops the cached encoder settings of all channels;
+// the frame rate is shared, so a change affects every channel
+func (c *Client) invalidateEncoder() {
+	c.encoderMu.Lock()
+	defer c.encoderMu.Unlock()
+	c.encoder = nil
+}
+
+// queryAutoValue reads a value printed as "<n> ..." or "auto <n> ..."
+func (c *Client) queryAutoValue(command string) (int, bool, error) {
+	output, err := c.QueryCommand(command)
+	if err != nil {
+		return 0, false, err
+	}
+
+	fields := strings.Fields(output)
+	auto := fields[0] == "auto"
+	if auto {
+		fields = fields[1:]
+	}
+	if len(fields) == 0 {
+		return 0, false, fmt.Errorf("unexpected %s value: %s", command, output)
+	}
+	value, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return 0, false, fmt.Errorf("unexpected %s value: %s", command, output)
+	}
+	return value, auto, nil
+}
+
+// setValue sends a setter command and checks that the camera accepted it
+func (c *Client) setValue(command string) error {
+	output, err := c.QueryCommand(command)
+	if err != nil {
+		return err
+	}
+	if output != "ok" {
+		return fmt.Errorf("command %q failed: %s", command, output)
+	}
+	return nil
+}
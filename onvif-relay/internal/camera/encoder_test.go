@@ -0,0 +1,93 @@
+package camera
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestGetEncoderSettingsCachesUntilSet(t *testing.T) {
+	values := map[string]string{
+		"video bitrate 1": "auto 180",
+		"video fps":       "20 isp:20/1 enc:20/1",
+	}
+	queries := 0
+
+	client, closeClient := newPTZTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var request CommandRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Fatalf("failed to decode request: %v", err)
+		}
+		if value, ok := values[request.Exec]; ok {
+			queries++
+			fmt.Fprintln(w, value)
+			return
+		}
+		switch request.Exec {
+		case "video bitrate 1 300":
+			values["video bitrate 1"] = "300"
+			fmt.Fprintln(w, "ok")
+		case "video fps 15":
+			values["video fps"] = "15 isp:15/1 enc:15/1"
+			fmt.Fprintln(w, "ok")
+		default:
+			t.Fatalf("unexpected command: %q", request.Exec)
+		}
+	})
+	defer closeClient()
+
+	settings, err := client.GetEncoderSettings(1)
+	if err != nil {
+		t.Fatalf("GetEncoderSettings returned an error: %v", err)
+	}
+	want := EncoderSettings{Bitrate: 180, BitrateAuto: true, FrameRate: 20}
+	if settings != want {
+		t.Fatalf("settings = %+v, want %+v", settings, want)
+	}
+	if _, err := client.GetEncoderSettings(1); err != nil {
+		t.Fatalf("GetEncoderSettings returned an error: %v", err)
+	}
+	if queries != 2 {
+		t.Fatalf("queries = %d, want 2 (second call should be cached)", queries)
+	}
+
+	if err := client.SetBitrate(1, 300); err != nil {
+		t.Fatalf("SetBitrate returned an error: %v", err)
+	}
+	if err := client.SetFrameRate(15); err != nil {
+		t.Fatalf("SetFrameRate returned an error: %v", err)
+	}
+	settings, err = client.GetEncoderSettings(1)
+	if err != nil {
+		t.Fatalf("GetEncoderSettings returned an error: %v", err)
+	}
+	if want := (EncoderSettings{Bitrate: 300, FrameRate: 15}); settings != want {
+		t.Fatalf("settings after set = %+v, want %+v", settings, want)
+	}
+}
+
+func TestSetBitrateRejectsOutOfRange(t *testing.T) {
+	client, closeClient := newPTZTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("no command should be sent")
+	})
+	defer closeClient()
+
+	if err := client.SetBitrate(0, 5000); err == nil {
+		t.Fatal("SetBitrate accepted 5000 kbps")
+	}
+	if err := client.SetFrameRate(0); err == nil {
+		t.Fatal("SetFrameRate accepted 0 fps")
+	}
+}
+
+func TestSetBitrateReportsCameraError(t *testing.T) {
+	client, closeClient := newPTZTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "error")
+	})
+	defer closeClient()
+
+	if err := client.SetBitrate(2, 500); err == nil {
+		t.Fatal("SetBitrate ignored the camera's error response")
+	}
+}
@@ -5,11 +5,25 @@ import (
 	"log"
 	"sync"
 	"time"
+
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/eventbus"
 )
 
+// HealthEvent is published on the event bus when a camera's health changes
+type HealthEvent struct {
+	Camera  string
+	Healthy bool
+}
+
+// CameraName returns the name of the camera the event belongs to
+func (e HealthEvent) CameraName() string {
+	return e.Camera
+}
+
 // HealthChecker monitors camera health
 type HealthChecker struct {
 	registry *Registry
+	bus      *eventbus.Bus
 	interval time.Duration
 	failures map[string]int // Track consecutive failures per camera
 	mu       sync.Mutex
@@ -18,10 +32,11 @@ type HealthChecker struct {
 }
 
 // NewHealthChecker creates a new health checker
-func NewHealthChecker(registry *Registry, interval time.Duration) *HealthChecker {
+func NewHealthChecker(registry *Registry, bus *eventbus.Bus, interval time.Duration) *HealthChecker {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &HealthChecker{
 		registry: registry,
+		bus:      bus,
 		interval: interval,
 		failures: make(map[string]int),
 		ctx:      ctx,
@@ -85,6 +100,7 @@ func (h *HealthChecker) checkCamera(cam *Camera) {
 		if consecutiveFailures >= 3 && cam.GetHealth() {
 			cam.SetHealth(false)
 			log.Printf("Camera %s marked as UNHEALTHY", cam.Config.Name)
+			h.bus.Publish(HealthEvent{Camera: cam.Config.Name, Healthy: false})
 		}
 	} else {
 		// Reset failure count on success
@@ -97,6 +113,7 @@ func (h *HealthChecker) checkCamera(cam *Camera) {
 		if !cam.GetHealth() {
 			cam.SetHealth(true)
 			log.Printf("Camera %s marked as HEALTHY", cam.Config.Name)
+			h.bus.Publish(HealthEvent{Camera: cam.Config.Name, Healthy: true})
 		}
 	}
 }
@@ -2,13 +2,129 @@ package camera
 
 import (
 	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// imagingCacheTTL bounds how long cached imaging settings are used.
+// Settings can also be changed from the camera's web UI, so the cache expires.
+const imagingCacheTTL = 60 * time.Second
+
+// ImagingSettings holds the current imaging settings in ONVIF units
+type ImagingSettings struct {
+	Brightness  float64 // 0.0-1.0
+	Contrast    float64 // 0.0-1.0
+	Saturation  float64 // 0.0-1.0
+	Sharpness   float64 // 0.0-1.0
+	IRCutFilter string  // "ON", "OFF" or "AUTO"; empty if not read
+}
+
+// GetImagingSettings returns the current imaging settings, cached per camera.
+// The cache is invalidated by the imaging setters.
+func (c *Client) GetImagingSettings() (ImagingSettings, error) {
+	c.imagingMu.Lock()
+	defer c.imagingMu.Unlock()
+
+	if c.imaging != nil && time.Since(c.imagingFetched) < imagingCacheTTL {
+		return *c.imaging, nil
+	}
+
+	var settings ImagingSettings
+	var err error
+	if settings.Brightness, err = c.GetBrightness(); err != nil {
+		return ImagingSettings{}, err
+	}
+	if settings.Contrast, err = c.GetContrast(); err != nil {
+		return ImagingSettings{}, err
+	}
+	if settings.Saturation, err = c.GetSaturation(); err != nil {
+		return ImagingSettings{}, err
+	}
+	if settings.Sharpness, err = c.GetSharpness(); err != nil {
+		return ImagingSettings{}, err
+	}
+	if c.cfg.Capabilities.IR {
+		if settings.IRCutFilter, err = c.GetIRCutFilter(); err != nil {
+			return ImagingSettings{}, err
+		}
+	}
+
+	c.imaging = &settings
+	c.imagingFetched = time.Now()
+	return settings, nil
+}
+
+// invalidateImaging drops the cached imaging settings
+func (c *Client) invalidateImaging() {
+	c.imagingMu.Lock()
+	defer c.imagingMu.Unlock()
+	c.imaging = nil
+}
+
+// GetBrightness returns the brightness level (0.0-1.0)
+func (c *Client) GetBrightness() (float64, error) {
+	return c.getVideoValue("bri")
+}
+
+// GetContrast returns the contrast level (0.0-1.0)
+func (c *Client) GetContrast() (float64, error) {
+	return c.getVideoValue("cont")
+}
+
+// GetSaturation returns the saturation level (0.0-1.0)
+func (c *Client) GetSaturation() (float64, error) {
+	return c.getVideoValue("sat")
+}
+
+// GetSharpness returns the sharpness level (0.0-1.0)
+func (c *Client) GetSharpness() (float64, error) {
+	return c.getVideoValue("sharp")
+}
+
+// GetIRCutFilter returns the IR cut filter mode derived from the night vision property
+// Night vision "on" → "OFF" (filter removed), "off" → "ON", "auto" → "AUTO"
+func (c *Client) GetIRCutFilter() (string, error) {
+	nightVision, err := c.GetProperty("nightVision")
+	if err != nil {
+		return "", err
+	}
+
+	switch strings.ToLower(nightVision) {
+	case "on":
+		return "OFF", nil
+	case "off":
+		return "ON", nil
+	case "auto":
+		return "AUTO", nil
+	default:
+		return "", fmt.Errorf("unexpected nightVision value: %s", nightVision)
+	}
+}
+
+// getVideoValue reads a "video <name>" value (0-255) and converts it to ONVIF units
+func (c *Client) getVideoValue(name string) (float64, error) {
+	output, err := c.QueryCommand("video " + name)
+	if err != nil {
+		return 0, err
+	}
+
+	fields := strings.Fields(output)
+	value, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return 0, fmt.Errorf("unexpected video %s value: %s", name, output)
+	}
+
+	return atomCamToONVIFValue(value), nil
+}
+
 // SetBrightness sets the brightness level
 // ONVIF value: 0.0-1.0 → AtomCam value: 0-255 (center: 128)
 func (c *Client) SetBrightness(value float64) error {
 	atomcamValue := onvifToAtomCamValue(value)
 	command := fmt.Sprintf("video bri %d", atomcamValue)
+	defer c.invalidateImaging()
 	return c.SendCommand(command)
 }
 
@@ -17,6 +133,7 @@ func (c *Client) SetBrightness(value float64) error {
 func (c *Client) SetContrast(value float64) error {
 	atomcamValue := onvifToAtomCamValue(value)
 	command := fmt.Sprintf("video cont %d", atomcamValue)
+	defer c.invalidateImaging()
 	return c.SendCommand(command)
 }
 
@@ -25,6 +142,7 @@ func (c *Client) SetContrast(value float64) error {
 func (c *Client) SetSaturation(value float64) error {
 	atomcamValue := onvifToAtomCamValue(value)
 	command := fmt.Sprintf("video sat %d", atomcamValue)
+	defer c.invalidateImaging()
 	return c.SendCommand(command)
 }
 
@@ -33,12 +151,15 @@ func (c *Client) SetSaturation(value float64) error {
 func (c *Client) SetSharpness(value float64) error {
 	atomcamValue := onvifToAtomCamValue(value)
 	command := fmt.Sprintf("video sharp %d", atomcamValue)
+	defer c.invalidateImaging()
 	return c.SendCommand(command)
 }
 
 // SetIRCutFilter sets the IR cut filter mode
 // Modes: "ON" (day mode, IR filter enabled), "OFF" (night mode, IR filter disabled), "AUTO"
 func (c *Client) SetIRCutFilter(mode string) error {
+	defer c.invalidateImaging()
+
 	switch mode {
 	case "ON":
 		// Day mode: IR LED off, night vision off
@@ -86,8 +207,9 @@ func onvifToAtomCamValue(value float64) int {
 		value = 1.0
 	}
 
-	// Convert to 0-255 range
-	atomcamValue := int(value * 255.0)
+	// Convert to 0-255 range (rounded so that values read back through
+	// atomCamToONVIFValue are written unchanged)
+	atomcamValue := int(math.Round(value * 255.0))
 	if atomcamValue > 255 {
 		atomcamValue = 255
 	}
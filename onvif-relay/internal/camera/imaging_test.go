@@ -0,0 +1,66 @@
+package camera
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestGetImagingSettingsCachesUntilSet(t *testing.T) {
+	values := map[string]string{
+		"video bri":            "128",
+		"video cont":           "255",
+		"video sat":            "0",
+		"video sharp":          "64",
+		"property nightVision": "on",
+	}
+	queries := 0
+
+	client, closeClient := newPTZTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var request CommandRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Fatalf("failed to decode request: %v", err)
+		}
+		if value, ok := values[request.Exec]; ok {
+			queries++
+			fmt.Fprintln(w, value)
+			return
+		}
+		if request.Exec == "video bri 51" {
+			values["video bri"] = "51"
+			fmt.Fprintln(w, "ok")
+			return
+		}
+		t.Fatalf("unexpected command: %q", request.Exec)
+	})
+	defer closeClient()
+	client.cfg.Capabilities.IR = true
+
+	settings, err := client.GetImagingSettings()
+	if err != nil {
+		t.Fatalf("GetImagingSettings returned an error: %v", err)
+	}
+	want := ImagingSettings{Brightness: 128.0 / 255.0, Contrast: 1, Saturation: 0, Sharpness: 64.0 / 255.0, IRCutFilter: "OFF"}
+	if settings != want {
+		t.Fatalf("settings = %+v, want %+v", settings, want)
+	}
+
+	if _, err := client.GetImagingSettings(); err != nil {
+		t.Fatalf("GetImagingSettings returned an error: %v", err)
+	}
+	if queries != 5 {
+		t.Fatalf("queries = %d, want 5 (second call should be cached)", queries)
+	}
+
+	if err := client.SetBrightness(0.2); err != nil {
+		t.Fatalf("SetBrightness returned an error: %v", err)
+	}
+	settings, err = client.GetImagingSettings()
+	if err != nil {
+		t.Fatalf("GetImagingSettings returned an error: %v", err)
+	}
+	if queries != 10 || settings.Brightness != 0.2 {
+		t.Fatalf("after Set: queries = %d, brightness = %v, want 10 and 0.2", queries, settings.Brightness)
+	}
+}
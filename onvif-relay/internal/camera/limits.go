@@ -0,0 +1,69 @@
+package camera
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/config"
+)
+
+// ErrNoGoZone is returned for a PTZ target inside a no-go zone of the camera, or a move
+// whose path passes through one
+var ErrNoGoZone = errors.New("no-go zone")
+
+// ptzLimits returns the pan/tilt range the camera may move in: ptz.limits or the motor range
+func (c *Camera) ptzLimits() config.PTZLimits {
+	if l := c.Config.PTZ.Limits; l != nil {
+		return *l
+	}
+	return config.PTZLimits{PanMin: 0, PanMax: 355, TiltMin: 0, TiltMax: 180}
+}
+
+// PTZBounds returns the pan/tilt range the camera may move in: ptz.limits or the motor range
+func (c *Camera) PTZBounds() (panMin, panMax, tiltMin, tiltMax int) {
+	l := c.ptzLimits()
+	return l.PanMin, l.PanMax, l.TiltMin, l.TiltMax
+}
+
+// LimitPTZ clamps a PTZ target to the camera's limits and rejects it inside a no-go zone
+func (c *Camera) LimitPTZ(pan, tilt int) (int, int, error) {
+	limits := c.ptzLimits()
+	pan, tilt = limits.Clamp(pan, tilt)
+	if zone, ok := c.Config.PTZ.NoGoZone(float64(pan), float64(tilt)); ok {
+		return pan, tilt, fmt.Errorf("%w: (%d, %d) is in %s", ErrNoGoZone, pan, tilt, zone.Name)
+	}
+	return pan, tilt, nil
+}
+
+// checkPTZPath rejects a move whose straight pan/tilt path from the current position passes
+// through a no-go zone. The position is read from the camera; while a move is in flight the
+// path from its target is checked as well. Without a position the move is refused.
+func (c *Camera) checkPTZPath(pan, tilt int) error {
+	if len(c.Config.PTZ.NoGoZones) == 0 {
+		return nil
+	}
+
+	fromPan, fromTilt, err := c.SyncPTZPosition()
+	if err != nil {
+		return fmt.Errorf("cannot check the path to (%d, %d) against no-go zones: %w", pan, tilt, err)
+	}
+	starts := [][2]int{{fromPan, fromTilt}}
+	c.moveMu.Lock()
+	if c.moving {
+		starts = append(starts, [2]int{c.movePan, c.moveTilt})
+	}
+	c.moveMu.Unlock()
+
+	for _, from := range starts {
+		if zone, ok := c.Config.PTZ.NoGoZoneOnPath(float64(from[0]), float64(from[1]), float64(pan), float64(tilt)); ok {
+			return fmt.Errorf("%w: path from (%d, %d) to (%d, %d) crosses %s", ErrNoGoZone, from[0], from[1], pan, tilt, zone.Name)
+		}
+	}
+	return nil
+}
+
+// inNoGoZone reports whether a pan/tilt position is inside a no-go zone
+func (c *Camera) inNoGoZone(pan, tilt int) bool {
+	_, ok := c.Config.PTZ.NoGoZone(float64(pan), float64(tilt))
+	return ok
+}
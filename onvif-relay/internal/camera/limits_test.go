@@ -0,0 +1,120 @@
+package camera
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/config"
+)
+
+func TestLimitPTZ(t *testing.T) {
+	cam := &Camera{Config: &config.CameraConfig{Name: "swing", PTZ: config.PTZConfig{
+		Limits: &config.PTZLimits{PanMin: 30, PanMax: 300, TiltMin: 10, TiltMax: 150},
+		NoGoZones: []config.PTZZone{{Name: "neighbour", Points: []config.PTZPoint{
+			{Pan: 100, Tilt: 40}, {Pan: 140, Tilt: 40}, {Pan: 120, Tilt: 90},
+		}}},
+	}}}
+
+	if pan, tilt, err := cam.LimitPTZ(0, 180); err != nil || pan != 30 || tilt != 150 {
+		t.Fatalf("LimitPTZ(0, 180) = %d, %d, %v, want 30, 150", pan, tilt, err)
+	}
+	if _, _, err := cam.LimitPTZ(120, 50); !errors.Is(err, ErrNoGoZone) {
+		t.Fatalf("LimitPTZ(120, 50) error = %v, want ErrNoGoZone", err)
+	}
+	// Inside the bounding box of the triangle but outside the triangle
+	if _, _, err := cam.LimitPTZ(102, 85); err != nil {
+		t.Fatalf("LimitPTZ(102, 85) returned an error: %v", err)
+	}
+}
+
+func TestMovePTZRejectsPathThroughNoGoZone(t *testing.T) {
+	var moves atomic.Int32
+	var motorPos atomic.Value
+	motorPos.Store("50.0 90.0")
+	client, closeClient := newPTZTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			moves.Add(1)
+			return
+		}
+		fmt.Fprintf(w, "MOTORPOS=%s\n", motorPos.Load())
+	})
+	defer closeClient()
+
+	// A zone between two reachable positions
+	cam := &Camera{Config: &config.CameraConfig{Name: "swing", PTZ: config.PTZConfig{
+		NoGoZones: []config.PTZZone{{Name: "neighbour", Points: []config.PTZPoint{
+			{Pan: 100, Tilt: 60}, {Pan: 140, Tilt: 60}, {Pan: 140, Tilt: 120}, {Pan: 100, Tilt: 120},
+		}}},
+	}}, Client: client}
+	// A stale cached position must not hide the zone
+	cam.SetPTZPosition(50, 150)
+
+	if err := cam.MovePTZ(200, 90, 5); !errors.Is(err, ErrNoGoZone) {
+		t.Fatalf("MovePTZ across the zone error = %v, want ErrNoGoZone", err)
+	}
+	if n := moves.Load(); n != 0 {
+		t.Fatalf("%d commands sent for a rejected move, want none", n)
+	}
+
+	// Around the zone
+	if err := cam.MovePTZ(50, 150, 5); err != nil {
+		t.Fatalf("MovePTZ away from the zone returned an error: %v", err)
+	}
+	motorPos.Store("50.0 150.0")
+	if err := cam.MovePTZ(200, 150, 5); err != nil {
+		t.Fatalf("MovePTZ above the zone returned an error: %v", err)
+	}
+
+	// While that move is in flight, its target counts as a start of the path too
+	motorPos.Store("60.0 150.0")
+	if err := cam.MovePTZ(60, 90, 5); !errors.Is(err, ErrNoGoZone) {
+		t.Fatalf("MovePTZ from the in-flight target across the zone error = %v, want ErrNoGoZone", err)
+	}
+}
+
+func TestMovePTZRefusesUnknownPositionWithNoGoZones(t *testing.T) {
+	var moves atomic.Int32
+	client, closeClient := newPTZTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			moves.Add(1)
+			return
+		}
+		http.Error(w, "motor not ready", http.StatusServiceUnavailable)
+	})
+	defer closeClient()
+
+	cam := &Camera{Config: &config.CameraConfig{Name: "swing", PTZ: config.PTZConfig{
+		NoGoZones: []config.PTZZone{{Name: "neighbour", Points: []config.PTZPoint{
+			{Pan: 100, Tilt: 60}, {Pan: 140, Tilt: 60}, {Pan: 140, Tilt: 120}, {Pan: 100, Tilt: 120},
+		}}},
+	}}, Client: client}
+
+	if err := cam.MovePTZ(50, 150, 5); err == nil {
+		t.Fatal("MovePTZ without a known position succeeded, want an error")
+	}
+	if n := moves.Load(); n != 0 {
+		t.Fatalf("%d commands sent without a known position, want none", n)
+	}
+}
+
+func TestPTZZoneCrosses(t *testing.T) {
+	zone := config.PTZZone{Points: []config.PTZPoint{{Pan: 100, Tilt: 40}, {Pan: 140, Tilt: 40}, {Pan: 120, Tilt: 90}}}
+	tests := []struct {
+		name                             string
+		fromPan, fromTilt, toPan, toTilt float64
+		want                             bool
+	}{
+		{"through the middle", 80, 60, 160, 60, true},
+		{"below", 80, 30, 160, 30, false},
+		{"through a corner into the inside", 120, 100, 120, 50, true},
+		{"past a corner", 100, 100, 140, 80, false},
+	}
+	for _, tt := range tests {
+		if got := zone.Crosses(tt.fromPan, tt.fromTilt, tt.toPan, tt.toTilt); got != tt.want {
+			t.Errorf("%s: Crosses = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
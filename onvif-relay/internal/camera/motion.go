@@ -0,0 +1,127 @@
+package camera
+
+import (
+	"log"
+	"time"
+)
+
+const (
+	// settlePollInterval is how often MOTORPOS is polled while the motor moves
+	settlePollInterval = 300 * time.Millisecond
+	// settleGrace is how long an unchanged position is not taken as settled, since
+	// the motor may not have started yet
+	settleGrace = time.Second
+	// settleTimeout gives up waiting for the motor after a full sweep at the lowest speed
+	settleTimeout = 30 * time.Second
+)
+
+// MovePTZ moves the camera to pan/tilt and reports it as moving until MOTORPOS settles.
+// The cached position follows MOTORPOS, not the target.
+// The target is clamped to ptz.limits; a target in a no-go zone, or one whose straight
+// path from the current position passes through a no-go zone, fails with ErrNoGoZone.
+// It ends a running continuous move.
+func (c *Camera) MovePTZ(pan, tilt, speed int) error {
+	pan, tilt, err := c.LimitPTZ(pan, tilt)
+	if err != nil {
+		return err
+	}
+	if err := c.checkPTZPath(pan, tilt); err != nil {
+		return err
+	}
+
+	c.endContinuousMove()
+	if err := c.Client.PTZMove(pan, tilt, speed); err != nil {
+		return err
+	}
+
+	go c.watchMove(c.beginMove(pan, tilt), pan, tilt)
+	return nil
+}
+
+// StopPTZ ends a running continuous move and halts the motor where it is
+func (c *Camera) StopPTZ() error {
+	c.endContinuousMove()
+	if err := c.Client.PTZStop(); err != nil {
+		return err
+	}
+
+	pan, tilt := c.GetPTZPosition()
+	if p, t, err := c.SyncPTZPosition(); err == nil {
+		pan, tilt = p, t
+	}
+	go c.watchMove(c.beginMove(pan, tilt), pan, tilt)
+	return nil
+}
+
+// beginMove marks the motor as moving to pan/tilt and returns the sequence number of the new move
+func (c *Camera) beginMove(pan, tilt int) uint64 {
+	c.moveMu.Lock()
+	defer c.moveMu.Unlock()
+	c.moveSeq++
+	c.moving = true
+	c.movePan, c.moveTilt = pan, tilt
+	return c.moveSeq
+}
+
+// retargetMove records the new target of move seq unless a newer move superseded it
+func (c *Camera) retargetMove(seq uint64, pan, tilt int) {
+	c.moveMu.Lock()
+	defer c.moveMu.Unlock()
+	if c.moveSeq == seq {
+		c.movePan, c.moveTilt = pan, tilt
+	}
+}
+
+// PTZMoving reports whether the motor is still moving after the last move command
+func (c *Camera) PTZMoving() bool {
+	c.moveMu.Lock()
+	defer c.moveMu.Unlock()
+	return c.moving
+}
+
+// watchMove polls MOTORPOS until the motor reaches the target or stops moving, and
+// caches the position it settled at. It returns early when a newer move supersedes it.
+func (c *Camera) watchMove(seq uint64, targetPan, targetTilt int) {
+	start := time.Now()
+	ticker := time.NewTicker(settlePollInterval)
+	defer ticker.Stop()
+
+	lastPan, lastTilt, haveLast := 0, 0, false
+	for range ticker.C {
+		if c.superseded(seq) {
+			return
+		}
+		if time.Since(start) > settleTimeout {
+			log.Printf("PTZ %s: motor did not settle within %s", c.Config.Name, settleTimeout)
+			break
+		}
+
+		pan, tilt, err := c.Client.PTZGetPosition()
+		if err != nil {
+			continue
+		}
+		if pan == targetPan && tilt == targetTilt {
+			c.SetPTZPosition(pan, tilt)
+			break
+		}
+		if haveLast && pan == lastPan && tilt == lastTilt && time.Since(start) >= settleGrace {
+			// Stopped short of the target (e.g. at a mechanical limit)
+			c.SetPTZPosition(pan, tilt)
+			break
+		}
+		lastPan, lastTilt, haveLast = pan, tilt, true
+	}
+
+	c.moveMu.Lock()
+	defer c.moveMu.Unlock()
+	if c.moveSeq == seq {
+		c.moving = false
+	}
+}
+
+// superseded reports whether a newer move was started after move seq
+func (c *Camera) superseded(seq uint64) bool {
+	c.moveMu.Lock()
+	defer c.moveMu.Unlock()
+	return c.moveSeq != seq
+}
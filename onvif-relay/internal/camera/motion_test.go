@@ -0,0 +1,107 @@
+package camera
+
+import (
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/config"
+)
+
+func TestMovePTZReportsMovingUntilSettled(t *testing.T) {
+	client, closeClient := newPTZTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("name") == "status" {
+			_, _ = w.Write([]byte("MOTORPOS=100.0 80.0\n"))
+		}
+	})
+	defer closeClient()
+
+	cam := &Camera{Config: &config.CameraConfig{Name: "swing"}, Client: client}
+	if err := cam.MovePTZ(100, 80, 5); err != nil {
+		t.Fatalf("MovePTZ returned an error: %v", err)
+	}
+	if !cam.PTZMoving() {
+		t.Fatal("camera is not moving right after MovePTZ")
+	}
+
+	deadline := time.Now().Add(3 * time.Second)
+	for cam.PTZMoving() {
+		if time.Now().After(deadline) {
+			t.Fatal("camera did not settle at the target position")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if pan, tilt := cam.GetPTZPosition(); pan != 100 || tilt != 80 {
+		t.Fatalf("position = (%d, %d), want (100, 80)", pan, tilt)
+	}
+}
+
+func TestMovePTZCachesThePositionTheMotorStoppedAt(t *testing.T) {
+	// The motor stops short of the target (e.g. blocked)
+	client, closeClient := newPTZTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("name") == "status" {
+			_, _ = w.Write([]byte("MOTORPOS=150.0 60.0\n"))
+		}
+	})
+	defer closeClient()
+
+	cam := &Camera{Config: &config.CameraConfig{Name: "swing"}, Client: client}
+	cam.SetPTZPosition(120, 90)
+	if err := cam.MovePTZ(200, 40, 5); err != nil {
+		t.Fatalf("MovePTZ returned an error: %v", err)
+	}
+	if pan, tilt := cam.GetPTZPosition(); pan != 120 || tilt != 90 {
+		t.Fatalf("position right after MovePTZ = (%d, %d), want the last known (120, 90)", pan, tilt)
+	}
+
+	deadline := time.Now().Add(3 * time.Second)
+	for cam.PTZMoving() {
+		if time.Now().After(deadline) {
+			t.Fatal("camera did not settle")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if pan, tilt := cam.GetPTZPosition(); pan != 150 || tilt != 60 {
+		t.Fatalf("position = (%d, %d), want MOTORPOS (150, 60)", pan, tilt)
+	}
+}
+
+func TestViewMirror(t *testing.T) {
+	tests := []struct {
+		name      string
+		mount     string
+		motorPos  string
+		wantPan   bool
+		wantTilt  bool
+		wantReads bool
+	}{
+		{"auto ignores flip", "", "MOTORPOS=100.0 80.0 1 1 1", false, false, false},
+		{"desk", config.MountDesk, "MOTORPOS=100.0 80.0 2 2 1", false, false, false},
+		{"ceiling without flip", config.MountCeiling, "MOTORPOS=100.0 80.0 2 2 1", true, true, true},
+		{"ceiling with flip_mirror", config.MountCeiling, "MOTORPOS=100.0 80.0 1 1 1", false, false, true},
+		{"ceiling with mirror only", config.MountCeiling, "MOTORPOS=100.0 80.0 2 1 1", true, false, true},
+		{"ceiling with FLIP after motor init", config.MountCeiling, "FLIP=flip\nMOTORPOS=100.0 80.0", false, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var reads atomic.Int32
+			client, closeClient := newPTZTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				reads.Add(1)
+				_, _ = w.Write([]byte(tt.motorPos + "\n"))
+			})
+			defer closeClient()
+
+			cfg := &config.CameraConfig{Name: "swing", PTZ: config.PTZConfig{Mount: tt.mount}}
+			cam := &Camera{Config: cfg, Client: client}
+			pan, tilt := cam.ViewMirror()
+			if pan != tt.wantPan || tilt != tt.wantTilt {
+				t.Fatalf("ViewMirror = (%t, %t), want (%t, %t)", pan, tilt, tt.wantPan, tt.wantTilt)
+			}
+			cam.ViewMirror()
+			if n := reads.Load(); (n == 1) != tt.wantReads || n > 1 {
+				t.Fatalf("status read %d times", n)
+			}
+		})
+	}
+}
@@ -31,58 +31,110 @@ func (c *Client) PTZMove(pan, tilt, speed int) error {
 	return c.SendCommand(command)
 }
 
-// PTZStop stops PTZ movement
+// PTZStop halts PTZ movement.
+// The firmware has no stop command ("move 0 0 0" would move to (0, 0)), so the motor is
+// sent to the position it reports right now.
 func (c *Client) PTZStop() error {
-	// AtomCam doesn't need explicit stop command
-	// ContinuousMove sends absolute positions, camera stops automatically when it reaches the target
-	// Sending "move 0 0 0" would cause the camera to move to position (0,0) which is wrong
-	return nil
+	pan, tilt, err := c.PTZGetPosition()
+	if err != nil {
+		return fmt.Errorf("failed to stop PTZ: %w", err)
+	}
+	return c.PTZMove(pan, tilt, 9)
+}
+
+// MotorStatus is the MOTORPOS line of AtomCam's status endpoint
+type MotorStatus struct {
+	Pan       int  // 0-355, mirrored by the firmware when HFlip is set
+	Tilt      int  // 0-180, mirrored by the firmware when VFlip is set
+	HFlip     bool // Picture is mirrored horizontally (video flip "flip" or "flip_mirror")
+	VFlip     bool // Picture is flipped vertically (video flip "mirror" or "flip_mirror")
+	FlipKnown bool // The firmware reported the flip state
 }
 
 // PTZGetPosition gets the current PTZ position from AtomCam's status endpoint.
 func (c *Client) PTZGetPosition() (pan, tilt int, err error) {
+	status, err := c.PTZGetStatus()
+	if err != nil {
+		return 0, 0, err
+	}
+	return status.Pan, status.Tilt, nil
+}
+
+// PTZGetStatus gets the motor position and the picture flip state from AtomCam's status
+// endpoint. The flip state is the "FLIP=<normal|flip|mirror|flip_mirror>" line. Before the
+// motor is initialized MOTORPOS also carries it ("MOTORPOS=<pan> <tilt> <hflip> <vflip> <idle>",
+// the raw horSwitch/verSwitch settings where 1 is on and 2 is off), which is only used
+// without FLIP; afterwards MOTORPOS may be just "<pan> <tilt>".
+func (c *Client) PTZGetStatus() (MotorStatus, error) {
 	url := fmt.Sprintf("http://%s:%d/cgi-bin/cmd.cgi?name=status", c.cfg.Host, c.cfg.HTTPPort)
 	resp, err := c.httpClient.Get(url)
 	if err != nil {
-		return 0, 0, fmt.Errorf("failed to get PTZ position: %w", err)
+		return MotorStatus{}, fmt.Errorf("failed to get PTZ position: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return 0, 0, fmt.Errorf("unexpected status code while getting PTZ position: %d", resp.StatusCode)
+		return MotorStatus{}, fmt.Errorf("unexpected status code while getting PTZ position: %d", resp.StatusCode)
 	}
 
 	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
 	if err != nil {
-		return 0, 0, fmt.Errorf("failed to read PTZ position: %w", err)
+		return MotorStatus{}, fmt.Errorf("failed to read PTZ position: %w", err)
 	}
 
+	var status *MotorStatus
+	var flip string
 	for _, line := range strings.Split(string(body), "\n") {
-		if !strings.HasPrefix(line, "MOTORPOS=") {
+		if value, ok := strings.CutPrefix(line, "FLIP="); ok {
+			flip = strings.TrimSpace(value)
+			continue
+		}
+		if !strings.HasPrefix(line, "MOTORPOS=") || status != nil {
 			continue
 		}
 
 		fields := strings.Fields(strings.TrimPrefix(line, "MOTORPOS="))
 		if len(fields) < 2 {
-			return 0, 0, fmt.Errorf("invalid MOTORPOS response: %q", line)
+			return MotorStatus{}, fmt.Errorf("invalid MOTORPOS response: %q", line)
 		}
 
 		panValue, err := strconv.ParseFloat(fields[0], 64)
 		if err != nil {
-			return 0, 0, fmt.Errorf("invalid MOTORPOS pan value %q: %w", fields[0], err)
+			return MotorStatus{}, fmt.Errorf("invalid MOTORPOS pan value %q: %w", fields[0], err)
 		}
 		tiltValue, err := strconv.ParseFloat(fields[1], 64)
 		if err != nil {
-			return 0, 0, fmt.Errorf("invalid MOTORPOS tilt value %q: %w", fields[1], err)
+			return MotorStatus{}, fmt.Errorf("invalid MOTORPOS tilt value %q: %w", fields[1], err)
 		}
 
-		pan = int(math.Round(panValue))
-		tilt = int(math.Round(tiltValue))
-		if pan < 0 || pan > 355 || tilt < 0 || tilt > 180 {
-			return 0, 0, fmt.Errorf("MOTORPOS out of range: pan=%d tilt=%d", pan, tilt)
+		status = &MotorStatus{
+			Pan:  int(math.Round(panValue)),
+			Tilt: int(math.Round(tiltValue)),
+		}
+		if status.Pan < 0 || status.Pan > 355 || status.Tilt < 0 || status.Tilt > 180 {
+			return MotorStatus{}, fmt.Errorf("MOTORPOS out of range: pan=%d tilt=%d", status.Pan, status.Tilt)
 		}
-		return pan, tilt, nil
+		if len(fields) >= 4 {
+			hflip, herr := strconv.Atoi(fields[2])
+			vflip, verr := strconv.Atoi(fields[3])
+			if herr == nil && verr == nil {
+				status.HFlip, status.VFlip, status.FlipKnown = hflip == 1, vflip == 1, true
+			}
+		}
+	}
+	if status == nil {
+		return MotorStatus{}, fmt.Errorf("MOTORPOS not found in status response")
 	}
 
-	return 0, 0, fmt.Errorf("MOTORPOS not found in status response")
+	switch flip {
+	case "normal":
+		status.HFlip, status.VFlip, status.FlipKnown = false, false, true
+	case "flip":
+		status.HFlip, status.VFlip, status.FlipKnown = true, false, true
+	case "mirror":
+		status.HFlip, status.VFlip, status.FlipKnown = false, true, true
+	case "flip_mirror":
+		status.HFlip, status.VFlip, status.FlipKnown = true, true, true
+	}
+	return *status, nil
 }
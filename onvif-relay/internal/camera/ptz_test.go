@@ -52,6 +52,75 @@ func TestPTZGetPosition(t *testing.T) {
 	}
 }
 
+func TestPTZGetStatusReadsFlipState(t *testing.T) {
+	client, closeClient := newPTZTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "MOTORPOS=231.4 92.6 1 2 1")
+	})
+	defer closeClient()
+
+	status, err := client.PTZGetStatus()
+	if err != nil {
+		t.Fatalf("PTZGetStatus returned an error: %v", err)
+	}
+	want := MotorStatus{Pan: 231, Tilt: 93, HFlip: true, FlipKnown: true}
+	if status != want {
+		t.Fatalf("PTZGetStatus = %+v, want %+v", status, want)
+	}
+}
+
+func TestPTZGetStatusReadsFlipOff(t *testing.T) {
+	// horSwitch/verSwitch are 2 when off
+	client, closeClient := newPTZTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "MOTORPOS=180.0 90.0 2 2 0")
+	})
+	defer closeClient()
+
+	status, err := client.PTZGetStatus()
+	if err != nil {
+		t.Fatalf("PTZGetStatus returned an error: %v", err)
+	}
+	want := MotorStatus{Pan: 180, Tilt: 90, FlipKnown: true}
+	if status != want {
+		t.Fatalf("PTZGetStatus = %+v, want %+v", status, want)
+	}
+}
+
+func TestPTZGetStatusReadsFlipLine(t *testing.T) {
+	// After motor initialization MOTORPOS has no flip fields; FLIP= comes first
+	client, closeClient := newPTZTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "CENTER=177.5 90.0")
+		fmt.Fprintln(w, "FLIP=mirror")
+		fmt.Fprintln(w, "MOTORPOS=120.0 45.0")
+	})
+	defer closeClient()
+
+	status, err := client.PTZGetStatus()
+	if err != nil {
+		t.Fatalf("PTZGetStatus returned an error: %v", err)
+	}
+	want := MotorStatus{Pan: 120, Tilt: 45, VFlip: true, FlipKnown: true}
+	if status != want {
+		t.Fatalf("PTZGetStatus = %+v, want %+v", status, want)
+	}
+}
+
+func TestPTZGetStatusPrefersFlipLine(t *testing.T) {
+	client, closeClient := newPTZTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "FLIP=flip_mirror")
+		fmt.Fprintln(w, "MOTORPOS=180.0 90.0 2 2 0")
+	})
+	defer closeClient()
+
+	status, err := client.PTZGetStatus()
+	if err != nil {
+		t.Fatalf("PTZGetStatus returned an error: %v", err)
+	}
+	want := MotorStatus{Pan: 180, Tilt: 90, HFlip: true, VFlip: true, FlipKnown: true}
+	if status != want {
+		t.Fatalf("PTZGetStatus = %+v, want %+v", status, want)
+	}
+}
+
 func TestPTZGetPositionMissingMotorPosition(t *testing.T) {
 	client, closeClient := newPTZTestClient(t, func(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprintln(w, "TIMESTAMP=2026/07/15 12:00:00")
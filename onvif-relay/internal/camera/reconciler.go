@@ -0,0 +1,139 @@
+package camera
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/eventbus"
+)
+
+// PositionEvent is published on the event bus when the PTZ position read from a camera
+// (MOTORPOS) changes, and once more when the motor has settled
+type PositionEvent struct {
+	Camera string
+	Pan    int
+	Tilt   int
+	Moving bool // The position changed since the previous reading
+}
+
+// CameraName returns the name of the camera the event belongs to
+func (e PositionEvent) CameraName() string {
+	return e.Camera
+}
+
+// positionState is what the reconciler last read from a camera
+type positionState struct {
+	pan, tilt int
+	moving    bool
+	polled    time.Time
+}
+
+// PositionReconciler keeps the cached PTZ positions in line with the cameras. It reads
+// MOTORPOS every fastInterval while a camera moves (after a relay move, or when the
+// camera's own motion tracking turns it) and every slowInterval while it is idle.
+type PositionReconciler struct {
+	registry     *Registry
+	bus          *eventbus.Bus
+	fastInterval time.Duration
+	slowInterval time.Duration
+	states       map[string]*positionState // By camera name; only used by run
+	ctx          context.Context
+	cancel       context.CancelFunc
+}
+
+// NewPositionReconciler creates a new position reconciler
+func NewPositionReconciler(registry *Registry, bus *eventbus.Bus, fastInterval, slowInterval time.Duration) *PositionReconciler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &PositionReconciler{
+		registry:     registry,
+		bus:          bus,
+		fastInterval: fastInterval,
+		slowInterval: slowInterval,
+		states:       make(map[string]*positionState),
+		ctx:          ctx,
+		cancel:       cancel,
+	}
+}
+
+// Start starts the position reconciler
+func (p *PositionReconciler) Start() {
+	go p.run()
+}
+
+// Stop stops the position reconciler
+func (p *PositionReconciler) Stop() {
+	p.cancel()
+}
+
+// run reconciles the PTZ cameras that are due on every fast tick
+func (p *PositionReconciler) run() {
+	ticker := time.NewTicker(p.fastInterval)
+	defer ticker.Stop()
+
+	log.Printf("Position reconciler started (interval: %v moving, %v idle)", p.fastInterval, p.slowInterval)
+
+	for {
+		p.reconcileAll(time.Now())
+
+		select {
+		case <-p.ctx.Done():
+			log.Printf("Position reconciler stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reconcileAll reads the position of each healthy PTZ camera whose poll is due
+func (p *PositionReconciler) reconcileAll(now time.Time) {
+	seen := make(map[string]bool)
+	for _, cam := range p.registry.List() {
+		if !cam.Config.Capabilities.PTZ {
+			continue
+		}
+		seen[cam.Config.Name] = true
+		if cam.GetHealth() && p.due(cam, now) {
+			p.reconcile(cam, now)
+		}
+	}
+
+	// Forget cameras removed by a configuration reload
+	for name := range p.states {
+		if !seen[name] {
+			delete(p.states, name)
+		}
+	}
+}
+
+// due reports whether the position of a camera should be read now
+func (p *PositionReconciler) due(cam *Camera, now time.Time) bool {
+	state, ok := p.states[cam.Config.Name]
+	if !ok || state.moving || cam.PTZMoving() {
+		return true
+	}
+	return now.Sub(state.polled) >= p.slowInterval
+}
+
+// reconcile reads the position of a camera into the cache and publishes a PositionEvent
+// when it changed or the camera stopped moving
+func (p *PositionReconciler) reconcile(cam *Camera, now time.Time) {
+	pan, tilt, err := cam.SyncPTZPosition()
+	if err != nil {
+		// Health checks report unreachable cameras; try again on the next tick
+		return
+	}
+
+	state, known := p.states[cam.Config.Name]
+	if !known {
+		state = &positionState{}
+		p.states[cam.Config.Name] = state
+	}
+	moving := known && (pan != state.pan || tilt != state.tilt)
+	changed := !known || moving || state.moving
+	state.pan, state.tilt, state.moving, state.polled = pan, tilt, moving, now
+
+	if changed {
+		p.bus.Publish(PositionEvent{Camera: cam.Config.Name, Pan: pan, Tilt: tilt, Moving: moving})
+	}
+}
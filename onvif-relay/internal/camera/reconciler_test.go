@@ -0,0 +1,72 @@
+package camera
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/config"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/eventbus"
+)
+
+func TestPositionReconcilerPublishesChanges(t *testing.T) {
+	var mu sync.Mutex
+	pan, tilt := 100, 80
+	client, closeClient := newPTZTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Fprintf(w, "MOTORPOS=%d.0 %d.0\n", pan, tilt)
+	})
+	defer closeClient()
+
+	cam := &Camera{Config: &config.CameraConfig{Name: "swing"}, Client: client}
+	bus := eventbus.New()
+	events := bus.Subscribe(8)
+	defer events.Close()
+	p := NewPositionReconciler(nil, bus, time.Second, time.Minute)
+
+	expect := func(want *PositionEvent) {
+		t.Helper()
+		select {
+		case e := <-events.Events():
+			if want == nil || e != *want {
+				t.Fatalf("event = %+v, want %+v", e, want)
+			}
+		default:
+			if want != nil {
+				t.Fatalf("no event, want %+v", *want)
+			}
+		}
+	}
+
+	now := time.Now()
+	p.reconcile(cam, now)
+	expect(&PositionEvent{Camera: "swing", Pan: 100, Tilt: 80})
+	if got, gotTilt := cam.GetPTZPosition(); got != 100 || gotTilt != 80 {
+		t.Fatalf("cached position = (%d, %d), want (100, 80)", got, gotTilt)
+	}
+	if p.due(cam, now.Add(time.Second)) {
+		t.Fatal("idle camera is due before the slow interval")
+	}
+	if !p.due(cam, now.Add(time.Minute)) {
+		t.Fatal("idle camera is not due after the slow interval")
+	}
+
+	// The camera turns on its own (e.g. motion tracking)
+	mu.Lock()
+	pan = 140
+	mu.Unlock()
+	p.reconcile(cam, now.Add(time.Minute))
+	expect(&PositionEvent{Camera: "swing", Pan: 140, Tilt: 80, Moving: true})
+	if !p.due(cam, now.Add(time.Minute+time.Second)) {
+		t.Fatal("moving camera is not due on the next fast tick")
+	}
+
+	// Settled: one more event, then nothing until it moves again
+	p.reconcile(cam, now.Add(time.Minute+time.Second))
+	expect(&PositionEvent{Camera: "swing", Pan: 140, Tilt: 80})
+	p.reconcile(cam, now.Add(time.Minute+2*time.Second))
+	expect(nil)
+}
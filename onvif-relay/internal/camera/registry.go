@@ -2,7 +2,10 @@ package camera
 
 import (
 	"fmt"
+	"log"
+	"reflect"
 	"sort"
+	"strings"
 	"sync"
 
 	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/config"
@@ -28,6 +31,76 @@ func NewRegistry(cfg *config.Config) (*Registry, error) {
 	return r, nil
 }
 
+// ConfigChangedEvent is published on the event bus when a camera is added,
+// updated or removed by a configuration reload
+type ConfigChangedEvent struct {
+	Camera  string
+	Removed bool
+}
+
+// CameraName returns the name of the camera the event belongs to
+func (e ConfigChangedEvent) CameraName() string {
+	return e.Camera
+}
+
+// Update applies a reloaded configuration: new cameras are added, removed cameras are
+// closed, and cameras whose configuration changed are replaced by a new instance that
+// takes over the state of the old one (see takeOver). Unchanged cameras are kept as they are.
+func (r *Registry) Update(cfg *config.Config) (added, updated, removed []string) {
+	var replaced, closed []*Camera
+	defer func() {
+		// Handing over and closing wait for running continuous moves, so they are done after unlocking
+		for _, old := range replaced {
+			if cam, err := r.Get(old.Config.Name); err == nil {
+				cam.resumeMove(old)
+			}
+		}
+		for _, cam := range closed {
+			cam.Close()
+		}
+	}()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := make(map[string]bool)
+	for i := range cfg.Cameras {
+		camCfg := &cfg.Cameras[i]
+		names[camCfg.Name] = true
+
+		old, ok := r.cameras[camCfg.Name]
+		if !ok {
+			r.cameras[camCfg.Name] = NewCamera(camCfg)
+			added = append(added, camCfg.Name)
+			continue
+		}
+		if reflect.DeepEqual(old.Config, camCfg) {
+			continue
+		}
+
+		cam := NewCamera(camCfg)
+		cam.takeOver(old)
+		r.cameras[camCfg.Name] = cam
+		replaced = append(replaced, old)
+		closed = append(closed, old)
+		updated = append(updated, camCfg.Name)
+	}
+
+	for name, cam := range r.cameras {
+		if !names[name] {
+			delete(r.cameras, name)
+			closed = append(closed, cam)
+			removed = append(removed, name)
+		}
+	}
+
+	sort.Strings(added)
+	sort.Strings(updated)
+	sort.Strings(removed)
+	log.Printf("Camera registry updated: added=%v updated=%v removed=%v", added, updated, removed)
+	return added, updated, removed
+}
+
 // Get retrieves a camera by name
 func (r *Registry) Get(name string) (*Camera, error) {
 	r.mu.RLock()
@@ -41,6 +114,47 @@ func (r *Registry) Get(name string) (*Camera, error) {
 	return cam, nil
 }
 
+// FindByHostname retrieves a camera by the hostname it reports (e.g. in webhooks).
+// The hostname is matched case-insensitively against the camera name, the configured
+// host and the first label of the configured host.
+func (r *Registry) FindByHostname(hostname string) (*Camera, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, cam := range r.cameras {
+		host := cam.Config.Host
+		shortHost, _, _ := strings.Cut(host, ".")
+		if strings.EqualFold(hostname, cam.Config.Name) ||
+			strings.EqualFold(hostname, host) ||
+			strings.EqualFold(hostname, shortHost) {
+			return cam, nil
+		}
+	}
+
+	return nil, fmt.Errorf("camera not found for hostname: %s", hostname)
+}
+
+// GetByVideoSourceToken retrieves a camera by its ONVIF video source token.
+// An empty or legacy token ("VideoSource_1") is accepted when only one camera is configured.
+func (r *Registry) GetByVideoSourceToken(token string) (*Camera, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, cam := range r.cameras {
+		if cam.VideoSourceToken() == token {
+			return cam, nil
+		}
+	}
+
+	if (token == "" || token == "VideoSource_1") && len(r.cameras) == 1 {
+		for _, cam := range r.cameras {
+			return cam, nil
+		}
+	}
+
+	return nil, fmt.Errorf("video source not found: %s", token)
+}
+
 // List returns all cameras
 func (r *Registry) List() []*Camera {
 	r.mu.RLock()
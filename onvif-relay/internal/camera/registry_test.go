@@ -0,0 +1,80 @@
+package camera
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/config"
+)
+
+func TestRegistryUpdate(t *testing.T) {
+	registry, err := NewRegistry(&config.Config{Cameras: []config.CameraConfig{
+		{Name: "front", Host: "192.168.1.10", HTTPPort: 80},
+		{Name: "garage", Host: "192.168.1.20", HTTPPort: 80},
+		{Name: "porch", Host: "192.168.1.30", HTTPPort: 80},
+	}})
+	if err != nil {
+		t.Fatalf("failed to create registry: %v", err)
+	}
+	defer registry.Close()
+
+	front, _ := registry.Get("front")
+	garage, _ := registry.Get("garage")
+	garage.SetPTZPosition(42, 24)
+	garage.SetHealth(false)
+
+	added, updated, removed := registry.Update(&config.Config{Cameras: []config.CameraConfig{
+		{Name: "front", Host: "192.168.1.10", HTTPPort: 80},
+		{Name: "garage", Host: "192.168.1.21", HTTPPort: 80},
+		{Name: "yard", Host: "192.168.1.40", HTTPPort: 80},
+	}})
+	if fmt.Sprint(added, updated, removed) != "[yard] [garage] [porch]" {
+		t.Fatalf("Update = %v %v %v, want [yard] [garage] [porch]", added, updated, removed)
+	}
+
+	if cam, _ := registry.Get("front"); cam != front {
+		t.Fatal("unchanged camera was replaced")
+	}
+	cam, err := registry.Get("garage")
+	if err != nil || cam == garage || cam.Config.Host != "192.168.1.21" {
+		t.Fatalf("garage = %+v (err %v), want new instance with updated host", cam, err)
+	}
+	if pan, tilt := cam.GetPTZPosition(); pan != 42 || tilt != 24 || cam.GetHealth() {
+		t.Fatalf("updated camera state = (%d, %d, healthy=%v), want (42, 24, false)", pan, tilt, cam.GetHealth())
+	}
+	if _, err := registry.Get("porch"); err == nil {
+		t.Fatal("removed camera is still registered")
+	}
+}
+
+func TestRegistryUpdateHandsOverPTZState(t *testing.T) {
+	cam, moves, cleanup := newContinuousTestCamera(t)
+	defer cleanup()
+	cam.Config.Host, cam.Config.HTTPPort = cam.Client.cfg.Host, cam.Client.cfg.HTTPPort
+	registry := &Registry{cameras: map[string]*Camera{"swing": cam}}
+
+	joystick := PTZController{Name: "joystick", Priority: 30}
+	if err := cam.ArbitrateContinuousMove(joystick, 50, 0, 9, 10*time.Second); err != nil {
+		t.Fatalf("ArbitrateContinuousMove returned an error: %v", err)
+	}
+	time.Sleep(continuousStepInterval + continuousStepInterval/2)
+
+	cfg := *cam.Config
+	cfg.PTZ.LeaseTime = 30
+	registry.Update(&config.Config{Cameras: []config.CameraConfig{cfg}})
+	updated, err := registry.Get("swing")
+	if err != nil || updated == cam {
+		t.Fatalf("swing = %p (err %v), want a new instance", updated, err)
+	}
+	defer updated.endContinuousMove()
+
+	if lease, ok := updated.PTZLease(); !ok || lease.Holder != joystick {
+		t.Fatalf("lease = %+v (held %t), want held by joystick", lease, ok)
+	}
+	before := len(moves())
+	time.Sleep(2 * continuousStepInterval)
+	if !updated.PTZMoving() || len(moves()) <= before {
+		t.Fatalf("moves = %q (moving %t), want the continuous move to go on after the update", moves(), updated.PTZMoving())
+	}
+}
@@ -1,9 +1,13 @@
 package camera
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"net/http"
+	"sync"
+	"time"
 )
 
 const (
@@ -33,3 +37,87 @@ func (c *Client) GetSnapshot() ([]byte, error) {
 
 	return data, nil
 }
+
+// defaultSnapshotMaxAge is how long a snapshot is reused without snapshot.max_age
+const defaultSnapshotMaxAge = time.Second
+
+// Snapshot is a JPEG image fetched from a camera
+type Snapshot struct {
+	Data    []byte
+	Fetched time.Time
+	ETag    string // Strong entity tag of Data, quoted
+}
+
+// Age returns how long ago the snapshot was fetched
+func (s Snapshot) Age() time.Duration {
+	return time.Since(s.Fetched)
+}
+
+// snapshotFetch is a snapshot request to the camera that concurrent callers wait for
+type snapshotFetch struct {
+	snap Snapshot
+	err  error
+	done chan struct{}
+}
+
+// snapshotCache holds the last good snapshot of a camera and the fetch in progress
+type snapshotCache struct {
+	mu       sync.Mutex
+	last     *Snapshot
+	inflight *snapshotFetch
+}
+
+// Snapshot returns a snapshot of the camera no older than snapshot.max_age. Callers
+// arriving while a fetch is in progress wait for it instead of sending their own request,
+// so polling clients do not overload the camera's HTTP server.
+func (c *Camera) Snapshot() (Snapshot, error) {
+	s := &c.snapshot
+	s.mu.Lock()
+	if s.last != nil && s.last.Age() < c.snapshotMaxAge() {
+		snap := *s.last
+		s.mu.Unlock()
+		return snap, nil
+	}
+	if f := s.inflight; f != nil {
+		s.mu.Unlock()
+		<-f.done
+		return f.snap, f.err
+	}
+	f := &snapshotFetch{done: make(chan struct{})}
+	s.inflight = f
+	s.mu.Unlock()
+
+	data, err := c.Client.GetSnapshot()
+	if err == nil {
+		sum := sha256.Sum256(data)
+		f.snap = Snapshot{Data: data, Fetched: time.Now(), ETag: `"` + hex.EncodeToString(sum[:16]) + `"`}
+	}
+	f.err = err
+
+	s.mu.Lock()
+	if err == nil {
+		s.last = &f.snap
+	}
+	s.inflight = nil
+	s.mu.Unlock()
+	close(f.done)
+	return f.snap, f.err
+}
+
+// LastSnapshot returns the last snapshot fetched successfully, however old it is
+func (c *Camera) LastSnapshot() (Snapshot, bool) {
+	c.snapshot.mu.Lock()
+	defer c.snapshot.mu.Unlock()
+	if c.snapshot.last == nil {
+		return Snapshot{}, false
+	}
+	return *c.snapshot.last, true
+}
+
+// snapshotMaxAge returns how long a snapshot is reused
+func (c *Camera) snapshotMaxAge() time.Duration {
+	if c.Config.Snapshot.MaxAge > 0 {
+		return time.Duration(c.Config.Snapshot.MaxAge * float64(time.Second))
+	}
+	return defaultSnapshotMaxAge
+}
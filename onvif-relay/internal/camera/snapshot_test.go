@@ -0,0 +1,69 @@
+package camera
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/config"
+)
+
+func TestSnapshotCoalescesConcurrentFetches(t *testing.T) {
+	var requests atomic.Int32
+	var failing atomic.Bool
+	client, closeClient := newPTZTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/cgi-bin/get_jpeg.cgi" {
+			t.Errorf("unexpected request URL: %s", r.URL.String())
+		}
+		requests.Add(1)
+		if failing.Load() {
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+		_, _ = w.Write([]byte("jpeg"))
+	})
+	defer closeClient()
+
+	cam := &Camera{Config: &config.CameraConfig{Name: "swing", Snapshot: config.SnapshotConfig{MaxAge: 0.2}}, Client: client}
+
+	var wg sync.WaitGroup
+	snaps := make([]Snapshot, 5)
+	for i := range snaps {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			snap, err := cam.Snapshot()
+			if err != nil {
+				t.Errorf("Snapshot returned an error: %v", err)
+			}
+			snaps[i] = snap
+		}()
+	}
+	wg.Wait()
+	if n := requests.Load(); n != 1 {
+		t.Fatalf("%d requests to the camera, want 1", n)
+	}
+	for _, snap := range snaps {
+		if string(snap.Data) != "jpeg" || snap.ETag != snaps[0].ETag || snap.ETag == "" {
+			t.Fatalf("snapshot = %q (ETag %s), want %q (ETag %s)", snap.Data, snap.ETag, "jpeg", snaps[0].ETag)
+		}
+	}
+
+	// Reused within max-age
+	if _, err := cam.Snapshot(); err != nil || requests.Load() != 1 {
+		t.Fatalf("Snapshot within max-age: err = %v, %d requests, want cached", err, requests.Load())
+	}
+
+	// Expired and failing: an error, but the last good snapshot is kept
+	time.Sleep(250 * time.Millisecond)
+	failing.Store(true)
+	if _, err := cam.Snapshot(); err == nil {
+		t.Fatal("Snapshot returned nil error for a failing camera")
+	}
+	if last, ok := cam.LastSnapshot(); !ok || string(last.Data) != "jpeg" {
+		t.Fatalf("LastSnapshot = %q (%t), want %q", last.Data, ok, "jpeg")
+	}
+}
@@ -2,7 +2,11 @@ package config
 
 import (
 	"fmt"
+	"net"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
 
 	"gopkg.in/yaml.v3"
 )
@@ -15,12 +19,46 @@ type Config struct {
 
 // ServerConfig represents ONVIF relay server configuration
 type ServerConfig struct {
-	OnvifPort  int            `yaml:"onvif_port"`
-	DeviceName string         `yaml:"device_name"`
-	Discovery  bool           `yaml:"discovery"`
-	Auth       AuthConfig     `yaml:"auth"`
-	Mediamtx   MediamtxConfig `yaml:"mediamtx"`
-	Proxies    []ProxyConfig  `yaml:"proxies,omitempty"`
+	OnvifPort     int            `yaml:"onvif_port"`
+	DeviceName    string         `yaml:"device_name"`
+	Discovery     bool           `yaml:"discovery"`
+	AdvertiseHost string         `yaml:"advertise_host,omitempty"` // Host advertised in XAddrs and URIs (default: per request)
+	Auth          AuthConfig     `yaml:"auth"`
+	Users         []UserConfig   `yaml:"users,omitempty"`
+	StateDir      string         `yaml:"state_dir,omitempty"`
+	Mediamtx      MediamtxConfig `yaml:"mediamtx"`
+	Proxies       []ProxyConfig  `yaml:"proxies,omitempty"`
+	MQTT          MQTTConfig     `yaml:"mqtt,omitempty"`
+	TLS           TLSConfig      `yaml:"tls,omitempty"`
+	// RTSP port of the ONVIF audio backchannel bridged to atomtalkd (0 disables it)
+	BackchannelPort int `yaml:"backchannel_port,omitempty"`
+	// Target of the ONVIF SystemReboot operation: "relay" (default) restarts the relay,
+	// a camera name reboots that camera
+	SystemReboot string `yaml:"system_reboot,omitempty"`
+	// Cameras users created via ONVIF CreateUsers may access (default: all)
+	OnvifUserCameras []string `yaml:"onvif_user_cameras,omitempty"`
+}
+
+// SystemRebootRelay is the system_reboot value that restarts the relay itself
+const SystemRebootRelay = "relay"
+
+// TLSConfig represents HTTPS listener settings
+type TLSConfig struct {
+	Enabled  bool     `yaml:"enabled"`             // Serve HTTPS on onvif_port
+	HTTPPort int      `yaml:"http_port,omitempty"` // Also serve plain HTTP on this port (default: HTTPS only)
+	CertFile string   `yaml:"cert_file,omitempty"` // PEM certificate (omit to generate a self-signed one in state_dir/tls)
+	KeyFile  string   `yaml:"key_file,omitempty"`  // PEM private key of cert_file
+	Hosts    []string `yaml:"hosts,omitempty"`     // Extra host names/IPs for the generated certificate
+}
+
+// MQTTConfig represents the persistent MQTT bridge settings
+type MQTTConfig struct {
+	Broker          string `yaml:"broker"`                     // Broker URL (e.g. "tcp://mqtt:1883"); empty disables the bridge
+	Username        string `yaml:"username,omitempty"`         // Broker username
+	Password        string `yaml:"password,omitempty"`         // Broker password
+	ClientID        string `yaml:"client_id,omitempty"`        // MQTT client ID (default: "onvif-relay")
+	TopicPrefix     string `yaml:"topic_prefix,omitempty"`     // Base topic for state and commands (default: "atomcam")
+	DiscoveryPrefix string `yaml:"discovery_prefix,omitempty"` // Home Assistant discovery prefix (default: "homeassistant")
 }
 
 // ProxyConfig represents a single reverse proxy rule
@@ -36,6 +74,15 @@ type AuthConfig struct {
 	Password string `yaml:"password"`
 }
 
+// UserConfig represents an additional relay account with an ONVIF user level
+type UserConfig struct {
+	Username    string   `yaml:"username"`
+	Password    string   `yaml:"password"`
+	Level       string   `yaml:"level"`                  // Administrator, Operator, User or Anonymous
+	Cameras     []string `yaml:"cameras,omitempty"`      // Cameras this user may access (default: all)
+	PTZPriority int      `yaml:"ptz_priority,omitempty"` // PTZ lease priority 1-100 (default: by level)
+}
+
 // MediamtxConfig represents mediamtx integration settings
 type MediamtxConfig struct {
 	API      string `yaml:"api"`
@@ -57,6 +104,7 @@ type CameraConfig struct {
 	Capabilities   CapabilitiesConfig `yaml:"capabilities"`
 	Streams        []StreamConfig     `yaml:"streams"`
 	PTZ            PTZConfig          `yaml:"ptz,omitempty"`
+	Snapshot       SnapshotConfig     `yaml:"snapshot,omitempty"`
 }
 
 // TalkConfig represents speaker talk bridge settings.
@@ -66,14 +114,140 @@ type TalkConfig struct {
 	Token   string `yaml:"token,omitempty"`
 }
 
+// SnapshotConfig represents snapshot cache settings
+type SnapshotConfig struct {
+	MaxAge float64 `yaml:"max_age,omitempty"` // Seconds a snapshot is reused before fetching a new one (default: 1)
+}
+
 // PTZConfig represents PTZ-specific configuration
 type PTZConfig struct {
 	Home          *PTZPreset  `yaml:"home,omitempty"`           // Home position
 	Presets       []PTZPreset `yaml:"presets,omitempty"`        // Presets 1-9
 	HorizontalFOV float64     `yaml:"horizontal_fov,omitempty"` // Horizontal field of view in degrees (e.g., 120.0)
 	VerticalFOV   float64     `yaml:"vertical_fov,omitempty"`   // Vertical field of view in degrees (e.g., 67.5)
+	Mount         string      `yaml:"mount,omitempty"`          // Mount orientation: "auto" (default), "desk" or "ceiling"
+	Limits        *PTZLimits  `yaml:"limits,omitempty"`         // Soft pan/tilt limits within the motor range
+	NoGoZones     []PTZZone   `yaml:"no_go_zones,omitempty"`    // Pan/tilt regions the camera must not be pointed at
+	LeaseTime     float64     `yaml:"lease_time,omitempty"`     // Seconds a client keeps control after its last PTZ command (default: 10)
+}
+
+// PTZLimits restricts the pan/tilt range of a camera, in AtomCam degrees.
+// A zero maximum means the end of the motor range.
+type PTZLimits struct {
+	PanMin  int `yaml:"pan_min"`
+	PanMax  int `yaml:"pan_max"`
+	TiltMin int `yaml:"tilt_min"`
+	TiltMax int `yaml:"tilt_max"`
+}
+
+// Clamp restricts a pan/tilt position to the limits
+func (l *PTZLimits) Clamp(pan, tilt int) (int, int) {
+	return min(max(pan, l.PanMin), l.PanMax), min(max(tilt, l.TiltMin), l.TiltMax)
+}
+
+// Contains reports whether a pan/tilt position is within the limits
+func (l *PTZLimits) Contains(pan, tilt int) bool {
+	return pan >= l.PanMin && pan <= l.PanMax && tilt >= l.TiltMin && tilt <= l.TiltMax
+}
+
+// PTZZone is a polygon in pan/tilt space the camera must not be pointed at.
+// It applies to the center of the picture, not to the whole field of view.
+type PTZZone struct {
+	Name   string     `yaml:"name,omitempty"`
+	Points []PTZPoint `yaml:"points"` // Corners of the polygon (at least 3)
+}
+
+// PTZPoint is a pan/tilt position in AtomCam degrees
+type PTZPoint struct {
+	Pan  int `yaml:"pan"`
+	Tilt int `yaml:"tilt"`
+}
+
+// Contains reports whether a pan/tilt position is inside the zone (even-odd rule)
+func (z *PTZZone) Contains(pan, tilt float64) bool {
+	inside := false
+	for i, j := 0, len(z.Points)-1; i < len(z.Points); j, i = i, i+1 {
+		pi, pj := z.Points[i], z.Points[j]
+		ti, tj := float64(pi.Tilt), float64(pj.Tilt)
+		if (ti > tilt) == (tj > tilt) {
+			continue
+		}
+		crossPan := float64(pi.Pan) + (tilt-ti)*float64(pj.Pan-pi.Pan)/(tj-ti)
+		if pan < crossPan {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// Crosses reports whether the straight pan/tilt path between two positions passes through
+// the inside of the zone
+func (z *PTZZone) Crosses(fromPan, fromTilt, toPan, toTilt float64) bool {
+	dp, dt := toPan-fromPan, toTilt-fromTilt
+
+	// Cut the path where it meets an edge; each piece is then entirely inside or outside
+	cuts := []float64{0, 1}
+	for i, j := 0, len(z.Points)-1; i < len(z.Points); j, i = i, i+1 {
+		wp, wt := float64(z.Points[j].Pan)-fromPan, float64(z.Points[j].Tilt)-fromTilt
+		ep, et := float64(z.Points[i].Pan-z.Points[j].Pan), float64(z.Points[i].Tilt-z.Points[j].Tilt)
+		denom := dp*et - dt*ep
+		if denom == 0 {
+			// Parallel edge: cut at its ends if it lies on the path
+			if length := dp*dp + dt*dt; length > 0 && wp*dt-wt*dp == 0 {
+				cuts = append(cuts, (wp*dp+wt*dt)/length, ((wp+ep)*dp+(wt+et)*dt)/length)
+			}
+			continue
+		}
+		t := (wp*et - wt*ep) / denom
+		u := (wp*dt - wt*dp) / denom
+		if u >= 0 && u <= 1 {
+			cuts = append(cuts, t)
+		}
+	}
+
+	sort.Float64s(cuts)
+	for k := 1; k < len(cuts); k++ {
+		if cuts[k] <= 0 || cuts[k-1] >= 1 {
+			continue
+		}
+		t := (max(cuts[k-1], 0) + min(cuts[k], 1)) / 2
+		if z.Contains(fromPan+t*dp, fromTilt+t*dt) {
+			return true
+		}
+	}
+	return false
 }
 
+// NoGoZone returns the no-go zone containing a pan/tilt position
+func (p *PTZConfig) NoGoZone(pan, tilt float64) (*PTZZone, bool) {
+	for i := range p.NoGoZones {
+		if p.NoGoZones[i].Contains(pan, tilt) {
+			return &p.NoGoZones[i], true
+		}
+	}
+	return nil, false
+}
+
+// NoGoZoneOnPath returns the no-go zone the straight pan/tilt path between two positions
+// passes through. A zone containing the start is skipped, so that a camera inside a zone
+// (e.g. one added after it was pointed there) can be moved out.
+func (p *PTZConfig) NoGoZoneOnPath(fromPan, fromTilt, toPan, toTilt float64) (*PTZZone, bool) {
+	for i := range p.NoGoZones {
+		zone := &p.NoGoZones[i]
+		if !zone.Contains(fromPan, fromTilt) && zone.Crosses(fromPan, fromTilt, toPan, toTilt) {
+			return zone, true
+		}
+	}
+	return nil, false
+}
+
+// PTZ mount orientations
+const (
+	MountAuto    = "auto"    // Trust the camera: its flip setting is already applied to pan/tilt by the firmware
+	MountDesk    = "desk"    // Upright camera; same mapping as auto
+	MountCeiling = "ceiling" // Upside-down camera shown upright: pan/tilt are mirrored unless the camera flips the picture itself
+)
+
 // CapabilitiesConfig represents camera capabilities
 type CapabilitiesConfig struct {
 	PTZ bool `yaml:"ptz"`
@@ -99,6 +273,68 @@ type StreamConfig struct {
 	Codec       string `yaml:"codec"`
 	ProfileName string `yaml:"profile_name"`
 	RTSPURL     string `yaml:"rtsp_url,omitempty"` // Optional: override RTSP URL (if not set, use mediamtx)
+	// Optional: snapshot size advertised by GetSnapshotUri for this profile, as "WxH" or
+	// "stream" for the stream resolution (default: the camera's full-size snapshot)
+	SnapshotSize string `yaml:"snapshot_size,omitempty"`
+	// Optional: firmware encoder channel of the stream (0: main H.264, 1: sub, 3: main H.265);
+	// derived from the camera's RTSP path if not set
+	EncoderChannel *int `yaml:"encoder_channel,omitempty"`
+}
+
+// MaxSnapshotDimension bounds the width and height of scaled snapshots (snapshot_size and
+// the width/height query parameters of /snapshot)
+const MaxSnapshotDimension = 8192
+
+// encoderChannels maps the camera's RTSP paths to their firmware encoder channels
+var encoderChannels = map[string]int{
+	"video0_unicast": 0,
+	"video1_unicast": 1,
+	"video2_unicast": 3,
+}
+
+// Channel returns the firmware encoder channel used by "video bitrate"; ok is false
+// if it is neither configured nor known from the stream path
+func (s *StreamConfig) Channel() (int, bool) {
+	if s.EncoderChannel != nil {
+		return *s.EncoderChannel, true
+	}
+	channel, ok := encoderChannels[s.Path]
+	return channel, ok
+}
+
+// Host returns the mediamtx RTSP host: rtsp_host, defaulting to the mediamtx service
+// name in the Docker network when mediamtx is enabled
+func (m *MediamtxConfig) Host() string {
+	if m.RTSPHost == "" && m.API != "" {
+		return "mediamtx"
+	}
+	return m.RTSPHost
+}
+
+// BaseURL returns the URL of the relay's HTTP server at host: https on onvif_port when TLS
+// is enabled, plain http otherwise
+func (s *ServerConfig) BaseURL(host string) string {
+	if s.TLS.Enabled {
+		return fmt.Sprintf("https://%s", net.JoinHostPort(host, strconv.Itoa(s.OnvifPort)))
+	}
+	return fmt.Sprintf("http://%s", net.JoinHostPort(host, strconv.Itoa(s.OnvifPort)))
+}
+
+// AdvertisedHost returns advertise_host if set, else the host detected for a client
+func (s *ServerConfig) AdvertisedHost(detected string) string {
+	if s.AdvertiseHost != "" {
+		return s.AdvertiseHost
+	}
+	return detected
+}
+
+// BaseURLs returns the URLs of every listener of the relay at host, HTTPS first
+func (s *ServerConfig) BaseURLs(host string) []string {
+	urls := []string{s.BaseURL(host)}
+	if s.TLS.Enabled && s.TLS.HTTPPort != 0 {
+		urls = append(urls, fmt.Sprintf("http://%s", net.JoinHostPort(host, strconv.Itoa(s.TLS.HTTPPort))))
+	}
+	return urls
 }
 
 // LoadConfig loads configuration from a YAML file
@@ -113,5 +349,10 @@ func LoadConfig(path string) (*Config, error) {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
 
+	// Relay state (users created via ONVIF, ...) is kept next to the config file by default
+	if cfg.Server.StateDir == "" {
+		cfg.Server.StateDir = filepath.Join(filepath.Dir(path), "state")
+	}
+
 	return &cfg, nil
 }
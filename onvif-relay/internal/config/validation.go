@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"net"
 	"regexp"
 	"strings"
 )
@@ -31,6 +32,13 @@ func (c *Config) Validate() error {
 			return fmt.Errorf("camera[%d] (%s): %w", i, cam.Name, err)
 		}
 
+		// MQTT presets without mqtt_broker publish through the MQTT bridge
+		for j, preset := range cam.PTZ.Presets {
+			if preset.MQTTTopic != "" && preset.MQTTBroker == "" && c.Server.MQTT.Broker == "" {
+				return fmt.Errorf("camera[%d] (%s): ptz.presets[%d]: mqtt_broker is required without server.mqtt.broker", i, cam.Name, j)
+			}
+		}
+
 		// Check for duplicate camera names
 		if cameraNames[cam.Name] {
 			return fmt.Errorf("duplicate camera name: %s", cam.Name)
@@ -38,11 +46,30 @@ func (c *Config) Validate() error {
 		cameraNames[cam.Name] = true
 	}
 
+	// SystemReboot reboots the relay or one configured camera
+	if target := c.Server.SystemReboot; target != "" && target != SystemRebootRelay && !cameraNames[target] {
+		return fmt.Errorf("server config: system_reboot: unknown camera: %s", target)
+	}
+
+	// Camera allow lists must refer to configured cameras
+	for i, u := range c.Server.Users {
+		for _, name := range u.Cameras {
+			if !cameraNames[name] {
+				return fmt.Errorf("server config: users[%d] (%s): unknown camera: %s", i, u.Username, name)
+			}
+		}
+	}
+	for _, name := range c.Server.OnvifUserCameras {
+		if !cameraNames[name] {
+			return fmt.Errorf("server config: onvif_user_cameras: unknown camera: %s", name)
+		}
+	}
+
 	return nil
 }
 
 // reservedPaths are paths used internally by the ONVIF server
-var reservedPaths = []string{"/onvif/", "/snapshot/", "/talk/"}
+var reservedPaths = []string{"/onvif/", "/snapshot/", "/talk/", "/ptz/", "/webhook/"}
 
 // Validate validates server configuration
 func (s *ServerConfig) Validate() error {
@@ -54,22 +81,51 @@ func (s *ServerConfig) Validate() error {
 		return fmt.Errorf("device_name is required")
 	}
 
-	if s.Auth.Username == "" {
-		return fmt.Errorf("auth.username is required")
+	if s.AdvertiseHost != "" && !validHostPattern.MatchString(s.AdvertiseHost) && net.ParseIP(s.AdvertiseHost) == nil {
+		return fmt.Errorf("invalid advertise_host: %q", s.AdvertiseHost)
 	}
 
-	// Validate auth username format (prevent shell injection, consistent with camera validation)
-	if !validCredentialPattern.MatchString(s.Auth.Username) {
-		return fmt.Errorf("invalid auth.username: contains shell metacharacters")
-	}
+	// auth is the built-in Administrator account; it may be omitted when users are configured
+	if s.Auth.Username != "" || s.Auth.Password != "" || len(s.Users) == 0 {
+		if s.Auth.Username == "" {
+			return fmt.Errorf("auth.username is required")
+		}
+
+		// Validate auth username format (prevent shell injection, consistent with camera validation)
+		if !validCredentialPattern.MatchString(s.Auth.Username) {
+			return fmt.Errorf("invalid auth.username: contains shell metacharacters")
+		}
+
+		if s.Auth.Password == "" {
+			return fmt.Errorf("auth.password is required")
+		}
 
-	if s.Auth.Password == "" {
-		return fmt.Errorf("auth.password is required")
+		// Validate auth password format (prevent shell injection, consistent with camera validation)
+		if !validCredentialPattern.MatchString(s.Auth.Password) {
+			return fmt.Errorf("invalid auth.password: contains shell metacharacters")
+		}
 	}
 
-	// Validate auth password format (prevent shell injection, consistent with camera validation)
-	if !validCredentialPattern.MatchString(s.Auth.Password) {
-		return fmt.Errorf("invalid auth.password: contains shell metacharacters")
+	usernames := make(map[string]bool)
+	hasAdministrator := s.Auth.Username != ""
+	if hasAdministrator {
+		usernames[s.Auth.Username] = true
+	}
+	for i := range s.Users {
+		u := &s.Users[i]
+		if err := u.Validate(); err != nil {
+			return fmt.Errorf("users[%d] (%s): %w", i, u.Username, err)
+		}
+		if usernames[u.Username] {
+			return fmt.Errorf("users[%d]: duplicate username: %s", i, u.Username)
+		}
+		usernames[u.Username] = true
+		if u.Level == UserLevelAdministrator {
+			hasAdministrator = true
+		}
+	}
+	if !hasAdministrator {
+		return fmt.Errorf("an Administrator account is required (auth or a user with level Administrator)")
 	}
 
 	if err := s.Mediamtx.Validate(); err != nil {
@@ -87,6 +143,134 @@ func (s *ServerConfig) Validate() error {
 		proxyPaths[p.Path] = true
 	}
 
+	if err := s.MQTT.Validate(); err != nil {
+		return fmt.Errorf("mqtt: %w", err)
+	}
+
+	if err := s.TLS.Validate(s.OnvifPort); err != nil {
+		return fmt.Errorf("tls: %w", err)
+	}
+
+	if s.BackchannelPort != 0 {
+		if s.BackchannelPort < 0 || s.BackchannelPort > 65535 {
+			return fmt.Errorf("invalid backchannel_port: %d (must be 1-65535)", s.BackchannelPort)
+		}
+		if s.BackchannelPort == s.OnvifPort || (s.TLS.Enabled && s.BackchannelPort == s.TLS.HTTPPort) {
+			return fmt.Errorf("backchannel_port must differ from the HTTP ports")
+		}
+	}
+
+	return nil
+}
+
+// ONVIF user levels
+const (
+	UserLevelAdministrator = "Administrator"
+	UserLevelOperator      = "Operator"
+	UserLevelUser          = "User"
+	UserLevelAnonymous     = "Anonymous"
+)
+
+// Validate validates a user account and normalizes its level
+func (u *UserConfig) Validate() error {
+	if u.Username == "" {
+		return fmt.Errorf("username is required")
+	}
+	if !validCredentialPattern.MatchString(u.Username) {
+		return fmt.Errorf("invalid username: contains shell metacharacters")
+	}
+	if u.Password == "" {
+		return fmt.Errorf("password is required")
+	}
+	if !validCredentialPattern.MatchString(u.Password) {
+		return fmt.Errorf("invalid password: contains shell metacharacters")
+	}
+
+	level := ""
+	for _, l := range []string{UserLevelAdministrator, UserLevelOperator, UserLevelUser, UserLevelAnonymous} {
+		if strings.EqualFold(u.Level, l) {
+			level = l
+			break
+		}
+	}
+	if level == "" {
+		return fmt.Errorf("invalid level: %q (must be Administrator, Operator, User or Anonymous)", u.Level)
+	}
+	u.Level = level
+
+	if u.PTZPriority < 0 || u.PTZPriority > 100 {
+		return fmt.Errorf("ptz_priority out of range: %d (must be 1-100)", u.PTZPriority)
+	}
+
+	return nil
+}
+
+// Validate validates MQTT bridge configuration and applies defaults
+func (m *MQTTConfig) Validate() error {
+	// Empty broker means the MQTT bridge is disabled
+	if m.Broker == "" {
+		return nil
+	}
+
+	validSchemes := []string{"tcp://", "ssl://", "tls://", "mqtt://", "mqtts://", "ws://", "wss://"}
+	validScheme := false
+	for _, scheme := range validSchemes {
+		if strings.HasPrefix(m.Broker, scheme) {
+			validScheme = true
+			break
+		}
+	}
+	if !validScheme {
+		return fmt.Errorf("broker must start with tcp://, ssl://, tls://, mqtt://, mqtts://, ws:// or wss://: %s", m.Broker)
+	}
+
+	if m.ClientID == "" {
+		m.ClientID = "onvif-relay"
+	}
+	if m.TopicPrefix == "" {
+		m.TopicPrefix = "atomcam"
+	}
+	if m.DiscoveryPrefix == "" {
+		m.DiscoveryPrefix = "homeassistant"
+	}
+
+	m.TopicPrefix = strings.Trim(m.TopicPrefix, "/")
+	m.DiscoveryPrefix = strings.Trim(m.DiscoveryPrefix, "/")
+	if m.TopicPrefix == "" || strings.ContainsAny(m.TopicPrefix, "+#") {
+		return fmt.Errorf("invalid topic_prefix: %q (must not be empty or contain wildcards)", m.TopicPrefix)
+	}
+	if m.DiscoveryPrefix == "" || strings.ContainsAny(m.DiscoveryPrefix, "+#") {
+		return fmt.Errorf("invalid discovery_prefix: %q (must not be empty or contain wildcards)", m.DiscoveryPrefix)
+	}
+
+	return nil
+}
+
+// Validate validates HTTPS listener settings; onvifPort is the port HTTPS is served on
+func (t *TLSConfig) Validate(onvifPort int) error {
+	if !t.Enabled {
+		return nil
+	}
+
+	if t.HTTPPort != 0 {
+		if t.HTTPPort < 0 || t.HTTPPort > 65535 {
+			return fmt.Errorf("invalid http_port: %d (must be 1-65535)", t.HTTPPort)
+		}
+		if t.HTTPPort == onvifPort {
+			return fmt.Errorf("http_port must differ from onvif_port (%d), which serves HTTPS", onvifPort)
+		}
+	}
+
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		return fmt.Errorf("cert_file and key_file must be set together")
+	}
+
+	for _, host := range t.Hosts {
+		if !validHostPattern.MatchString(host) && net.ParseIP(host) == nil {
+			return fmt.Errorf("invalid host: %q", host)
+		}
+	}
+
 	return nil
 }
 
@@ -98,6 +282,9 @@ func (p *ProxyConfig) Validate() error {
 	if !strings.HasPrefix(p.Path, "/") {
 		return fmt.Errorf("path must start with /: %s", p.Path)
 	}
+	if p.Path == "/" {
+		return fmt.Errorf("path / conflicts with the root ONVIF handler")
+	}
 	for _, reserved := range reservedPaths {
 		if strings.HasPrefix(p.Path, reserved) {
 			return fmt.Errorf("path conflicts with reserved ONVIF path %s", reserved)
@@ -209,11 +396,42 @@ func (c *CameraConfig) Validate() error {
 	if err := c.Talk.Validate(); err != nil {
 		return fmt.Errorf("talk: %w", err)
 	}
+	if c.Snapshot.MaxAge < 0 {
+		return fmt.Errorf("snapshot.max_age must be >= 0")
+	}
+
+	switch strings.ToLower(c.PTZ.Mount) {
+	case "", MountAuto, MountDesk, MountCeiling:
+		c.PTZ.Mount = strings.ToLower(c.PTZ.Mount)
+	default:
+		return fmt.Errorf("invalid ptz.mount: %s (must be auto, desk or ceiling)", c.PTZ.Mount)
+	}
+
+	if c.PTZ.Limits != nil {
+		if err := c.PTZ.Limits.Validate(); err != nil {
+			return fmt.Errorf("ptz.limits: %w", err)
+		}
+	}
+	for i := range c.PTZ.NoGoZones {
+		zone := &c.PTZ.NoGoZones[i]
+		if err := zone.Validate(); err != nil {
+			return fmt.Errorf("ptz.no_go_zones[%d]: %w", i, err)
+		}
+		if zone.Name == "" {
+			zone.Name = fmt.Sprintf("zone %d", i+1)
+		}
+	}
+	if c.PTZ.LeaseTime < 0 {
+		return fmt.Errorf("ptz.lease_time must be >= 0")
+	}
 
 	if c.PTZ.Home != nil {
 		if err := c.PTZ.Home.validatePosition(); err != nil {
 			return fmt.Errorf("ptz.home: %w", err)
 		}
+		if err := c.PTZ.validateReachable(c.PTZ.Home.Pan, c.PTZ.Home.Tilt); err != nil {
+			return fmt.Errorf("ptz.home: %w", err)
+		}
 	}
 
 	presetTokens := make(map[string]bool)
@@ -222,6 +440,11 @@ func (c *CameraConfig) Validate() error {
 		if err := preset.Validate(); err != nil {
 			return fmt.Errorf("ptz.presets[%d]: %w", i, err)
 		}
+		if preset.Tracking == "" && preset.MQTTTopic == "" {
+			if err := c.PTZ.validateReachable(preset.Pan, preset.Tilt); err != nil {
+				return fmt.Errorf("ptz.presets[%d]: %w", i, err)
+			}
+		}
 
 		token := preset.Token
 		if token == "" {
@@ -249,9 +472,7 @@ func (p *PTZPreset) Validate() error {
 	}
 
 	if hasMQTT {
-		if p.MQTTBroker == "" {
-			return fmt.Errorf("mqtt_broker is required for an MQTT preset")
-		}
+		// Without mqtt_broker the message goes to the server.mqtt broker (checked by Config.Validate)
 		if p.MQTTTopic == "" {
 			return fmt.Errorf("mqtt_topic is required for an MQTT preset")
 		}
@@ -280,6 +501,47 @@ func (p *PTZPreset) validatePosition() error {
 	return nil
 }
 
+// Validate validates soft PTZ limits; zero maximums are set to the end of the motor range.
+func (l *PTZLimits) Validate() error {
+	if l.PanMax == 0 {
+		l.PanMax = 355
+	}
+	if l.TiltMax == 0 {
+		l.TiltMax = 180
+	}
+	if l.PanMin < 0 || l.PanMax > 355 || l.PanMin > l.PanMax {
+		return fmt.Errorf("invalid pan range: %d-%d (must be within 0-355)", l.PanMin, l.PanMax)
+	}
+	if l.TiltMin < 0 || l.TiltMax > 180 || l.TiltMin > l.TiltMax {
+		return fmt.Errorf("invalid tilt range: %d-%d (must be within 0-180)", l.TiltMin, l.TiltMax)
+	}
+	return nil
+}
+
+// Validate validates a PTZ no-go zone.
+func (z *PTZZone) Validate() error {
+	if len(z.Points) < 3 {
+		return fmt.Errorf("at least 3 points are required")
+	}
+	for i, p := range z.Points {
+		if p.Pan < 0 || p.Pan > 355 || p.Tilt < 0 || p.Tilt > 180 {
+			return fmt.Errorf("points[%d] out of range: (%d, %d)", i, p.Pan, p.Tilt)
+		}
+	}
+	return nil
+}
+
+// validateReachable checks that a configured position is within the limits and outside the no-go zones
+func (p *PTZConfig) validateReachable(pan, tilt int) error {
+	if p.Limits != nil && !p.Limits.Contains(pan, tilt) {
+		return fmt.Errorf("position (%d, %d) is outside ptz.limits", pan, tilt)
+	}
+	if zone, ok := p.NoGoZone(float64(pan), float64(tilt)); ok {
+		return fmt.Errorf("position (%d, %d) is inside no-go zone %s", pan, tilt, zone.Name)
+	}
+	return nil
+}
+
 // Validate validates camera speaker talk settings.
 func (t *TalkConfig) Validate() error {
 	if !t.Enabled {
@@ -327,5 +589,24 @@ func (s *StreamConfig) Validate() error {
 		return fmt.Errorf("invalid codec: %s (must be h264 or h265)", s.Codec)
 	}
 
+	// Validate snapshot size
+	if s.SnapshotSize != "" && s.SnapshotSize != "stream" {
+		var width, height int
+		if _, err := fmt.Sscanf(s.SnapshotSize, "%dx%d", &width, &height); err != nil ||
+			width <= 0 || height <= 0 || fmt.Sprintf("%dx%d", width, height) != s.SnapshotSize {
+			return fmt.Errorf("invalid snapshot_size: %s (must be WxH or stream)", s.SnapshotSize)
+		}
+		if width > MaxSnapshotDimension || height > MaxSnapshotDimension {
+			return fmt.Errorf("invalid snapshot_size: %s (width and height must be at most %d)", s.SnapshotSize, MaxSnapshotDimension)
+		}
+	}
+
+	// Validate encoder channel (the firmware has no channel 2)
+	if s.EncoderChannel != nil {
+		if ch := *s.EncoderChannel; ch != 0 && ch != 1 && ch != 3 {
+			return fmt.Errorf("invalid encoder_channel: %d (must be 0, 1 or 3)", ch)
+		}
+	}
+
 	return nil
 }
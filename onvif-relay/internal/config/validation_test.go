@@ -1,6 +1,9 @@
 package config
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 func TestPTZPresetValidateTracking(t *testing.T) {
 	preset := PTZPreset{Name: "Tracking On", Tracking: "ON"}
@@ -12,6 +15,71 @@ func TestPTZPresetValidateTracking(t *testing.T) {
 	}
 }
 
+func TestCameraConfigValidatePTZMount(t *testing.T) {
+	cam := CameraConfig{
+		Name:     "garden",
+		Host:     "atomcam-garden.local",
+		RTSPPort: 8554,
+		HTTPPort: 80,
+		Streams:  []StreamConfig{{Path: "garden", Codec: "h264", ProfileName: "Garden_Main"}},
+		PTZ:      PTZConfig{Mount: "Ceiling"},
+	}
+	if err := cam.Validate(); err != nil {
+		t.Fatalf("Validate returned an error: %v", err)
+	}
+	if cam.PTZ.Mount != MountCeiling {
+		t.Fatalf("Mount = %q, want %q", cam.PTZ.Mount, MountCeiling)
+	}
+
+	cam.PTZ.Mount = "wall"
+	if err := cam.Validate(); err == nil || !strings.Contains(err.Error(), "ptz.mount") {
+		t.Fatalf("Validate = %v, want invalid ptz.mount error", err)
+	}
+}
+
+func TestCameraConfigValidatePTZLimitsAndNoGoZones(t *testing.T) {
+	cam := CameraConfig{
+		Name:     "garden",
+		Host:     "atomcam-garden.local",
+		RTSPPort: 8554,
+		HTTPPort: 80,
+		Streams:  []StreamConfig{{Path: "garden", Codec: "h264", ProfileName: "Garden_Main"}},
+		PTZ: PTZConfig{
+			Limits: &PTZLimits{PanMin: 30, TiltMin: 20},
+			NoGoZones: []PTZZone{{Points: []PTZPoint{
+				{Pan: 100, Tilt: 40}, {Pan: 140, Tilt: 40}, {Pan: 140, Tilt: 90}, {Pan: 100, Tilt: 90},
+			}}},
+			Presets: []PTZPreset{{Name: "Gate", Pan: 200, Tilt: 60}},
+		},
+	}
+	if err := cam.Validate(); err != nil {
+		t.Fatalf("Validate returned an error: %v", err)
+	}
+	if cam.PTZ.Limits.PanMax != 355 || cam.PTZ.Limits.TiltMax != 180 {
+		t.Fatalf("Limits = %+v, want the motor range as maximums", *cam.PTZ.Limits)
+	}
+	if cam.PTZ.NoGoZones[0].Name != "zone 1" {
+		t.Fatalf("zone name = %q, want zone 1", cam.PTZ.NoGoZones[0].Name)
+	}
+
+	cam.PTZ.Presets[0].Pan = 120
+	if err := cam.Validate(); err == nil || !strings.Contains(err.Error(), "no-go zone") {
+		t.Fatalf("Validate = %v, want a preset in a no-go zone error", err)
+	}
+
+	cam.PTZ.Presets = nil
+	cam.PTZ.Home = &PTZPreset{Name: "Home", Pan: 10, Tilt: 90}
+	if err := cam.Validate(); err == nil || !strings.Contains(err.Error(), "outside ptz.limits") {
+		t.Fatalf("Validate = %v, want a home outside the limits error", err)
+	}
+
+	cam.PTZ.Home = nil
+	cam.PTZ.NoGoZones[0].Points = cam.PTZ.NoGoZones[0].Points[:2]
+	if err := cam.Validate(); err == nil || !strings.Contains(err.Error(), "ptz.no_go_zones[0]") {
+		t.Fatalf("Validate = %v, want a no-go zone error", err)
+	}
+}
+
 func TestPTZPresetValidateRejectsInvalidTracking(t *testing.T) {
 	preset := PTZPreset{Name: "Tracking", Tracking: "toggle"}
 	if err := preset.Validate(); err == nil {
@@ -32,8 +100,175 @@ func TestPTZPresetValidateRejectsCombinedActions(t *testing.T) {
 }
 
 func TestPTZPresetValidateRequiresCompleteMQTTConfiguration(t *testing.T) {
-	preset := PTZPreset{Name: "MQTT", MQTTTopic: "test/topic"}
+	preset := PTZPreset{Name: "MQTT", MQTTBroker: "tcp://mqtt:1883", MQTTMessage: "ON"}
 	if err := preset.Validate(); err == nil {
-		t.Fatal("Validate returned nil without mqtt_broker")
+		t.Fatal("Validate returned nil without mqtt_topic")
+	}
+}
+
+func TestConfigValidateMQTTPresetBroker(t *testing.T) {
+	cfg := Config{
+		Server: ServerConfig{OnvifPort: 8080, DeviceName: "relay", Auth: AuthConfig{Username: "admin", Password: "secret"}},
+		Cameras: []CameraConfig{{
+			Name:     "garage",
+			Host:     "192.168.1.20",
+			HTTPPort: 80,
+			RTSPPort: 8554,
+			Streams:  []StreamConfig{{Path: "video0_unicast", Codec: "h264", ProfileName: "Garage_Main"}},
+			PTZ:      PTZConfig{Presets: []PTZPreset{{Name: "Light", MQTTTopic: "home/light", MQTTMessage: "ON"}}},
+		}},
+	}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "mqtt_broker") {
+		t.Fatalf("Validate = %v, want mqtt_broker error without server.mqtt", err)
+	}
+
+	cfg.Server.MQTT.Broker = "tcp://mqtt:1883"
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate with server.mqtt returned an error: %v", err)
+	}
+}
+
+func TestMQTTConfigValidateAppliesDefaults(t *testing.T) {
+	mqtt := MQTTConfig{Broker: "tcp://mqtt:1883", TopicPrefix: "/cams/"}
+	if err := mqtt.Validate(); err != nil {
+		t.Fatalf("Validate returned an error: %v", err)
+	}
+	if mqtt.TopicPrefix != "cams" || mqtt.DiscoveryPrefix != "homeassistant" || mqtt.ClientID != "onvif-relay" {
+		t.Fatalf("MQTTConfig = %+v, want defaults applied", mqtt)
+	}
+}
+
+func TestMQTTConfigValidateRejectsWildcardPrefix(t *testing.T) {
+	mqtt := MQTTConfig{Broker: "tcp://mqtt:1883", TopicPrefix: "atomcam/#"}
+	if err := mqtt.Validate(); err == nil {
+		t.Fatal("Validate returned nil for wildcard topic_prefix")
+	}
+}
+
+func TestServerConfigValidateUsers(t *testing.T) {
+	server := ServerConfig{
+		OnvifPort:  8080,
+		DeviceName: "relay",
+		Users: []UserConfig{
+			{Username: "owner", Password: "secret", Level: "administrator"},
+			{Username: "viewer", Password: "secret", Level: "user"},
+		},
+	}
+	if err := server.Validate(); err != nil {
+		t.Fatalf("Validate returned an error: %v", err)
+	}
+	if server.Users[0].Level != UserLevelAdministrator || server.Users[1].Level != UserLevelUser {
+		t.Fatalf("levels = %s, %s; want normalized names", server.Users[0].Level, server.Users[1].Level)
+	}
+
+	server.Users[1].PTZPriority = 101
+	if err := server.Validate(); err == nil || !strings.Contains(err.Error(), "ptz_priority") {
+		t.Fatalf("Validate = %v, want ptz_priority range error", err)
+	}
+}
+
+func TestServerConfigValidateRequiresAdministrator(t *testing.T) {
+	server := ServerConfig{
+		OnvifPort:  8080,
+		DeviceName: "relay",
+		Users:      []UserConfig{{Username: "viewer", Password: "secret", Level: UserLevelUser}},
+	}
+	if err := server.Validate(); err == nil {
+		t.Fatal("Validate returned nil without an Administrator account")
+	}
+}
+
+func TestConfigValidateRejectsUnknownUserCamera(t *testing.T) {
+	cfg := Config{
+		Server: ServerConfig{
+			OnvifPort:  8080,
+			DeviceName: "relay",
+			Auth:       AuthConfig{Username: "admin", Password: "secret"},
+			Users:      []UserConfig{{Username: "viewer", Password: "secret", Level: UserLevelUser, Cameras: []string{"garage"}}},
+		},
+		Cameras: []CameraConfig{{
+			Name:     "garden",
+			Host:     "atomcam-garden.local",
+			RTSPPort: 8554,
+			HTTPPort: 80,
+			Streams:  []StreamConfig{{Path: "garden", Codec: "h264", ProfileName: "Garden_Main"}},
+		}},
+	}
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "unknown camera: garage") {
+		t.Fatalf("Validate = %v, want unknown camera error", err)
+	}
+}
+
+func TestConfigValidateSystemReboot(t *testing.T) {
+	cfg := Config{
+		Server: ServerConfig{
+			OnvifPort:  8080,
+			DeviceName: "relay",
+			Auth:       AuthConfig{Username: "admin", Password: "secret"},
+		},
+		Cameras: []CameraConfig{{
+			Name:     "garden",
+			Host:     "atomcam-garden.local",
+			RTSPPort: 8554,
+			HTTPPort: 80,
+			Streams:  []StreamConfig{{Path: "garden", Codec: "h264", ProfileName: "Garden_Main"}},
+		}},
+	}
+	for _, target := range []string{"", SystemRebootRelay, "garden"} {
+		cfg.Server.SystemReboot = target
+		if err := cfg.Validate(); err != nil {
+			t.Fatalf("Validate with system_reboot %q returned an error: %v", target, err)
+		}
+	}
+
+	cfg.Server.SystemReboot = "garage"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "unknown camera: garage") {
+		t.Fatalf("Validate = %v, want unknown camera error", err)
+	}
+}
+
+func TestTLSConfigValidate(t *testing.T) {
+	valid := TLSConfig{Enabled: true, HTTPPort: 8081, Hosts: []string{"relay.local", "192.0.2.10"}}
+	if err := valid.Validate(8080); err != nil {
+		t.Fatalf("Validate returned an error: %v", err)
+	}
+
+	for name, tlsCfg := range map[string]TLSConfig{
+		"http_port equals onvif_port": {Enabled: true, HTTPPort: 8080},
+		"cert without key":            {Enabled: true, CertFile: "/config/relay.crt"},
+		"invalid host":                {Enabled: true, Hosts: []string{"relay;rm"}},
+	} {
+		if err := tlsCfg.Validate(8080); err == nil {
+			t.Errorf("%s: Validate returned nil", name)
+		}
+	}
+}
+
+func TestServerConfigBaseURLs(t *testing.T) {
+	server := ServerConfig{OnvifPort: 8443, TLS: TLSConfig{Enabled: true, HTTPPort: 8080}}
+	urls := server.BaseURLs("192.0.2.10")
+	if len(urls) != 2 || urls[0] != "https://192.0.2.10:8443" || urls[1] != "http://192.0.2.10:8080" {
+		t.Fatalf("BaseURLs = %v, want https then http", urls)
+	}
+
+	server.TLS = TLSConfig{}
+	if url := server.BaseURL("relay.local"); url != "http://relay.local:8443" {
+		t.Fatalf("BaseURL = %s, want plain http", url)
+	}
+}
+
+func TestStreamConfigValidateSnapshotSize(t *testing.T) {
+	for _, size := range []string{"", "stream", "640x360", "8192x8192"} {
+		stream := StreamConfig{Path: "video1_unicast", Codec: "h264", ProfileName: "Sub", SnapshotSize: size}
+		if err := stream.Validate(); err != nil {
+			t.Errorf("Validate(snapshot_size %q) = %v, want nil", size, err)
+		}
+	}
+	for _, size := range []string{"640", "0x360", "640x360px", "small", "8193x4608", "1920x9000"} {
+		stream := StreamConfig{Path: "video1_unicast", Codec: "h264", ProfileName: "Sub", SnapshotSize: size}
+		if err := stream.Validate(); err == nil {
+			t.Errorf("Validate(snapshot_size %q) = nil, want error", size)
+		}
 	}
 }
@@ -0,0 +1,141 @@
+package discovery
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/state"
+)
+
+// scopePrefix is the prefix of the ONVIF scope URIs
+const scopePrefix = "onvif://www.onvif.org/"
+
+// maxConfigurableScopes limits the number of scopes set via SetScopes
+const maxConfigurableScopes = 32
+
+// Scope errors; the ONVIF device service maps them to fault subcodes
+var (
+	ErrScopeOverwrite = errors.New("scope overwrites a fixed scope")
+	ErrTooManyScopes  = errors.New("too many scopes")
+	ErrInvalidScope   = errors.New("invalid scope")
+)
+
+// Scopes holds the scopes advertised by WS-Discovery and returned by GetScopes:
+// fixed scopes describing the relay and configurable scopes (name, location, ...)
+// that clients replace with SetScopes. Configurable scopes are persisted in a state file.
+type Scopes struct {
+	path  string
+	fixed []string
+
+	mu              sync.RWMutex
+	configurable    []string
+	metadataVersion int
+}
+
+// scopesState is the persisted form of the configurable scopes
+type scopesState struct {
+	Scopes          []string `json:"scopes"`
+	MetadataVersion int      `json:"metadata_version"`
+}
+
+// NewScopes creates the scopes of the relay and loads configurable scopes set via ONVIF from path.
+// Until SetScopes is called the configurable scope is the device name.
+func NewScopes(deviceName, path string) (*Scopes, error) {
+	name := url.PathEscape(deviceName)
+	s := &Scopes{
+		path: path,
+		fixed: []string{
+			scopePrefix + "type/video_encoder",
+			scopePrefix + "type/ptz",
+			scopePrefix + "Profile/Streaming",
+			scopePrefix + "hardware/" + name,
+		},
+		configurable:    []string{scopePrefix + "name/" + name},
+		metadataVersion: 1,
+	}
+
+	var saved scopesState
+	if err := state.Load(path, &saved); err != nil {
+		return nil, err
+	}
+	if saved.Scopes != nil {
+		s.configurable = saved.Scopes
+	}
+	if saved.MetadataVersion > 0 {
+		s.metadataVersion = saved.MetadataVersion
+	}
+
+	return s, nil
+}
+
+// Fixed returns the scopes that cannot be changed
+func (s *Scopes) Fixed() []string {
+	return append([]string(nil), s.fixed...)
+}
+
+// Configurable returns the scopes set via SetScopes
+func (s *Scopes) Configurable() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]string(nil), s.configurable...)
+}
+
+// All returns the fixed and configurable scopes
+func (s *Scopes) All() []string {
+	return append(s.Fixed(), s.Configurable()...)
+}
+
+// MetadataVersion returns the WS-Discovery metadata version, incremented on every change
+func (s *Scopes) MetadataVersion() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.metadataVersion
+}
+
+// Set replaces the configurable scopes and saves them
+func (s *Scopes) Set(scopes []string) error {
+	if len(scopes) > maxConfigurableScopes {
+		return ErrTooManyScopes
+	}
+
+	configurable := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		scope = strings.TrimSpace(scope)
+		if err := validateScope(scope); err != nil {
+			return err
+		}
+		for _, fixed := range s.fixed {
+			if scope == fixed {
+				return fmt.Errorf("%w: %s", ErrScopeOverwrite, scope)
+			}
+		}
+		configurable = append(configurable, scope)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	saved := scopesState{Scopes: configurable, MetadataVersion: s.metadataVersion + 1}
+	if err := state.Save(s.path, saved); err != nil {
+		return err
+	}
+	s.configurable = configurable
+	s.metadataVersion = saved.MetadataVersion
+	return nil
+}
+
+// validateScope checks that a scope is an absolute URI without whitespace;
+// scopes are separated by spaces in ProbeMatches
+func validateScope(scope string) error {
+	if scope == "" || strings.ContainsAny(scope, " \t\r\n") {
+		return fmt.Errorf("%w: %q", ErrInvalidScope, scope)
+	}
+	u, err := url.Parse(scope)
+	if err != nil || u.Scheme == "" {
+		return fmt.Errorf("%w: %q", ErrInvalidScope, scope)
+	}
+	return nil
+}
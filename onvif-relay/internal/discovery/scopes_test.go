@@ -0,0 +1,85 @@
+package discovery
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestScopesDefaults(t *testing.T) {
+	scopes, err := NewScopes("ONVIF Relay", filepath.Join(t.TempDir(), "scopes.json"))
+	if err != nil {
+		t.Fatalf("NewScopes returned an error: %v", err)
+	}
+
+	if got, want := scopes.Configurable(), []string{"onvif://www.onvif.org/name/ONVIF%20Relay"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Configurable = %v, want %v", got, want)
+	}
+	if got := scopes.All(); len(got) != len(scopes.Fixed())+1 {
+		t.Fatalf("All = %v, want fixed and configurable scopes", got)
+	}
+	if v := scopes.MetadataVersion(); v != 1 {
+		t.Fatalf("MetadataVersion = %d, want 1", v)
+	}
+}
+
+func TestScopesSetPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scopes.json")
+	scopes, err := NewScopes("relay", path)
+	if err != nil {
+		t.Fatalf("NewScopes returned an error: %v", err)
+	}
+
+	want := []string{"onvif://www.onvif.org/name/garage", "onvif://www.onvif.org/location/tokyo"}
+	if err := scopes.Set(want); err != nil {
+		t.Fatalf("Set returned an error: %v", err)
+	}
+	if v := scopes.MetadataVersion(); v != 2 {
+		t.Fatalf("MetadataVersion after Set = %d, want 2", v)
+	}
+
+	reloaded, err := NewScopes("relay", path)
+	if err != nil {
+		t.Fatalf("NewScopes returned an error: %v", err)
+	}
+	if got := reloaded.Configurable(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("reloaded Configurable = %v, want %v", got, want)
+	}
+	if v := reloaded.MetadataVersion(); v != 2 {
+		t.Fatalf("reloaded MetadataVersion = %d, want 2", v)
+	}
+
+	// Removing all configurable scopes is kept across restarts
+	if err := reloaded.Set(nil); err != nil {
+		t.Fatalf("Set returned an error: %v", err)
+	}
+	if reloaded, _ = NewScopes("relay", path); len(reloaded.Configurable()) != 0 {
+		t.Fatalf("Configurable = %v, want none", reloaded.Configurable())
+	}
+}
+
+func TestScopesSetRejectsInvalidScopes(t *testing.T) {
+	scopes, err := NewScopes("relay", filepath.Join(t.TempDir(), "scopes.json"))
+	if err != nil {
+		t.Fatalf("NewScopes returned an error: %v", err)
+	}
+
+	tests := []struct {
+		scopes []string
+		want   error
+	}{
+		{[]string{"onvif://www.onvif.org/type/ptz"}, ErrScopeOverwrite},
+		{[]string{"not a scope"}, ErrInvalidScope},
+		{[]string{"relative/path"}, ErrInvalidScope},
+		{make([]string, maxConfigurableScopes+1), ErrTooManyScopes},
+	}
+	for _, tt := range tests {
+		if err := scopes.Set(tt.scopes); !errors.Is(err, tt.want) {
+			t.Errorf("Set(%q) = %v, want %v", tt.scopes, err, tt.want)
+		}
+	}
+	if v := scopes.MetadataVersion(); v != 1 {
+		t.Fatalf("MetadataVersion after rejected Set = %d, want 1", v)
+	}
+}
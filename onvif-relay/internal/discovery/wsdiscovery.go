@@ -25,9 +25,8 @@ const (
 type Responder struct {
 	deviceUUID   string
 	deviceName   string
-	xaddrs       string
-	scopes       []string
-	metadataVersion int
+	baseURLs     func(host string) []string
+	scopes       *Scopes
 	conn         *net.UDPConn
 	ctx          context.Context
 	cancel       context.CancelFunc
@@ -56,24 +55,19 @@ type Probe struct {
 	Scopes  string   `xml:"Scopes,omitempty"`
 }
 
-// NewResponder creates a new WS-Discovery responder
-func NewResponder(deviceName, baseURL string) (*Responder, error) {
+// NewResponder creates a new WS-Discovery responder advertising scopes.
+// baseURLs returns the relay URLs (preferred first) for the local address that received a
+// probe; the device service of each is advertised in XAddrs.
+func NewResponder(deviceName string, scopes *Scopes, baseURLs func(host string) []string) (*Responder, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	uuid := generateUUID(deviceName)
-	xaddrs := baseURL + "/onvif/device_service"
 
 	return &Responder{
 		deviceUUID:      uuid,
 		deviceName:      deviceName,
-		xaddrs:          xaddrs,
-		scopes:          []string{
-			"onvif://www.onvif.org/type/video_encoder",
-			"onvif://www.onvif.org/type/ptz",
-			"onvif://www.onvif.org/hardware/" + deviceName,
-			"onvif://www.onvif.org/name/" + deviceName,
-		},
-		metadataVersion: 1,
+		baseURLs:        baseURLs,
+		scopes:          scopes,
 		ctx:             ctx,
 		cancel:          cancel,
 	}, nil
@@ -163,16 +157,38 @@ func (r *Responder) handleProbe(data []byte, remoteAddr *net.UDPAddr) {
 	log.Printf("Received WS-Discovery Probe from %s", remoteAddr.String())
 
 	// Send ProbeMatch response
-	response := r.buildProbeMatch(probe.Header.MessageID)
+	response := r.buildProbeMatch(probe.Header.MessageID, r.xaddrs(localAddrFor(remoteAddr)))
 	r.sendResponse(response, remoteAddr)
 }
 
+// xaddrs returns the device service addresses advertised to clients reaching the relay at host
+func (r *Responder) xaddrs(host string) string {
+	var addrs []string
+	for _, baseURL := range r.baseURLs(host) {
+		addrs = append(addrs, baseURL+"/onvif/device_service")
+	}
+	return strings.Join(addrs, " ")
+}
+
+// localAddrFor returns the local IP address the relay uses to reach remote, i.e. the address
+// of the interface the probe arrived on. The multicast socket does not report the destination
+// address, so it is taken from a connected UDP socket (no packet is sent).
+func localAddrFor(remote *net.UDPAddr) string {
+	conn, err := net.DialUDP("udp4", nil, remote)
+	if err != nil {
+		log.Printf("WS-Discovery: failed to determine local address for %s: %v", remote, err)
+		return "localhost"
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP.String()
+}
+
 // buildProbeMatch builds a ProbeMatch response
-func (r *Responder) buildProbeMatch(relatesTo string) string {
+func (r *Responder) buildProbeMatch(relatesTo, xaddrs string) string {
 	// Escape all user/config-provided values to prevent XML injection
 	relatesTo = html.EscapeString(relatesTo)
-	scopesStr := html.EscapeString(strings.Join(r.scopes, " "))
-	xaddrs := html.EscapeString(r.xaddrs)
+	scopesStr := html.EscapeString(strings.Join(r.scopes.All(), " "))
+	xaddrs = html.EscapeString(xaddrs)
 
 	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
 <SOAP-ENV:Envelope
@@ -202,7 +218,7 @@ func (r *Responder) buildProbeMatch(relatesTo string) string {
 </SOAP-ENV:Envelope>`,
 		wsaNamespace, wsdNamespace, onvifNamespace,
 		generateMessageID(), relatesTo, r.deviceUUID,
-		scopesStr, xaddrs, r.metadataVersion)
+		scopesStr, xaddrs, r.scopes.MetadataVersion())
 }
 
 // sendResponse sends a response to the client
@@ -0,0 +1,76 @@
+package eventbus
+
+import (
+	"log"
+	"sync"
+)
+
+// Event is a message published on the bus
+type Event interface {
+	// CameraName returns the name of the camera the event belongs to
+	CameraName() string
+}
+
+// Bus is an in-process publish/subscribe hub for relay events.
+// Publishing never blocks: events are dropped for subscribers whose buffer is full.
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers map[*Subscription]struct{}
+}
+
+// Subscription receives events published on a bus
+type Subscription struct {
+	bus  *Bus
+	ch   chan Event
+	once sync.Once
+}
+
+// New creates a new event bus
+func New() *Bus {
+	return &Bus{
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// Subscribe registers a subscriber with the given channel buffer size
+func (b *Bus) Subscribe(buffer int) *Subscription {
+	sub := &Subscription{
+		bus: b,
+		ch:  make(chan Event, buffer),
+	}
+
+	b.mu.Lock()
+	b.subscribers[sub] = struct{}{}
+	b.mu.Unlock()
+
+	return sub
+}
+
+// Publish delivers an event to all subscribers
+func (b *Bus) Publish(event Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for sub := range b.subscribers {
+		select {
+		case sub.ch <- event:
+		default:
+			log.Printf("Event bus: subscriber buffer full, dropping %T for %s", event, event.CameraName())
+		}
+	}
+}
+
+// Events returns the channel delivering events; it is closed by Close
+func (s *Subscription) Events() <-chan Event {
+	return s.ch
+}
+
+// Close unregisters the subscription and closes its channel
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.bus.mu.Lock()
+		delete(s.bus.subscribers, s)
+		s.bus.mu.Unlock()
+		close(s.ch)
+	})
+}
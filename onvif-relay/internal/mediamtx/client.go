@@ -5,7 +5,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
+	"sort"
 	"time"
 
 	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/config"
@@ -76,7 +78,7 @@ func (c *Client) ConfigurePath(name string, cfg PathConfig) error {
 
 	// If path already exists, delete and recreate
 	if isPathExistsError(err) {
-		if err := c.deletePath(name); err != nil {
+		if err := c.DeletePath(name); err != nil {
 			return fmt.Errorf("failed to delete existing path %s: %w", name, err)
 		}
 
@@ -91,6 +93,61 @@ func (c *Client) ConfigurePath(name string, cfg PathConfig) error {
 	return err
 }
 
+// SyncPaths applies the difference between two sets of path configurations:
+// new or changed paths are (re)configured and paths that are no longer present are deleted.
+// Unchanged paths are left alone so that their active sessions are kept.
+func (c *Client) SyncPaths(oldPaths, newPaths map[string]PathConfig) error {
+	for _, name := range sortedPathNames(oldPaths) {
+		if _, ok := newPaths[name]; ok {
+			continue
+		}
+		log.Printf("Deleting path: %s", name)
+		if err := c.DeletePath(name); err != nil {
+			return fmt.Errorf("failed to delete path %s: %w", name, err)
+		}
+	}
+
+	for _, name := range sortedPathNames(newPaths) {
+		cfg := newPaths[name]
+		if old, ok := oldPaths[name]; ok && old == cfg {
+			continue
+		}
+		log.Printf("Configuring path: %s", name)
+		if err := c.ConfigurePath(name, cfg); err != nil {
+			return fmt.Errorf("failed to configure path %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// BuildPathConfigs builds the path configuration of every camera stream, keyed by path name ({camera}/{stream})
+func BuildPathConfigs(cfg *config.Config) map[string]PathConfig {
+	paths := make(map[string]PathConfig)
+	for i := range cfg.Cameras {
+		cam := &cfg.Cameras[i]
+		for j := range cam.Streams {
+			stream := &cam.Streams[j]
+			paths[fmt.Sprintf("%s/%s", cam.Name, stream.Path)] = PathConfig{
+				RunOnDemand:           BuildFFmpegCommand(cam, stream, &cfg.Server.Mediamtx),
+				RunOnDemandRestart:    true,
+				RunOnDemandCloseAfter: "60s",
+			}
+		}
+	}
+	return paths
+}
+
+// sortedPathNames returns the path names in a stable order
+func sortedPathNames(paths map[string]PathConfig) []string {
+	names := make([]string, 0, len(paths))
+	for name := range paths {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // addPath adds a new path
 func (c *Client) addPath(name string, cfg PathConfig) error {
 	url := fmt.Sprintf("%s/v3/config/paths/add/%s", c.baseURL, name)
@@ -124,8 +181,8 @@ func (c *Client) addPath(name string, cfg PathConfig) error {
 	return nil
 }
 
-// deletePath deletes a path
-func (c *Client) deletePath(name string) error {
+// DeletePath deletes a path
+func (c *Client) DeletePath(name string) error {
 	url := fmt.Sprintf("%s/v3/config/paths/delete/%s", c.baseURL, name)
 
 	req, err := http.NewRequest(http.MethodDelete, url, nil)
@@ -0,0 +1,529 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/camera"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/config"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/eventbus"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/webhook"
+)
+
+const (
+	// stateInterval is how often tracking and IR state are read from the cameras
+	stateInterval = 60 * time.Second
+	// motionHoldTime is how long the motion state stays ON after an alarm
+	motionHoldTime = 30 * time.Second
+	// publishTimeout bounds how long a publish waits for the broker
+	publishTimeout = 5 * time.Second
+)
+
+// Move directions accepted on the move command topic
+const (
+	MoveLeft  = "left"
+	MoveRight = "right"
+	MoveUp    = "up"
+	MoveDown  = "down"
+	MoveHome  = "home"
+)
+
+// Commander executes PTZ commands received over MQTT.
+// It is implemented by the ONVIF server so that MQTT moves go through the PTZ service.
+type Commander interface {
+	// Move moves the camera one step in a direction, or to its home position
+	Move(cam *camera.Camera, direction string) error
+	// GotoPreset recalls a PTZ preset (position, tracking or MQTT action)
+	GotoPreset(cam *camera.Camera, presetToken string) error
+}
+
+// Bridge is a long-lived MQTT connection publishing camera state and
+// receiving commands, with Home Assistant discovery.
+type Bridge struct {
+	cfg       config.MQTTConfig
+	registry  *camera.Registry
+	commander Commander
+	events    *eventbus.Subscription
+	client    mqtt.Client
+
+	mu              sync.Mutex
+	positions       map[string]string   // Last published position payload per camera
+	discoveryTopics map[string][]string // Published discovery topics per camera
+
+	// motionMu is held while motion state is published, so that ON and OFF of a camera
+	// cannot be reordered
+	motionMu    sync.Mutex
+	motionHolds map[string]*motionHold // Pending motion OFF per camera
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewBridge creates a new MQTT bridge
+func NewBridge(cfg config.MQTTConfig, registry *camera.Registry, bus *eventbus.Bus, commander Commander) *Bridge {
+	b := &Bridge{
+		cfg:             cfg,
+		registry:        registry,
+		commander:       commander,
+		events:          bus.Subscribe(32),
+		positions:       make(map[string]string),
+		motionHolds:     make(map[string]*motionHold),
+		discoveryTopics: make(map[string][]string),
+		done:            make(chan struct{}),
+	}
+
+	opts := mqtt.NewClientOptions()
+	opts.AddBroker(cfg.Broker)
+	opts.SetClientID(cfg.ClientID)
+	opts.SetUsername(cfg.Username)
+	opts.SetPassword(cfg.Password)
+	opts.SetConnectTimeout(5 * time.Second)
+	opts.SetAutoReconnect(true)
+	opts.SetConnectRetry(true)
+	opts.SetOrderMatters(false)
+	opts.SetWill(b.bridgeAvailabilityTopic(), "offline", 1, true)
+	opts.SetOnConnectHandler(b.onConnect)
+	opts.SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+		log.Printf("MQTT bridge: connection lost: %v", err)
+	})
+	b.client = mqtt.NewClient(opts)
+
+	return b
+}
+
+// Start connects to the broker and starts publishing.
+// Connection failures are retried in the background.
+func (b *Bridge) Start() {
+	log.Printf("MQTT bridge: connecting to %s", b.cfg.Broker)
+	b.client.Connect()
+
+	b.wg.Add(2)
+	go b.runEvents()
+	go b.runPolling()
+}
+
+// Stop publishes the bridge as offline and disconnects
+func (b *Bridge) Stop() {
+	close(b.done)
+	b.events.Close()
+	b.wg.Wait()
+
+	b.motionMu.Lock()
+	for _, hold := range b.motionHolds {
+		hold.timer.Stop()
+	}
+	b.motionMu.Unlock()
+
+	if b.client.IsConnected() {
+		b.publish(b.bridgeAvailabilityTopic(), true, "offline")
+	}
+	b.client.Disconnect(250)
+	log.Printf("MQTT bridge stopped")
+}
+
+// onConnect publishes discovery and current state, and subscribes to command topics.
+// It runs again after every reconnect so that retained state is always fresh.
+func (b *Bridge) onConnect(client mqtt.Client) {
+	log.Printf("MQTT bridge: connected to %s", b.cfg.Broker)
+
+	commandTopic := b.cfg.TopicPrefix + "/+/+/set"
+	if token := client.Subscribe(commandTopic, 1, b.onCommand); token.WaitTimeout(publishTimeout) && token.Error() != nil {
+		log.Printf("MQTT bridge: failed to subscribe to %s: %v", commandTopic, token.Error())
+	}
+
+	b.publish(b.bridgeAvailabilityTopic(), true, "online")
+
+	b.mu.Lock()
+	b.positions = make(map[string]string)
+	b.mu.Unlock()
+
+	for _, cam := range b.registry.List() {
+		b.publishCamera(cam)
+	}
+}
+
+// publishCamera publishes the discovery payloads and current state of a camera.
+// Discovery topics published earlier but no longer present (e.g. removed presets) are cleared.
+func (b *Bridge) publishCamera(cam *camera.Camera) {
+	messages := b.discoveryMessages(cam)
+	topics := make([]string, 0, len(messages))
+	for _, msg := range messages {
+		b.publish(msg.topic, true, msg.payload)
+		topics = append(topics, msg.topic)
+	}
+	b.clearDiscovery(cam.Config.Name, topics)
+
+	b.publishAvailability(cam.Config.Name, cam.GetHealth())
+	b.publish(b.stateTopic(cam.Config.Name, "motion"), true, "OFF")
+	b.publishPosition(cam)
+	b.publishDeviceState(cam)
+	b.publishSnapshot(cam)
+}
+
+// clearDiscovery removes the previously published discovery topics of a camera that are
+// not in keep, and records keep as the camera's current discovery topics
+func (b *Bridge) clearDiscovery(cameraName string, keep []string) {
+	b.mu.Lock()
+	previous := b.discoveryTopics[cameraName]
+	if len(keep) > 0 {
+		b.discoveryTopics[cameraName] = keep
+	} else {
+		delete(b.discoveryTopics, cameraName)
+	}
+	b.mu.Unlock()
+
+	kept := make(map[string]bool, len(keep))
+	for _, topic := range keep {
+		kept[topic] = true
+	}
+	for _, topic := range previous {
+		if !kept[topic] {
+			// An empty retained payload removes the entity from Home Assistant
+			b.publish(topic, true, "")
+		}
+	}
+}
+
+// runEvents publishes health changes, configuration changes, PTZ positions, alarms and uploaded images
+// from the event bus
+func (b *Bridge) runEvents() {
+	defer b.wg.Done()
+
+	for event := range b.events.Events() {
+		switch e := event.(type) {
+		case camera.HealthEvent:
+			b.publishAvailability(e.Camera, e.Healthy)
+		case camera.ConfigChangedEvent:
+			if !b.client.IsConnectionOpen() {
+				continue
+			}
+			if e.Removed {
+				b.clearDiscovery(e.Camera, nil)
+				b.publishAvailability(e.Camera, false)
+				continue
+			}
+			if cam, err := b.registry.Get(e.Camera); err == nil {
+				b.publishCamera(cam)
+			}
+		case camera.PositionEvent:
+			if b.client.IsConnectionOpen() {
+				b.publishPositionState(e.Camera, e.Pan, e.Tilt)
+			}
+		case webhook.AlarmEvent:
+			b.motion(e.Camera)
+		case webhook.MediaUploadEvent:
+			if strings.HasPrefix(e.ContentType, "image/") {
+				b.publish(b.stateTopic(e.Camera, "snapshot"), true, e.Data)
+			}
+		}
+	}
+}
+
+// runPolling periodically publishes tracking and IR state
+func (b *Bridge) runPolling() {
+	defer b.wg.Done()
+
+	stateTicker := time.NewTicker(stateInterval)
+	defer stateTicker.Stop()
+
+	for {
+		select {
+		case <-b.done:
+			return
+		case <-stateTicker.C:
+			if !b.client.IsConnectionOpen() {
+				continue
+			}
+			for _, cam := range b.registry.List() {
+				if cam.GetHealth() {
+					b.publishDeviceState(cam)
+				}
+			}
+		}
+	}
+}
+
+// motionHold is the pending motion OFF of a camera
+type motionHold struct {
+	timer *time.Timer
+	gen   uint64 // Incremented by each alarm; only the timer of the latest alarm publishes OFF
+}
+
+// motion publishes motion ON and schedules OFF after the hold time.
+// Repeated alarms extend the hold time.
+func (b *Bridge) motion(cameraName string) {
+	b.motionMu.Lock()
+	defer b.motionMu.Unlock()
+
+	b.publish(b.stateTopic(cameraName, "motion"), true, "ON")
+
+	hold, ok := b.motionHolds[cameraName]
+	if ok {
+		hold.timer.Stop()
+	} else {
+		hold = &motionHold{}
+		b.motionHolds[cameraName] = hold
+
+		// Refresh the camera entity with the scene that triggered the alarm
+		if cam, err := b.registry.Get(cameraName); err == nil {
+			go b.publishSnapshot(cam)
+		}
+	}
+	hold.gen++
+	gen := hold.gen
+	hold.timer = time.AfterFunc(motionHoldTime, func() {
+		b.motionOff(cameraName, hold, gen)
+	})
+}
+
+// motionOff publishes motion OFF when the hold of an alarm expires. A timer that fired
+// while a newer alarm was extending the hold finds a newer generation and does nothing.
+func (b *Bridge) motionOff(cameraName string, hold *motionHold, gen uint64) {
+	b.motionMu.Lock()
+	defer b.motionMu.Unlock()
+
+	if b.motionHolds[cameraName] != hold || hold.gen != gen {
+		return
+	}
+	delete(b.motionHolds, cameraName)
+	b.publish(b.stateTopic(cameraName, "motion"), true, "OFF")
+}
+
+// onCommand handles messages on {prefix}/{camera}/{command}/set
+func (b *Bridge) onCommand(_ mqtt.Client, msg mqtt.Message) {
+	if err := b.handleCommand(msg.Topic(), strings.TrimSpace(string(msg.Payload()))); err != nil {
+		log.Printf("MQTT bridge: command %s failed: %v", msg.Topic(), err)
+	}
+}
+
+// handleCommand executes a command received on a command topic
+func (b *Bridge) handleCommand(topic, payload string) error {
+	parts := strings.Split(strings.TrimPrefix(topic, b.cfg.TopicPrefix+"/"), "/")
+	if len(parts) != 3 || parts[2] != "set" {
+		return fmt.Errorf("invalid command topic")
+	}
+
+	cam, err := b.registry.Get(parts[0])
+	if err != nil {
+		return err
+	}
+
+	log.Printf("MQTT bridge: %s command for %s: %s", parts[1], cam.Config.Name, payload)
+
+	switch parts[1] {
+	case "move":
+		if !cam.Config.Capabilities.PTZ {
+			return fmt.Errorf("camera does not support PTZ: %s", cam.Config.Name)
+		}
+		direction := strings.ToLower(payload)
+		switch direction {
+		case MoveLeft, MoveRight, MoveUp, MoveDown, MoveHome:
+		default:
+			return fmt.Errorf("invalid move direction: %s", payload)
+		}
+		if err := b.commander.Move(cam, direction); err != nil {
+			return err
+		}
+		b.publishPosition(cam)
+		return nil
+
+	case "preset":
+		if !cam.Config.Capabilities.PTZ {
+			return fmt.Errorf("camera does not support PTZ: %s", cam.Config.Name)
+		}
+		if err := b.commander.GotoPreset(cam, payload); err != nil {
+			return err
+		}
+		b.publishPosition(cam)
+		// Tracking action presets change the tracking state
+		b.publishTracking(cam)
+		return nil
+
+	case "ir":
+		if !cam.Config.Capabilities.IR {
+			return fmt.Errorf("camera does not support IR: %s", cam.Config.Name)
+		}
+		mode, ok := irCutFilterModes[strings.ToLower(payload)]
+		if !ok {
+			return fmt.Errorf("invalid IR mode: %s (must be auto, on or off)", payload)
+		}
+		if err := cam.Client.SetIRCutFilter(mode); err != nil {
+			return err
+		}
+		b.publish(b.stateTopic(cam.Config.Name, "ir"), true, strings.ToLower(payload))
+		return nil
+
+	case "tracking":
+		if !cam.Config.Capabilities.PTZ {
+			return fmt.Errorf("camera does not support PTZ: %s", cam.Config.Name)
+		}
+		var enabled bool
+		switch strings.ToUpper(payload) {
+		case "ON":
+			enabled = true
+		case "OFF":
+			enabled = false
+		default:
+			return fmt.Errorf("invalid tracking state: %s (must be ON or OFF)", payload)
+		}
+		if err := cam.Client.SetTracking(enabled); err != nil {
+			return err
+		}
+		b.publish(b.stateTopic(cam.Config.Name, "tracking"), true, onOff(enabled))
+		return nil
+
+	default:
+		return fmt.Errorf("unknown command: %s", parts[1])
+	}
+}
+
+// irCutFilterModes maps MQTT IR modes (night vision on/off/auto) to IR cut filter modes
+var irCutFilterModes = map[string]string{
+	"auto": "AUTO",
+	"on":   "OFF", // IR on = night mode = IR cut filter off
+	"off":  "ON",
+}
+
+// publishAvailability publishes whether a camera is reachable
+func (b *Bridge) publishAvailability(cameraName string, healthy bool) {
+	availability := "offline"
+	if healthy {
+		availability = "online"
+	}
+	b.publish(b.stateTopic(cameraName, "availability"), true, availability)
+}
+
+// publishPosition publishes the cached PTZ position if it changed
+func (b *Bridge) publishPosition(cam *camera.Camera) {
+	if !cam.Config.Capabilities.PTZ {
+		return
+	}
+
+	pan, tilt := cam.GetPTZPosition()
+	b.publishPositionState(cam.Config.Name, pan, tilt)
+}
+
+// publishPositionState publishes a PTZ position if it differs from the last one published
+func (b *Bridge) publishPositionState(cameraName string, pan, tilt int) {
+	data, err := json.Marshal(struct {
+		Pan  int `json:"pan"`
+		Tilt int `json:"tilt"`
+	}{pan, tilt})
+	if err != nil {
+		return
+	}
+
+	b.mu.Lock()
+	changed := b.positions[cameraName] != string(data)
+	b.positions[cameraName] = string(data)
+	b.mu.Unlock()
+
+	if changed {
+		b.publish(b.stateTopic(cameraName, "position"), true, data)
+	}
+}
+
+// publishDeviceState reads and publishes tracking and IR state
+func (b *Bridge) publishDeviceState(cam *camera.Camera) {
+	b.publishTracking(cam)
+
+	if cam.Config.Capabilities.IR {
+		nightVision, err := cam.Client.GetProperty("nightVision")
+		if err != nil {
+			log.Printf("MQTT bridge: failed to read IR mode of %s: %v", cam.Config.Name, err)
+			return
+		}
+		if _, ok := irCutFilterModes[nightVision]; ok {
+			b.publish(b.stateTopic(cam.Config.Name, "ir"), true, nightVision)
+		}
+	}
+}
+
+// publishTracking reads and publishes the motion tracking state
+func (b *Bridge) publishTracking(cam *camera.Camera) {
+	if !cam.Config.Capabilities.PTZ {
+		return
+	}
+
+	tracking, err := cam.Client.GetProperty("tracking")
+	if err != nil {
+		log.Printf("MQTT bridge: failed to read tracking state of %s: %v", cam.Config.Name, err)
+		return
+	}
+	b.publish(b.stateTopic(cam.Config.Name, "tracking"), true, onOff(tracking == "on"))
+}
+
+// publishSnapshot publishes a JPEG snapshot for the Home Assistant camera entity
+func (b *Bridge) publishSnapshot(cam *camera.Camera) {
+	if !cam.GetHealth() {
+		return
+	}
+
+	snap, err := cam.Snapshot()
+	if err != nil {
+		log.Printf("MQTT bridge: failed to get snapshot of %s: %v", cam.Config.Name, err)
+		return
+	}
+	b.publish(b.stateTopic(cam.Config.Name, "snapshot"), true, snap.Data)
+}
+
+// Broker returns the URL of the broker the bridge is connected to
+func (b *Bridge) Broker() string {
+	return b.cfg.Broker
+}
+
+// PublishMessage publishes a non-retained message over the bridge's connection, e.g. for
+// an MQTT action preset
+func (b *Bridge) PublishMessage(topic, message string) error {
+	if !b.client.IsConnectionOpen() {
+		return fmt.Errorf("not connected to MQTT broker %s", b.cfg.Broker)
+	}
+	token := b.client.Publish(topic, 0, false, message)
+	if !token.WaitTimeout(publishTimeout) {
+		return fmt.Errorf("publish to %s timed out", topic)
+	}
+	if err := token.Error(); err != nil {
+		return fmt.Errorf("failed to publish MQTT message: %w", err)
+	}
+	return nil
+}
+
+// publish publishes a message and waits for the broker to accept it
+func (b *Bridge) publish(topic string, retained bool, payload interface{}) {
+	token := b.client.Publish(topic, 0, retained, payload)
+	if !token.WaitTimeout(publishTimeout) {
+		log.Printf("MQTT bridge: publish to %s timed out", topic)
+		return
+	}
+	if err := token.Error(); err != nil {
+		log.Printf("MQTT bridge: failed to publish to %s: %v", topic, err)
+	}
+}
+
+// stateTopic returns {prefix}/{camera}/{name}
+func (b *Bridge) stateTopic(cameraName, name string) string {
+	return fmt.Sprintf("%s/%s/%s", b.cfg.TopicPrefix, cameraName, name)
+}
+
+// commandTopic returns {prefix}/{camera}/{name}/set
+func (b *Bridge) commandTopic(cameraName, name string) string {
+	return b.stateTopic(cameraName, name) + "/set"
+}
+
+// bridgeAvailabilityTopic returns the topic of the relay's own availability (LWT)
+func (b *Bridge) bridgeAvailabilityTopic() string {
+	return b.cfg.TopicPrefix + "/availability"
+}
+
+func onOff(on bool) string {
+	if on {
+		return "ON"
+	}
+	return "OFF"
+}
@@ -0,0 +1,240 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/camera"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/config"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/eventbus"
+)
+
+// fakeClient records published messages
+type fakeClient struct {
+	mqtt.Client
+	mu        sync.Mutex
+	published map[string]string
+}
+
+func (c *fakeClient) Publish(topic string, qos byte, retained bool, payload interface{}) mqtt.Token {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch p := payload.(type) {
+	case string:
+		c.published[topic] = p
+	case []byte:
+		c.published[topic] = string(p)
+	}
+	return &fakeToken{}
+}
+
+// fakeToken is an already completed MQTT token
+type fakeToken struct{}
+
+func (t *fakeToken) Wait() bool                     { return true }
+func (t *fakeToken) WaitTimeout(time.Duration) bool { return true }
+func (t *fakeToken) Done() <-chan struct{} {
+	done := make(chan struct{})
+	close(done)
+	return done
+}
+func (t *fakeToken) Error() error { return nil }
+
+// fakeCommander records PTZ commands
+type fakeCommander struct {
+	moves []string
+}
+
+func (c *fakeCommander) Move(cam *camera.Camera, direction string) error {
+	c.moves = append(c.moves, cam.Config.Name+":"+direction)
+	return nil
+}
+
+func (c *fakeCommander) GotoPreset(cam *camera.Camera, presetToken string) error {
+	c.moves = append(c.moves, cam.Config.Name+":preset:"+presetToken)
+	return nil
+}
+
+func newTestBridge(t *testing.T) (*Bridge, *fakeClient, *fakeCommander, *[]string, func()) {
+	t.Helper()
+
+	var commands []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request camera.CommandRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		commands = append(commands, request.Exec)
+		fmt.Fprintln(w, "ok")
+	}))
+
+	host, portString, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		server.Close()
+		t.Fatalf("failed to split test server address: %v", err)
+	}
+	var port int
+	if _, err := fmt.Sscanf(portString, "%d", &port); err != nil {
+		server.Close()
+		t.Fatalf("failed to parse test server port: %v", err)
+	}
+
+	registry, err := camera.NewRegistry(&config.Config{Cameras: []config.CameraConfig{
+		{
+			Name:         "garage",
+			Host:         host,
+			HTTPPort:     port,
+			Capabilities: config.CapabilitiesConfig{PTZ: true, IR: true},
+			Streams:      []config.StreamConfig{{ProfileName: "Garage_Main"}},
+			PTZ: config.PTZConfig{Presets: []config.PTZPreset{
+				{Name: "Door", Pan: 100, Tilt: 90},
+				{Name: "Tracking On", Token: "tracking-on", Tracking: "on"},
+			}},
+		},
+	}})
+	if err != nil {
+		server.Close()
+		t.Fatalf("failed to create registry: %v", err)
+	}
+
+	client := &fakeClient{published: make(map[string]string)}
+	commander := &fakeCommander{}
+	bridge := &Bridge{
+		cfg:             config.MQTTConfig{TopicPrefix: "atomcam", DiscoveryPrefix: "homeassistant"},
+		registry:        registry,
+		commander:       commander,
+		events:          eventbus.New().Subscribe(1),
+		client:          client,
+		positions:       make(map[string]string),
+		motionHolds:     make(map[string]*motionHold),
+		discoveryTopics: make(map[string][]string),
+		done:            make(chan struct{}),
+	}
+
+	return bridge, client, commander, &commands, func() {
+		registry.Close()
+		server.Close()
+	}
+}
+
+func TestDiscoveryMessages(t *testing.T) {
+	bridge, _, _, _, closeBridge := newTestBridge(t)
+	defer closeBridge()
+
+	cam, _ := bridge.registry.Get("garage")
+	configs := make(map[string]discoveryConfig)
+	for _, msg := range bridge.discoveryMessages(cam) {
+		var cfg discoveryConfig
+		if err := json.Unmarshal(msg.payload, &cfg); err != nil {
+			t.Fatalf("invalid discovery payload for %s: %v", msg.topic, err)
+		}
+		configs[msg.topic] = cfg
+	}
+
+	tracking, ok := configs["homeassistant/switch/atomcam_garage/tracking/config"]
+	if !ok {
+		t.Fatalf("tracking switch not advertised; topics: %v", configs)
+	}
+	if tracking.CommandTopic != "atomcam/garage/tracking/set" || tracking.StateTopic != "atomcam/garage/tracking" {
+		t.Fatalf("tracking switch = %+v, want atomcam/garage/tracking topics", tracking)
+	}
+	if len(tracking.Availability) != 2 || tracking.Device.Identifiers[0] != "atomcam_garage" {
+		t.Fatalf("tracking switch = %+v, want bridge and camera availability on device atomcam_garage", tracking)
+	}
+
+	preset, ok := configs["homeassistant/button/atomcam_garage/preset_1/config"]
+	if !ok || preset.PayloadPress != "1" || preset.CommandTopic != "atomcam/garage/preset/set" {
+		t.Fatalf("preset button = %+v (found %v), want payload 1 on atomcam/garage/preset/set", preset, ok)
+	}
+
+	for _, topic := range []string{
+		"homeassistant/camera/atomcam_garage/snapshot/config",
+		"homeassistant/binary_sensor/atomcam_garage/motion/config",
+		"homeassistant/select/atomcam_garage/ir/config",
+		"homeassistant/button/atomcam_garage/move_left/config",
+		"homeassistant/button/atomcam_garage/preset_tracking-on/config",
+	} {
+		if _, ok := configs[topic]; !ok {
+			t.Fatalf("discovery topic %s missing", topic)
+		}
+	}
+}
+
+func TestHandleCommand(t *testing.T) {
+	bridge, client, commander, commands, closeBridge := newTestBridge(t)
+	defer closeBridge()
+
+	if err := bridge.handleCommand("atomcam/garage/tracking/set", "ON"); err != nil {
+		t.Fatalf("tracking command returned an error: %v", err)
+	}
+	if len(*commands) != 1 || (*commands)[0] != "property tracking on" {
+		t.Fatalf("camera commands = %v, want [property tracking on]", *commands)
+	}
+	if got := client.published["atomcam/garage/tracking"]; got != "ON" {
+		t.Fatalf("tracking state = %q, want ON", got)
+	}
+
+	if err := bridge.handleCommand("atomcam/garage/ir/set", "on"); err != nil {
+		t.Fatalf("ir command returned an error: %v", err)
+	}
+	if got := client.published["atomcam/garage/ir"]; got != "on" {
+		t.Fatalf("ir state = %q, want on", got)
+	}
+
+	if err := bridge.handleCommand("atomcam/garage/move/set", "Left"); err != nil {
+		t.Fatalf("move command returned an error: %v", err)
+	}
+	if len(commander.moves) != 1 || commander.moves[0] != "garage:left" {
+		t.Fatalf("moves = %v, want [garage:left]", commander.moves)
+	}
+
+	if err := bridge.handleCommand("atomcam/garage/move/set", "sideways"); err == nil {
+		t.Fatal("invalid move direction was accepted")
+	}
+	if err := bridge.handleCommand("atomcam/unknown/move/set", "left"); err == nil {
+		t.Fatal("command for unknown camera was accepted")
+	}
+}
+
+func TestMotionIgnoresExpiredHoldOfEarlierAlarm(t *testing.T) {
+	bridge, client, _, _, closeBridge := newTestBridge(t)
+	defer closeBridge()
+
+	topic := "atomcam/garage/motion"
+	state := func() string {
+		client.mu.Lock()
+		defer client.mu.Unlock()
+		return client.published[topic]
+	}
+
+	bridge.motion("garage")
+	bridge.motionMu.Lock()
+	first := bridge.motionHolds["garage"]
+	firstGen := first.gen
+	bridge.motionMu.Unlock()
+
+	// The first hold expires while a second alarm extends it: its timer must not end the motion
+	bridge.motion("garage")
+	bridge.motionOff("garage", first, firstGen)
+	if got := state(); got != "ON" {
+		t.Fatalf("motion = %q after the first hold expired, want ON", got)
+	}
+
+	bridge.motionMu.Lock()
+	gen := bridge.motionHolds["garage"].gen
+	bridge.motionHolds["garage"].timer.Stop()
+	bridge.motionMu.Unlock()
+	bridge.motionOff("garage", first, gen)
+	if got := state(); got != "OFF" {
+		t.Fatalf("motion = %q after the latest hold expired, want OFF", got)
+	}
+}
@@ -0,0 +1,171 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/camera"
+)
+
+// discoveryMessage is a retained Home Assistant MQTT discovery payload
+type discoveryMessage struct {
+	topic   string
+	payload []byte
+}
+
+// discoveryDevice groups the entities of one camera into a Home Assistant device
+type discoveryDevice struct {
+	Identifiers      []string `json:"identifiers"`
+	Name             string   `json:"name"`
+	Manufacturer     string   `json:"manufacturer"`
+	Model            string   `json:"model"`
+	ConfigurationURL string   `json:"configuration_url,omitempty"`
+}
+
+// discoveryAvailability is an availability topic of a Home Assistant entity
+type discoveryAvailability struct {
+	Topic string `json:"topic"`
+}
+
+// discoveryConfig is the configuration of a Home Assistant MQTT entity
+type discoveryConfig struct {
+	Name              string                  `json:"name"`
+	UniqueID          string                  `json:"unique_id"`
+	Device            discoveryDevice         `json:"device"`
+	Availability      []discoveryAvailability `json:"availability"`
+	AvailabilityMode  string                  `json:"availability_mode"`
+	Icon              string                  `json:"icon,omitempty"`
+	DeviceClass       string                  `json:"device_class,omitempty"`
+	Topic             string                  `json:"topic,omitempty"`
+	StateTopic        string                  `json:"state_topic,omitempty"`
+	CommandTopic      string                  `json:"command_topic,omitempty"`
+	ValueTemplate     string                  `json:"value_template,omitempty"`
+	UnitOfMeasurement string                  `json:"unit_of_measurement,omitempty"`
+	PayloadOn         string                  `json:"payload_on,omitempty"`
+	PayloadOff        string                  `json:"payload_off,omitempty"`
+	PayloadPress      string                  `json:"payload_press,omitempty"`
+	Options           []string                `json:"options,omitempty"`
+}
+
+// discoveryMessages builds the Home Assistant discovery payloads of a camera:
+// a camera entity (snapshot), motion sensor, IR mode select, and for PTZ cameras
+// a tracking switch, move/home/preset buttons and pan/tilt sensors.
+func (b *Bridge) discoveryMessages(cam *camera.Camera) []discoveryMessage {
+	name := cam.Config.Name
+	nodeID := "atomcam_" + name
+
+	device := discoveryDevice{
+		Identifiers:      []string{nodeID},
+		Name:             name,
+		Manufacturer:     "ATOM tech",
+		Model:            "AtomCam",
+		ConfigurationURL: fmt.Sprintf("http://%s:%d/", cam.Config.Host, cam.Config.HTTPPort),
+	}
+	availability := []discoveryAvailability{
+		{Topic: b.bridgeAvailabilityTopic()},
+		{Topic: b.stateTopic(name, "availability")},
+	}
+
+	var messages []discoveryMessage
+	add := func(component, objectID string, entity discoveryConfig) {
+		entity.UniqueID = nodeID + "_" + objectID
+		entity.Device = device
+		entity.Availability = availability
+		entity.AvailabilityMode = "all"
+
+		payload, err := json.Marshal(entity)
+		if err != nil {
+			log.Printf("MQTT bridge: failed to encode discovery for %s/%s: %v", name, objectID, err)
+			return
+		}
+		messages = append(messages, discoveryMessage{
+			topic:   fmt.Sprintf("%s/%s/%s/%s/config", b.cfg.DiscoveryPrefix, component, nodeID, objectID),
+			payload: payload,
+		})
+	}
+
+	add("camera", "snapshot", discoveryConfig{
+		Name:  "Snapshot",
+		Topic: b.stateTopic(name, "snapshot"),
+	})
+	add("binary_sensor", "motion", discoveryConfig{
+		Name:        "Motion",
+		DeviceClass: "motion",
+		StateTopic:  b.stateTopic(name, "motion"),
+		PayloadOn:   "ON",
+		PayloadOff:  "OFF",
+	})
+
+	if cam.Config.Capabilities.IR {
+		add("select", "ir", discoveryConfig{
+			Name:         "Night vision",
+			Icon:         "mdi:weather-night",
+			StateTopic:   b.stateTopic(name, "ir"),
+			CommandTopic: b.commandTopic(name, "ir"),
+			Options:      []string{"auto", "on", "off"},
+		})
+	}
+
+	if !cam.Config.Capabilities.PTZ {
+		return messages
+	}
+
+	add("switch", "tracking", discoveryConfig{
+		Name:         "Motion tracking",
+		Icon:         "mdi:target-account",
+		StateTopic:   b.stateTopic(name, "tracking"),
+		CommandTopic: b.commandTopic(name, "tracking"),
+		PayloadOn:    "ON",
+		PayloadOff:   "OFF",
+	})
+
+	for _, direction := range []string{MoveLeft, MoveRight, MoveUp, MoveDown, MoveHome} {
+		icon := "mdi:arrow-" + direction + "-bold"
+		if direction == MoveHome {
+			icon = "mdi:home"
+		}
+		add("button", "move_"+direction, discoveryConfig{
+			Name:         "Move " + direction,
+			Icon:         icon,
+			CommandTopic: b.commandTopic(name, "move"),
+			PayloadPress: direction,
+		})
+	}
+
+	for i, preset := range cam.Config.PTZ.Presets {
+		token := preset.Token
+		if token == "" {
+			token = fmt.Sprintf("%d", i+1)
+		}
+		add("button", "preset_"+discoveryObjectID(token), discoveryConfig{
+			Name:         "Preset " + preset.Name,
+			Icon:         "mdi:crosshairs-gps",
+			CommandTopic: b.commandTopic(name, "preset"),
+			PayloadPress: token,
+		})
+	}
+
+	for _, axis := range []string{"pan", "tilt"} {
+		add("sensor", axis, discoveryConfig{
+			Name:              strings.ToUpper(axis[:1]) + axis[1:],
+			Icon:              "mdi:axis-arrow",
+			StateTopic:        b.stateTopic(name, "position"),
+			ValueTemplate:     fmt.Sprintf("{{ value_json.%s }}", axis),
+			UnitOfMeasurement: "°",
+		})
+	}
+
+	return messages
+}
+
+// discoveryObjectID replaces characters not allowed in discovery object IDs
+func discoveryObjectID(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-' {
+			return r
+		}
+		return '_'
+	}, s)
+}
@@ -0,0 +1,361 @@
+package onvif
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/auth"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/camera"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/discovery"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/onvif/media"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/onvif/ptz"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/onvif/soap"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/pkg/digest"
+)
+
+// SOAP services, each with its own actions (GetServiceCapabilities, GetConfigurations, ...)
+const (
+	serviceDevice  = "Device"
+	serviceMedia   = "Media"
+	serviceMedia2  = "Media2"
+	servicePTZ     = "PTZ"
+	serviceImaging = "Imaging"
+	serviceEvents  = "Events" // Including requests to PullPoint subscriptions
+)
+
+// actionLevels maps the SOAP actions of each service to the minimum user level allowed to call them.
+// Actions not listed (user management, SetScopes, SystemReboot, ...) require the Administrator level.
+var actionLevels = map[string]map[string]auth.Level{
+	serviceDevice: {
+		// GetSystemDateAndTime is exempt from authentication per ONVIF spec
+		"GetSystemDateAndTime": auth.LevelAnonymous,
+		"GetDeviceInformation": auth.LevelUser,
+		"GetCapabilities":      auth.LevelUser,
+		"GetServices":          auth.LevelUser,
+		"GetScopes":            auth.LevelUser,
+		"GetHostname":          auth.LevelUser,
+		"GetNetworkInterfaces": auth.LevelUser,
+		"GetNTP":               auth.LevelUser,
+		"GetDNS":               auth.LevelUser,
+	},
+
+	serviceMedia: {
+		"GetProfiles":                         auth.LevelUser,
+		"GetVideoSources":                     auth.LevelUser,
+		"GetStreamUri":                        auth.LevelUser,
+		"GetSnapshotUri":                      auth.LevelUser,
+		"GetVideoEncoderConfigurations":       auth.LevelUser,
+		"GetVideoEncoderConfiguration":        auth.LevelUser,
+		"GetVideoEncoderConfigurationOptions": auth.LevelUser,
+		"SetVideoEncoderConfiguration":        auth.LevelOperator,
+		"GetAudioOutputs":                     auth.LevelUser,
+		"GetAudioOutputConfigurations":        auth.LevelUser,
+	},
+
+	serviceMedia2: {
+		"GetProfiles":                   auth.LevelUser,
+		"GetStreamUri":                  auth.LevelUser,
+		"GetSnapshotUri":                auth.LevelUser,
+		"GetVideoEncoderConfigurations": auth.LevelUser,
+		"GetVideoSourceConfigurations":  auth.LevelUser,
+	},
+
+	// Reading is allowed to users, moving requires an operator
+	servicePTZ: {
+		"GetServiceCapabilities":  auth.LevelUser,
+		"GetNodes":                auth.LevelUser,
+		"GetNode":                 auth.LevelUser,
+		"GetConfigurations":       auth.LevelUser,
+		"GetConfiguration":        auth.LevelUser,
+		"GetConfigurationOptions": auth.LevelUser,
+		"GetPresets":              auth.LevelUser,
+		"GetStatus":               auth.LevelUser,
+		"ContinuousMove":          auth.LevelOperator,
+		"Stop":                    auth.LevelOperator,
+		"GotoHomePosition":        auth.LevelOperator,
+		"SetHomePosition":         auth.LevelOperator,
+		"GotoPreset":              auth.LevelOperator,
+		"SetPreset":               auth.LevelOperator,
+		"RemovePreset":            auth.LevelOperator,
+		"GetPresetTours":          auth.LevelUser,
+		"GetPresetTour":           auth.LevelUser,
+		"GetPresetTourOptions":    auth.LevelUser,
+		"CreatePresetTour":        auth.LevelOperator,
+		"ModifyPresetTour":        auth.LevelOperator,
+		"OperatePresetTour":       auth.LevelOperator,
+		"RemovePresetTour":        auth.LevelOperator,
+		"AbsoluteMove":            auth.LevelOperator,
+		"RelativeMove":            auth.LevelOperator,
+		"MoveAndStartTracking":    auth.LevelOperator,
+		"SendAuxiliaryCommand":    auth.LevelOperator,
+	},
+
+	serviceImaging: {
+		"GetImagingSettings": auth.LevelUser,
+		"GetOptions":         auth.LevelUser,
+		"SetImagingSettings": auth.LevelOperator,
+	},
+
+	serviceEvents: {
+		"GetServiceCapabilities":      auth.LevelUser,
+		"GetEventProperties":          auth.LevelUser,
+		"CreatePullPointSubscription": auth.LevelUser,
+		"PullMessages":                auth.LevelUser,
+		"Renew":                       auth.LevelUser,
+		"Unsubscribe":                 auth.LevelUser,
+		"SetSynchronizationPoint":     auth.LevelUser,
+	},
+}
+
+// requiredLevel returns the minimum user level for a SOAP action of a service
+func requiredLevel(service, action string) auth.Level {
+	if level, ok := actionLevels[service][action]; ok {
+		return level
+	}
+	return auth.LevelAdministrator
+}
+
+// soapRealm is the HTTP authentication realm of SOAP requests without WS-Security
+const soapRealm = "ONVIF Relay"
+
+// authorize authenticates a SOAP request and checks that the caller may call the action
+// on every camera referenced by the request.
+// It sends a NotAuthorized fault and returns nil if the request is refused; callers without
+// usable credentials get HTTP 401 with Digest and Basic challenges.
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request, body []byte, service, action string) *auth.User {
+	user, httpAuth, err := s.authenticate(r, body)
+	if err != nil {
+		log.Printf("Authentication failed for %s: %v", action, err)
+		if httpAuth {
+			s.sendUnauthorized(w, r, errors.Is(err, digest.ErrStaleNonce))
+		} else {
+			s.sendFault(w, soap.NewNotAuthorizedFault())
+		}
+		return nil
+	}
+
+	if level := requiredLevel(service, action); !user.Allows(level) {
+		if user == auth.Anonymous {
+			log.Printf("Authentication failed for %s: no credentials", action)
+			s.sendUnauthorized(w, r, false)
+		} else {
+			log.Printf("User %s (%s) is not allowed to call %s %s (requires %s)", user.Username, user.Level, service, action, level)
+			s.sendFault(w, soap.NewNotAuthorizedFault())
+		}
+		return nil
+	}
+
+	for _, name := range s.requestCameras(body) {
+		if !user.CanAccess(name) {
+			log.Printf("User %s is not allowed to access camera %s (%s)", user.Username, name, action)
+			s.sendFault(w, soap.NewNotAuthorizedFault())
+			return nil
+		}
+	}
+
+	return user
+}
+
+// authenticate returns the user of a SOAP request from its WS-UsernameToken, or from
+// HTTP Digest/Basic credentials when the request has no WS-Security header (httpAuth is true).
+// Requests without any credentials are Anonymous.
+func (s *Server) authenticate(r *http.Request, body []byte) (user *auth.User, httpAuth bool, err error) {
+	var envelope soap.Envelope
+	if err := xml.Unmarshal(body, &envelope); err != nil {
+		return nil, false, fmt.Errorf("failed to parse SOAP envelope: %w", err)
+	}
+
+	if envelope.Header == nil || envelope.Header.Security == nil {
+		user, err := s.users.AuthenticateRequest(r, soapRealm)
+		return user, true, err
+	}
+
+	user, ok := s.users.Lookup(envelope.Header.Security.UsernameToken.Username)
+	if !ok {
+		return nil, false, fmt.Errorf("invalid credentials")
+	}
+	if err := soap.ValidateUsernameToken(envelope.Header.Security, user.Username, user.Password); err != nil {
+		return nil, false, err
+	}
+	return user, false, nil
+}
+
+// sendUnauthorized sends a NotAuthorized fault with HTTP 401 and authentication challenges
+func (s *Server) sendUnauthorized(w http.ResponseWriter, r *http.Request, stale bool) {
+	s.users.Challenge(w, r, soapRealm, stale)
+	s.sendFaultStatus(w, soap.NewNotAuthorizedFault(), http.StatusUnauthorized)
+}
+
+// profileTokenSuffixes make the tokens of the PTZ, video source and video encoder
+// configurations of a profile
+var profileTokenSuffixes = []string{"_PTZ", "_VSC", "_VEC"}
+
+// scopedTokens are token elements that only identify an entity within the profile of the request
+var scopedTokens = map[string]bool{
+	"PresetToken":     true,
+	"PresetTourToken": true,
+}
+
+// requestCameras returns the cameras referenced by the tokens of a request: the elements
+// named *Token and the token attributes of configurations.
+// Unknown tokens are ignored here; the services reject them.
+func (s *Server) requestCameras(body []byte) []string {
+	bodyContent, err := soap.GetBodyContent(body)
+	if err != nil {
+		return nil
+	}
+
+	var cameras []string
+	decoder := xml.NewDecoder(bytes.NewReader(bodyContent))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return cameras
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		for _, attr := range start.Attr {
+			if attr.Name.Local == "token" {
+				if name, ok := s.tokenCamera(attr.Value); ok {
+					cameras = append(cameras, name)
+				}
+			}
+		}
+		if !strings.HasSuffix(start.Name.Local, "Token") || scopedTokens[start.Name.Local] {
+			continue
+		}
+		var value string
+		if err := decoder.DecodeElement(&value, &start); err != nil {
+			return cameras
+		}
+		if name, ok := s.tokenCamera(strings.TrimSpace(value)); ok {
+			cameras = append(cameras, name)
+		}
+	}
+}
+
+// tokenCamera returns the camera an ONVIF token belongs to: a profile or one of its
+// configurations, or an entity of the camera (video source, PTZ node, audio output and
+// its configurations)
+func (s *Server) tokenCamera(token string) (string, bool) {
+	if token == "" {
+		return "", false
+	}
+	if profile, err := s.registry.GetProfileByToken(token); err == nil {
+		return profile.Camera.Config.Name, true
+	}
+	for _, suffix := range profileTokenSuffixes {
+		if profileToken, ok := strings.CutSuffix(token, suffix); ok {
+			if profile, err := s.registry.GetProfileByToken(profileToken); err == nil {
+				return profile.Camera.Config.Name, true
+			}
+		}
+	}
+	if cam, err := s.registry.GetByVideoSourceToken(token); err == nil {
+		return cam.Config.Name, true
+	}
+	for _, cam := range s.registry.List() {
+		switch token {
+		case cam.PTZNodeToken(), cam.AudioOutputToken(), media.AudioOutputConfigurationToken(cam), media.AudioDecoderConfigurationToken(cam):
+			return cam.Config.Name, true
+		}
+	}
+	return "", false
+}
+
+// userFault maps a user management error to the ONVIF fault defined for it
+func userFault(err error) *soap.Fault {
+	switch {
+	case errors.Is(err, auth.ErrUsernameClash):
+		return soap.NewNestedFault(soap.FaultCodeSender, soap.SubcodeOperationProhibited, "ter:UsernameClash", err.Error())
+	case errors.Is(err, auth.ErrUsernameTooLong):
+		return soap.NewNestedFault(soap.FaultCodeSender, soap.SubcodeOperationProhibited, "ter:UsernameTooLong", err.Error())
+	case errors.Is(err, auth.ErrPasswordTooLong):
+		return soap.NewNestedFault(soap.FaultCodeSender, soap.SubcodeOperationProhibited, "ter:PasswordTooLong", err.Error())
+	case errors.Is(err, auth.ErrPasswordTooWeak):
+		return soap.NewNestedFault(soap.FaultCodeSender, soap.SubcodeOperationProhibited, "ter:PasswordTooWeak", err.Error())
+	case errors.Is(err, auth.ErrAnonymousNotAllowed):
+		return soap.NewNestedFault(soap.FaultCodeSender, soap.SubcodeOperationProhibited, "ter:AnonymousNotAllowed", err.Error())
+	case errors.Is(err, auth.ErrTooManyUsers):
+		return soap.NewNestedFault(soap.FaultCodeReceiver, "ter:Action", "ter:TooManyUsers", err.Error())
+	case errors.Is(err, auth.ErrUsernameMissing):
+		return soap.NewNestedFault(soap.FaultCodeSender, soap.SubcodeInvalidArgVal, "ter:UsernameMissing", err.Error())
+	case errors.Is(err, auth.ErrFixedUser):
+		return soap.NewNestedFault(soap.FaultCodeSender, soap.SubcodeInvalidArgVal, "ter:FixedUser", err.Error())
+	}
+	return soap.NewInvalidArgsFault(err.Error())
+}
+
+// scopeFault maps a SetScopes error to the ONVIF fault defined for it
+func scopeFault(err error) *soap.Fault {
+	switch {
+	case errors.Is(err, discovery.ErrScopeOverwrite):
+		return soap.NewNestedFault(soap.FaultCodeSender, soap.SubcodeOperationProhibited, "ter:ScopeOverwrite", err.Error())
+	case errors.Is(err, discovery.ErrTooManyScopes):
+		return soap.NewNestedFault(soap.FaultCodeReceiver, "ter:Action", "ter:TooManyScopes", err.Error())
+	case errors.Is(err, discovery.ErrInvalidScope):
+		return soap.NewInvalidArgsFault(err.Error())
+	}
+	return soap.NewActionFailedFault(err.Error())
+}
+
+// mediaFault maps a media configuration error to the ONVIF fault defined for it
+func mediaFault(err error) *soap.Fault {
+	switch {
+	case errors.Is(err, media.ErrNoConfig):
+		return soap.NewNestedFault(soap.FaultCodeSender, soap.SubcodeInvalidArgVal, "ter:NoConfig", err.Error())
+	case errors.Is(err, media.ErrConfigModify):
+		return soap.NewNestedFault(soap.FaultCodeSender, soap.SubcodeInvalidArgVal, "ter:ConfigModify", err.Error())
+	}
+	return soap.NewActionFailedFault(err.Error())
+}
+
+// ptzFault maps a PTZ node, configuration or move error to the ONVIF fault defined for it.
+// A move refused because another client controls the camera, or dropped from the PTZ queue
+// before it ran, is OperationProhibited.
+func ptzFault(err error) *soap.Fault {
+	switch {
+	case errors.Is(err, ptz.ErrNoEntity):
+		return soap.NewNestedFault(soap.FaultCodeSender, soap.SubcodeInvalidArgVal, "ter:NoEntity", err.Error())
+	case errors.Is(err, ptz.ErrNoConfig):
+		return soap.NewNestedFault(soap.FaultCodeSender, soap.SubcodeInvalidArgVal, "ter:NoConfig", err.Error())
+	case errors.Is(err, camera.ErrNoGoZone):
+		return soap.NewNestedFault(soap.FaultCodeSender, soap.SubcodeInvalidArgVal, "ter:InvalidPosition", err.Error())
+	case errors.Is(err, ptz.ErrNoFOV):
+		return soap.NewNestedFault(soap.FaultCodeSender, soap.SubcodeInvalidArgVal, "ter:SpaceNotSupported", err.Error())
+	case errors.Is(err, camera.ErrPTZLocked), errors.Is(err, camera.ErrPTZSuperseded):
+		return soap.NewFault(soap.FaultCodeSender, soap.SubcodeOperationProhibited, err.Error())
+	}
+	return soap.NewActionFailedFault(err.Error())
+}
+
+// presetFault maps a PTZ preset or preset tour error to the ONVIF fault defined for it
+func presetFault(err error) *soap.Fault {
+	switch {
+	case errors.Is(err, ptz.ErrNoToken), errors.Is(err, ptz.ErrNoTour):
+		return soap.NewNestedFault(soap.FaultCodeSender, soap.SubcodeInvalidArgVal, "ter:NoToken", err.Error())
+	case errors.Is(err, ptz.ErrPresetExist):
+		return soap.NewNestedFault(soap.FaultCodeSender, soap.SubcodeInvalidArgVal, "ter:PresetExist", err.Error())
+	case errors.Is(err, ptz.ErrInvalidPresetName):
+		return soap.NewNestedFault(soap.FaultCodeSender, soap.SubcodeInvalidArgVal, "ter:InvalidPresetName", err.Error())
+	case errors.Is(err, ptz.ErrTooManyPresets):
+		return soap.NewNestedFault(soap.FaultCodeReceiver, "ter:Action", "ter:TooManyPresets", err.Error())
+	case errors.Is(err, ptz.ErrFixedPreset):
+		return soap.NewFault(soap.FaultCodeSender, soap.SubcodeOperationProhibited, err.Error())
+	case errors.Is(err, ptz.ErrInvalidTour), errors.Is(err, ptz.ErrUnsupportedTourOp):
+		return soap.NewNestedFault(soap.FaultCodeSender, soap.SubcodeInvalidArgVal, "ter:InvalidPresetTour", err.Error())
+	case errors.Is(err, ptz.ErrTooManyTours):
+		return soap.NewNestedFault(soap.FaultCodeReceiver, "ter:Action", "ter:TooManyPresetTours", err.Error())
+	case errors.Is(err, ptz.ErrTourActivation):
+		return soap.NewNestedFault(soap.FaultCodeReceiver, "ter:Action", "ter:ActivationFailed", err.Error())
+	}
+	return soap.NewActionFailedFault(err.Error())
+}
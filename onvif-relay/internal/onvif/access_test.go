@@ -0,0 +1,55 @@
+package onvif
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/auth"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/camera"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/config"
+)
+
+func TestRequestCamerasResolvesEveryTokenKind(t *testing.T) {
+	registry, err := camera.NewRegistry(&config.Config{Cameras: []config.CameraConfig{
+		{Name: "garage", Host: "192.168.1.20", HTTPPort: 80, Streams: []config.StreamConfig{{ProfileName: "garage_main"}}},
+		{Name: "porch", Host: "192.168.1.30", HTTPPort: 80, Streams: []config.StreamConfig{{ProfileName: "porch_main"}}},
+	}})
+	if err != nil {
+		t.Fatalf("failed to create registry: %v", err)
+	}
+	defer registry.Close()
+	s := &Server{registry: registry}
+
+	tests := []struct {
+		content string
+		want    []string
+	}{
+		{`<GetNode><NodeToken>porch_PTZNode</NodeToken></GetNode>`, []string{"porch"}},
+		{`<GetConfiguration><PTZConfigurationToken>porch_main_PTZ</PTZConfigurationToken></GetConfiguration>`, []string{"porch"}},
+		{`<GetVideoEncoderConfiguration><ConfigurationToken>garage_main_VEC</ConfigurationToken></GetVideoEncoderConfiguration>`, []string{"garage"}},
+		{`<SetVideoEncoderConfiguration><Configuration token="porch_main_VEC"/></SetVideoEncoderConfiguration>`, []string{"porch"}},
+		{`<GetImagingSettings><VideoSourceToken>porch_VideoSource</VideoSourceToken></GetImagingSettings>`, []string{"porch"}},
+		{`<GetAudioOutputConfiguration><ConfigurationToken>porch_AOC</ConfigurationToken></GetAudioOutputConfiguration>`, []string{"porch"}},
+		{`<GotoPreset><ProfileToken>garage_main</ProfileToken><PresetToken>porch_main</PresetToken></GotoPreset>`, []string{"garage"}},
+	}
+
+	for _, tt := range tests {
+		body := []byte(`<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope"><s:Body>` + tt.content + `</s:Body></s:Envelope>`)
+		if got := s.requestCameras(body); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Fatalf("requestCameras(%s) = %v, want %v", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestRequiredLevelIsPerService(t *testing.T) {
+	if got := requiredLevel(servicePTZ, "GetServiceCapabilities"); got != auth.LevelUser {
+		t.Fatalf("PTZ GetServiceCapabilities requires %s, want %s", got, auth.LevelUser)
+	}
+	// Media2 has no SetVideoEncoderConfiguration of its own; it is not allowed to operators
+	if got := requiredLevel(serviceMedia2, "SetVideoEncoderConfiguration"); got != auth.LevelAdministrator {
+		t.Fatalf("Media2 SetVideoEncoderConfiguration requires %s, want %s", got, auth.LevelAdministrator)
+	}
+	if got := requiredLevel(serviceDevice, "GetStatus"); got != auth.LevelAdministrator {
+		t.Fatalf("Device GetStatus requires %s, want %s", got, auth.LevelAdministrator)
+	}
+}
@@ -2,6 +2,7 @@ package device
 
 import (
 	"encoding/xml"
+	"strings"
 )
 
 // GetCapabilitiesRequest represents GetCapabilities request
@@ -22,6 +23,7 @@ type Capabilities struct {
 	Media   *MediaCapabilities   `xml:"tds:Media,omitempty"`
 	PTZ     *PTZCapabilities     `xml:"tds:PTZ,omitempty"`
 	Imaging *ImagingCapabilities `xml:"tds:Imaging,omitempty"`
+	Events  *EventsCapabilities  `xml:"tds:Events,omitempty"`
 }
 
 // DeviceCapabilities represents device service capabilities
@@ -88,8 +90,17 @@ type ImagingCapabilities struct {
 	XAddr string `xml:"tt:XAddr"`
 }
 
-// GetCapabilities handles GetCapabilities request
-func (s *Service) GetCapabilities(categories []string) *GetCapabilitiesResponse {
+// EventsCapabilities represents Events service capabilities
+type EventsCapabilities struct {
+	XAddr                                         string `xml:"tt:XAddr"`
+	WSSubscriptionPolicySupport                   bool   `xml:"tt:WSSubscriptionPolicySupport"`
+	WSPullPointSupport                            bool   `xml:"tt:WSPullPointSupport"`
+	WSPausableSubscriptionManagerInterfaceSupport bool   `xml:"tt:WSPausableSubscriptionManagerInterfaceSupport"`
+}
+
+// GetCapabilities handles GetCapabilities request; XAddrs are below baseURL, the relay URL
+// advertised to the client
+func (s *Service) GetCapabilities(categories []string, baseURL string) *GetCapabilitiesResponse {
 	resp := &GetCapabilitiesResponse{
 		Capabilities: Capabilities{},
 	}
@@ -103,7 +114,7 @@ func (s *Service) GetCapabilities(categories []string) *GetCapabilitiesResponse
 		switch cat {
 		case "All", "Device":
 			resp.Capabilities.Device = &DeviceCapabilities{
-				XAddr: s.baseURL + "/onvif/device_service",
+				XAddr: baseURL + "/onvif/device_service",
 				Network: &NetworkCapabilities{
 					IPFilter:          false,
 					ZeroConfiguration: false,
@@ -124,7 +135,7 @@ func (s *Service) GetCapabilities(categories []string) *GetCapabilitiesResponse
 				},
 				Security: &SecurityCapabilities{
 					TLS11:                false,
-					TLS12:                false,
+					TLS12:                strings.HasPrefix(baseURL, "https://"),
 					OnboardKeyGeneration: false,
 					AccessPolicyConfig:   false,
 				},
@@ -134,7 +145,7 @@ func (s *Service) GetCapabilities(categories []string) *GetCapabilitiesResponse
 		switch cat {
 		case "All", "Media":
 			resp.Capabilities.Media = &MediaCapabilities{
-				XAddr: s.baseURL + "/onvif/media_service",
+				XAddr: baseURL + "/onvif/media_service",
 				StreamingCapabilities: &StreamingCapabilities{
 					RTPMulticast: false,
 					RTP_TCP:      true,
@@ -146,14 +157,24 @@ func (s *Service) GetCapabilities(categories []string) *GetCapabilitiesResponse
 		switch cat {
 		case "All", "PTZ":
 			resp.Capabilities.PTZ = &PTZCapabilities{
-				XAddr: s.baseURL + "/onvif/ptz_service",
+				XAddr: baseURL + "/onvif/ptz_service",
 			}
 		}
 
 		switch cat {
 		case "All", "Imaging":
 			resp.Capabilities.Imaging = &ImagingCapabilities{
-				XAddr: s.baseURL + "/onvif/imaging_service",
+				XAddr: baseURL + "/onvif/imaging_service",
+			}
+		}
+
+		switch cat {
+		case "All", "Events":
+			resp.Capabilities.Events = &EventsCapabilities{
+				XAddr:                       baseURL + "/onvif/events_service",
+				WSSubscriptionPolicySupport: false,
+				WSPullPointSupport:          true,
+				WSPausableSubscriptionManagerInterfaceSupport: false,
 			}
 		}
 	}
@@ -0,0 +1,220 @@
+package device
+
+import (
+	"bufio"
+	"encoding/xml"
+	"io"
+	"net"
+	"os"
+	"strings"
+)
+
+// resolvConfPath is the resolver configuration reported by GetDNS
+const resolvConfPath = "/etc/resolv.conf"
+
+// GetHostnameRequest represents GetHostname request
+type GetHostnameRequest struct {
+	XMLName xml.Name `xml:"GetHostname"`
+}
+
+// GetHostnameResponse represents GetHostname response
+type GetHostnameResponse struct {
+	XMLName             xml.Name            `xml:"tds:GetHostnameResponse"`
+	HostnameInformation HostnameInformation `xml:"tds:HostnameInformation"`
+}
+
+// HostnameInformation represents the host name of the relay
+type HostnameInformation struct {
+	FromDHCP bool   `xml:"tt:FromDHCP"`
+	Name     string `xml:"tt:Name,omitempty"`
+}
+
+// GetNetworkInterfacesRequest represents GetNetworkInterfaces request
+type GetNetworkInterfacesRequest struct {
+	XMLName xml.Name `xml:"GetNetworkInterfaces"`
+}
+
+// GetNetworkInterfacesResponse represents GetNetworkInterfaces response
+type GetNetworkInterfacesResponse struct {
+	XMLName           xml.Name           `xml:"tds:GetNetworkInterfacesResponse"`
+	NetworkInterfaces []NetworkInterface `xml:"tds:NetworkInterfaces"`
+}
+
+// NetworkInterface represents a network interface of the relay host
+type NetworkInterface struct {
+	Token   string                `xml:"token,attr"`
+	Enabled bool                  `xml:"tt:Enabled"`
+	Info    NetworkInterfaceInfo  `xml:"tt:Info"`
+	IPv4    *IPv4NetworkInterface `xml:"tt:IPv4,omitempty"`
+}
+
+// NetworkInterfaceInfo represents the name, MAC address and MTU of an interface
+type NetworkInterfaceInfo struct {
+	Name      string `xml:"tt:Name"`
+	HwAddress string `xml:"tt:HwAddress"`
+	MTU       int    `xml:"tt:MTU"`
+}
+
+// IPv4NetworkInterface represents the IPv4 settings of an interface
+type IPv4NetworkInterface struct {
+	Enabled bool              `xml:"tt:Enabled"`
+	Config  IPv4Configuration `xml:"tt:Config"`
+}
+
+// IPv4Configuration represents the IPv4 addresses of an interface.
+// The relay does not know how the host obtained them, so they are reported as manual.
+type IPv4Configuration struct {
+	Manual []PrefixedIPv4Address `xml:"tt:Manual"`
+	DHCP   bool                  `xml:"tt:DHCP"`
+}
+
+// PrefixedIPv4Address represents an IPv4 address with its prefix length
+type PrefixedIPv4Address struct {
+	Address      string `xml:"tt:Address"`
+	PrefixLength int    `xml:"tt:PrefixLength"`
+}
+
+// GetNTPRequest represents GetNTP request
+type GetNTPRequest struct {
+	XMLName xml.Name `xml:"GetNTP"`
+}
+
+// GetNTPResponse represents GetNTP response
+type GetNTPResponse struct {
+	XMLName        xml.Name       `xml:"tds:GetNTPResponse"`
+	NTPInformation NTPInformation `xml:"tds:NTPInformation"`
+}
+
+// NTPInformation represents the NTP settings; the relay uses the host clock and has none of its own
+type NTPInformation struct {
+	FromDHCP bool `xml:"tt:FromDHCP"`
+}
+
+// GetDNSRequest represents GetDNS request
+type GetDNSRequest struct {
+	XMLName xml.Name `xml:"GetDNS"`
+}
+
+// GetDNSResponse represents GetDNS response
+type GetDNSResponse struct {
+	XMLName        xml.Name       `xml:"tds:GetDNSResponse"`
+	DNSInformation DNSInformation `xml:"tds:DNSInformation"`
+}
+
+// DNSInformation represents the resolver settings of the relay host
+type DNSInformation struct {
+	FromDHCP     bool        `xml:"tt:FromDHCP"`
+	SearchDomain []string    `xml:"tt:SearchDomain"`
+	DNSManual    []IPAddress `xml:"tt:DNSManual"`
+}
+
+// IPAddress represents an IPv4 or IPv6 address
+type IPAddress struct {
+	Type        string `xml:"tt:Type"`
+	IPv4Address string `xml:"tt:IPv4Address,omitempty"`
+	IPv6Address string `xml:"tt:IPv6Address,omitempty"`
+}
+
+// GetHostname handles GetHostname request
+func (s *Service) GetHostname() *GetHostnameResponse {
+	name, _ := os.Hostname()
+	return &GetHostnameResponse{
+		HostnameInformation: HostnameInformation{Name: name},
+	}
+}
+
+// GetNetworkInterfaces handles GetNetworkInterfaces request.
+// It reports the interfaces of the relay host that are up, excluding loopback.
+func (s *Service) GetNetworkInterfaces() (*GetNetworkInterfacesResponse, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &GetNetworkInterfacesResponse{}
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			return nil, err
+		}
+		resp.NetworkInterfaces = append(resp.NetworkInterfaces, networkInterface(iface, addrs))
+	}
+	return resp, nil
+}
+
+// networkInterface builds the GetNetworkInterfaces entry of a host interface
+func networkInterface(iface net.Interface, addrs []net.Addr) NetworkInterface {
+	ni := NetworkInterface{
+		Token:   iface.Name,
+		Enabled: true,
+		Info: NetworkInterfaceInfo{
+			Name:      iface.Name,
+			HwAddress: iface.HardwareAddr.String(),
+			MTU:       iface.MTU,
+		},
+	}
+
+	var manual []PrefixedIPv4Address
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.To4() == nil {
+			continue
+		}
+		prefix, _ := ipNet.Mask.Size()
+		manual = append(manual, PrefixedIPv4Address{Address: ipNet.IP.String(), PrefixLength: prefix})
+	}
+	if len(manual) > 0 {
+		ni.IPv4 = &IPv4NetworkInterface{Enabled: true, Config: IPv4Configuration{Manual: manual}}
+	}
+	return ni
+}
+
+// GetNTP handles GetNTP request
+func (s *Service) GetNTP() *GetNTPResponse {
+	return &GetNTPResponse{}
+}
+
+// GetDNS handles GetDNS request with the name servers and search domains of the relay host
+func (s *Service) GetDNS() *GetDNSResponse {
+	resp := &GetDNSResponse{}
+
+	f, err := os.Open(resolvConfPath)
+	if err != nil {
+		return resp
+	}
+	defer f.Close()
+
+	resp.DNSInformation = parseResolvConf(f)
+	return resp
+}
+
+// parseResolvConf reads the nameserver and search lines of a resolv.conf file
+func parseResolvConf(r io.Reader) DNSInformation {
+	var info DNSInformation
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+
+		switch fields[0] {
+		case "nameserver":
+			ip := net.ParseIP(fields[1])
+			if ip == nil {
+				continue
+			}
+			if ip.To4() != nil {
+				info.DNSManual = append(info.DNSManual, IPAddress{Type: "IPv4", IPv4Address: ip.String()})
+			} else {
+				info.DNSManual = append(info.DNSManual, IPAddress{Type: "IPv6", IPv6Address: ip.String()})
+			}
+		case "search", "domain":
+			info.SearchDomain = append(info.SearchDomain, fields[1:]...)
+		}
+	}
+	return info
+}
@@ -0,0 +1,50 @@
+package device
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseResolvConf(t *testing.T) {
+	info := parseResolvConf(strings.NewReader(`# Generated by Docker
+nameserver 192.168.1.1
+nameserver fd00::1
+nameserver invalid
+search lan home.arpa
+options ndots:0
+`))
+
+	want := []IPAddress{
+		{Type: "IPv4", IPv4Address: "192.168.1.1"},
+		{Type: "IPv6", IPv6Address: "fd00::1"},
+	}
+	if !reflect.DeepEqual(info.DNSManual, want) {
+		t.Fatalf("DNSManual = %+v, want %+v", info.DNSManual, want)
+	}
+	if !reflect.DeepEqual(info.SearchDomain, []string{"lan", "home.arpa"}) {
+		t.Fatalf("SearchDomain = %v", info.SearchDomain)
+	}
+}
+
+func TestNetworkInterface(t *testing.T) {
+	hw, _ := net.ParseMAC("02:42:ac:11:00:02")
+	iface := net.Interface{Name: "eth0", MTU: 1500, HardwareAddr: hw}
+	addrs := []net.Addr{
+		&net.IPNet{IP: net.ParseIP("192.168.1.20"), Mask: net.CIDRMask(24, 32)},
+		&net.IPNet{IP: net.ParseIP("fe80::1"), Mask: net.CIDRMask(64, 128)},
+	}
+
+	ni := networkInterface(iface, addrs)
+	if ni.Token != "eth0" || ni.Info.HwAddress != "02:42:ac:11:00:02" || ni.Info.MTU != 1500 {
+		t.Fatalf("unexpected interface info: %+v", ni)
+	}
+	if ni.IPv4 == nil || !reflect.DeepEqual(ni.IPv4.Config.Manual, []PrefixedIPv4Address{{Address: "192.168.1.20", PrefixLength: 24}}) {
+		t.Fatalf("IPv4 = %+v, want 192.168.1.20/24", ni.IPv4)
+	}
+
+	if ni := networkInterface(iface, addrs[1:]); ni.IPv4 != nil {
+		t.Fatalf("IPv4 = %+v, want none for an IPv6-only interface", ni.IPv4)
+	}
+}
@@ -0,0 +1,59 @@
+package device
+
+import (
+	"encoding/xml"
+)
+
+// Scope definitions of GetScopes
+const (
+	ScopeDefFixed        = "Fixed"
+	ScopeDefConfigurable = "Configurable"
+)
+
+// GetScopesRequest represents GetScopes request
+type GetScopesRequest struct {
+	XMLName xml.Name `xml:"GetScopes"`
+}
+
+// GetScopesResponse represents GetScopes response
+type GetScopesResponse struct {
+	XMLName xml.Name `xml:"tds:GetScopesResponse"`
+	Scopes  []Scope  `xml:"tds:Scopes"`
+}
+
+// Scope represents one scope of GetScopes response
+type Scope struct {
+	ScopeDef  string `xml:"tt:ScopeDef"`
+	ScopeItem string `xml:"tt:ScopeItem"`
+}
+
+// SetScopesRequest represents SetScopes request
+type SetScopesRequest struct {
+	XMLName xml.Name `xml:"SetScopes"`
+	Scopes  []string `xml:"Scopes"`
+}
+
+// SetScopesResponse represents SetScopes response
+type SetScopesResponse struct {
+	XMLName xml.Name `xml:"tds:SetScopesResponse"`
+}
+
+// GetScopes handles GetScopes request; the scopes are those advertised by WS-Discovery
+func (s *Service) GetScopes() *GetScopesResponse {
+	resp := &GetScopesResponse{}
+	for _, scope := range s.scopes.Fixed() {
+		resp.Scopes = append(resp.Scopes, Scope{ScopeDef: ScopeDefFixed, ScopeItem: scope})
+	}
+	for _, scope := range s.scopes.Configurable() {
+		resp.Scopes = append(resp.Scopes, Scope{ScopeDef: ScopeDefConfigurable, ScopeItem: scope})
+	}
+	return resp
+}
+
+// SetScopes handles SetScopes request, replacing all configurable scopes
+func (s *Service) SetScopes(req SetScopesRequest) (*SetScopesResponse, error) {
+	if err := s.scopes.Set(req.Scopes); err != nil {
+		return nil, err
+	}
+	return &SetScopesResponse{}, nil
+}
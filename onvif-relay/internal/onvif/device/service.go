@@ -3,6 +3,9 @@ package device
 import (
 	"encoding/xml"
 	"time"
+
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/auth"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/discovery"
 )
 
 // GetDeviceInformationRequest represents GetDeviceInformation request
@@ -68,14 +71,16 @@ type Date struct {
 // Service represents the Device service
 type Service struct {
 	deviceName string
-	baseURL    string
+	users      *auth.Store
+	scopes     *discovery.Scopes
 }
 
 // NewService creates a new Device service
-func NewService(deviceName, baseURL string) *Service {
+func NewService(deviceName string, users *auth.Store, scopes *discovery.Scopes) *Service {
 	return &Service{
 		deviceName: deviceName,
-		baseURL:    baseURL,
+		users:      users,
+		scopes:     scopes,
 	}
 }
 
@@ -0,0 +1,69 @@
+package device
+
+import (
+	"encoding/xml"
+)
+
+// ONVIF service namespaces advertised by GetServices
+const (
+	NamespaceDevice  = "http://www.onvif.org/ver10/device/wsdl"
+	NamespaceMedia   = "http://www.onvif.org/ver10/media/wsdl"
+	NamespaceMedia2  = "http://www.onvif.org/ver20/media/wsdl"
+	NamespacePTZ     = "http://www.onvif.org/ver20/ptz/wsdl"
+	NamespaceImaging = "http://www.onvif.org/ver20/imaging/wsdl"
+	NamespaceEvents  = "http://www.onvif.org/ver10/events/wsdl"
+)
+
+// GetServicesRequest represents GetServices request
+type GetServicesRequest struct {
+	XMLName           xml.Name `xml:"GetServices"`
+	IncludeCapability bool     `xml:"IncludeCapability"`
+}
+
+// GetServicesResponse represents GetServices response
+type GetServicesResponse struct {
+	XMLName  xml.Name      `xml:"tds:GetServicesResponse"`
+	Services []ServiceInfo `xml:"tds:Service"`
+}
+
+// ServiceInfo represents one service entry of GetServices
+type ServiceInfo struct {
+	Namespace string         `xml:"tds:Namespace"`
+	XAddr     string         `xml:"tds:XAddr"`
+	Version   ServiceVersion `xml:"tds:Version"`
+}
+
+// ServiceVersion represents the version of a service
+type ServiceVersion struct {
+	Major int `xml:"tt:Major"`
+	Minor int `xml:"tt:Minor"`
+}
+
+// services lists the services of the relay with their endpoint paths and versions
+var services = []struct {
+	namespace string
+	path      string
+	major     int
+	minor     int
+}{
+	{NamespaceDevice, "/onvif/device_service", 2, 5},
+	{NamespaceMedia, "/onvif/media_service", 2, 5},
+	{NamespaceMedia2, "/onvif/media2_service", 2, 5},
+	{NamespacePTZ, "/onvif/ptz_service", 2, 5},
+	{NamespaceImaging, "/onvif/imaging_service", 2, 5},
+	{NamespaceEvents, "/onvif/events_service", 2, 5},
+}
+
+// GetServices handles GetServices request; XAddrs are below baseURL, the relay URL
+// advertised to the client. Service capabilities are not included.
+func (s *Service) GetServices(baseURL string) *GetServicesResponse {
+	resp := &GetServicesResponse{}
+	for _, svc := range services {
+		resp.Services = append(resp.Services, ServiceInfo{
+			Namespace: svc.namespace,
+			XAddr:     baseURL + svc.path,
+			Version:   ServiceVersion{Major: svc.major, Minor: svc.minor},
+		})
+	}
+	return resp
+}
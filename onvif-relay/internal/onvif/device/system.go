@@ -0,0 +1,16 @@
+package device
+
+import (
+	"encoding/xml"
+)
+
+// SystemRebootRequest represents SystemReboot request
+type SystemRebootRequest struct {
+	XMLName xml.Name `xml:"SystemReboot"`
+}
+
+// SystemRebootResponse represents SystemReboot response
+type SystemRebootResponse struct {
+	XMLName xml.Name `xml:"tds:SystemRebootResponse"`
+	Message string   `xml:"tds:Message"`
+}
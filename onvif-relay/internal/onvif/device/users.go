@@ -0,0 +1,125 @@
+package device
+
+import (
+	"encoding/xml"
+
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/auth"
+)
+
+// GetUsersRequest represents GetUsers request
+type GetUsersRequest struct {
+	XMLName xml.Name `xml:"GetUsers"`
+}
+
+// GetUsersResponse represents GetUsers response
+type GetUsersResponse struct {
+	XMLName xml.Name `xml:"tds:GetUsersResponse"`
+	User    []User   `xml:"tds:User"`
+}
+
+// User represents a user account in GetUsers response (passwords are never returned)
+type User struct {
+	Username  string `xml:"tt:Username"`
+	UserLevel string `xml:"tt:UserLevel"`
+}
+
+// UserInput represents a user account in CreateUsers and SetUser requests
+type UserInput struct {
+	Username  string `xml:"Username"`
+	Password  string `xml:"Password"`
+	UserLevel string `xml:"UserLevel"`
+}
+
+// CreateUsersRequest represents CreateUsers request
+type CreateUsersRequest struct {
+	XMLName xml.Name    `xml:"CreateUsers"`
+	User    []UserInput `xml:"User"`
+}
+
+// CreateUsersResponse represents CreateUsers response
+type CreateUsersResponse struct {
+	XMLName xml.Name `xml:"tds:CreateUsersResponse"`
+}
+
+// DeleteUsersRequest represents DeleteUsers request
+type DeleteUsersRequest struct {
+	XMLName  xml.Name `xml:"DeleteUsers"`
+	Username []string `xml:"Username"`
+}
+
+// DeleteUsersResponse represents DeleteUsers response
+type DeleteUsersResponse struct {
+	XMLName xml.Name `xml:"tds:DeleteUsersResponse"`
+}
+
+// SetUserRequest represents SetUser request
+type SetUserRequest struct {
+	XMLName xml.Name    `xml:"SetUser"`
+	User    []UserInput `xml:"User"`
+}
+
+// SetUserResponse represents SetUser response
+type SetUserResponse struct {
+	XMLName xml.Name `xml:"tds:SetUserResponse"`
+}
+
+// GetUsers handles GetUsers request
+func (s *Service) GetUsers() *GetUsersResponse {
+	resp := &GetUsersResponse{}
+	for _, u := range s.users.List() {
+		resp.User = append(resp.User, User{
+			Username:  u.Username,
+			UserLevel: u.Level.String(),
+		})
+	}
+	return resp
+}
+
+// CreateUsers handles CreateUsers request
+func (s *Service) CreateUsers(req CreateUsersRequest) (*CreateUsersResponse, error) {
+	users, err := toAuthUsers(req.User)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.users.Create(users); err != nil {
+		return nil, err
+	}
+	return &CreateUsersResponse{}, nil
+}
+
+// DeleteUsers handles DeleteUsers request
+func (s *Service) DeleteUsers(req DeleteUsersRequest) (*DeleteUsersResponse, error) {
+	if err := s.users.Delete(req.Username); err != nil {
+		return nil, err
+	}
+	return &DeleteUsersResponse{}, nil
+}
+
+// SetUser handles SetUser request
+func (s *Service) SetUser(req SetUserRequest) (*SetUserResponse, error) {
+	users, err := toAuthUsers(req.User)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.users.Update(users); err != nil {
+		return nil, err
+	}
+	return &SetUserResponse{}, nil
+}
+
+// toAuthUsers converts users of a request; ONVIF clients cannot restrict cameras
+func toAuthUsers(inputs []UserInput) ([]auth.User, error) {
+	users := make([]auth.User, 0, len(inputs))
+	for _, in := range inputs {
+		level, err := auth.ParseLevel(in.UserLevel)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, auth.User{
+			Username: in.Username,
+			Password: in.Password,
+			Level:    level,
+		})
+	}
+	return users, nil
+}
@@ -0,0 +1,597 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/auth"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/camera"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/eventbus"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/onvif/soap"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/webhook"
+)
+
+const (
+	// maxPullPoints limits concurrent PullPoint subscriptions
+	maxPullPoints = 10
+	// maxQueuedMessages limits messages buffered per subscription
+	maxQueuedMessages = 100
+	// defaultSubscriptionTime is used when the client omits InitialTerminationTime
+	defaultSubscriptionTime = 60 * time.Second
+	// maxSubscriptionTime caps InitialTerminationTime and Renew requests
+	maxSubscriptionTime = 24 * time.Hour
+	// maxPullTimeout keeps long-polling below the HTTP server write timeout
+	maxPullTimeout = 20 * time.Second
+	// defaultMessageLimit is used when the client omits MessageLimit
+	defaultMessageLimit = 10
+	// alarmHoldTime is how long a detection stays active after the last alarm.
+	// The firmware only reports the start of an alarm, so the end is synthesized.
+	alarmHoldTime = 30 * time.Second
+)
+
+// alarmTypeWait is how long an alarmEvent waits for the recognitionNotify carrying its
+// alarm type before it is reported as motion
+var alarmTypeWait = 2 * time.Second
+
+// detection identifies a detector on the camera
+type detection int
+
+const (
+	detectionMotion detection = iota
+	detectionSound
+)
+
+// stateKey identifies a detection state of a camera
+type stateKey struct {
+	camera    string
+	detection detection
+}
+
+// detectionState holds the current value of a detection and its reset timer
+type detectionState struct {
+	active     bool
+	timer      *time.Timer
+	generation uint64 // invalidates timers that fired while being replaced
+}
+
+// subscription represents a PullPoint subscription
+type subscription struct {
+	id              string
+	owner           *auth.User // Creator; only its cameras are reported
+	filter          topicFilter
+	terminationTime time.Time
+	queue           []NotificationMessage
+	notify          chan struct{}
+}
+
+// Service represents the Events service
+type Service struct {
+	registry *camera.Registry
+	events   *eventbus.Subscription
+	done     chan struct{}
+
+	mu            sync.Mutex
+	subscriptions map[string]*subscription
+	nextID        uint64
+	states        map[stateKey]*detectionState
+	pending       map[string]*time.Timer // alarmEvents waiting for their alarm type, by camera
+}
+
+// NewService creates a new Events service fed by webhook alarms from the event bus
+func NewService(registry *camera.Registry, bus *eventbus.Bus) *Service {
+	s := &Service{
+		registry:      registry,
+		events:        bus.Subscribe(16),
+		done:          make(chan struct{}),
+		subscriptions: make(map[string]*subscription),
+		states:        make(map[stateKey]*detectionState),
+		pending:       make(map[string]*time.Timer),
+	}
+
+	go s.run()
+
+	return s
+}
+
+// Close stops consuming the event bus and pending detection reset timers
+func (s *Service) Close() {
+	s.events.Close()
+	<-s.done
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, st := range s.states {
+		if st.timer != nil {
+			st.timer.Stop()
+		}
+	}
+	for _, timer := range s.pending {
+		timer.Stop()
+	}
+}
+
+// run consumes camera alarms from the event bus
+func (s *Service) run() {
+	defer close(s.done)
+
+	for event := range s.events.Events() {
+		var err error
+		switch event := event.(type) {
+		case webhook.AlarmEvent:
+			err = s.AlarmStarted(event.CameraName())
+		case webhook.RecognitionEvent:
+			if alarmType, ok := event.AlarmType(); ok {
+				err = s.Alarm(event.CameraName(), alarmType)
+			}
+		}
+		if err != nil {
+			log.Printf("Events: alarm from %s ignored: %v", event.CameraName(), err)
+		}
+	}
+}
+
+// GetServiceCapabilities handles GetServiceCapabilities request
+func (s *Service) GetServiceCapabilities() *GetServiceCapabilitiesResponse {
+	return &GetServiceCapabilitiesResponse{
+		Capabilities: ServiceCapabilities{
+			WSSubscriptionPolicySupport:                   false,
+			WSPausableSubscriptionManagerInterfaceSupport: false,
+			MaxNotificationProducers:                      0,
+			MaxPullPoints:                                 maxPullPoints,
+			PersistentNotificationStorage:                 false,
+		},
+	}
+}
+
+// GetEventProperties handles GetEventProperties request
+func (s *Service) GetEventProperties() *GetEventPropertiesResponse {
+	return &GetEventPropertiesResponse{
+		TopicNamespaceLocation: []string{"http://www.onvif.org/onvif/ver10/topics/topicns.xml"},
+		FixedTopicSet:          true,
+		TopicSet:               TopicSet{Content: topicSetXML},
+		TopicExpressionDialect: []string{
+			TopicDialectConcreteSet,
+			TopicDialectConcrete,
+		},
+		MessageContentFilterDialect:  []string{"http://www.onvif.org/ver10/tev/messageContentFilter/ItemFilter"},
+		MessageContentSchemaLocation: []string{"http://www.onvif.org/onvif/ver10/schema/onvif.xsd"},
+	}
+}
+
+// CreatePullPointSubscription handles CreatePullPointSubscription request.
+// The subscription only receives events of cameras the owner may access; its address is
+// below baseURL, the relay URL advertised to the client.
+func (s *Service) CreatePullPointSubscription(req CreatePullPointSubscriptionRequest, owner *auth.User, baseURL string) (*CreatePullPointSubscriptionResponse, error) {
+	now := time.Now().UTC()
+	terminationTime, err := terminationTimeFrom(req.InitialTerminationTime, now)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.removeExpiredLocked(now)
+	if len(s.subscriptions) >= maxPullPoints {
+		return nil, fmt.Errorf("maximum number of pull points reached (%d)", maxPullPoints)
+	}
+
+	s.nextID++
+	sub := &subscription{
+		id:              strconv.FormatUint(s.nextID, 10),
+		owner:           owner,
+		filter:          newTopicFilter(req.Filter),
+		terminationTime: terminationTime,
+		notify:          make(chan struct{}, 1),
+	}
+	s.subscriptions[sub.id] = sub
+
+	// Property events must report their current state to new subscribers
+	s.enqueueLocked(sub, s.initialMessagesLocked(now))
+
+	log.Printf("Events: created pull point subscription %s (expires %s)", sub.id, terminationTime.Format(time.RFC3339))
+
+	return &CreatePullPointSubscriptionResponse{
+		SubscriptionReference: EndpointReference{
+			Address: SubscriptionAddress(baseURL, sub.id),
+		},
+		CurrentTime:     formatTime(now),
+		TerminationTime: formatTime(terminationTime),
+	}, nil
+}
+
+// SubscriptionOwner returns the username that created a subscription
+func (s *Service) SubscriptionOwner(id string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub, ok := s.subscriptions[id]
+	if !ok {
+		return "", false
+	}
+	return sub.owner.Username, true
+}
+
+// SubscriptionAddress returns the endpoint address of a subscription at the relay URL baseURL
+func SubscriptionAddress(baseURL, id string) string {
+	return baseURL + "/onvif/pullpoint/" + id
+}
+
+// PullMessages handles PullMessages request.
+// It waits until messages are available, the timeout elapses or ctx is cancelled.
+func (s *Service) PullMessages(ctx context.Context, id string, req PullMessagesRequest) (*PullMessagesResponse, error) {
+	timeout := maxPullTimeout
+	if req.Timeout != "" {
+		d, err := soap.ParseDuration(req.Timeout)
+		if err != nil {
+			return nil, err
+		}
+		if d < timeout {
+			timeout = d
+		}
+	}
+	limit := req.MessageLimit
+	if limit <= 0 {
+		limit = defaultMessageLimit
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		s.mu.Lock()
+		sub, err := s.lookupLocked(id, time.Now())
+		if err != nil {
+			s.mu.Unlock()
+			return nil, err
+		}
+		if len(sub.queue) > 0 {
+			n := limit
+			if n > len(sub.queue) {
+				n = len(sub.queue)
+			}
+			messages := append([]NotificationMessage(nil), sub.queue[:n]...)
+			sub.queue = sub.queue[n:]
+			resp := s.pullResponseLocked(sub, messages)
+			s.mu.Unlock()
+			return resp, nil
+		}
+		notify := sub.notify
+		s.mu.Unlock()
+
+		select {
+		case <-notify:
+		case <-timer.C:
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			sub, err := s.lookupLocked(id, time.Now())
+			if err != nil {
+				return nil, err
+			}
+			return s.pullResponseLocked(sub, nil), nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Renew handles Renew request
+func (s *Service) Renew(id string, req RenewRequest) (*RenewResponse, error) {
+	now := time.Now().UTC()
+	terminationTime, err := terminationTimeFrom(req.TerminationTime, now)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub, err := s.lookupLocked(id, now)
+	if err != nil {
+		return nil, err
+	}
+	sub.terminationTime = terminationTime
+
+	return &RenewResponse{
+		TerminationTime: formatTime(terminationTime),
+		CurrentTime:     formatTime(now),
+	}, nil
+}
+
+// Unsubscribe handles Unsubscribe request
+func (s *Service) Unsubscribe(id string) (*UnsubscribeResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.lookupLocked(id, time.Now()); err != nil {
+		return nil, err
+	}
+	delete(s.subscriptions, id)
+	log.Printf("Events: removed pull point subscription %s", id)
+
+	return &UnsubscribeResponse{}, nil
+}
+
+// SetSynchronizationPoint handles SetSynchronizationPoint request by re-sending current property states
+func (s *Service) SetSynchronizationPoint(id string) (*SetSynchronizationPointResponse, error) {
+	now := time.Now().UTC()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub, err := s.lookupLocked(id, now)
+	if err != nil {
+		return nil, err
+	}
+	s.enqueueLocked(sub, s.initialMessagesLocked(now))
+
+	return &SetSynchronizationPointResponse{}, nil
+}
+
+// AlarmStarted records an alarmEvent of a camera. The event does not say which detector
+// fired, so it waits alarmTypeWait for the alarm type (sent by the firmware as a
+// recognitionNotify when WEBHOOK_ALARM_INFO is on) and is reported as motion without one.
+func (s *Service) AlarmStarted(cameraName string) error {
+	cam, err := s.registry.Get(cameraName)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.pending[cameraName]; ok {
+		return nil
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(alarmTypeWait, func() {
+		s.mu.Lock()
+		if s.pending[cameraName] != timer {
+			s.mu.Unlock()
+			return
+		}
+		delete(s.pending, cameraName)
+		s.mu.Unlock()
+
+		s.trigger(cam, detectionMotion)
+	})
+	s.pending[cameraName] = timer
+
+	return nil
+}
+
+// Alarm records an alarm of a camera with the alarm type taken from the event payload.
+// It replaces a pending alarmEvent of the camera.
+func (s *Service) Alarm(cameraName string, alarmType webhook.AlarmType) error {
+	cam, err := s.registry.Get(cameraName)
+	if err != nil {
+		return err
+	}
+
+	var d detection
+	switch alarmType {
+	case webhook.AlarmTypeMotion:
+		d = detectionMotion
+	case webhook.AlarmTypeSound:
+		d = detectionSound
+	default:
+		return fmt.Errorf("unsupported alarm type %d", alarmType)
+	}
+
+	s.mu.Lock()
+	if timer, ok := s.pending[cameraName]; ok {
+		timer.Stop()
+		delete(s.pending, cameraName)
+	}
+	s.mu.Unlock()
+
+	s.trigger(cam, d)
+	return nil
+}
+
+// trigger activates a detection and (re)arms its reset timer
+func (s *Service) trigger(cam *camera.Camera, d detection) {
+	key := stateKey{camera: cam.Config.Name, detection: d}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, ok := s.states[key]
+	if !ok {
+		st = &detectionState{}
+		s.states[key] = st
+	}
+
+	if st.timer != nil {
+		st.timer.Stop()
+	}
+	st.generation++
+	generation := st.generation
+	st.timer = time.AfterFunc(alarmHoldTime, func() {
+		s.reset(cam, d, st, generation)
+	})
+
+	if !st.active {
+		st.active = true
+		s.publishLocked(s.buildMessages(cam, d, true, "Changed", time.Now().UTC()))
+	}
+}
+
+// reset deactivates a detection after the hold time elapsed
+func (s *Service) reset(cam *camera.Camera, d detection, st *detectionState, generation uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !st.active || st.generation != generation {
+		return
+	}
+	st.active = false
+	st.timer = nil
+	s.publishLocked(s.buildMessages(cam, d, false, "Changed", time.Now().UTC()))
+}
+
+// buildMessages builds the ONVIF notifications for a detection state of a camera
+func (s *Service) buildMessages(cam *camera.Camera, d detection, active bool, operation string, now time.Time) []NotificationMessage {
+	value := strconv.FormatBool(active)
+	utcTime := formatTime(now)
+
+	var messages []NotificationMessage
+	switch d {
+	case detectionMotion:
+		for _, stream := range cam.Config.Streams {
+			messages = append(messages, newNotification(TopicCellMotion, utcTime, operation,
+				[]SimpleItem{
+					{Name: "VideoSourceConfigurationToken", Value: stream.ProfileName + "_VSC"},
+					{Name: "VideoAnalyticsConfigurationToken", Value: stream.ProfileName + "_VAC"},
+					{Name: "Rule", Value: "MotionDetectorRule"},
+				},
+				[]SimpleItem{{Name: "IsMotion", Value: value}},
+			))
+		}
+		messages = append(messages, newNotification(TopicMotionAlarm, utcTime, operation,
+			[]SimpleItem{{Name: "Source", Value: cam.VideoSourceToken()}},
+			[]SimpleItem{{Name: "State", Value: value}},
+		))
+	case detectionSound:
+		messages = append(messages, newNotification(TopicDetectedSound, utcTime, operation,
+			[]SimpleItem{
+				{Name: "AudioSourceConfigurationToken", Value: cam.Config.Name + "_ASC"},
+				{Name: "AudioAnalyticsConfigurationToken", Value: cam.Config.Name + "_AAC"},
+				{Name: "Rule", Value: "SoundDetectorRule"},
+			},
+			[]SimpleItem{{Name: "IsSoundDetected", Value: value}},
+		))
+	}
+
+	for i := range messages {
+		messages[i].camera = cam.Config.Name
+	}
+	return messages
+}
+
+// initialMessagesLocked builds "Initialized" messages with the current state of every camera
+func (s *Service) initialMessagesLocked(now time.Time) []NotificationMessage {
+	var messages []NotificationMessage
+	for _, cam := range s.registry.List() {
+		for _, d := range []detection{detectionMotion, detectionSound} {
+			active := false
+			if st, ok := s.states[stateKey{camera: cam.Config.Name, detection: d}]; ok {
+				active = st.active
+			}
+			messages = append(messages, s.buildMessages(cam, d, active, "Initialized", now)...)
+		}
+	}
+	return messages
+}
+
+// publishLocked delivers messages to all live subscriptions
+func (s *Service) publishLocked(messages []NotificationMessage) {
+	s.removeExpiredLocked(time.Now())
+	for _, sub := range s.subscriptions {
+		s.enqueueLocked(sub, messages)
+	}
+}
+
+// enqueueLocked appends messages matching the subscription filter and wakes up a waiting PullMessages
+func (s *Service) enqueueLocked(sub *subscription, messages []NotificationMessage) {
+	added := false
+	for _, msg := range messages {
+		if !sub.filter.Match(msg.Topic.Value) || !sub.owner.CanAccess(msg.camera) {
+			continue
+		}
+		sub.queue = append(sub.queue, msg)
+		added = true
+	}
+	if !added {
+		return
+	}
+
+	// Drop the oldest messages when the client is not pulling
+	if len(sub.queue) > maxQueuedMessages {
+		sub.queue = sub.queue[len(sub.queue)-maxQueuedMessages:]
+	}
+
+	select {
+	case sub.notify <- struct{}{}:
+	default:
+	}
+}
+
+// lookupLocked returns a live subscription by ID
+func (s *Service) lookupLocked(id string, now time.Time) (*subscription, error) {
+	sub, ok := s.subscriptions[id]
+	if !ok {
+		return nil, fmt.Errorf("subscription not found: %s", id)
+	}
+	if now.After(sub.terminationTime) {
+		delete(s.subscriptions, id)
+		return nil, fmt.Errorf("subscription expired: %s", id)
+	}
+	return sub, nil
+}
+
+// removeExpiredLocked removes subscriptions past their termination time
+func (s *Service) removeExpiredLocked(now time.Time) {
+	for id, sub := range s.subscriptions {
+		if now.After(sub.terminationTime) {
+			delete(s.subscriptions, id)
+			log.Printf("Events: pull point subscription %s expired", id)
+		}
+	}
+}
+
+// pullResponseLocked builds a PullMessages response
+func (s *Service) pullResponseLocked(sub *subscription, messages []NotificationMessage) *PullMessagesResponse {
+	return &PullMessagesResponse{
+		CurrentTime:         formatTime(time.Now().UTC()),
+		TerminationTime:     formatTime(sub.terminationTime),
+		NotificationMessage: messages,
+	}
+}
+
+// newNotification builds a single notification message
+func newNotification(topic, utcTime, operation string, source, data []SimpleItem) NotificationMessage {
+	return NotificationMessage{
+		Topic: TopicExpression{
+			Dialect: TopicDialectConcreteSet,
+			Value:   topic,
+		},
+		Message: MessageHolder{
+			Message: Message{
+				UtcTime:           utcTime,
+				PropertyOperation: operation,
+				Source:            ItemList{SimpleItem: source},
+				Data:              ItemList{SimpleItem: data},
+			},
+		},
+	}
+}
+
+// terminationTimeFrom parses a requested termination time, applying defaults and limits
+func terminationTimeFrom(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return now.Add(defaultSubscriptionTime), nil
+	}
+
+	t, err := soap.ParseTime(value, now)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !t.After(now) {
+		return time.Time{}, fmt.Errorf("termination time must be in the future: %s", value)
+	}
+	if t.Sub(now) > maxSubscriptionTime {
+		t = now.Add(maxSubscriptionTime)
+	}
+	return t.UTC(), nil
+}
+
+// formatTime formats a time as xs:dateTime in UTC
+func formatTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05Z")
+}
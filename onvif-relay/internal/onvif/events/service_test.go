@@ -0,0 +1,235 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/auth"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/camera"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/config"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/eventbus"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/webhook"
+)
+
+func newEventsTestService(t *testing.T) (*Service, func()) {
+	t.Helper()
+
+	server := httptest.NewServer(http.NotFoundHandler())
+
+	host, portString, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		server.Close()
+		t.Fatalf("failed to split test server address: %v", err)
+	}
+	var port int
+	if _, err := fmt.Sscanf(portString, "%d", &port); err != nil {
+		server.Close()
+		t.Fatalf("failed to parse test server port: %v", err)
+	}
+
+	cfg := &config.Config{Cameras: []config.CameraConfig{
+		{
+			Name:     "swing",
+			Host:     host,
+			HTTPPort: port,
+			Streams: []config.StreamConfig{
+				{ProfileName: "Main"},
+			},
+		},
+	}}
+	registry, err := camera.NewRegistry(cfg)
+	if err != nil {
+		server.Close()
+		t.Fatalf("failed to create registry: %v", err)
+	}
+
+	service := NewService(registry, eventbus.New())
+	return service, func() {
+		service.Close()
+		registry.Close()
+		server.Close()
+	}
+}
+
+// testOwner is an Administrator allowed to access every camera
+var testOwner = &auth.User{Username: "admin", Level: auth.LevelAdministrator}
+
+func pullTopics(t *testing.T, service *Service, id string) []string {
+	t.Helper()
+
+	resp, err := service.PullMessages(context.Background(), id, PullMessagesRequest{Timeout: "PT1S", MessageLimit: 100})
+	if err != nil {
+		t.Fatalf("PullMessages returned an error: %v", err)
+	}
+
+	var topics []string
+	for _, msg := range resp.NotificationMessage {
+		var value string
+		for _, item := range msg.Message.Message.Data.SimpleItem {
+			value = item.Value
+		}
+		topics = append(topics, fmt.Sprintf("%s=%s(%s)", msg.Topic.Value, value, msg.Message.Message.PropertyOperation))
+	}
+	return topics
+}
+
+func TestAlarmIsDeliveredToPullPointSubscription(t *testing.T) {
+	service, closeService := newEventsTestService(t)
+	defer closeService()
+
+	resp, err := service.CreatePullPointSubscription(CreatePullPointSubscriptionRequest{
+		Filter: &Filter{TopicExpression: []TopicExpression{
+			{Dialect: TopicDialectConcreteSet, Value: "tns1:RuleEngine//."},
+		}},
+		InitialTerminationTime: "PT60S",
+	}, testOwner, "http://relay:8080")
+	if err != nil {
+		t.Fatalf("CreatePullPointSubscription returned an error: %v", err)
+	}
+	if resp.SubscriptionReference.Address != "http://relay:8080/onvif/pullpoint/1" {
+		t.Fatalf("subscription address = %q, want %q", resp.SubscriptionReference.Address, "http://relay:8080/onvif/pullpoint/1")
+	}
+
+	initial := pullTopics(t, service, "1")
+	want := []string{TopicCellMotion + "=false(Initialized)"}
+	if fmt.Sprint(initial) != fmt.Sprint(want) {
+		t.Fatalf("initial messages = %v, want %v", initial, want)
+	}
+
+	if err := service.Alarm("swing", webhook.AlarmTypeMotion); err != nil {
+		t.Fatalf("Alarm returned an error: %v", err)
+	}
+
+	changed := pullTopics(t, service, "1")
+	want = []string{TopicCellMotion + "=true(Changed)"}
+	if fmt.Sprint(changed) != fmt.Sprint(want) {
+		t.Fatalf("alarm messages = %v, want %v", changed, want)
+	}
+}
+
+func TestSoundAlarmIsDeliveredAsDetectedSound(t *testing.T) {
+	wait := alarmTypeWait
+	alarmTypeWait = 200 * time.Millisecond
+	defer func() { alarmTypeWait = wait }()
+
+	service, closeService := newEventsTestService(t)
+	defer closeService()
+
+	if _, err := service.CreatePullPointSubscription(CreatePullPointSubscriptionRequest{
+		Filter: &Filter{TopicExpression: []TopicExpression{
+			{Dialect: TopicDialectConcreteSet, Value: "tns1:AudioAnalytics/Audio/DetectedSound|tns1:RuleEngine//."},
+		}},
+	}, testOwner, "http://relay:8080"); err != nil {
+		t.Fatalf("CreatePullPointSubscription returned an error: %v", err)
+	}
+	pullTopics(t, service, "1")
+
+	// The alarm type of an alarmEvent arrives in the recognitionNotify that follows it
+	if err := service.AlarmStarted("swing"); err != nil {
+		t.Fatalf("AlarmStarted returned an error: %v", err)
+	}
+	if err := service.Alarm("swing", webhook.AlarmTypeSound); err != nil {
+		t.Fatalf("Alarm returned an error: %v", err)
+	}
+
+	changed := pullTopics(t, service, "1")
+	want := []string{TopicDetectedSound + "=true(Changed)"}
+	if fmt.Sprint(changed) != fmt.Sprint(want) {
+		t.Fatalf("alarm messages = %v, want %v", changed, want)
+	}
+
+	// The pending alarmEvent was replaced and does not raise motion later
+	time.Sleep(alarmTypeWait + 100*time.Millisecond)
+	if topics := pullTopics(t, service, "1"); len(topics) != 0 {
+		t.Fatalf("messages after the wait = %v, want none", topics)
+	}
+}
+
+func TestAlarmWithoutTypeIsDeliveredAsMotion(t *testing.T) {
+	wait := alarmTypeWait
+	alarmTypeWait = 50 * time.Millisecond
+	defer func() { alarmTypeWait = wait }()
+
+	service, closeService := newEventsTestService(t)
+	defer closeService()
+
+	if _, err := service.CreatePullPointSubscription(CreatePullPointSubscriptionRequest{
+		Filter: &Filter{TopicExpression: []TopicExpression{
+			{Dialect: TopicDialectConcreteSet, Value: "tns1:RuleEngine//."},
+		}},
+	}, testOwner, "http://relay:8080"); err != nil {
+		t.Fatalf("CreatePullPointSubscription returned an error: %v", err)
+	}
+	pullTopics(t, service, "1")
+
+	if err := service.AlarmStarted("swing"); err != nil {
+		t.Fatalf("AlarmStarted returned an error: %v", err)
+	}
+
+	changed := pullTopics(t, service, "1")
+	want := []string{TopicCellMotion + "=true(Changed)"}
+	if fmt.Sprint(changed) != fmt.Sprint(want) {
+		t.Fatalf("alarm messages = %v, want %v", changed, want)
+	}
+}
+
+func TestSubscriptionOnlyReceivesAllowedCameras(t *testing.T) {
+	service, closeService := newEventsTestService(t)
+	defer closeService()
+
+	owner := &auth.User{Username: "viewer", Level: auth.LevelUser, Cameras: []string{"garden"}}
+	if _, err := service.CreatePullPointSubscription(CreatePullPointSubscriptionRequest{}, owner, "http://relay:8080"); err != nil {
+		t.Fatalf("CreatePullPointSubscription returned an error: %v", err)
+	}
+	if err := service.Alarm("swing", webhook.AlarmTypeMotion); err != nil {
+		t.Fatalf("Alarm returned an error: %v", err)
+	}
+
+	if topics := pullTopics(t, service, "1"); len(topics) != 0 {
+		t.Fatalf("messages = %v, want none for a camera the owner may not access", topics)
+	}
+	if username, ok := service.SubscriptionOwner("1"); !ok || username != "viewer" {
+		t.Fatalf("SubscriptionOwner = %q, %v; want viewer", username, ok)
+	}
+}
+
+func TestUnsubscribeRemovesSubscription(t *testing.T) {
+	service, closeService := newEventsTestService(t)
+	defer closeService()
+
+	if _, err := service.CreatePullPointSubscription(CreatePullPointSubscriptionRequest{}, testOwner, "http://relay:8080"); err != nil {
+		t.Fatalf("CreatePullPointSubscription returned an error: %v", err)
+	}
+	if _, err := service.Unsubscribe("1"); err != nil {
+		t.Fatalf("Unsubscribe returned an error: %v", err)
+	}
+	if _, err := service.PullMessages(context.Background(), "1", PullMessagesRequest{Timeout: "PT1S"}); err == nil {
+		t.Fatal("PullMessages succeeded after Unsubscribe")
+	}
+}
+
+func TestTopicFilterMatch(t *testing.T) {
+	tests := []struct {
+		expression string
+		topic      string
+		want       bool
+	}{
+		{"tns1:RuleEngine/CellMotionDetector/Motion", TopicCellMotion, true},
+		{"tns1:RuleEngine//.", TopicCellMotion, true},
+		{"tns1:VideoSource/MotionAlarm|tns1:RuleEngine//.", TopicMotionAlarm, true},
+		{"tns1:VideoSource//.", TopicCellMotion, false},
+		{"tns1:RuleEngine/CellMotionDetector", TopicCellMotion, false},
+	}
+
+	for _, tt := range tests {
+		filter := newTopicFilter(&Filter{TopicExpression: []TopicExpression{{Value: tt.expression}}})
+		if got := filter.Match(tt.topic); got != tt.want {
+			t.Fatalf("Match(%q, %q) = %v, want %v", tt.expression, tt.topic, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package events
+
+import (
+	"strings"
+)
+
+// Topics published by the relay
+const (
+	TopicCellMotion    = "tns1:RuleEngine/CellMotionDetector/Motion"
+	TopicMotionAlarm   = "tns1:VideoSource/MotionAlarm"
+	TopicDetectedSound = "tns1:AudioAnalytics/Audio/DetectedSound"
+)
+
+// topicSetXML describes the topics above in the wstop:TopicSet format
+const topicSetXML = `<tns1:RuleEngine><CellMotionDetector><Motion wstop:topic="true"><tt:MessageDescription IsProperty="true"><tt:Source><tt:SimpleItemDescription Name="VideoSourceConfigurationToken" Type="tt:ReferenceToken"/><tt:SimpleItemDescription Name="VideoAnalyticsConfigurationToken" Type="tt:ReferenceToken"/><tt:SimpleItemDescription Name="Rule" Type="xs:string"/></tt:Source><tt:Data><tt:SimpleItemDescription Name="IsMotion" Type="xs:boolean"/></tt:Data></tt:MessageDescription></Motion></CellMotionDetector></tns1:RuleEngine>` +
+	`<tns1:VideoSource><MotionAlarm wstop:topic="true"><tt:MessageDescription IsProperty="true"><tt:Source><tt:SimpleItemDescription Name="Source" Type="tt:ReferenceToken"/></tt:Source><tt:Data><tt:SimpleItemDescription Name="State" Type="xs:boolean"/></tt:Data></tt:MessageDescription></MotionAlarm></tns1:VideoSource>` +
+	`<tns1:AudioAnalytics><Audio><DetectedSound wstop:topic="true"><tt:MessageDescription IsProperty="true"><tt:Source><tt:SimpleItemDescription Name="AudioSourceConfigurationToken" Type="tt:ReferenceToken"/><tt:SimpleItemDescription Name="AudioAnalyticsConfigurationToken" Type="tt:ReferenceToken"/><tt:SimpleItemDescription Name="Rule" Type="xs:string"/></tt:Source><tt:Data><tt:SimpleItemDescription Name="IsSoundDetected" Type="xs:boolean"/></tt:Data></tt:MessageDescription></DetectedSound></Audio></tns1:AudioAnalytics>`
+
+// topicFilter matches topics against the TopicExpressions of a subscription.
+// An empty filter matches every topic.
+type topicFilter []string
+
+// newTopicFilter builds a filter from ConcreteSet/Concrete/Simple topic expressions.
+// Alternatives are separated by "|" and a trailing "//." selects a whole subtree.
+func newTopicFilter(filter *Filter) topicFilter {
+	if filter == nil {
+		return nil
+	}
+
+	var patterns topicFilter
+	for _, expr := range filter.TopicExpression {
+		for _, alt := range strings.Split(expr.Value, "|") {
+			alt = strings.TrimSpace(alt)
+			if alt == "" {
+				continue
+			}
+			patterns = append(patterns, normalizeTopic(alt))
+		}
+	}
+	return patterns
+}
+
+// Match reports whether the topic passes the filter
+func (f topicFilter) Match(topic string) bool {
+	if len(f) == 0 {
+		return true
+	}
+
+	topic = normalizeTopic(topic)
+	for _, pattern := range f {
+		if subtree := strings.TrimSuffix(pattern, "//."); subtree != pattern {
+			if topic == subtree || strings.HasPrefix(topic, subtree+"/") {
+				return true
+			}
+			continue
+		}
+		if topic == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeTopic strips namespace prefixes from each path segment (e.g. "tns1:VideoSource/MotionAlarm" → "VideoSource/MotionAlarm")
+func normalizeTopic(topic string) string {
+	segments := strings.Split(topic, "/")
+	for i, segment := range segments {
+		if idx := strings.Index(segment, ":"); idx >= 0 {
+			segments[i] = segment[idx+1:]
+		}
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,164 @@
+package events
+
+import (
+	"encoding/xml"
+)
+
+// Topic expression dialects
+const (
+	TopicDialectConcreteSet = "http://www.onvif.org/ver10/tev/topicExpression/ConcreteSet"
+	TopicDialectConcrete    = "http://docs.oasis-open.org/wsn/t-1/TopicExpression/Concrete"
+	TopicDialectSimple      = "http://docs.oasis-open.org/wsn/t-1/TopicExpression/Simple"
+)
+
+// GetServiceCapabilitiesRequest represents Events GetServiceCapabilities request
+type GetServiceCapabilitiesRequest struct {
+	XMLName xml.Name `xml:"GetServiceCapabilities"`
+}
+
+// GetServiceCapabilitiesResponse represents Events GetServiceCapabilities response
+type GetServiceCapabilitiesResponse struct {
+	XMLName      xml.Name            `xml:"tev:GetServiceCapabilitiesResponse"`
+	Capabilities ServiceCapabilities `xml:"tev:Capabilities"`
+}
+
+// ServiceCapabilities represents Events service capabilities
+type ServiceCapabilities struct {
+	WSSubscriptionPolicySupport                   bool `xml:"WSSubscriptionPolicySupport,attr"`
+	WSPausableSubscriptionManagerInterfaceSupport bool `xml:"WSPausableSubscriptionManagerInterfaceSupport,attr"`
+	MaxNotificationProducers                      int  `xml:"MaxNotificationProducers,attr"`
+	MaxPullPoints                                 int  `xml:"MaxPullPoints,attr"`
+	PersistentNotificationStorage                 bool `xml:"PersistentNotificationStorage,attr"`
+}
+
+// TopicExpression represents a WS-BaseNotification topic expression
+type TopicExpression struct {
+	Dialect string `xml:"Dialect,attr"`
+	Value   string `xml:",chardata"`
+}
+
+// Filter represents a subscription filter
+type Filter struct {
+	TopicExpression []TopicExpression `xml:"TopicExpression"`
+}
+
+// CreatePullPointSubscriptionRequest represents CreatePullPointSubscription request
+type CreatePullPointSubscriptionRequest struct {
+	XMLName                xml.Name `xml:"CreatePullPointSubscription"`
+	Filter                 *Filter  `xml:"Filter,omitempty"`
+	InitialTerminationTime string   `xml:"InitialTerminationTime,omitempty"`
+}
+
+// CreatePullPointSubscriptionResponse represents CreatePullPointSubscription response
+type CreatePullPointSubscriptionResponse struct {
+	XMLName               xml.Name          `xml:"tev:CreatePullPointSubscriptionResponse"`
+	SubscriptionReference EndpointReference `xml:"tev:SubscriptionReference"`
+	CurrentTime           string            `xml:"wsnt:CurrentTime"`
+	TerminationTime       string            `xml:"wsnt:TerminationTime"`
+}
+
+// EndpointReference represents a WS-Addressing endpoint reference
+type EndpointReference struct {
+	Address string `xml:"wsa:Address"`
+}
+
+// PullMessagesRequest represents PullMessages request
+type PullMessagesRequest struct {
+	XMLName      xml.Name `xml:"PullMessages"`
+	Timeout      string   `xml:"Timeout"`
+	MessageLimit int      `xml:"MessageLimit"`
+}
+
+// PullMessagesResponse represents PullMessages response
+type PullMessagesResponse struct {
+	XMLName             xml.Name              `xml:"tev:PullMessagesResponse"`
+	CurrentTime         string                `xml:"tev:CurrentTime"`
+	TerminationTime     string                `xml:"tev:TerminationTime"`
+	NotificationMessage []NotificationMessage `xml:"wsnt:NotificationMessage"`
+}
+
+// NotificationMessage represents a WS-BaseNotification message
+type NotificationMessage struct {
+	Topic   TopicExpression `xml:"wsnt:Topic"`
+	Message MessageHolder   `xml:"wsnt:Message"`
+
+	camera string // Camera the message is about; used to filter per subscriber
+}
+
+// MessageHolder wraps the ONVIF message payload
+type MessageHolder struct {
+	Message Message `xml:"tt:Message"`
+}
+
+// Message represents an ONVIF event message
+type Message struct {
+	UtcTime           string   `xml:"UtcTime,attr"`
+	PropertyOperation string   `xml:"PropertyOperation,attr,omitempty"`
+	Source            ItemList `xml:"tt:Source"`
+	Data              ItemList `xml:"tt:Data"`
+}
+
+// ItemList represents a list of simple items
+type ItemList struct {
+	SimpleItem []SimpleItem `xml:"tt:SimpleItem"`
+}
+
+// SimpleItem represents a name/value pair in an event message
+type SimpleItem struct {
+	Name  string `xml:"Name,attr"`
+	Value string `xml:"Value,attr"`
+}
+
+// RenewRequest represents Renew request
+type RenewRequest struct {
+	XMLName         xml.Name `xml:"Renew"`
+	TerminationTime string   `xml:"TerminationTime"`
+}
+
+// RenewResponse represents Renew response
+type RenewResponse struct {
+	XMLName         xml.Name `xml:"wsnt:RenewResponse"`
+	TerminationTime string   `xml:"wsnt:TerminationTime"`
+	CurrentTime     string   `xml:"wsnt:CurrentTime"`
+}
+
+// UnsubscribeRequest represents Unsubscribe request
+type UnsubscribeRequest struct {
+	XMLName xml.Name `xml:"Unsubscribe"`
+}
+
+// UnsubscribeResponse represents Unsubscribe response
+type UnsubscribeResponse struct {
+	XMLName xml.Name `xml:"wsnt:UnsubscribeResponse"`
+}
+
+// SetSynchronizationPointRequest represents SetSynchronizationPoint request
+type SetSynchronizationPointRequest struct {
+	XMLName xml.Name `xml:"SetSynchronizationPoint"`
+}
+
+// SetSynchronizationPointResponse represents SetSynchronizationPoint response
+type SetSynchronizationPointResponse struct {
+	XMLName xml.Name `xml:"tev:SetSynchronizationPointResponse"`
+}
+
+// GetEventPropertiesRequest represents GetEventProperties request
+type GetEventPropertiesRequest struct {
+	XMLName xml.Name `xml:"GetEventProperties"`
+}
+
+// GetEventPropertiesResponse represents GetEventProperties response
+type GetEventPropertiesResponse struct {
+	XMLName                      xml.Name `xml:"tev:GetEventPropertiesResponse"`
+	TopicNamespaceLocation       []string `xml:"tev:TopicNamespaceLocation"`
+	FixedTopicSet                bool     `xml:"wsnt:FixedTopicSet"`
+	TopicSet                     TopicSet `xml:"wstop:TopicSet"`
+	TopicExpressionDialect       []string `xml:"wsnt:TopicExpressionDialect"`
+	MessageContentFilterDialect  []string `xml:"tev:MessageContentFilterDialect"`
+	MessageContentSchemaLocation []string `xml:"tev:MessageContentSchemaLocation"`
+}
+
+// TopicSet holds the topic tree advertised by GetEventProperties
+type TopicSet struct {
+	Content string `xml:",innerxml"`
+}
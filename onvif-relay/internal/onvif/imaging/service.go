@@ -128,42 +128,43 @@ func NewService(registry *camera.Registry) *Service {
 }
 
 // GetImagingSettings handles GetImagingSettings request
-func (s *Service) GetImagingSettings(videoSourceToken string) *GetImagingSettingsResponse {
-	// Return default settings
-	// Note: AtomCam doesn't provide a way to query current settings,
-	// so we return sensible defaults (center values)
-	brightness := 0.5
-	contrast := 0.5
-	saturation := 0.5
-	sharpness := 0.5
-	irCutFilter := "AUTO"
+func (s *Service) GetImagingSettings(videoSourceToken string) (*GetImagingSettingsResponse, error) {
+	cam, err := s.getCamera(videoSourceToken)
+	if err != nil {
+		return nil, err
+	}
 
-	return &GetImagingSettingsResponse{
-		ImagingSettings: ImagingSettings{
-			Brightness:      &brightness,
-			ColorSaturation: &saturation,
-			Contrast:        &contrast,
-			Sharpness:       &sharpness,
-			IrCutFilter:     &irCutFilter,
-			Exposure: &Exposure{
-				Mode: "AUTO",
-			},
+	// Read current values from the camera (cached per camera, invalidated on Set)
+	current, err := cam.Client.GetImagingSettings()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get imaging settings: %w", err)
+	}
+
+	settings := ImagingSettings{
+		Brightness:      &current.Brightness,
+		ColorSaturation: &current.Saturation,
+		Contrast:        &current.Contrast,
+		Sharpness:       &current.Sharpness,
+		Exposure: &Exposure{
+			Mode: "AUTO",
 		},
 	}
+	if current.IRCutFilter != "" {
+		settings.IrCutFilter = &current.IRCutFilter
+	}
+
+	return &GetImagingSettingsResponse{
+		ImagingSettings: settings,
+	}, nil
 }
 
 // SetImagingSettings handles SetImagingSettings request
 func (s *Service) SetImagingSettings(videoSourceToken string, settings ImagingSettings) error {
-	// For now, apply settings to all cameras
-	// TODO: Map videoSourceToken to specific camera
-	cameras := s.registry.List()
-	if len(cameras) == 0 {
-		return fmt.Errorf("no cameras configured")
+	cam, err := s.getCamera(videoSourceToken)
+	if err != nil {
+		return err
 	}
 
-	// Apply to first camera (or all cameras - depends on requirements)
-	cam := cameras[0]
-
 	// Apply brightness
 	if settings.Brightness != nil {
 		if err := cam.Client.SetBrightness(*settings.Brightness); err != nil {
@@ -220,9 +221,23 @@ func (s *Service) SetImagingSettings(videoSourceToken string, settings ImagingSe
 	return nil
 }
 
+// getCamera returns the camera for a video source token
+func (s *Service) getCamera(videoSourceToken string) (*camera.Camera, error) {
+	return s.registry.GetByVideoSourceToken(videoSourceToken)
+}
+
 // GetOptions handles GetOptions request
-func (s *Service) GetOptions(videoSourceToken string) *GetOptionsResponse {
-	irCutModes := []string{"ON", "OFF", "AUTO"}
+func (s *Service) GetOptions(videoSourceToken string) (*GetOptionsResponse, error) {
+	cam, err := s.getCamera(videoSourceToken)
+	if err != nil {
+		return nil, err
+	}
+
+	// IR cut filter modes are only offered for cameras with IR control
+	var irCutModes []string
+	if cam.Config.Capabilities.IR {
+		irCutModes = []string{"ON", "OFF", "AUTO"}
+	}
 
 	return &GetOptionsResponse{
 		ImagingOptions: ImagingOptions{
@@ -256,5 +271,5 @@ func (s *Service) GetOptions(videoSourceToken string) *GetOptionsResponse {
 				},
 			},
 		},
-	}
+	}, nil
 }
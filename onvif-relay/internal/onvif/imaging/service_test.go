@@ -0,0 +1,77 @@
+package imaging
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/camera"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/config"
+)
+
+// newTestCamera starts a fake camera recording the commands it receives
+func newTestCamera(t *testing.T, name string, commands *[]string) (config.CameraConfig, func()) {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request camera.CommandRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		*commands = append(*commands, request.Exec)
+		fmt.Fprintln(w, "ok")
+	}))
+
+	host, portString, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		server.Close()
+		t.Fatalf("failed to split test server address: %v", err)
+	}
+	var port int
+	if _, err := fmt.Sscanf(portString, "%d", &port); err != nil {
+		server.Close()
+		t.Fatalf("failed to parse test server port: %v", err)
+	}
+
+	return config.CameraConfig{
+		Name:     name,
+		Host:     host,
+		HTTPPort: port,
+		Streams:  []config.StreamConfig{{ProfileName: name + "_Main"}},
+	}, server.Close
+}
+
+func TestSetImagingSettingsTargetsOneCamera(t *testing.T) {
+	var frontCommands, garageCommands []string
+	front, closeFront := newTestCamera(t, "front", &frontCommands)
+	defer closeFront()
+	garage, closeGarage := newTestCamera(t, "garage", &garageCommands)
+	defer closeGarage()
+
+	registry, err := camera.NewRegistry(&config.Config{Cameras: []config.CameraConfig{front, garage}})
+	if err != nil {
+		t.Fatalf("failed to create registry: %v", err)
+	}
+	defer registry.Close()
+
+	service := NewService(registry)
+	brightness := 0.2
+	if err := service.SetImagingSettings("garage_VideoSource", ImagingSettings{Brightness: &brightness}); err != nil {
+		t.Fatalf("SetImagingSettings returned an error: %v", err)
+	}
+
+	if len(frontCommands) != 0 {
+		t.Fatalf("front camera commands = %v, want none", frontCommands)
+	}
+	if len(garageCommands) != 1 || garageCommands[0] != "video bri 51" {
+		t.Fatalf("garage camera commands = %v, want [video bri 51]", garageCommands)
+	}
+
+	if err := service.SetImagingSettings("VideoSource_1", ImagingSettings{Brightness: &brightness}); err == nil {
+		t.Fatal("SetImagingSettings accepted an ambiguous legacy token with two cameras")
+	}
+}
@@ -0,0 +1,118 @@
+package media
+
+import (
+	"encoding/xml"
+
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/auth"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/camera"
+)
+
+// sendPrimacyHalfDuplexClient tells clients that the audio output is used by the client
+// only (the backchannel does not mute the camera's microphone)
+const sendPrimacyHalfDuplexClient = "www.onvif.org/ver20/HalfDuplex/Client"
+
+// GetAudioOutputsRequest represents GetAudioOutputs request
+type GetAudioOutputsRequest struct {
+	XMLName xml.Name `xml:"GetAudioOutputs"`
+}
+
+// GetAudioOutputsResponse represents GetAudioOutputs response
+type GetAudioOutputsResponse struct {
+	XMLName      xml.Name      `xml:"trt:GetAudioOutputsResponse"`
+	AudioOutputs []AudioOutput `xml:"trt:AudioOutputs"`
+}
+
+// AudioOutput represents a speaker (one per camera with talk enabled)
+type AudioOutput struct {
+	Token string `xml:"token,attr"`
+}
+
+// GetAudioOutputConfigurationsRequest represents GetAudioOutputConfigurations request
+type GetAudioOutputConfigurationsRequest struct {
+	XMLName xml.Name `xml:"GetAudioOutputConfigurations"`
+}
+
+// GetAudioOutputConfigurationsResponse represents GetAudioOutputConfigurations response
+type GetAudioOutputConfigurationsResponse struct {
+	XMLName        xml.Name                   `xml:"trt:GetAudioOutputConfigurationsResponse"`
+	Configurations []AudioOutputConfiguration `xml:"trt:Configurations"`
+}
+
+// AudioOutputConfiguration represents audio output configuration
+type AudioOutputConfiguration struct {
+	Token       string `xml:"token,attr"`
+	Name        string `xml:"tt:Name"`
+	UseCount    int    `xml:"tt:UseCount"`
+	OutputToken string `xml:"tt:OutputToken"`
+	SendPrimacy string `xml:"tt:SendPrimacy,omitempty"`
+	OutputLevel int    `xml:"tt:OutputLevel"`
+}
+
+// AudioDecoderConfiguration represents audio decoder configuration of the backchannel
+type AudioDecoderConfiguration struct {
+	Token    string `xml:"token,attr"`
+	Name     string `xml:"tt:Name"`
+	UseCount int    `xml:"tt:UseCount"`
+}
+
+// GetAudioOutputs handles GetAudioOutputs request; cameras with talk enabled that the
+// user may access have one audio output each
+func (s *Service) GetAudioOutputs(user *auth.User) *GetAudioOutputsResponse {
+	resp := &GetAudioOutputsResponse{AudioOutputs: []AudioOutput{}}
+	for _, cam := range s.talkCameras(user) {
+		resp.AudioOutputs = append(resp.AudioOutputs, AudioOutput{Token: cam.AudioOutputToken()})
+	}
+	return resp
+}
+
+// GetAudioOutputConfigurations handles GetAudioOutputConfigurations request
+func (s *Service) GetAudioOutputConfigurations(user *auth.User) *GetAudioOutputConfigurationsResponse {
+	resp := &GetAudioOutputConfigurationsResponse{Configurations: []AudioOutputConfiguration{}}
+	for _, cam := range s.talkCameras(user) {
+		resp.Configurations = append(resp.Configurations, *buildAudioOutputConfiguration(cam))
+	}
+	return resp
+}
+
+// talkCameras returns the cameras with talk enabled that the user may access
+func (s *Service) talkCameras(user *auth.User) []*camera.Camera {
+	var cameras []*camera.Camera
+	for _, cam := range s.registry.List() {
+		if cam.Config.Talk.Enabled && user.CanAccess(cam.Config.Name) {
+			cameras = append(cameras, cam)
+		}
+	}
+	return cameras
+}
+
+// buildAudioOutputConfiguration builds the audio output configuration of a camera,
+// shared by all of its profiles
+func buildAudioOutputConfiguration(cam *camera.Camera) *AudioOutputConfiguration {
+	return &AudioOutputConfiguration{
+		Token:       AudioOutputConfigurationToken(cam),
+		Name:        cam.Config.Name + " Audio Output",
+		UseCount:    len(cam.Config.Streams),
+		OutputToken: cam.AudioOutputToken(),
+		SendPrimacy: sendPrimacyHalfDuplexClient,
+		OutputLevel: 100,
+	}
+}
+
+// AudioOutputConfigurationToken returns the token of the audio output configuration of a camera
+func AudioOutputConfigurationToken(cam *camera.Camera) string {
+	return cam.Config.Name + "_AOC"
+}
+
+// AudioDecoderConfigurationToken returns the token of the backchannel decoder configuration of a camera
+func AudioDecoderConfigurationToken(cam *camera.Camera) string {
+	return cam.Config.Name + "_ADC"
+}
+
+// buildAudioDecoderConfiguration builds the G.711 decoder configuration of the backchannel
+func buildAudioDecoderConfiguration(cam *camera.Camera) *AudioDecoderConfiguration {
+	return &AudioDecoderConfiguration{
+		Token:    AudioDecoderConfigurationToken(cam),
+		Name:     cam.Config.Name + " Audio Decoder",
+		UseCount: len(cam.Config.Streams),
+	}
+}
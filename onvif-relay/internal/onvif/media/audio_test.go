@@ -0,0 +1,83 @@
+package media
+
+import (
+	"testing"
+
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/auth"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/camera"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/config"
+)
+
+// newAudioTestService returns a service for a camera with talk (frontdoor) and one
+// without (garage), with the backchannel proxy on port 8555
+func newAudioTestService(t *testing.T) *Service {
+	t.Helper()
+	registry, err := camera.NewRegistry(&config.Config{Cameras: []config.CameraConfig{
+		{
+			Name:     "frontdoor",
+			Host:     "192.168.1.10",
+			HTTPPort: 80,
+			Talk:     config.TalkConfig{Enabled: true},
+			Streams:  []config.StreamConfig{{Path: "video0_unicast", Codec: "h264", ProfileName: "FrontDoor_Main"}},
+		},
+		{
+			Name:     "garage",
+			Host:     "192.168.1.20",
+			HTTPPort: 80,
+			Streams:  []config.StreamConfig{{Path: "video0_unicast", Codec: "h264", ProfileName: "Garage_Main"}},
+		},
+	}})
+	if err != nil {
+		t.Fatalf("failed to create registry: %v", err)
+	}
+	t.Cleanup(registry.Close)
+	return NewService(registry, "relay", 8554, 8555)
+}
+
+func TestAudioOutputsListTalkCameras(t *testing.T) {
+	service := newAudioTestService(t)
+
+	outputs := service.GetAudioOutputs(testAdmin)
+	if len(outputs.AudioOutputs) != 1 || outputs.AudioOutputs[0].Token != "frontdoor_AudioOutput" {
+		t.Fatalf("AudioOutputs = %+v, want only frontdoor", outputs.AudioOutputs)
+	}
+
+	configs := service.GetAudioOutputConfigurations(testAdmin)
+	if len(configs.Configurations) != 1 || configs.Configurations[0].OutputToken != "frontdoor_AudioOutput" {
+		t.Fatalf("Configurations = %+v, want the frontdoor output", configs.Configurations)
+	}
+
+	viewer := &auth.User{Username: "viewer", Level: auth.LevelUser, Cameras: []string{"garage"}}
+	if outputs := service.GetAudioOutputs(viewer); len(outputs.AudioOutputs) != 0 {
+		t.Fatalf("AudioOutputs for garage-only user = %+v, want none", outputs.AudioOutputs)
+	}
+}
+
+func TestStreamUriAdvertisesBackchannelProxyForTalkCameras(t *testing.T) {
+	service := newAudioTestService(t)
+
+	resp, err := service.GetStreamUri(GetStreamUriRequest{ProfileToken: "FrontDoor_Main"}, "relay.lan")
+	if err != nil {
+		t.Fatalf("GetStreamUri returned an error: %v", err)
+	}
+	if got := resp.MediaUri.Uri; got != "rtsp://relay.lan:8555/frontdoor/video0_unicast" {
+		t.Fatalf("stream URI of a talk camera = %s, want the backchannel proxy", got)
+	}
+
+	udp := GetStreamUriRequest{ProfileToken: "FrontDoor_Main", StreamSetup: StreamSetup{Transport: Transport{Protocol: "UDP"}}}
+	resp, err = service.GetStreamUri(udp, "relay.lan")
+	if err != nil {
+		t.Fatalf("GetStreamUri returned an error: %v", err)
+	}
+	if got := resp.MediaUri.Uri; got != "rtsp://relay:8554/frontdoor/video0_unicast" {
+		t.Fatalf("UDP stream URI of a talk camera = %s, want the mediamtx path", got)
+	}
+
+	resp, err = service.GetStreamUri(GetStreamUriRequest{ProfileToken: "Garage_Main"}, "relay.lan")
+	if err != nil {
+		t.Fatalf("GetStreamUri returned an error: %v", err)
+	}
+	if got := resp.MediaUri.Uri; got != "rtsp://relay:8554/garage/video0_unicast" {
+		t.Fatalf("stream URI of a camera without talk = %s, want the mediamtx path", got)
+	}
+}
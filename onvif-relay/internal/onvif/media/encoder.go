@@ -0,0 +1,336 @@
+package media
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/auth"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/camera"
+)
+
+var (
+	// ErrNoConfig is returned for unknown configuration tokens (ter:NoConfig)
+	ErrNoConfig = errors.New("configuration token does not exist")
+	// ErrConfigModify is returned for settings the camera cannot apply (ter:ConfigModify)
+	ErrConfigModify = errors.New("parameters can not be set")
+)
+
+// Rate control reported when the camera's encoder settings cannot be read. Both are within
+// the ranges of GetVideoEncoderConfigurationOptions, so clients can send them back.
+const (
+	defaultFrameRate = camera.MaxFrameRate
+	defaultBitrate   = camera.MaxBitrate
+)
+
+// GetVideoEncoderConfigurationsRequest represents GetVideoEncoderConfigurations request
+type GetVideoEncoderConfigurationsRequest struct {
+	XMLName xml.Name `xml:"GetVideoEncoderConfigurations"`
+}
+
+// GetVideoEncoderConfigurationsResponse represents GetVideoEncoderConfigurations response
+type GetVideoEncoderConfigurationsResponse struct {
+	XMLName        xml.Name                    `xml:"trt:GetVideoEncoderConfigurationsResponse"`
+	Configurations []VideoEncoderConfiguration `xml:"trt:Configurations"`
+}
+
+// GetVideoEncoderConfigurationRequest represents GetVideoEncoderConfiguration request
+type GetVideoEncoderConfigurationRequest struct {
+	XMLName            xml.Name `xml:"GetVideoEncoderConfiguration"`
+	ConfigurationToken string   `xml:"ConfigurationToken"`
+}
+
+// GetVideoEncoderConfigurationResponse represents GetVideoEncoderConfiguration response
+type GetVideoEncoderConfigurationResponse struct {
+	XMLName       xml.Name                  `xml:"trt:GetVideoEncoderConfigurationResponse"`
+	Configuration VideoEncoderConfiguration `xml:"trt:Configuration"`
+}
+
+// GetVideoEncoderConfigurationOptionsRequest represents GetVideoEncoderConfigurationOptions request.
+// Either token may be given; without both, the options of the first accessible profile are returned.
+type GetVideoEncoderConfigurationOptionsRequest struct {
+	XMLName            xml.Name `xml:"GetVideoEncoderConfigurationOptions"`
+	ConfigurationToken string   `xml:"ConfigurationToken"`
+	ProfileToken       string   `xml:"ProfileToken"`
+}
+
+// GetVideoEncoderConfigurationOptionsResponse represents GetVideoEncoderConfigurationOptions response
+type GetVideoEncoderConfigurationOptionsResponse struct {
+	XMLName xml.Name                         `xml:"trt:GetVideoEncoderConfigurationOptionsResponse"`
+	Options VideoEncoderConfigurationOptions `xml:"trt:Options"`
+}
+
+// VideoEncoderConfigurationOptions represents the settable ranges of a video encoder configuration
+type VideoEncoderConfigurationOptions struct {
+	QualityRange IntRange                      `xml:"tt:QualityRange"`
+	H264         *H264Options                  `xml:"tt:H264"`
+	Extension    *VideoEncoderOptionsExtension `xml:"tt:Extension,omitempty"`
+}
+
+// IntRange represents an integer range
+type IntRange struct {
+	Min int `xml:"tt:Min"`
+	Max int `xml:"tt:Max"`
+}
+
+// H264Options represents H.264 encoder options
+type H264Options struct {
+	ResolutionsAvailable  []Resolution `xml:"tt:ResolutionsAvailable"`
+	GovLengthRange        IntRange     `xml:"tt:GovLengthRange"`
+	FrameRateRange        IntRange     `xml:"tt:FrameRateRange"`
+	EncodingIntervalRange IntRange     `xml:"tt:EncodingIntervalRange"`
+	H264ProfilesSupported []string     `xml:"tt:H264ProfilesSupported"`
+}
+
+// VideoEncoderOptionsExtension carries the bitrate range (H264Options2)
+type VideoEncoderOptionsExtension struct {
+	H264 *H264Options2 `xml:"tt:H264"`
+}
+
+// H264Options2 represents H.264 encoder options with bitrate range
+type H264Options2 struct {
+	H264Options
+	BitrateRange IntRange `xml:"tt:BitrateRange"`
+}
+
+// SetVideoEncoderConfigurationRequest represents SetVideoEncoderConfiguration request
+type SetVideoEncoderConfigurationRequest struct {
+	XMLName          xml.Name                         `xml:"SetVideoEncoderConfiguration"`
+	Configuration    VideoEncoderConfigurationSetting `xml:"Configuration"`
+	ForcePersistence bool                             `xml:"ForcePersistence"`
+}
+
+// VideoEncoderConfigurationSetting is the requested configuration of SetVideoEncoderConfiguration.
+// Elements left out of the request keep their current value.
+type VideoEncoderConfigurationSetting struct {
+	Token       string              `xml:"token,attr"`
+	Encoding    string              `xml:"Encoding"`
+	Resolution  *ResolutionSetting  `xml:"Resolution"`
+	RateControl *RateControlSetting `xml:"RateControl"`
+}
+
+// ResolutionSetting represents a requested resolution
+type ResolutionSetting struct {
+	Width  int `xml:"Width"`
+	Height int `xml:"Height"`
+}
+
+// RateControlSetting represents a requested rate control
+type RateControlSetting struct {
+	FrameRateLimit   int `xml:"FrameRateLimit"`
+	EncodingInterval int `xml:"EncodingInterval"`
+	BitrateLimit     int `xml:"BitrateLimit"`
+}
+
+// SetVideoEncoderConfigurationResponse represents SetVideoEncoderConfiguration response
+type SetVideoEncoderConfigurationResponse struct {
+	XMLName xml.Name `xml:"trt:SetVideoEncoderConfigurationResponse"`
+}
+
+// GetVideoEncoderConfigurations handles GetVideoEncoderConfigurations request;
+// only configurations of cameras the user may access are listed
+func (s *Service) GetVideoEncoderConfigurations(user *auth.User) *GetVideoEncoderConfigurationsResponse {
+	resp := &GetVideoEncoderConfigurationsResponse{}
+	for _, p := range s.registry.GetAllProfiles() {
+		if !user.CanAccess(p.Camera.Config.Name) {
+			continue
+		}
+		resp.Configurations = append(resp.Configurations, *s.buildVideoEncoderConfiguration(&p))
+	}
+	return resp
+}
+
+// GetVideoEncoderConfiguration handles GetVideoEncoderConfiguration request
+func (s *Service) GetVideoEncoderConfiguration(token string, user *auth.User) (*GetVideoEncoderConfigurationResponse, error) {
+	profile, err := s.encoderProfile(token, user)
+	if err != nil {
+		return nil, err
+	}
+	return &GetVideoEncoderConfigurationResponse{
+		Configuration: *s.buildVideoEncoderConfiguration(profile),
+	}, nil
+}
+
+// GetVideoEncoderConfigurationOptions handles GetVideoEncoderConfigurationOptions request.
+// The resolution is fixed by the stream; frame rate and bitrate ranges are the firmware's.
+func (s *Service) GetVideoEncoderConfigurationOptions(req GetVideoEncoderConfigurationOptionsRequest, user *auth.User) (*GetVideoEncoderConfigurationOptionsResponse, error) {
+	var profile *camera.Profile
+	var err error
+	switch {
+	case req.ConfigurationToken != "":
+		profile, err = s.encoderProfile(req.ConfigurationToken, user)
+	case req.ProfileToken != "":
+		profile, err = s.registry.GetProfileByToken(req.ProfileToken)
+		if err != nil {
+			err = fmt.Errorf("%w: %s", ErrNoConfig, req.ProfileToken)
+		}
+	default:
+		for _, p := range s.registry.GetAllProfiles() {
+			if user.CanAccess(p.Camera.Config.Name) {
+				profile = &p
+				break
+			}
+		}
+		if profile == nil {
+			err = ErrNoConfig
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	width, height := ParseResolution(profile.Stream.Resolution)
+	h264 := H264Options{
+		ResolutionsAvailable: []Resolution{{Width: width, Height: height}},
+		// The firmware sets the GOP length to the frame rate
+		GovLengthRange:        IntRange{Min: camera.MinFrameRate, Max: camera.MaxFrameRate},
+		FrameRateRange:        IntRange{Min: camera.MinFrameRate, Max: camera.MaxFrameRate},
+		EncodingIntervalRange: IntRange{Min: 1, Max: 1},
+		H264ProfilesSupported: []string{"Main"},
+	}
+	options := VideoEncoderConfigurationOptions{
+		QualityRange: IntRange{Min: 4, Max: 4},
+		H264:         &h264,
+	}
+	if _, ok := profile.Stream.Channel(); ok {
+		options.Extension = &VideoEncoderOptionsExtension{
+			H264: &H264Options2{
+				H264Options:  h264,
+				BitrateRange: IntRange{Min: camera.MinBitrate, Max: camera.MaxBitrate},
+			},
+		}
+	}
+
+	return &GetVideoEncoderConfigurationOptionsResponse{Options: options}, nil
+}
+
+// SetVideoEncoderConfiguration handles SetVideoEncoderConfiguration request.
+// Frame rate and bitrate are sent to the camera; the frame rate is shared by all streams
+// of a camera. Resolution and encoding are fixed and can not be changed.
+func (s *Service) SetVideoEncoderConfiguration(req SetVideoEncoderConfigurationRequest, user *auth.User) error {
+	profile, err := s.encoderProfile(req.Configuration.Token, user)
+	if err != nil {
+		return err
+	}
+	setting := req.Configuration
+
+	if setting.Resolution != nil {
+		width, height := ParseResolution(profile.Stream.Resolution)
+		if setting.Resolution.Width != width || setting.Resolution.Height != height {
+			return fmt.Errorf("%w: resolution is fixed at %dx%d", ErrConfigModify, width, height)
+		}
+	}
+	if setting.Encoding != "" && setting.Encoding != "H264" {
+		return fmt.Errorf("%w: encoding %s is not supported", ErrConfigModify, setting.Encoding)
+	}
+	if setting.RateControl == nil {
+		return nil
+	}
+
+	rate := setting.RateControl
+	// The reported defaults are accepted as they are (see the encoder channel check below)
+	if rate.FrameRateLimit != 0 && rate.FrameRateLimit != defaultFrameRate && (rate.FrameRateLimit < camera.MinFrameRate || rate.FrameRateLimit > camera.MaxFrameRate) {
+		return fmt.Errorf("%w: frame rate %d out of range (%d-%d)", ErrConfigModify, rate.FrameRateLimit, camera.MinFrameRate, camera.MaxFrameRate)
+	}
+	if rate.EncodingInterval > 1 {
+		return fmt.Errorf("%w: encoding interval %d is not supported", ErrConfigModify, rate.EncodingInterval)
+	}
+	if rate.BitrateLimit != 0 && rate.BitrateLimit != defaultBitrate && (rate.BitrateLimit < camera.MinBitrate || rate.BitrateLimit > camera.MaxBitrate) {
+		return fmt.Errorf("%w: bitrate %d out of range (%d-%d)", ErrConfigModify, rate.BitrateLimit, camera.MinBitrate, camera.MaxBitrate)
+	}
+
+	// Clients usually send back the whole configuration, so only changed values are applied;
+	// a frame rate change restarts the camera's encoders
+	channel, ok := profile.Stream.Channel()
+	if !ok {
+		// Without an encoder channel the reported defaults can not be changed
+		if (rate.FrameRateLimit != 0 && rate.FrameRateLimit != defaultFrameRate) || (rate.BitrateLimit != 0 && rate.BitrateLimit != defaultBitrate) {
+			return fmt.Errorf("%w: encoder channel of stream %s is unknown", ErrConfigModify, profile.Stream.Path)
+		}
+		return nil
+	}
+
+	client := profile.Camera.Client
+	current, err := client.GetEncoderSettings(channel)
+	if err != nil {
+		return fmt.Errorf("failed to get encoder settings: %w", err)
+	}
+	if rate.BitrateLimit != 0 && (rate.BitrateLimit != current.Bitrate || current.BitrateAuto) {
+		log.Printf("SetVideoEncoderConfiguration: %s channel %d bitrate %d kbps", profile.Camera.Config.Name, channel, rate.BitrateLimit)
+		if err := client.SetBitrate(channel, rate.BitrateLimit); err != nil {
+			return fmt.Errorf("failed to set bitrate: %w", err)
+		}
+	}
+	if rate.FrameRateLimit != 0 && (rate.FrameRateLimit != current.FrameRate || current.FrameRateAuto) {
+		log.Printf("SetVideoEncoderConfiguration: %s frame rate %d fps", profile.Camera.Config.Name, rate.FrameRateLimit)
+		if err := client.SetFrameRate(rate.FrameRateLimit); err != nil {
+			return fmt.Errorf("failed to set frame rate: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// encoderProfile returns the profile of a video encoder configuration token
+func (s *Service) encoderProfile(token string, user *auth.User) (*camera.Profile, error) {
+	profileName, ok := strings.CutSuffix(token, encoderTokenSuffix)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNoConfig, token)
+	}
+	profile, err := s.registry.GetProfileByToken(profileName)
+	if err != nil || !user.CanAccess(profile.Camera.Config.Name) {
+		return nil, fmt.Errorf("%w: %s", ErrNoConfig, token)
+	}
+	return profile, nil
+}
+
+// RateControl returns the rate control of a profile: the camera's current frame rate and
+// the bitrate of the stream's encoder channel, or defaults if they can not be read
+func (s *Service) RateControl(p *camera.Profile) RateControl {
+	rate := RateControl{
+		FrameRateLimit:   defaultFrameRate,
+		EncodingInterval: 1,
+		BitrateLimit:     defaultBitrate,
+	}
+
+	channel, ok := p.Stream.Channel()
+	if !ok || !p.Camera.GetHealth() {
+		return rate
+	}
+	settings, err := p.Camera.Client.GetEncoderSettings(channel)
+	if err != nil {
+		log.Printf("Failed to get encoder settings of %s channel %d: %v", p.Camera.Config.Name, channel, err)
+		return rate
+	}
+	rate.FrameRateLimit = settings.FrameRate
+	rate.BitrateLimit = settings.Bitrate
+	return rate
+}
+
+// buildVideoEncoderConfiguration builds the video encoder configuration of a profile
+func (s *Service) buildVideoEncoderConfiguration(p *camera.Profile) *VideoEncoderConfiguration {
+	width, height := ParseResolution(p.Stream.Resolution)
+	rate := s.RateControl(p)
+	return &VideoEncoderConfiguration{
+		Token:    p.Stream.ProfileName + encoderTokenSuffix,
+		Name:     p.Stream.ProfileName + " Video Encoder",
+		UseCount: 1,
+		// ONVIF Profile S/T only supports JPEG, MPEG4, H264
+		// H265 is not part of the official ONVIF ver10 spec, so report as H264 for compatibility;
+		// the Media2 service reports the real codec
+		Encoding: "H264",
+		Resolution: Resolution{
+			Width:  width,
+			Height: height,
+		},
+		Quality:     4.0,
+		RateControl: rate,
+		H264: &H264Configuration{
+			GovLength:   rate.FrameRateLimit,
+			H264Profile: "Main",
+		},
+		SessionTimeout: "PT60S",
+	}
+}
@@ -0,0 +1,157 @@
+package media
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/auth"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/camera"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/config"
+)
+
+var testAdmin = &auth.User{Username: "admin", Level: auth.LevelAdministrator}
+
+// newEncoderTestService returns a Media service for a camera served by handler
+func newEncoderTestService(t *testing.T, handler http.HandlerFunc) *Service {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	host, portString, _ := net.SplitHostPort(server.Listener.Addr().String())
+	port, _ := strconv.Atoi(portString)
+
+	registry, err := camera.NewRegistry(&config.Config{Cameras: []config.CameraConfig{{
+		Name:     "garage",
+		Host:     host,
+		HTTPPort: port,
+		Streams: []config.StreamConfig{
+			{Path: "video0_unicast", Resolution: "1920x1080", Codec: "h264", ProfileName: "Garage_Main"},
+			{Path: "custom", Resolution: "640x360", Codec: "h264", ProfileName: "Garage_Custom"},
+		},
+	}}})
+	if err != nil {
+		t.Fatalf("failed to create registry: %v", err)
+	}
+	t.Cleanup(registry.Close)
+
+	return NewService(registry, "relay", 8554, 0)
+}
+
+// encoderCamera simulates the firmware's bitrate and fps commands and records the setters
+func encoderCamera(t *testing.T, commands *[]string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var request camera.CommandRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Fatalf("failed to decode request: %v", err)
+		}
+		switch request.Exec {
+		case "video bitrate 0":
+			fmt.Fprintln(w, "960")
+		case "video fps":
+			fmt.Fprintln(w, "20 isp:20/1 enc:20/1")
+		default:
+			*commands = append(*commands, request.Exec)
+			fmt.Fprintln(w, "ok")
+		}
+	}
+}
+
+func TestGetVideoEncoderConfigurationReportsCameraRateControl(t *testing.T) {
+	var commands []string
+	service := newEncoderTestService(t, encoderCamera(t, &commands))
+
+	resp, err := service.GetVideoEncoderConfiguration("Garage_Main_VEC", testAdmin)
+	if err != nil {
+		t.Fatalf("GetVideoEncoderConfiguration returned an error: %v", err)
+	}
+	want := RateControl{FrameRateLimit: 20, EncodingInterval: 1, BitrateLimit: 960}
+	if resp.Configuration.RateControl != want {
+		t.Fatalf("RateControl = %+v, want %+v", resp.Configuration.RateControl, want)
+	}
+
+	if _, err := service.GetVideoEncoderConfiguration("Unknown_VEC", testAdmin); !errors.Is(err, ErrNoConfig) {
+		t.Fatalf("unknown token error = %v, want ErrNoConfig", err)
+	}
+	viewer := &auth.User{Username: "viewer", Level: auth.LevelUser, Cameras: []string{"garden"}}
+	if _, err := service.GetVideoEncoderConfiguration("Garage_Main_VEC", viewer); !errors.Is(err, ErrNoConfig) {
+		t.Fatalf("inaccessible camera error = %v, want ErrNoConfig", err)
+	}
+}
+
+func TestSetVideoEncoderConfigurationSendsChangedValues(t *testing.T) {
+	var commands []string
+	service := newEncoderTestService(t, encoderCamera(t, &commands))
+
+	var req SetVideoEncoderConfigurationRequest
+	req.Configuration.Token = "Garage_Main_VEC"
+	req.Configuration.Encoding = "H264"
+	req.Configuration.Resolution = &ResolutionSetting{1920, 1080}
+	req.Configuration.RateControl = &RateControlSetting{FrameRateLimit: 20, EncodingInterval: 1, BitrateLimit: 1500}
+
+	if err := service.SetVideoEncoderConfiguration(req, testAdmin); err != nil {
+		t.Fatalf("SetVideoEncoderConfiguration returned an error: %v", err)
+	}
+	if len(commands) != 1 || commands[0] != "video bitrate 0 1500" {
+		t.Fatalf("commands = %q, want only the bitrate change", commands)
+	}
+}
+
+func TestSetVideoEncoderConfigurationRejectsFixedSettings(t *testing.T) {
+	var commands []string
+	service := newEncoderTestService(t, encoderCamera(t, &commands))
+
+	var resolution SetVideoEncoderConfigurationRequest
+	resolution.Configuration.Token = "Garage_Main_VEC"
+	resolution.Configuration.Resolution = &ResolutionSetting{1280, 720}
+	if err := service.SetVideoEncoderConfiguration(resolution, testAdmin); !errors.Is(err, ErrConfigModify) {
+		t.Fatalf("resolution change error = %v, want ErrConfigModify", err)
+	}
+
+	var bitrate SetVideoEncoderConfigurationRequest
+	bitrate.Configuration.Token = "Garage_Custom_VEC"
+	bitrate.Configuration.RateControl = &RateControlSetting{BitrateLimit: 500}
+	if err := service.SetVideoEncoderConfiguration(bitrate, testAdmin); !errors.Is(err, ErrConfigModify) {
+		t.Fatalf("bitrate change without encoder channel error = %v, want ErrConfigModify", err)
+	}
+
+	if len(commands) != 0 {
+		t.Fatalf("commands = %q, want none", commands)
+	}
+}
+
+func TestVideoEncoderConfigurationRoundTripWithoutEncoderChannel(t *testing.T) {
+	var commands []string
+	service := newEncoderTestService(t, encoderCamera(t, &commands))
+
+	resp, err := service.GetVideoEncoderConfiguration("Garage_Custom_VEC", testAdmin)
+	if err != nil {
+		t.Fatalf("GetVideoEncoderConfiguration returned an error: %v", err)
+	}
+	reported := resp.Configuration
+	if reported.RateControl.BitrateLimit < camera.MinBitrate || reported.RateControl.BitrateLimit > camera.MaxBitrate {
+		t.Fatalf("reported bitrate %d is outside %d-%d", reported.RateControl.BitrateLimit, camera.MinBitrate, camera.MaxBitrate)
+	}
+
+	// A client sends the reported configuration back unchanged
+	var req SetVideoEncoderConfigurationRequest
+	req.Configuration.Token = reported.Token
+	req.Configuration.Encoding = reported.Encoding
+	req.Configuration.Resolution = &ResolutionSetting{reported.Resolution.Width, reported.Resolution.Height}
+	req.Configuration.RateControl = &RateControlSetting{
+		FrameRateLimit:   reported.RateControl.FrameRateLimit,
+		EncodingInterval: reported.RateControl.EncodingInterval,
+		BitrateLimit:     reported.RateControl.BitrateLimit,
+	}
+	if err := service.SetVideoEncoderConfiguration(req, testAdmin); err != nil {
+		t.Fatalf("SetVideoEncoderConfiguration with the reported configuration returned an error: %v", err)
+	}
+	if len(commands) != 0 {
+		t.Fatalf("commands = %q, want none", commands)
+	}
+}
@@ -4,7 +4,10 @@ import (
 	"encoding/xml"
 	"fmt"
 	"log"
+	"net"
+	"strconv"
 
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/auth"
 	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/camera"
 )
 
@@ -27,6 +30,8 @@ type Profile struct {
 	VideoSourceConfiguration *VideoSourceConfiguration `xml:"tt:VideoSourceConfiguration,omitempty"`
 	VideoEncoderConfiguration *VideoEncoderConfiguration `xml:"tt:VideoEncoderConfiguration,omitempty"`
 	PTZConfiguration   *PTZConfiguration   `xml:"tt:PTZConfiguration,omitempty"`
+	AudioOutputConfiguration  *AudioOutputConfiguration  `xml:"tt:Extension>tt:AudioOutputConfiguration,omitempty"`
+	AudioDecoderConfiguration *AudioDecoderConfiguration `xml:"tt:Extension>tt:AudioDecoderConfiguration,omitempty"`
 }
 
 // VideoSourceConfiguration represents video source configuration
@@ -45,14 +50,26 @@ type Bounds struct {
 	Height int `xml:"height,attr"`
 }
 
+// encoderTokenSuffix is appended to the profile name to form the video encoder configuration token
+const encoderTokenSuffix = "_VEC"
+
 // VideoEncoderConfiguration represents video encoder configuration
 type VideoEncoderConfiguration struct {
 	Token      string     `xml:"token,attr"`
 	Name       string     `xml:"tt:Name"`
+	UseCount   int        `xml:"tt:UseCount"`
 	Encoding   string     `xml:"tt:Encoding"`
 	Resolution Resolution `xml:"tt:Resolution"`
 	Quality    float64    `xml:"tt:Quality"`
 	RateControl RateControl `xml:"tt:RateControl,omitempty"`
+	H264       *H264Configuration `xml:"tt:H264,omitempty"`
+	SessionTimeout string `xml:"tt:SessionTimeout"`
+}
+
+// H264Configuration represents H.264 encoder settings
+type H264Configuration struct {
+	GovLength   int    `xml:"tt:GovLength"`
+	H264Profile string `xml:"tt:H264Profile"`
 }
 
 // Resolution represents resolution
@@ -119,34 +136,53 @@ type GetSnapshotUriResponse struct {
 	MediaUri  MediaUri  `xml:"trt:MediaUri"`
 }
 
+// GetVideoSourcesRequest represents GetVideoSources request
+type GetVideoSourcesRequest struct {
+	XMLName xml.Name `xml:"GetVideoSources"`
+}
+
+// GetVideoSourcesResponse represents GetVideoSources response
+type GetVideoSourcesResponse struct {
+	XMLName      xml.Name      `xml:"trt:GetVideoSourcesResponse"`
+	VideoSources []VideoSource `xml:"trt:VideoSources"`
+}
+
+// VideoSource represents a physical video input (one per camera)
+type VideoSource struct {
+	Token      string     `xml:"token,attr"`
+	Framerate  float64    `xml:"tt:Framerate"`
+	Resolution Resolution `xml:"tt:Resolution"`
+}
+
 // Service represents the Media service
 type Service struct {
-	registry      *camera.Registry
-	mediamtxHost  string
-	mediamtxPort  int
-	snapshotHost  string
-	snapshotPort  int
+	registry        *camera.Registry
+	mediamtxHost    string
+	mediamtxPort    int
+	backchannelPort int // RTSP port of the relay's backchannel proxy (0 = disabled)
 }
 
 // NewService creates a new Media service
-func NewService(registry *camera.Registry, mediamtxHost string, mediamtxPort int, snapshotHost string, snapshotPort int) *Service {
+func NewService(registry *camera.Registry, mediamtxHost string, mediamtxPort, backchannelPort int) *Service {
 	return &Service{
-		registry:     registry,
-		mediamtxHost: mediamtxHost,
-		mediamtxPort: mediamtxPort,
-		snapshotHost: snapshotHost,
-		snapshotPort: snapshotPort,
+		registry:        registry,
+		mediamtxHost:    mediamtxHost,
+		mediamtxPort:    mediamtxPort,
+		backchannelPort: backchannelPort,
 	}
 }
 
-// GetProfiles handles GetProfiles request
-func (s *Service) GetProfiles() *GetProfilesResponse {
+// GetProfiles handles GetProfiles request; only profiles of cameras the user may access are listed
+func (s *Service) GetProfiles(user *auth.User) *GetProfilesResponse {
 	profiles := s.registry.GetAllProfiles()
 	resp := &GetProfilesResponse{
 		Profiles: make([]Profile, 0, len(profiles)),
 	}
 
 	for _, p := range profiles {
+		if !user.CanAccess(p.Camera.Config.Name) {
+			continue
+		}
 		profile := s.buildProfile(&p)
 		// Debug: Check PTZ configuration
 		if profile.PTZConfiguration != nil {
@@ -160,42 +196,108 @@ func (s *Service) GetProfiles() *GetProfilesResponse {
 	return resp
 }
 
-// GetStreamUri handles GetStreamUri request
-func (s *Service) GetStreamUri(profileToken string) (*GetStreamUriResponse, error) {
-	profile, err := s.registry.GetProfileByToken(profileToken)
+// GetVideoSources handles GetVideoSources request; only cameras the user may access are listed
+func (s *Service) GetVideoSources(user *auth.User) *GetVideoSourcesResponse {
+	resp := &GetVideoSourcesResponse{}
+
+	// Profiles are sorted by camera name; each camera is one video source
+	// with the resolution of its largest stream
+	var current *VideoSource
+	var currentCamera *camera.Camera
+	for _, p := range s.registry.GetAllProfiles() {
+		if !user.CanAccess(p.Camera.Config.Name) {
+			continue
+		}
+		if p.Camera != currentCamera {
+			resp.VideoSources = append(resp.VideoSources, VideoSource{
+				Token:     p.Camera.VideoSourceToken(),
+				Framerate: 30,
+			})
+			current = &resp.VideoSources[len(resp.VideoSources)-1]
+			currentCamera = p.Camera
+		}
+
+		width, height := ParseResolution(p.Stream.Resolution)
+		if width*height > current.Resolution.Width*current.Resolution.Height {
+			current.Resolution = Resolution{Width: width, Height: height}
+		}
+	}
+
+	return resp
+}
+
+// GetStreamUri handles GetStreamUri request; relayHost is the relay host advertised to the client
+func (s *Service) GetStreamUri(req GetStreamUriRequest, relayHost string) (*GetStreamUriResponse, error) {
+	profile, err := s.registry.GetProfileByToken(req.ProfileToken)
 	if err != nil {
-		return nil, fmt.Errorf("profile not found: %s", profileToken)
+		return nil, fmt.Errorf("profile not found: %s", req.ProfileToken)
+	}
+
+	// The backchannel proxy only carries RTP interleaved in the RTSP connection
+	var interleaved bool
+	switch req.StreamSetup.Transport.Protocol {
+	case "", "RTSP", "TCP":
+		interleaved = true
 	}
 
+	return &GetStreamUriResponse{
+		MediaUri: MediaUri{
+			Uri:                 s.StreamURI(profile, relayHost, interleaved),
+			InvalidAfterConnect: false,
+			InvalidAfterReboot:  false,
+			Timeout:             "PT1H",
+		},
+	}, nil
+}
+
+// StreamURI returns the RTSP URI of a profile: the stream's rtsp_url if configured,
+// otherwise its mediamtx path. With backchannel_port, the mediamtx path of a camera with
+// talk is served by the relay at relayHost, which adds the audio backchannel to the stream.
+// The relay only serves RTSP over TCP, so clients asking for another transport (interleaved
+// false) get the mediamtx path.
+func (s *Service) StreamURI(profile *camera.Profile, relayHost string, interleaved bool) string {
 	// Check if custom RTSP URL is configured
 	var rtspURL string
 	if profile.Stream.RTSPURL != "" {
 		// Use custom RTSP URL from configuration
 		rtspURL = profile.Stream.RTSPURL
-		log.Printf("GetStreamUri: Using custom RTSP URL for %s: %s", profileToken, rtspURL)
+		log.Printf("GetStreamUri: Using custom RTSP URL for %s: %s", profile.Stream.ProfileName, rtspURL)
+	} else if s.backchannelPort != 0 && s.mediamtxHost != "" && profile.Camera.Config.Talk.Enabled && interleaved {
+		// Build backchannel proxy URL: rtsp://{relay_host}:{backchannel_port}/{camera}/{stream}
+		rtspPath := fmt.Sprintf("%s/%s", profile.Camera.Config.Name, profile.Stream.Path)
+		rtspURL = fmt.Sprintf("rtsp://%s/%s", net.JoinHostPort(relayHost, strconv.Itoa(s.backchannelPort)), rtspPath)
+		log.Printf("GetStreamUri: Using backchannel proxy URL for %s: %s", profile.Stream.ProfileName, rtspURL)
 	} else {
 		// Build default RTSP URL: rtsp://{mediamtx_host}:{port}/{camera}/{stream}
 		rtspPath := fmt.Sprintf("%s/%s", profile.Camera.Config.Name, profile.Stream.Path)
 		rtspURL = fmt.Sprintf("rtsp://%s:%d/%s", s.mediamtxHost, s.mediamtxPort, rtspPath)
-		log.Printf("GetStreamUri: Using mediamtx URL for %s: %s", profileToken, rtspURL)
+		log.Printf("GetStreamUri: Using mediamtx URL for %s: %s", profile.Stream.ProfileName, rtspURL)
 	}
+	return rtspURL
+}
 
-	return &GetStreamUriResponse{
-		MediaUri: MediaUri{
-			Uri:                 rtspURL,
-			InvalidAfterConnect: false,
-			InvalidAfterReboot:  false,
-			Timeout:             "PT1H",
-		},
-	}, nil
+// SnapshotURI returns the relay snapshot URI of a profile's camera below baseURL, with the
+// size of the profile's snapshot_size
+func SnapshotURI(profile *camera.Profile, baseURL string) string {
+	// Build snapshot URL: {http|https}://{relay_host}:{port}/snapshot/{camera}
+	uri := fmt.Sprintf("%s/snapshot/%s", baseURL, profile.Camera.Config.Name)
+
+	// Profiles with snapshot_size get a snapshot scaled to fit that size
+	var width, height int
+	switch size := profile.Stream.SnapshotSize; size {
+	case "":
+		return uri
+	case "stream":
+		width, height = ParseResolution(profile.Stream.Resolution)
+	default:
+		width, height = ParseResolution(size)
+	}
+	return fmt.Sprintf("%s?width=%d&height=%d", uri, width, height)
 }
 
 // buildProfile builds a Profile from camera.Profile
 func (s *Service) buildProfile(p *camera.Profile) *Profile {
-	width, height := parseResolution(p.Stream.Resolution)
-	// ONVIF Profile S/T only supports JPEG, MPEG4, H264
-	// H265 is not part of the official ONVIF ver10 spec, so report as H264 for compatibility
-	encoding := "H264"
+	width, height := ParseResolution(p.Stream.Resolution)
 
 	profile := &Profile{
 		Token: p.Stream.ProfileName,
@@ -204,7 +306,7 @@ func (s *Service) buildProfile(p *camera.Profile) *Profile {
 		VideoSourceConfiguration: &VideoSourceConfiguration{
 			Token:       p.Stream.ProfileName + "_VSC",
 			Name:        p.Stream.ProfileName + " Video Source",
-			SourceToken: "VideoSource_1",
+			SourceToken: p.Camera.VideoSourceToken(),
 			Bounds: Bounds{
 				X:      0,
 				Y:      0,
@@ -212,21 +314,7 @@ func (s *Service) buildProfile(p *camera.Profile) *Profile {
 				Height: height,
 			},
 		},
-		VideoEncoderConfiguration: &VideoEncoderConfiguration{
-			Token:    p.Stream.ProfileName + "_VEC",
-			Name:     p.Stream.ProfileName + " Video Encoder",
-			Encoding: encoding,
-			Resolution: Resolution{
-				Width:  width,
-				Height: height,
-			},
-			Quality: 4.0,
-			RateControl: RateControl{
-				FrameRateLimit:   30,
-				EncodingInterval: 1,
-				BitrateLimit:     4096,
-			},
-		},
+		VideoEncoderConfiguration: s.buildVideoEncoderConfiguration(p),
 	}
 
 	// Add PTZ configuration if supported
@@ -234,26 +322,29 @@ func (s *Service) buildProfile(p *camera.Profile) *Profile {
 		profile.PTZConfiguration = &PTZConfiguration{
 			Token:     p.Stream.ProfileName + "_PTZ",
 			Name:      p.Stream.ProfileName + " PTZ",
-			NodeToken: "PTZNode_1",
+			NodeToken: p.Camera.PTZNodeToken(),
 		}
 	}
 
+	// Add the speaker if talk is enabled (ONVIF audio backchannel)
+	if p.Camera.Config.Talk.Enabled {
+		profile.AudioOutputConfiguration = buildAudioOutputConfiguration(p.Camera)
+		profile.AudioDecoderConfiguration = buildAudioDecoderConfiguration(p.Camera)
+	}
+
 	return profile
 }
 
-// GetSnapshotUri handles GetSnapshotUri request
-func (s *Service) GetSnapshotUri(profileToken string) (*GetSnapshotUriResponse, error) {
+// GetSnapshotUri handles GetSnapshotUri request; baseURL is the relay URL advertised to the client
+func (s *Service) GetSnapshotUri(profileToken, baseURL string) (*GetSnapshotUriResponse, error) {
 	profile, err := s.registry.GetProfileByToken(profileToken)
 	if err != nil {
 		return nil, fmt.Errorf("profile not found: %s", profileToken)
 	}
 
-	// Build snapshot URL: http://{relay_host}:{port}/snapshot/{camera}
-	snapshotURL := fmt.Sprintf("http://%s:%d/snapshot/%s", s.snapshotHost, s.snapshotPort, profile.Camera.Config.Name)
-
 	return &GetSnapshotUriResponse{
 		MediaUri: MediaUri{
-			Uri:                 snapshotURL,
+			Uri:                 SnapshotURI(profile, baseURL),
 			InvalidAfterConnect: false,
 			InvalidAfterReboot:  false,
 			Timeout:             "PT1H",
@@ -261,8 +352,8 @@ func (s *Service) GetSnapshotUri(profileToken string) (*GetSnapshotUriResponse,
 	}, nil
 }
 
-// parseResolution parses resolution string (e.g., "1920x1080") to width and height
-func parseResolution(res string) (int, int) {
+// ParseResolution parses resolution string (e.g., "1920x1080") to width and height
+func ParseResolution(res string) (int, int) {
 	var width, height int
 	fmt.Sscanf(res, "%dx%d", &width, &height)
 	if width == 0 || height == 0 {
@@ -0,0 +1,29 @@
+package media
+
+import (
+	"testing"
+
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/camera"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/config"
+)
+
+func TestSnapshotURIUsesProfileSnapshotSize(t *testing.T) {
+	cam := &camera.Camera{Config: &config.CameraConfig{Name: "garage"}}
+	tests := []struct {
+		size string
+		want string
+	}{
+		{"", "http://relay:8080/snapshot/garage"},
+		{"stream", "http://relay:8080/snapshot/garage?width=640&height=360"},
+		{"320x180", "http://relay:8080/snapshot/garage?width=320&height=180"},
+	}
+	for _, tt := range tests {
+		profile := &camera.Profile{
+			Camera: cam,
+			Stream: &config.StreamConfig{Resolution: "640x360", ProfileName: "Garage_Sub", SnapshotSize: tt.size},
+		}
+		if got := SnapshotURI(profile, "http://relay:8080"); got != tt.want {
+			t.Errorf("SnapshotURI(snapshot_size %q) = %s, want %s", tt.size, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,318 @@
+// Package media2 implements the ONVIF Media2 (ver20) service.
+// Unlike Media (ver10), Media2 can describe H.265 streams, so each profile reports the
+// codec configured for its stream.
+package media2
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/auth"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/camera"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/onvif/media"
+)
+
+// Configuration types of GetProfiles Type
+const (
+	ConfigurationAll          = "All"
+	ConfigurationVideoSource  = "VideoSource"
+	ConfigurationVideoEncoder = "VideoEncoder"
+	ConfigurationPTZ          = "PTZ"
+)
+
+// GetProfilesRequest represents GetProfiles request
+type GetProfilesRequest struct {
+	XMLName xml.Name `xml:"GetProfiles"`
+	Token   string   `xml:"Token,omitempty"`
+	Type    []string `xml:"Type,omitempty"`
+}
+
+// GetProfilesResponse represents GetProfiles response
+type GetProfilesResponse struct {
+	XMLName  xml.Name       `xml:"tr2:GetProfilesResponse"`
+	Profiles []MediaProfile `xml:"tr2:Profiles"`
+}
+
+// MediaProfile represents a Media2 profile
+type MediaProfile struct {
+	Token          string            `xml:"token,attr"`
+	Fixed          bool              `xml:"fixed,attr"`
+	Name           string            `xml:"tr2:Name"`
+	Configurations *ConfigurationSet `xml:"tr2:Configurations,omitempty"`
+}
+
+// ConfigurationSet represents the configurations of a Media2 profile
+type ConfigurationSet struct {
+	VideoSource  *VideoSourceConfiguration   `xml:"tr2:VideoSource,omitempty"`
+	VideoEncoder *VideoEncoder2Configuration `xml:"tr2:VideoEncoder,omitempty"`
+	PTZ          *PTZConfiguration           `xml:"tr2:PTZ,omitempty"`
+}
+
+// VideoSourceConfiguration represents video source configuration
+type VideoSourceConfiguration struct {
+	Token       string       `xml:"token,attr"`
+	Name        string       `xml:"tt:Name"`
+	UseCount    int          `xml:"tt:UseCount"`
+	SourceToken string       `xml:"tt:SourceToken"`
+	Bounds      media.Bounds `xml:"tt:Bounds"`
+}
+
+// VideoEncoder2Configuration represents a Media2 video encoder configuration
+type VideoEncoder2Configuration struct {
+	Token       string           `xml:"token,attr"`
+	GovLength   int              `xml:"GovLength,attr,omitempty"`
+	Profile     string           `xml:"Profile,attr,omitempty"`
+	Name        string           `xml:"tt:Name"`
+	UseCount    int              `xml:"tt:UseCount"`
+	Encoding    string           `xml:"tt:Encoding"`
+	Resolution  media.Resolution `xml:"tt:Resolution"`
+	RateControl *RateControl2    `xml:"tt:RateControl,omitempty"`
+	Quality     float64          `xml:"tt:Quality"`
+}
+
+// RateControl2 represents Media2 rate control
+type RateControl2 struct {
+	ConstantBitRate bool    `xml:"ConstantBitRate,attr,omitempty"`
+	FrameRateLimit  float64 `xml:"tt:FrameRateLimit"`
+	BitrateLimit    int     `xml:"tt:BitrateLimit"`
+}
+
+// PTZConfiguration represents the PTZ configuration reference of a profile
+type PTZConfiguration struct {
+	Token     string `xml:"token,attr"`
+	Name      string `xml:"tt:Name"`
+	UseCount  int    `xml:"tt:UseCount"`
+	NodeToken string `xml:"tt:NodeToken"`
+}
+
+// GetStreamUriRequest represents GetStreamUri request
+type GetStreamUriRequest struct {
+	XMLName      xml.Name `xml:"GetStreamUri"`
+	Protocol     string   `xml:"Protocol"`
+	ProfileToken string   `xml:"ProfileToken"`
+}
+
+// GetStreamUriResponse represents GetStreamUri response
+type GetStreamUriResponse struct {
+	XMLName xml.Name `xml:"tr2:GetStreamUriResponse"`
+	Uri     string   `xml:"tr2:Uri"`
+}
+
+// GetSnapshotUriRequest represents GetSnapshotUri request
+type GetSnapshotUriRequest struct {
+	XMLName      xml.Name `xml:"GetSnapshotUri"`
+	ProfileToken string   `xml:"ProfileToken"`
+}
+
+// GetSnapshotUriResponse represents GetSnapshotUri response
+type GetSnapshotUriResponse struct {
+	XMLName xml.Name `xml:"tr2:GetSnapshotUriResponse"`
+	Uri     string   `xml:"tr2:Uri"`
+}
+
+// GetConfigurationRequest represents the request of GetVideoEncoderConfigurations and
+// GetVideoSourceConfigurations; both tokens are optional filters
+type GetConfigurationRequest struct {
+	ConfigurationToken string `xml:"ConfigurationToken,omitempty"`
+	ProfileToken       string `xml:"ProfileToken,omitempty"`
+}
+
+// GetVideoEncoderConfigurationsResponse represents GetVideoEncoderConfigurations response
+type GetVideoEncoderConfigurationsResponse struct {
+	XMLName        xml.Name                     `xml:"tr2:GetVideoEncoderConfigurationsResponse"`
+	Configurations []VideoEncoder2Configuration `xml:"tr2:Configurations"`
+}
+
+// GetVideoSourceConfigurationsResponse represents GetVideoSourceConfigurations response
+type GetVideoSourceConfigurationsResponse struct {
+	XMLName        xml.Name                   `xml:"tr2:GetVideoSourceConfigurationsResponse"`
+	Configurations []VideoSourceConfiguration `xml:"tr2:Configurations"`
+}
+
+// Service represents the Media2 service
+type Service struct {
+	registry *camera.Registry
+	media    *media.Service // Shared stream URI construction
+}
+
+// NewService creates a new Media2 service using the profiles of the registry
+func NewService(registry *camera.Registry, mediaService *media.Service) *Service {
+	return &Service{
+		registry: registry,
+		media:    mediaService,
+	}
+}
+
+// GetProfiles handles GetProfiles request; only profiles of cameras the user may access
+// are listed. Type selects the configurations included (default: none).
+func (s *Service) GetProfiles(req GetProfilesRequest, user *auth.User) (*GetProfilesResponse, error) {
+	resp := &GetProfilesResponse{Profiles: []MediaProfile{}}
+
+	for _, p := range s.profiles(req.Token, user) {
+		profile := MediaProfile{
+			Token: p.Stream.ProfileName,
+			Fixed: true,
+			Name:  p.Stream.ProfileName,
+		}
+		if len(req.Type) > 0 {
+			profile.Configurations = s.buildConfigurations(&p, req.Type)
+		}
+		resp.Profiles = append(resp.Profiles, profile)
+	}
+
+	if req.Token != "" && len(resp.Profiles) == 0 {
+		return nil, fmt.Errorf("profile not found: %s", req.Token)
+	}
+	return resp, nil
+}
+
+// GetStreamUri handles GetStreamUri request. Only RTSP is served (by mediamtx, the relay's
+// backchannel proxy or the stream's rtsp_url); relayHost is the relay host advertised to the client.
+func (s *Service) GetStreamUri(req GetStreamUriRequest, relayHost string) (*GetStreamUriResponse, error) {
+	profile, err := s.registry.GetProfileByToken(req.ProfileToken)
+	if err != nil {
+		return nil, fmt.Errorf("profile not found: %s", req.ProfileToken)
+	}
+
+	// Only RTSP (RTP over the RTSP connection) can reach the backchannel proxy
+	switch req.Protocol {
+	case "", "RTSP":
+		return &GetStreamUriResponse{Uri: s.media.StreamURI(profile, relayHost, true)}, nil
+	case "RtspUnicast", "RtspOverHttp", "RTSPS":
+		return &GetStreamUriResponse{Uri: s.media.StreamURI(profile, relayHost, false)}, nil
+	}
+	return nil, fmt.Errorf("unsupported stream protocol: %s", req.Protocol)
+}
+
+// GetSnapshotUri handles GetSnapshotUri request; baseURL is the relay URL advertised to the client
+func (s *Service) GetSnapshotUri(profileToken, baseURL string) (*GetSnapshotUriResponse, error) {
+	profile, err := s.registry.GetProfileByToken(profileToken)
+	if err != nil {
+		return nil, fmt.Errorf("profile not found: %s", profileToken)
+	}
+
+	return &GetSnapshotUriResponse{Uri: media.SnapshotURI(profile, baseURL)}, nil
+}
+
+// GetVideoEncoderConfigurations handles GetVideoEncoderConfigurations request
+func (s *Service) GetVideoEncoderConfigurations(req GetConfigurationRequest, user *auth.User) (*GetVideoEncoderConfigurationsResponse, error) {
+	resp := &GetVideoEncoderConfigurationsResponse{Configurations: []VideoEncoder2Configuration{}}
+	for _, p := range s.profiles(req.ProfileToken, user) {
+		vec := s.buildVideoEncoderConfiguration(&p)
+		if req.ConfigurationToken != "" && vec.Token != req.ConfigurationToken {
+			continue
+		}
+		resp.Configurations = append(resp.Configurations, *vec)
+	}
+
+	if (req.ConfigurationToken != "" || req.ProfileToken != "") && len(resp.Configurations) == 0 {
+		return nil, fmt.Errorf("video encoder configuration not found")
+	}
+	return resp, nil
+}
+
+// GetVideoSourceConfigurations handles GetVideoSourceConfigurations request
+func (s *Service) GetVideoSourceConfigurations(req GetConfigurationRequest, user *auth.User) (*GetVideoSourceConfigurationsResponse, error) {
+	resp := &GetVideoSourceConfigurationsResponse{Configurations: []VideoSourceConfiguration{}}
+	for _, p := range s.profiles(req.ProfileToken, user) {
+		vsc := buildVideoSourceConfiguration(&p)
+		if req.ConfigurationToken != "" && vsc.Token != req.ConfigurationToken {
+			continue
+		}
+		resp.Configurations = append(resp.Configurations, *vsc)
+	}
+
+	if (req.ConfigurationToken != "" || req.ProfileToken != "") && len(resp.Configurations) == 0 {
+		return nil, fmt.Errorf("video source configuration not found")
+	}
+	return resp, nil
+}
+
+// profiles returns the registry profiles the user may access, optionally only the one with token
+func (s *Service) profiles(token string, user *auth.User) []camera.Profile {
+	var profiles []camera.Profile
+	for _, p := range s.registry.GetAllProfiles() {
+		if token != "" && p.Stream.ProfileName != token {
+			continue
+		}
+		if !user.CanAccess(p.Camera.Config.Name) {
+			continue
+		}
+		profiles = append(profiles, p)
+	}
+	return profiles
+}
+
+// buildConfigurations returns the configurations of a profile selected by types
+func (s *Service) buildConfigurations(p *camera.Profile, types []string) *ConfigurationSet {
+	include := func(t string) bool {
+		for _, typ := range types {
+			if typ == ConfigurationAll || typ == t {
+				return true
+			}
+		}
+		return false
+	}
+
+	set := &ConfigurationSet{}
+	if include(ConfigurationVideoSource) {
+		set.VideoSource = buildVideoSourceConfiguration(p)
+	}
+	if include(ConfigurationVideoEncoder) {
+		set.VideoEncoder = s.buildVideoEncoderConfiguration(p)
+	}
+	if include(ConfigurationPTZ) && p.Camera.Config.Capabilities.PTZ {
+		set.PTZ = &PTZConfiguration{
+			Token:     p.Stream.ProfileName + "_PTZ",
+			Name:      p.Stream.ProfileName + " PTZ",
+			UseCount:  1,
+			NodeToken: p.Camera.PTZNodeToken(),
+		}
+	}
+	return set
+}
+
+// buildVideoSourceConfiguration builds the video source configuration of a profile.
+// Tokens are shared with the Media (ver10) service.
+func buildVideoSourceConfiguration(p *camera.Profile) *VideoSourceConfiguration {
+	width, height := media.ParseResolution(p.Stream.Resolution)
+	return &VideoSourceConfiguration{
+		Token:       p.Stream.ProfileName + "_VSC",
+		Name:        p.Stream.ProfileName + " Video Source",
+		UseCount:    1,
+		SourceToken: p.Camera.VideoSourceToken(),
+		Bounds:      media.Bounds{Width: width, Height: height},
+	}
+}
+
+// buildVideoEncoderConfiguration builds the video encoder configuration of a profile
+// with the codec of its stream and the camera's current rate control
+func (s *Service) buildVideoEncoderConfiguration(p *camera.Profile) *VideoEncoder2Configuration {
+	width, height := media.ParseResolution(p.Stream.Resolution)
+	rate := s.media.RateControl(p)
+	return &VideoEncoder2Configuration{
+		Token:      p.Stream.ProfileName + "_VEC",
+		GovLength:  rate.FrameRateLimit,
+		Profile:    "Main",
+		Name:       p.Stream.ProfileName + " Video Encoder",
+		UseCount:   1,
+		Encoding:   Encoding(p.Stream.Codec),
+		Resolution: media.Resolution{Width: width, Height: height},
+		RateControl: &RateControl2{
+			FrameRateLimit: float64(rate.FrameRateLimit),
+			BitrateLimit:   rate.BitrateLimit,
+		},
+		Quality: 4.0,
+	}
+}
+
+// Encoding returns the Media2 encoding name of a configured stream codec
+func Encoding(codec string) string {
+	switch strings.ToLower(codec) {
+	case "h265", "hevc":
+		return "H265"
+	default:
+		return "H264"
+	}
+}
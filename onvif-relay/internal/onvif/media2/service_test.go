@@ -0,0 +1,111 @@
+package media2
+
+import (
+	"testing"
+
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/auth"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/camera"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/config"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/onvif/media"
+)
+
+func newMedia2TestService(t *testing.T) (*Service, func()) {
+	t.Helper()
+
+	registry, err := camera.NewRegistry(&config.Config{Cameras: []config.CameraConfig{
+		{
+			Name:         "garage",
+			Host:         "192.168.1.20",
+			HTTPPort:     80,
+			Capabilities: config.CapabilitiesConfig{PTZ: true},
+			Streams: []config.StreamConfig{
+				{Path: "video0_unicast", Resolution: "1920x1080", Codec: "h265", ProfileName: "Garage_Main"},
+				{Path: "video1_unicast", Resolution: "640x360", Codec: "h264", ProfileName: "Garage_Sub"},
+			},
+		},
+		{
+			Name:     "garden",
+			Host:     "192.168.1.30",
+			HTTPPort: 80,
+			Streams:  []config.StreamConfig{{Path: "video0_unicast", Resolution: "1920x1080", Codec: "hevc", ProfileName: "Garden_Main"}},
+		},
+	}})
+	if err != nil {
+		t.Fatalf("failed to create registry: %v", err)
+	}
+	// The cameras are not reachable; report the default rate control
+	for _, cam := range registry.List() {
+		cam.SetHealth(false)
+	}
+
+	return NewService(registry, media.NewService(registry, "relay", 8554, 0)), registry.Close
+}
+
+var testAdmin = &auth.User{Username: "admin", Level: auth.LevelAdministrator}
+
+func TestGetProfilesReportsStreamCodec(t *testing.T) {
+	service, closeService := newMedia2TestService(t)
+	defer closeService()
+
+	resp, err := service.GetProfiles(GetProfilesRequest{Type: []string{ConfigurationAll}}, testAdmin)
+	if err != nil {
+		t.Fatalf("GetProfiles returned an error: %v", err)
+	}
+
+	encodings := make(map[string]string)
+	for _, p := range resp.Profiles {
+		if p.Configurations == nil || p.Configurations.VideoEncoder == nil || p.Configurations.VideoSource == nil {
+			t.Fatalf("profile %s has no video configurations", p.Token)
+		}
+		encodings[p.Token] = p.Configurations.VideoEncoder.Encoding
+	}
+	want := map[string]string{"Garage_Main": "H265", "Garage_Sub": "H264", "Garden_Main": "H265"}
+	for token, encoding := range want {
+		if encodings[token] != encoding {
+			t.Errorf("encoding of %s = %q, want %q", token, encodings[token], encoding)
+		}
+	}
+}
+
+func TestGetProfilesFiltersCamerasAndConfigurations(t *testing.T) {
+	service, closeService := newMedia2TestService(t)
+	defer closeService()
+
+	viewer := &auth.User{Username: "viewer", Level: auth.LevelUser, Cameras: []string{"garden"}}
+	resp, err := service.GetProfiles(GetProfilesRequest{}, viewer)
+	if err != nil {
+		t.Fatalf("GetProfiles returned an error: %v", err)
+	}
+	if len(resp.Profiles) != 1 || resp.Profiles[0].Token != "Garden_Main" || resp.Profiles[0].Configurations != nil {
+		t.Fatalf("profiles = %+v, want only Garden_Main without configurations", resp.Profiles)
+	}
+
+	if _, err := service.GetProfiles(GetProfilesRequest{Token: "Garage_Main"}, viewer); err == nil {
+		t.Fatal("GetProfiles returned a profile of a camera the user may not access")
+	}
+}
+
+func TestGetVideoEncoderConfigurationsByProfile(t *testing.T) {
+	service, closeService := newMedia2TestService(t)
+	defer closeService()
+
+	resp, err := service.GetVideoEncoderConfigurations(GetConfigurationRequest{ProfileToken: "Garage_Sub"}, testAdmin)
+	if err != nil {
+		t.Fatalf("GetVideoEncoderConfigurations returned an error: %v", err)
+	}
+	if len(resp.Configurations) != 1 {
+		t.Fatalf("configurations = %+v, want one", resp.Configurations)
+	}
+	vec := resp.Configurations[0]
+	if vec.Token != "Garage_Sub_VEC" || vec.Encoding != "H264" || vec.Resolution.Width != 640 || vec.Resolution.Height != 360 {
+		t.Fatalf("configuration = %+v, want Garage_Sub H264 640x360", vec)
+	}
+
+	uri, err := service.GetStreamUri(GetStreamUriRequest{Protocol: "RtspUnicast", ProfileToken: "Garage_Main"}, "relay.lan")
+	if err != nil {
+		t.Fatalf("GetStreamUri returned an error: %v", err)
+	}
+	if uri.Uri != "rtsp://relay:8554/garage/video0_unicast" {
+		t.Fatalf("stream URI = %s, want mediamtx path", uri.Uri)
+	}
+}
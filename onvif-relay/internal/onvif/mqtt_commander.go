@@ -0,0 +1,74 @@
+package onvif
+
+import (
+	"fmt"
+
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/auth"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/camera"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/mqtt"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/onvif/ptz"
+)
+
+// mqttMoveStep is the RelativeMove translation of one MQTT move step
+const mqttMoveStep = 0.2
+
+// mqttUser is the identity of MQTT commands: they control the PTZ like an Operator
+var mqttUser = &auth.User{Username: "mqtt", Level: auth.LevelOperator}
+
+// mqttCommander executes MQTT PTZ commands through the PTZ service
+type mqttCommander struct {
+	ptzService *ptz.Service
+}
+
+// MQTTCommander returns the PTZ command handler used by the MQTT bridge
+func (s *Server) MQTTCommander() mqtt.Commander {
+	return &mqttCommander{ptzService: s.ptzService}
+}
+
+// SetMQTTPublisher makes MQTT action presets publish through the MQTT bridge
+func (s *Server) SetMQTTPublisher(bridge ptz.MQTTPublisher) {
+	s.ptzService.SetMQTTPublisher(bridge)
+}
+
+// Move moves the camera one step in a direction, or to its home position
+func (c *mqttCommander) Move(cam *camera.Camera, direction string) error {
+	profileToken, err := mqttProfileToken(cam)
+	if err != nil {
+		return err
+	}
+
+	var x, y float64
+	switch direction {
+	case mqtt.MoveHome:
+		return c.ptzService.GotoHomePosition(profileToken, nil, mqttUser)
+	case mqtt.MoveLeft:
+		x = -mqttMoveStep
+	case mqtt.MoveRight:
+		x = mqttMoveStep
+	case mqtt.MoveUp:
+		y = mqttMoveStep
+	case mqtt.MoveDown:
+		y = -mqttMoveStep
+	default:
+		return fmt.Errorf("invalid move direction: %s", direction)
+	}
+
+	return c.ptzService.RelativeMove(profileToken, ptz.PTZVector{PanTilt: &ptz.Vector2D{X: x, Y: y}}, nil, mqttUser)
+}
+
+// GotoPreset recalls a PTZ preset
+func (c *mqttCommander) GotoPreset(cam *camera.Camera, presetToken string) error {
+	profileToken, err := mqttProfileToken(cam)
+	if err != nil {
+		return err
+	}
+	return c.ptzService.GotoPreset(profileToken, presetToken, nil, mqttUser)
+}
+
+// mqttProfileToken returns the profile token used for PTZ commands of a camera
+func mqttProfileToken(cam *camera.Camera) (string, error) {
+	if len(cam.Config.Streams) == 0 {
+		return "", fmt.Errorf("camera has no profiles: %s", cam.Config.Name)
+	}
+	return cam.Config.Streams[0].ProfileName, nil
+}
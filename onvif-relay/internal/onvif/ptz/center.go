@@ -0,0 +1,180 @@
+package ptz
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/auth"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/camera"
+)
+
+const (
+	// translationSpaceFov is the ONVIF translation space in fractions of the field of view:
+	// -1 and 1 are the edges of the current picture
+	translationSpaceFov = "http://www.onvif.org/ver10/tptz/PanTiltSpaces/TranslationSpaceFov"
+	// centerRealm is the HTTP authentication realm of click-to-center requests
+	centerRealm = "ONVIF Relay PTZ"
+	// maxCenterRequestSize limits click-to-center request bodies
+	maxCenterRequestSize = 4 << 10
+)
+
+// ErrNoFOV is returned for field-of-view moves on a camera without ptz.horizontal_fov and ptz.vertical_fov
+var ErrNoFOV = errors.New("field of view is not configured")
+
+// CenterRequest is the body of POST /ptz/{camera}/center
+type CenterRequest struct {
+	X     float64 `json:"x"`               // 0 = left edge, 1 = right edge of the picture
+	Y     float64 `json:"y"`               // 0 = top edge, 1 = bottom edge of the picture
+	Speed int     `json:"speed,omitempty"` // AtomCam speed 1-9 (0 = default)
+}
+
+// CenterResponse is the response of POST /ptz/{camera}/center
+type CenterResponse struct {
+	Pan  int `json:"pan"`
+	Tilt int `json:"tilt"`
+}
+
+// Validate validates the image coordinates and speed of a click-to-center request
+func (r *CenterRequest) Validate() error {
+	if math.IsNaN(r.X) || math.IsNaN(r.Y) || r.X < 0 || r.X > 1 || r.Y < 0 || r.Y > 1 {
+		return fmt.Errorf("image coordinates out of range: (%g, %g) (must be 0-1)", r.X, r.Y)
+	}
+	if r.Speed < 0 || r.Speed > 9 {
+		return fmt.Errorf("speed out of range: %d (must be 1-9)", r.Speed)
+	}
+	return nil
+}
+
+// hasFOV reports whether the field of view of a camera is configured
+func hasFOV(cam *camera.Camera) bool {
+	return cam.Config.PTZ.HorizontalFOV > 0 && cam.Config.PTZ.VerticalFOV > 0
+}
+
+// fovTarget returns the pan/tilt that brings a point of the current picture to its center.
+// x and y are in the FOV translation space of the camera's frame (x right, y up).
+func fovTarget(cam *camera.Camera, currentPan, currentTilt int, x, y float64) (pan, tilt int) {
+	halfHorizontalFOV := cam.Config.PTZ.HorizontalFOV / 2.0
+	halfVerticalFOV := cam.Config.PTZ.VerticalFOV / 2.0
+
+	// ONVIF y is positive up, which is towards AtomCam tilt 0
+	deltaPan := int(math.Round(x * halfHorizontalFOV))
+	deltaTilt := int(math.Round(-y * halfVerticalFOV))
+	return currentPan + deltaPan, currentTilt + deltaTilt
+}
+
+// CenterOn moves a camera so that a point of the picture becomes its center. x and y are
+// image coordinates from 0 to 1 with the origin at the top left, as seen by the viewer
+// (e.g. a click on a snapshot). It returns the target position.
+func (s *Service) CenterOn(cam *camera.Camera, x, y float64, speed int, user *auth.User) (pan, tilt int, err error) {
+	if !cam.Config.Capabilities.PTZ {
+		return 0, 0, fmt.Errorf("camera does not support PTZ: %s", cam.Config.Name)
+	}
+	if !hasFOV(cam) {
+		return 0, 0, fmt.Errorf("%w: %s", ErrNoFOV, cam.Config.Name)
+	}
+	req := CenterRequest{X: x, Y: y, Speed: speed}
+	if err := req.Validate(); err != nil {
+		return 0, 0, err
+	}
+	if speed == 0 {
+		speed = moveSpeed(nil)
+	}
+
+	err = cam.ArbitratePTZ(userController(user), func() error {
+		s.interruptTour(cam)
+
+		// Image coordinates to the FOV translation space of the viewer, then to the camera's frame
+		fovX, fovY := frameOf(cam).mapXY(x*2-1, 1-y*2)
+		currentPan, currentTilt := currentPTZPosition(cam, "Center")
+		pan, tilt = fovTarget(cam, currentPan, currentTilt, fovX, fovY)
+		var err error
+		pan, tilt, err = cam.LimitPTZ(pan, tilt)
+		if err != nil {
+			return err
+		}
+
+		log.Printf("PTZ Center: %s image=(%.3f, %.3f), current=(%d, %d), new AtomCam=(%d, %d)",
+			cam.Config.Name, x, y, currentPan, currentTilt, pan, tilt)
+		return cam.MovePTZ(pan, tilt, speed)
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return pan, tilt, nil
+}
+
+// CenterHandler returns an HTTP handler for POST /ptz/{camera}/center with a CenterRequest body
+func (s *Service) CenterHandler(users *auth.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		defer r.Body.Close()
+
+		// Extract camera name from path: /ptz/{camera}/center
+		cameraName, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/ptz/"), "/center")
+		if !ok || cameraName == "" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		if strings.Contains(cameraName, "/") || strings.Contains(cameraName, "..") || strings.Contains(cameraName, "\\") {
+			log.Printf("Invalid PTZ camera name attempted: %s", cameraName)
+			http.Error(w, "invalid camera name", http.StatusBadRequest)
+			return
+		}
+
+		// Moving the camera requires the Operator level and access to the camera
+		user, ok := users.AuthorizeRequest(w, r, centerRealm, auth.LevelOperator, cameraName)
+		if !ok {
+			return
+		}
+
+		cam, err := s.registry.Get(cameraName)
+		if err != nil {
+			http.Error(w, "camera not found", http.StatusNotFound)
+			return
+		}
+		if !cam.Config.Capabilities.PTZ {
+			http.Error(w, "PTZ not supported", http.StatusNotFound)
+			return
+		}
+
+		var req CenterRequest
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxCenterRequestSize)).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+		if err := req.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		pan, tilt, err := s.CenterOn(cam, req.X, req.Y, req.Speed, user)
+		if err != nil {
+			log.Printf("PTZ Center: %s: %v", cameraName, err)
+			status := http.StatusBadGateway
+			switch {
+			case errors.Is(err, camera.ErrNoGoZone):
+				status = http.StatusForbidden
+			case errors.Is(err, ErrNoFOV):
+				status = http.StatusConflict
+			case errors.Is(err, camera.ErrPTZLocked), errors.Is(err, camera.ErrPTZSuperseded):
+				status = http.StatusLocked
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(CenterResponse{Pan: pan, Tilt: tilt}); err != nil {
+			log.Printf("PTZ Center: failed to write response: %v", err)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package ptz
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/auth"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/config"
+)
+
+func TestCenterHandlerCentersOnClick(t *testing.T) {
+	service, commands, closeService := newTrackingTestService(t, nil)
+	defer closeService()
+
+	users, err := auth.NewStore(config.ServerConfig{
+		Auth:  config.AuthConfig{Username: "admin", Password: "secret"},
+		Users: []config.UserConfig{{Username: "viewer", Password: "secret", Level: config.UserLevelUser}},
+	}, filepath.Join(t.TempDir(), "users.json"))
+	if err != nil {
+		t.Fatalf("failed to create user store: %v", err)
+	}
+	handler := service.CenterHandler(users)
+	post := func(username, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/ptz/swing/center", strings.NewReader(body))
+		req.SetBasicAuth(username, "secret")
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	if rec := post("viewer", `{"x":0.75,"y":0.25}`); rec.Code != http.StatusForbidden {
+		t.Fatalf("viewer status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+	if rec := post("admin", `{"x":0.75,"y":0.25}`); rec.Code != http.StatusConflict {
+		t.Fatalf("status without FOV = %d, want %d", rec.Code, http.StatusConflict)
+	}
+
+	cam, err := service.registry.Get("swing")
+	if err != nil {
+		t.Fatalf("camera not found: %v", err)
+	}
+	cam.Config.PTZ.HorizontalFOV = 120
+	cam.Config.PTZ.VerticalFOV = 60
+
+	if rec := post("admin", `{"x":1.5,"y":0.25}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("status for x=1.5 = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+
+	// Up and right of the center of a 120x60 degree picture, from MOTORPOS (120, 45)
+	rec := post("admin", `{"x":0.75,"y":0.25}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	var resp CenterResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil || resp != (CenterResponse{Pan: 150, Tilt: 30}) {
+		t.Fatalf("response = %+v, %v, want pan 150 tilt 30", resp, err)
+	}
+	if command := <-commands; command != "move 150 30 5" {
+		t.Fatalf("command = %q, want move 150 30 5", command)
+	}
+}
+
+func TestRelativeMoveInFOVSpace(t *testing.T) {
+	service, commands, closeService := newTrackingTestService(t, nil)
+	defer closeService()
+
+	translation := PTZVector{PanTilt: &Vector2D{Space: translationSpaceFov, X: -0.5, Y: -1}}
+	if err := service.RelativeMove("Main", translation, nil, nil); !errors.Is(err, ErrNoFOV) {
+		t.Fatalf("RelativeMove error = %v, want ErrNoFOV", err)
+	}
+
+	cam, err := service.registry.Get("swing")
+	if err != nil {
+		t.Fatalf("camera not found: %v", err)
+	}
+	cam.Config.PTZ.HorizontalFOV = 120
+	cam.Config.PTZ.VerticalFOV = 60
+
+	spaces := service.GetNodes().PTZNode[0].SupportedPTZSpaces.RelativePanTiltTranslationSpace
+	if len(spaces) != 2 || spaces[1].URI != translationSpaceFov {
+		t.Fatalf("translation spaces = %+v, want TranslationSpaceFov advertised", spaces)
+	}
+
+	// Half the picture left and its bottom edge, from MOTORPOS (120, 45)
+	if err := service.RelativeMove("Main", translation, nil, nil); err != nil {
+		t.Fatalf("RelativeMove returned an error: %v", err)
+	}
+	if command := <-commands; command != "move 90 75 5" {
+		t.Fatalf("command = %q, want move 90 75 5", command)
+	}
+}
@@ -2,8 +2,34 @@ package ptz
 
 import (
 	"math"
+
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/camera"
 )
 
+// viewFrame maps ONVIF pan/tilt between the viewer's frame of reference and the camera's.
+// Mirroring an axis of the generic spaces negates it, so the mapping is its own inverse.
+type viewFrame struct {
+	mirrorPan  bool
+	mirrorTilt bool
+}
+
+// frameOf returns the view frame of a camera from its mount orientation
+func frameOf(cam *camera.Camera) viewFrame {
+	pan, tilt := cam.ViewMirror()
+	return viewFrame{mirrorPan: pan, mirrorTilt: tilt}
+}
+
+// mapXY maps an ONVIF position, translation or velocity between the two frames
+func (f viewFrame) mapXY(x, y float64) (float64, float64) {
+	if f.mirrorPan {
+		x = -x
+	}
+	if f.mirrorTilt {
+		y = -y
+	}
+	return x, y
+}
+
 // ONVIFToAtomCam converts ONVIF coordinates to AtomCam coordinates
 // ONVIF: x, y ∈ [-1.0, 1.0], velocity ∈ [0.0, 1.0]
 // AtomCam: pan ∈ [0, 355], tilt ∈ [0, 180], speed ∈ [1, 9]
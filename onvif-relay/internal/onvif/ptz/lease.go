@@ -0,0 +1,86 @@
+package ptz
+
+import (
+	"time"
+
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/auth"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/camera"
+)
+
+const (
+	// tourPriority is the PTZ lease priority of preset tours, below every user level so that
+	// clients take over from them (unless a user's ptz_priority is set at or below it)
+	tourPriority = 5
+	// leasePollInterval is how often a waiting tour checks whether the PTZ lease was released
+	leasePollInterval = 250 * time.Millisecond
+)
+
+// levelPriorities are the PTZ lease priorities of the user levels; ptz_priority of a user
+// in config.yaml overrides them
+var levelPriorities = map[auth.Level]int{
+	auth.LevelAnonymous:     10,
+	auth.LevelUser:          20,
+	auth.LevelOperator:      30,
+	auth.LevelAdministrator: 40,
+}
+
+// PTZLock is the client controlling the PTZ of a camera, reported in PTZStatus as an
+// extension element in the relay's own namespace
+type PTZLock struct {
+	Holder   string `xml:"Holder"`
+	Priority int    `xml:"Priority"`
+	Until    string `xml:"Until"`
+}
+
+// userController returns the PTZ controller of a user (nil is Anonymous)
+func userController(user *auth.User) camera.PTZController {
+	if user == nil {
+		user = auth.Anonymous
+	}
+	name := user.Username
+	if name == "" {
+		name = "anonymous"
+	}
+	priority := user.PTZPriority
+	if priority == 0 {
+		priority = levelPriorities[user.Level]
+	}
+	if priority == 0 {
+		priority = levelPriorities[auth.LevelAnonymous]
+	}
+	return camera.PTZController{Name: name, Priority: priority}
+}
+
+// tourController returns the PTZ controller of a preset tour
+func tourController(tour StoredTour) camera.PTZController {
+	return camera.PTZController{Name: "tour " + tour.Token, Priority: tourPriority}
+}
+
+// ptzLock returns the PTZ lock of a camera, or nil when nobody controls it
+func ptzLock(cam *camera.Camera) *PTZLock {
+	lease, ok := cam.PTZLease()
+	if !ok {
+		return nil
+	}
+	return &PTZLock{
+		Holder:   lease.Holder.Name,
+		Priority: lease.Holder.Priority,
+		Until:    lease.Expires.UTC().Format(time.RFC3339),
+	}
+}
+
+// waitForLease waits while a client other than the tour controls the camera, so that a
+// tour does not fight a client that has not released the PTZ yet. It returns false when
+// the tour is stopped meanwhile.
+func (r *tourRun) waitForLease(cam *camera.Camera) bool {
+	ctl := tourController(r.tour)
+	for {
+		lease, ok := cam.PTZLease()
+		if !ok || lease.Holder.Name == ctl.Name {
+			return true
+		}
+		if !r.sleep(min(time.Until(lease.Expires), leasePollInterval)) {
+			return false
+		}
+	}
+}
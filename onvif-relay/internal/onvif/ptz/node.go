@@ -0,0 +1,209 @@
+package ptz
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/camera"
+)
+
+const (
+	// ptzConfigurationSuffix makes the PTZ configuration token of a profile; media profiles use the same tokens
+	ptzConfigurationSuffix = "_PTZ"
+
+	positionGenericSpace    = "http://www.onvif.org/ver10/tptz/PanTiltSpaces/PositionGenericSpace"
+	translationGenericSpace = "http://www.onvif.org/ver10/tptz/PanTiltSpaces/TranslationGenericSpace"
+	velocityGenericSpace    = "http://www.onvif.org/ver10/tptz/PanTiltSpaces/VelocityGenericSpace"
+	genericSpeedSpace       = "http://www.onvif.org/ver10/tptz/PanTiltSpaces/GenericSpeedSpace"
+)
+
+// Node and configuration errors; the ONVIF server maps them to fault subcodes
+var (
+	ErrNoEntity = errors.New("PTZ node token does not exist")
+	ErrNoConfig = errors.New("PTZ configuration token does not exist")
+)
+
+// GetNodeRequest represents GetNode request
+type GetNodeRequest struct {
+	XMLName   xml.Name `xml:"GetNode"`
+	NodeToken string   `xml:"NodeToken"`
+}
+
+// GetNodeResponse represents GetNode response
+type GetNodeResponse struct {
+	XMLName xml.Name `xml:"tptz:GetNodeResponse"`
+	PTZNode PTZNode  `xml:"PTZNode"`
+}
+
+// GetConfigurationRequest represents GetConfiguration request
+type GetConfigurationRequest struct {
+	XMLName               xml.Name `xml:"GetConfiguration"`
+	PTZConfigurationToken string   `xml:"PTZConfigurationToken"`
+}
+
+// GetConfigurationResponse represents GetConfiguration response
+type GetConfigurationResponse struct {
+	XMLName          xml.Name         `xml:"tptz:GetConfigurationResponse"`
+	PTZConfiguration PTZConfiguration `xml:"PTZConfiguration"`
+}
+
+// GetConfigurationOptionsRequest represents GetConfigurationOptions request
+type GetConfigurationOptionsRequest struct {
+	XMLName            xml.Name `xml:"GetConfigurationOptions"`
+	ConfigurationToken string   `xml:"ConfigurationToken"`
+}
+
+// GetConfigurationOptionsResponse represents GetConfigurationOptions response
+type GetConfigurationOptionsResponse struct {
+	XMLName                 xml.Name                `xml:"tptz:GetConfigurationOptionsResponse"`
+	PTZConfigurationOptions PTZConfigurationOptions `xml:"tptz:PTZConfigurationOptions"`
+}
+
+// PTZConfigurationOptions represents the spaces and timeouts a PTZ configuration supports
+type PTZConfigurationOptions struct {
+	Spaces     PTZSpaces     `xml:"tt:Spaces"`
+	PTZTimeout DurationRange `xml:"tt:PTZTimeout"`
+}
+
+// GetNode handles GetNode request
+func (s *Service) GetNode(token string) (*GetNodeResponse, error) {
+	for _, cam := range s.registry.List() {
+		if cam.Config.Capabilities.PTZ && cam.PTZNodeToken() == token {
+			return &GetNodeResponse{PTZNode: ptzNode(cam)}, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrNoEntity, token)
+}
+
+// GetConfiguration handles GetConfiguration request
+func (s *Service) GetConfiguration(token string) (*GetConfigurationResponse, error) {
+	profile, err := s.configurationProfile(token)
+	if err != nil {
+		return nil, err
+	}
+	return &GetConfigurationResponse{PTZConfiguration: ptzConfiguration(profile)}, nil
+}
+
+// GetConfigurationOptions handles GetConfigurationOptions request
+func (s *Service) GetConfigurationOptions(token string) (*GetConfigurationOptionsResponse, error) {
+	profile, err := s.configurationProfile(token)
+	if err != nil {
+		return nil, err
+	}
+	return &GetConfigurationOptionsResponse{
+		PTZConfigurationOptions: PTZConfigurationOptions{
+			Spaces: ptzSpaces(profile.Camera),
+			PTZTimeout: DurationRange{
+				Min: "PT1S",
+				Max: "PT60S",
+			},
+		},
+	}, nil
+}
+
+// configurationProfile returns the PTZ profile of a PTZ configuration token
+func (s *Service) configurationProfile(token string) (*camera.Profile, error) {
+	profileToken, ok := strings.CutSuffix(token, ptzConfigurationSuffix)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNoConfig, token)
+	}
+	profile, err := s.registry.GetProfileByToken(profileToken)
+	if err != nil || !profile.Camera.Config.Capabilities.PTZ {
+		return nil, fmt.Errorf("%w: %s", ErrNoConfig, token)
+	}
+	return profile, nil
+}
+
+// ptzNode builds the PTZ node of a camera
+func ptzNode(cam *camera.Camera) PTZNode {
+	return PTZNode{
+		Token:                  cam.PTZNodeToken(),
+		Name:                   cam.Config.Name + " PTZ",
+		SupportedPTZSpaces:     ptzSpaces(cam),
+		MaximumNumberOfPresets: len(cam.Config.PTZ.Presets) + maxStoredPresets, // config.yaml presets plus saved ones
+		HomeSupported:          true,
+		AuxiliaryCommands: []string{
+			trackingAuxiliaryOn,
+			trackingAuxiliaryOff,
+		},
+		Extension: &PTZNodeExtension{
+			SupportedPresetTour: &PTZPresetTourSupported{
+				MaximumNumberOfPresetTours: maxStoredTours,
+				PTZPresetTourOperation:     []string{TourOperationStart, TourOperationStop, TourOperationPause},
+			},
+		},
+	}
+}
+
+// ptzConfiguration builds the PTZ configuration of a profile
+func ptzConfiguration(profile *camera.Profile) PTZConfiguration {
+	return PTZConfiguration{
+		Token:     profile.Stream.ProfileName + ptzConfigurationSuffix,
+		Name:      profile.Stream.ProfileName + " PTZ",
+		NodeToken: profile.Camera.PTZNodeToken(),
+		DefaultPTZSpeed: &PTZSpeed{
+			PanTilt: &Vector2D{
+				Space: velocityGenericSpace,
+				X:     0.5,
+				Y:     0.5,
+			},
+		},
+		DefaultPTZTimeout: "PT10S",
+		PanTiltLimits: &PanTiltLimits{
+			Range: positionSpace(profile.Camera),
+		},
+	}
+}
+
+// ptzSpaces returns the coordinate spaces of a camera. The absolute position space
+// is reduced to the camera's ptz.limits; TranslationSpaceFov needs the field of view.
+func ptzSpaces(cam *camera.Camera) PTZSpaces {
+	spaces := PTZSpaces{
+		AbsolutePanTiltPositionSpace: []Space2D{positionSpace(cam)},
+		RelativePanTiltTranslationSpace: []Space2D{
+			{
+				URI:    translationGenericSpace,
+				XRange: Range{Min: -1.0, Max: 1.0},
+				YRange: Range{Min: -1.0, Max: 1.0},
+			},
+		},
+		ContinuousPanTiltVelocitySpace: []Space2D{
+			{
+				URI:    velocityGenericSpace,
+				XRange: Range{Min: -1.0, Max: 1.0},
+				YRange: Range{Min: -1.0, Max: 1.0},
+			},
+		},
+		PanTiltSpeedSpace: []Space1D{
+			{
+				URI:    genericSpeedSpace,
+				XRange: Range{Min: 0.0, Max: 1.0},
+			},
+		},
+	}
+	if hasFOV(cam) {
+		// RelativeMove in fractions of the field of view (click-to-center)
+		spaces.RelativePanTiltTranslationSpace = append(spaces.RelativePanTiltTranslationSpace, Space2D{
+			URI:    translationSpaceFov,
+			XRange: Range{Min: -1.0, Max: 1.0},
+			YRange: Range{Min: -1.0, Max: 1.0},
+		})
+	}
+	return spaces
+}
+
+// positionSpace returns the generic position space a camera can reach within its
+// ptz.limits, in the viewer's frame of reference
+func positionSpace(cam *camera.Camera) Space2D {
+	panMin, panMax, tiltMin, tiltMax := cam.PTZBounds()
+	frame := frameOf(cam)
+	x1, y1 := frame.mapXY(AtomCamToONVIF(panMin, tiltMin))
+	x2, y2 := frame.mapXY(AtomCamToONVIF(panMax, tiltMax))
+	return Space2D{
+		URI:    positionGenericSpace,
+		XRange: Range{Min: min(x1, x2), Max: max(x1, x2)},
+		YRange: Range{Min: min(y1, y2), Max: max(y1, y2)},
+	}
+}
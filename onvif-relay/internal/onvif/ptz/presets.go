@@ -0,0 +1,224 @@
+package ptz
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/camera"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/config"
+)
+
+const (
+	// maxStoredPresets limits the presets saved with SetPreset per camera
+	maxStoredPresets = 32
+	// maxPresetNameLength limits the names of presets saved with SetPreset
+	maxPresetNameLength = 64
+	// storedPresetTokenFormat is the token format of new presets saved with SetPreset
+	storedPresetTokenFormat = "preset_%d"
+)
+
+// Preset errors; the ONVIF server maps them to fault subcodes
+var (
+	ErrNoToken           = errors.New("preset token does not exist")
+	ErrPresetExist       = errors.New("preset name already exists")
+	ErrTooManyPresets    = errors.New("maximum number of presets reached")
+	ErrInvalidPresetName = errors.New("invalid preset name")
+	ErrFixedPreset       = errors.New("preset is defined in the configuration file")
+)
+
+// SetPresetRequest represents SetPreset request
+type SetPresetRequest struct {
+	XMLName      xml.Name `xml:"SetPreset"`
+	ProfileToken string   `xml:"ProfileToken"`
+	PresetName   string   `xml:"PresetName,omitempty"`
+	PresetToken  string   `xml:"PresetToken,omitempty"`
+}
+
+// SetPresetResponse represents SetPreset response
+type SetPresetResponse struct {
+	XMLName     xml.Name `xml:"tptz:SetPresetResponse"`
+	PresetToken string   `xml:"tptz:PresetToken"`
+}
+
+// RemovePresetRequest represents RemovePreset request
+type RemovePresetRequest struct {
+	XMLName      xml.Name `xml:"RemovePreset"`
+	ProfileToken string   `xml:"ProfileToken"`
+	PresetToken  string   `xml:"PresetToken"`
+}
+
+// RemovePresetResponse represents RemovePreset response
+type RemovePresetResponse struct {
+	XMLName xml.Name `xml:"tptz:RemovePresetResponse"`
+}
+
+// presetEntry is a preset of a camera from config.yaml or saved with SetPreset
+type presetEntry struct {
+	Token  string
+	Name   string
+	Pan    int
+	Tilt   int
+	Action *config.PTZPreset // Config preset with an MQTT or tracking action instead of a position
+}
+
+// isActionPreset reports whether a config preset runs an action instead of moving the camera
+func isActionPreset(p *config.PTZPreset) bool {
+	return p.Tracking != "" || p.MQTTTopic != ""
+}
+
+// presets returns the presets of a camera: config.yaml presets first, then presets saved
+// with SetPreset. A saved preset with the token of a config position preset replaces it.
+func (s *Service) presets(cam *camera.Camera) []presetEntry {
+	stored := s.store.Presets(cam.Config.Name)
+	overrides := make(map[string]StoredPreset, len(stored))
+	for _, p := range stored {
+		overrides[p.Token] = p
+	}
+
+	var entries []presetEntry
+	for i := range cam.Config.PTZ.Presets {
+		p := &cam.Config.PTZ.Presets[i]
+		token := p.Token
+		if token == "" {
+			token = fmt.Sprintf("%d", i+1) // Default to 1-based index
+		}
+
+		entry := presetEntry{Token: token, Name: p.Name, Pan: p.Pan, Tilt: p.Tilt}
+		if isActionPreset(p) {
+			entry.Action = p
+		} else if override, ok := overrides[token]; ok {
+			entry.Name, entry.Pan, entry.Tilt = override.Name, override.Pan, override.Tilt
+		}
+		delete(overrides, token)
+		entries = append(entries, entry)
+	}
+
+	for _, p := range stored {
+		if _, ok := overrides[p.Token]; ok {
+			entries = append(entries, presetEntry{Token: p.Token, Name: p.Name, Pan: p.Pan, Tilt: p.Tilt})
+		}
+	}
+	return entries
+}
+
+// findPreset returns the preset of a camera with a token
+func (s *Service) findPreset(cam *camera.Camera, token string) (presetEntry, bool) {
+	return findEntry(s.presets(cam), token)
+}
+
+// SetPreset handles SetPreset request: it saves the current camera position as a new preset,
+// or as the new position of an existing one when PresetToken is given.
+// Action presets from config.yaml are read-only.
+func (s *Service) SetPreset(req SetPresetRequest) (*SetPresetResponse, error) {
+	profile, err := s.registry.GetProfileByToken(req.ProfileToken)
+	if err != nil {
+		return nil, fmt.Errorf("profile not found: %s", req.ProfileToken)
+	}
+	cam := profile.Camera
+	if !cam.Config.Capabilities.PTZ {
+		return nil, fmt.Errorf("camera does not support PTZ: %s", cam.Config.Name)
+	}
+
+	name := strings.TrimSpace(req.PresetName)
+	if utf8.RuneCountInString(name) > maxPresetNameLength {
+		return nil, fmt.Errorf("%w: longer than %d characters", ErrInvalidPresetName, maxPresetNameLength)
+	}
+
+	s.presetMu.Lock()
+	defer s.presetMu.Unlock()
+
+	entries := s.presets(cam)
+	token := req.PresetToken
+	if token != "" {
+		existing, ok := findEntry(entries, token)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrNoToken, token)
+		}
+		if existing.Action != nil {
+			return nil, fmt.Errorf("%w: %s is an action preset", ErrFixedPreset, token)
+		}
+		if name == "" {
+			name = existing.Name
+		}
+	} else {
+		if len(s.store.Presets(cam.Config.Name)) >= maxStoredPresets {
+			return nil, ErrTooManyPresets
+		}
+		token = nextPresetToken(entries)
+	}
+	if name == "" {
+		name = token
+	}
+	for _, p := range entries {
+		if p.Token != token && p.Name == name {
+			return nil, fmt.Errorf("%w: %s", ErrPresetExist, name)
+		}
+	}
+
+	pan, tilt, err := cam.SyncPTZPosition()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read camera position: %w", err)
+	}
+
+	preset := StoredPreset{Token: token, Name: name, Pan: pan, Tilt: tilt}
+	if err := s.store.SetPreset(cam.Config.Name, preset); err != nil {
+		return nil, err
+	}
+	log.Printf("PTZ SetPreset: %s saved preset %s (%s) at (%d, %d)", cam.Config.Name, token, name, pan, tilt)
+
+	return &SetPresetResponse{PresetToken: token}, nil
+}
+
+// RemovePreset handles RemovePreset request. Presets from config.yaml cannot be removed;
+// removing a saved position of one restores the position from config.yaml.
+func (s *Service) RemovePreset(req RemovePresetRequest) (*RemovePresetResponse, error) {
+	profile, err := s.registry.GetProfileByToken(req.ProfileToken)
+	if err != nil {
+		return nil, fmt.Errorf("profile not found: %s", req.ProfileToken)
+	}
+	cam := profile.Camera
+	if !cam.Config.Capabilities.PTZ {
+		return nil, fmt.Errorf("camera does not support PTZ: %s", cam.Config.Name)
+	}
+
+	s.presetMu.Lock()
+	defer s.presetMu.Unlock()
+
+	removed, err := s.store.RemovePreset(cam.Config.Name, req.PresetToken)
+	if err != nil {
+		return nil, err
+	}
+	if !removed {
+		if _, ok := s.findPreset(cam, req.PresetToken); ok {
+			return nil, fmt.Errorf("%w: %s", ErrFixedPreset, req.PresetToken)
+		}
+		return nil, fmt.Errorf("%w: %s", ErrNoToken, req.PresetToken)
+	}
+	log.Printf("PTZ RemovePreset: %s removed preset %s", cam.Config.Name, req.PresetToken)
+
+	return &RemovePresetResponse{}, nil
+}
+
+// findEntry returns the preset with a token
+func findEntry(entries []presetEntry, token string) (presetEntry, bool) {
+	for _, p := range entries {
+		if p.Token == token {
+			return p, true
+		}
+	}
+	return presetEntry{}, false
+}
+
+// nextPresetToken returns the first unused token for a new preset
+func nextPresetToken(entries []presetEntry) string {
+	for i := 1; ; i++ {
+		token := fmt.Sprintf(storedPresetTokenFormat, i)
+		if _, ok := findEntry(entries, token); !ok {
+			return token
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package ptz
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/config"
+)
+
+func TestSetPresetSavesCurrentPosition(t *testing.T) {
+	service, commands, closeService := newTrackingTestService(t, []config.PTZPreset{
+		{Name: "Door", Token: "1", Pan: 10, Tilt: 20},
+		{Name: "Tracking On", Token: "tracking-on", Tracking: "on"},
+	})
+	defer closeService()
+
+	resp, err := service.SetPreset(SetPresetRequest{ProfileToken: "Main", PresetName: "Gate"})
+	if err != nil {
+		t.Fatalf("SetPreset returned an error: %v", err)
+	}
+	if resp.PresetToken != "preset_1" {
+		t.Fatalf("PresetToken = %q, want preset_1", resp.PresetToken)
+	}
+
+	presets, err := service.GetPresets("Main")
+	if err != nil {
+		t.Fatalf("GetPresets returned an error: %v", err)
+	}
+	if len(presets.Preset) != 3 || presets.Preset[2].Token != "preset_1" || presets.Preset[2].Name != "Gate" {
+		t.Fatalf("GetPresets = %+v, want config presets followed by Gate", presets.Preset)
+	}
+	if presets.Preset[1].PTZPosition != nil {
+		t.Fatal("action preset has a position")
+	}
+
+	// Saved presets survive a restart and can be recalled
+	store, err := NewStore(service.store.path)
+	if err != nil {
+		t.Fatalf("failed to reload PTZ store: %v", err)
+	}
+	service.store = store
+	if err := service.GotoPreset("Main", "preset_1", nil, nil); err != nil {
+		t.Fatalf("GotoPreset returned an error: %v", err)
+	}
+	if command := <-commands; command != "move 120 45 5" {
+		t.Fatalf("command = %q, want move 120 45 5", command)
+	}
+}
+
+func TestSetPresetUpdatesConfigPosition(t *testing.T) {
+	service, commands, closeService := newTrackingTestService(t, []config.PTZPreset{
+		{Name: "Door", Token: "1", Pan: 10, Tilt: 20},
+	})
+	defer closeService()
+
+	if _, err := service.SetPreset(SetPresetRequest{ProfileToken: "Main", PresetToken: "1"}); err != nil {
+		t.Fatalf("SetPreset returned an error: %v", err)
+	}
+	if err := service.GotoPreset("Main", "1", nil, nil); err != nil {
+		t.Fatalf("GotoPreset returned an error: %v", err)
+	}
+	if command := <-commands; command != "move 120 45 5" {
+		t.Fatalf("command = %q, want move 120 45 5", command)
+	}
+
+	// Removing the saved position restores the one from the config file, which stays
+	if _, err := service.RemovePreset(RemovePresetRequest{ProfileToken: "Main", PresetToken: "1"}); err != nil {
+		t.Fatalf("RemovePreset returned an error: %v", err)
+	}
+	if _, err := service.RemovePreset(RemovePresetRequest{ProfileToken: "Main", PresetToken: "1"}); !errors.Is(err, ErrFixedPreset) {
+		t.Fatalf("RemovePreset of a config preset = %v, want ErrFixedPreset", err)
+	}
+	if entry, _ := service.findPreset(service.registry.List()[0], "1"); entry.Pan != 10 || entry.Tilt != 20 {
+		t.Fatalf("preset 1 = (%d, %d), want the config position (10, 20)", entry.Pan, entry.Tilt)
+	}
+}
+
+func TestSetPresetErrors(t *testing.T) {
+	service, _, closeService := newTrackingTestService(t, []config.PTZPreset{
+		{Name: "Door", Token: "1", Pan: 10, Tilt: 20},
+		{Name: "Tracking On", Token: "tracking-on", Tracking: "on"},
+	})
+	defer closeService()
+
+	tests := []struct {
+		req  SetPresetRequest
+		want error
+	}{
+		{SetPresetRequest{ProfileToken: "Main", PresetToken: "tracking-on"}, ErrFixedPreset},
+		{SetPresetRequest{ProfileToken: "Main", PresetToken: "unknown"}, ErrNoToken},
+		{SetPresetRequest{ProfileToken: "Main", PresetName: "Door"}, ErrPresetExist},
+	}
+	for _, tt := range tests {
+		if _, err := service.SetPreset(tt.req); !errors.Is(err, tt.want) {
+			t.Errorf("SetPreset(%+v) = %v, want %v", tt.req, err, tt.want)
+		}
+	}
+
+	if _, err := service.RemovePreset(RemovePresetRequest{ProfileToken: "Main", PresetToken: "unknown"}); !errors.Is(err, ErrNoToken) {
+		t.Fatalf("RemovePreset of an unknown token = %v, want ErrNoToken", err)
+	}
+}
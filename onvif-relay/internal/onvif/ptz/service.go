@@ -6,10 +6,14 @@ import (
 	"log"
 	"math"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/auth"
 	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/camera"
 	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/config"
 	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/mqtt"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/onvif/soap"
 )
 
 // GetNodesRequest represents GetNodes request
@@ -28,15 +32,34 @@ const (
 	trackingAuxiliaryOff = "atomcam:Tracking|Off"
 )
 
+const (
+	// continuousMaxRate is the ContinuousMove pan/tilt rate at full velocity, in degrees per second
+	continuousMaxRate = 60.0
+	// defaultContinuousTimeout ends a ContinuousMove without Timeout (DefaultPTZTimeout)
+	defaultContinuousTimeout = 10 * time.Second
+)
+
 // PTZNode represents a PTZ node
 type PTZNode struct {
-	Token                  string    `xml:"token,attr"`
-	Name                   string    `xml:"Name"`
-	SupportedPTZSpaces     PTZSpaces `xml:"SupportedPTZSpaces"`
-	MaximumNumberOfPresets int       `xml:"MaximumNumberOfPresets"`
-	HomeSupported          bool      `xml:"HomeSupported"`
-	FixedHomePosition      bool      `xml:"FixedHomePosition,omitempty"`
-	AuxiliaryCommands      []string  `xml:"AuxiliaryCommands,omitempty"`
+	Token                  string            `xml:"token,attr"`
+	Name                   string            `xml:"Name"`
+	SupportedPTZSpaces     PTZSpaces         `xml:"SupportedPTZSpaces"`
+	MaximumNumberOfPresets int               `xml:"MaximumNumberOfPresets"`
+	HomeSupported          bool              `xml:"HomeSupported"`
+	FixedHomePosition      bool              `xml:"FixedHomePosition,omitempty"`
+	AuxiliaryCommands      []string          `xml:"AuxiliaryCommands,omitempty"`
+	Extension              *PTZNodeExtension `xml:"Extension,omitempty"`
+}
+
+// PTZNodeExtension represents the preset tour support of a PTZ node
+type PTZNodeExtension struct {
+	SupportedPresetTour *PTZPresetTourSupported `xml:"SupportedPresetTour,omitempty"`
+}
+
+// PTZPresetTourSupported represents the number of preset tours and the supported operations
+type PTZPresetTourSupported struct {
+	MaximumNumberOfPresetTours int      `xml:"MaximumNumberOfPresetTours"`
+	PTZPresetTourOperation     []string `xml:"PTZPresetTourOperation"`
 }
 
 // PTZSpaces represents PTZ coordinate spaces
@@ -277,13 +300,82 @@ type SendAuxiliaryCommandResponse struct {
 // Service represents the PTZ service
 type Service struct {
 	registry *camera.Registry
+	store    *Store
+	presetMu sync.Mutex // Serializes changes of presets and preset tours
+
+	tourMu          sync.Mutex
+	tours           map[string]*tourRun // Running preset tours by camera name
+	tourResumeDelay time.Duration       // Idle time after a manual move before a paused tour resumes
+
+	mqttMu     sync.Mutex
+	mqttBridge MQTTPublisher // Persistent connection to the server.mqtt broker (nil = disabled)
+}
+
+// MQTTPublisher publishes messages over the relay's persistent connection to the
+// server.mqtt broker (the MQTT bridge)
+type MQTTPublisher interface {
+	Broker() string
+	PublishMessage(topic, message string) error
+}
+
+// NewService creates a new PTZ service; settings changed via ONVIF are saved in store.
+// Preset tours with AutoStart are started right away.
+func NewService(registry *camera.Registry, store *Store) *Service {
+	s := &Service{
+		registry:        registry,
+		store:           store,
+		tours:           make(map[string]*tourRun),
+		tourResumeDelay: defaultTourResumeDelay,
+	}
+	s.startAutoTours()
+	return s
+}
+
+// Close stops the running preset tours
+func (s *Service) Close() {
+	s.tourMu.Lock()
+	runs := s.tours
+	s.tours = make(map[string]*tourRun)
+	s.tourMu.Unlock()
+
+	for _, run := range runs {
+		run.halt()
+	}
+}
+
+// targetSpeed converts the optional ONVIF speed of AbsoluteMove and RelativeMove (0.0-1.0,
+// by the magnitude of the pan/tilt vector) to AtomCam speed (5-9), default 5
+func targetSpeed(speed *PTZSpeed) int {
+	if speed == nil || speed.PanTilt == nil {
+		return 5
+	}
+	speedMag := math.Sqrt(speed.PanTilt.X*speed.PanTilt.X + speed.PanTilt.Y*speed.PanTilt.Y)
+	if speedMag <= 0.01 {
+		return 5
+	}
+	return int(math.Round(math.Min(speedMag, 1.0)*4.0)) + 5
 }
 
-// NewService creates a new PTZ service
-func NewService(registry *camera.Registry) *Service {
-	return &Service{
-		registry: registry,
+// moveSpeed converts an optional ONVIF speed (0.0-1.0) to AtomCam speed (1-9), default 5
+func moveSpeed(speed *PTZSpeed) int {
+	if speed == nil || speed.PanTilt == nil {
+		return 5
+	}
+	speedMag := speed.PanTilt.X*speed.PanTilt.X + speed.PanTilt.Y*speed.PanTilt.Y
+	if speedMag <= 0.01 {
+		return 5
+	}
+
+	// Use average of X and Y components
+	avgSpeed := (speed.PanTilt.X + speed.PanTilt.Y) / 2.0
+	atomSpeed := int(avgSpeed*8) + 1 // Map 0.0-1.0 to 1-9
+	if atomSpeed < 1 {
+		atomSpeed = 1
+	}
+	if atomSpeed > 9 {
+		atomSpeed = 9
 	}
+	return atomSpeed
 }
 
 func currentPTZPosition(cam *camera.Camera, operation string) (pan, tilt int) {
@@ -299,59 +391,15 @@ func currentPTZPosition(cam *camera.Camera, operation string) (pan, tilt int) {
 	return pan, tilt
 }
 
-// GetNodes handles GetNodes request
+// GetNodes handles GetNodes request: one node per PTZ camera
 func (s *Service) GetNodes() *GetNodesResponse {
-	maximumNumberOfPresets := 0
+	nodes := []PTZNode{}
 	for _, cam := range s.registry.List() {
-		if count := len(cam.Config.PTZ.Presets); count > maximumNumberOfPresets {
-			maximumNumberOfPresets = count
+		if cam.Config.Capabilities.PTZ {
+			nodes = append(nodes, ptzNode(cam))
 		}
 	}
-
-	return &GetNodesResponse{
-		PTZNode: []PTZNode{
-			{
-				Token: "PTZNode_1",
-				Name:  "PTZ Node 1",
-				SupportedPTZSpaces: PTZSpaces{
-					AbsolutePanTiltPositionSpace: []Space2D{
-						{
-							URI:    "http://www.onvif.org/ver10/tptz/PanTiltSpaces/PositionGenericSpace",
-							XRange: Range{Min: -1.0, Max: 1.0},
-							YRange: Range{Min: -1.0, Max: 1.0},
-						},
-					},
-					RelativePanTiltTranslationSpace: []Space2D{
-						{
-							URI:    "http://www.onvif.org/ver10/tptz/PanTiltSpaces/TranslationGenericSpace",
-							XRange: Range{Min: -1.0, Max: 1.0},
-							YRange: Range{Min: -1.0, Max: 1.0},
-						},
-					},
-					ContinuousPanTiltVelocitySpace: []Space2D{
-						{
-							URI:    "http://www.onvif.org/ver10/tptz/PanTiltSpaces/VelocityGenericSpace",
-							XRange: Range{Min: -1.0, Max: 1.0},
-							YRange: Range{Min: -1.0, Max: 1.0},
-						},
-					},
-					PanTiltSpeedSpace: []Space1D{
-						{
-							URI:    "http://www.onvif.org/ver10/tptz/PanTiltSpaces/GenericSpeedSpace",
-							XRange: Range{Min: 0.0, Max: 1.0},
-						},
-					},
-				},
-				MaximumNumberOfPresets: maximumNumberOfPresets,
-				HomeSupported:          true,
-				FixedHomePosition:      true,
-				AuxiliaryCommands: []string{
-					trackingAuxiliaryOn,
-					trackingAuxiliaryOff,
-				},
-			},
-		},
-	}
+	return &GetNodesResponse{PTZNode: nodes}
 }
 
 // GetServiceCapabilities handles GetServiceCapabilities request.
@@ -363,36 +411,22 @@ func (s *Service) GetServiceCapabilities() *GetServiceCapabilitiesResponse {
 	}
 }
 
-// GetConfigurations handles GetConfigurations request
+// GetConfigurations handles GetConfigurations request: one configuration per PTZ profile
 func (s *Service) GetConfigurations() *GetConfigurationsResponse {
-	return &GetConfigurationsResponse{
-		PTZConfiguration: []PTZConfiguration{
-			{
-				Token:     "PTZConfig_1",
-				Name:      "PTZ Configuration 1",
-				NodeToken: "PTZNode_1",
-				DefaultPTZSpeed: &PTZSpeed{
-					PanTilt: &Vector2D{
-						Space: "http://www.onvif.org/ver10/tptz/PanTiltSpaces/VelocityGenericSpace",
-						X:     0.5,
-						Y:     0.5,
-					},
-				},
-				DefaultPTZTimeout: "PT10S",
-				PanTiltLimits: &PanTiltLimits{
-					Range: Space2D{
-						URI:    "http://www.onvif.org/ver10/tptz/PanTiltSpaces/PositionGenericSpace",
-						XRange: Range{Min: -1.0, Max: 1.0},
-						YRange: Range{Min: -1.0, Max: 1.0},
-					},
-				},
-			},
-		},
+	configurations := []PTZConfiguration{}
+	for _, profile := range s.registry.GetAllProfiles() {
+		if profile.Camera.Config.Capabilities.PTZ {
+			configurations = append(configurations, ptzConfiguration(&profile))
+		}
 	}
+	return &GetConfigurationsResponse{PTZConfiguration: configurations}
 }
 
-// ContinuousMove handles ContinuousMove request
-func (s *Service) ContinuousMove(profileToken string, velocity PTZSpeed) error {
+// ContinuousMove handles ContinuousMove request.
+// The camera keeps moving in the direction of the velocity until Timeout elapses
+// (DefaultPTZTimeout when omitted), Stop is called or another move replaces it.
+// Repeated requests of the same user change the direction of the running move.
+func (s *Service) ContinuousMove(profileToken string, velocity PTZSpeed, timeout string, user *auth.User) error {
 	// Get camera from profile token
 	profile, err := s.registry.GetProfileByToken(profileToken)
 	if err != nil {
@@ -410,52 +444,32 @@ func (s *Service) ContinuousMove(profileToken string, velocity PTZSpeed) error {
 		return nil
 	}
 
-	// Check if this is a zoom operation with pan/tilt
-	if velocity.Zoom != nil && velocity.PanTilt != nil {
-		// Ignore zoom component, only process pan/tilt
-		// (AtomCam doesn't support zoom)
+	duration := defaultContinuousTimeout
+	if timeout != "" {
+		d, err := soap.ParseDuration(timeout)
+		if err != nil {
+			return err
+		}
+		if d <= 0 {
+			return fmt.Errorf("invalid timeout: %s", timeout)
+		}
+		duration = d
 	}
 
-	// Extract velocity
+	// Extract velocity (the zoom component is ignored, AtomCam doesn't support zoom)
 	var velocityX, velocityY float64
 	if velocity.PanTilt != nil {
-		velocityX = velocity.PanTilt.X
-		velocityY = velocity.PanTilt.Y
+		velocityX = clamp(velocity.PanTilt.X, -1, 1)
+		velocityY = clamp(velocity.PanTilt.Y, -1, 1)
+		velocityX, velocityY = frameOf(profile.Camera).mapXY(velocityX, velocityY)
 	}
 
 	// Calculate velocity magnitude
+	ctl := userController(user)
 	velocityMag := math.Sqrt(velocityX*velocityX + velocityY*velocityY)
 	if velocityMag < 0.01 {
 		// Velocity too small, treat as stop
-		return profile.Camera.Client.PTZStop()
-	}
-
-	// Get current position
-	currentPan, currentTilt := currentPTZPosition(profile.Camera, "ContinuousMove")
-
-	// Calculate movement delta based on velocity
-	// velocity range: -1.0 to 1.0
-	// Scale to reasonable movement: ±5 degrees per command
-	const movementScale = 5.0
-	deltaPan := int(velocityX * movementScale)
-	deltaTilt := int(velocityY * movementScale) // Y: positive = down, negative = up
-
-	// Calculate new position
-	newPan := currentPan + deltaPan
-	newTilt := currentTilt + deltaTilt
-
-	// Clamp to valid range
-	if newPan < 0 {
-		newPan = 0
-	}
-	if newPan > 355 {
-		newPan = 355
-	}
-	if newTilt < 0 {
-		newTilt = 0
-	}
-	if newTilt > 180 {
-		newTilt = 180
+		return s.stop(profile.Camera, ctl)
 	}
 
 	// Convert velocity magnitude to speed (5-9)
@@ -468,15 +482,18 @@ func (s *Service) ContinuousMove(profileToken string, velocity PTZSpeed) error {
 		speed = 9
 	}
 
-	// Update tracked position
-	profile.Camera.SetPTZPosition(newPan, newTilt)
-
-	// Send PTZ move command
-	return profile.Camera.Client.PTZMove(newPan, newTilt, speed)
+	// ONVIF Y positive = up, which is towards AtomCam tilt 0
+	panRate := velocityX * continuousMaxRate
+	tiltRate := -velocityY * continuousMaxRate
+	if err := profile.Camera.ArbitrateContinuousMove(ctl, panRate, tiltRate, speed, duration); err != nil {
+		return err
+	}
+	s.interruptTour(profile.Camera)
+	return nil
 }
 
 // Stop handles Stop request
-func (s *Service) Stop(profileToken string) error {
+func (s *Service) Stop(profileToken string, user *auth.User) error {
 	// Get camera from profile token
 	profile, err := s.registry.GetProfileByToken(profileToken)
 	if err != nil {
@@ -488,12 +505,19 @@ func (s *Service) Stop(profileToken string) error {
 		return fmt.Errorf("camera does not support PTZ: %s", profile.Camera.Config.Name)
 	}
 
-	// Send PTZ stop command
-	return profile.Camera.Client.PTZStop()
+	return s.stop(profile.Camera, userController(user))
+}
+
+// stop ends any continuous move and halts the motor at its current position
+func (s *Service) stop(cam *camera.Camera, ctl camera.PTZController) error {
+	return cam.ArbitratePTZ(ctl, func() error {
+		s.interruptTour(cam)
+		return cam.StopPTZ()
+	})
 }
 
 // GotoHomePosition handles GotoHomePosition request
-func (s *Service) GotoHomePosition(profileToken string, speed *PTZSpeed) error {
+func (s *Service) GotoHomePosition(profileToken string, speed *PTZSpeed, user *auth.User) error {
 	// Get camera from profile token
 	profile, err := s.registry.GetProfileByToken(profileToken)
 	if err != nil {
@@ -505,36 +529,11 @@ func (s *Service) GotoHomePosition(profileToken string, speed *PTZSpeed) error {
 		return fmt.Errorf("camera does not support PTZ: %s", profile.Camera.Config.Name)
 	}
 
-	// Default speed for home position
-	defaultSpeed := 5
-	if speed != nil && speed.PanTilt != nil {
-		// Convert ONVIF speed (0.0-1.0) to AtomCam speed (1-9)
-		speedMag := speed.PanTilt.X*speed.PanTilt.X + speed.PanTilt.Y*speed.PanTilt.Y
-		if speedMag > 0.01 {
-			// Use average of X and Y components
-			avgSpeed := (speed.PanTilt.X + speed.PanTilt.Y) / 2.0
-			defaultSpeed = int(avgSpeed*8) + 1 // Map 0.0-1.0 to 1-9
-			if defaultSpeed < 1 {
-				defaultSpeed = 1
-			}
-			if defaultSpeed > 9 {
-				defaultSpeed = 9
-			}
-		}
-	}
-
-	// Get home position from config, or use default
-	pan := 160
-	tilt := 130
-	if profile.Camera.Config.PTZ.Home != nil {
-		pan = profile.Camera.Config.PTZ.Home.Pan
-		tilt = profile.Camera.Config.PTZ.Home.Tilt
-	}
-
-	// Update tracked position
-	profile.Camera.SetPTZPosition(pan, tilt)
-
-	return profile.Camera.Client.PTZMove(pan, tilt, defaultSpeed)
+	return profile.Camera.ArbitratePTZ(userController(user), func() error {
+		s.interruptTour(profile.Camera)
+		pan, tilt := s.homePosition(profile.Camera)
+		return profile.Camera.MovePTZ(pan, tilt, moveSpeed(speed))
+	})
 }
 
 // GetPresets handles GetPresets request
@@ -552,17 +551,25 @@ func (s *Service) GetPresets(profileToken string) (*GetPresetsResponse, error) {
 
 	presets := []Preset{}
 
-	// Add presets from config
-	for i, preset := range profile.Camera.Config.PTZ.Presets {
-		token := preset.Token
-		if token == "" {
-			token = fmt.Sprintf("%d", i+1) // Default to 1-based index
+	// Add presets from config and those saved with SetPreset; action presets have no position.
+	// Positions are reported in the viewer's frame of reference.
+	frame := frameOf(profile.Camera)
+	for _, entry := range s.presets(profile.Camera) {
+		preset := Preset{
+			Token: entry.Token,
+			Name:  entry.Name,
 		}
-
-		presets = append(presets, Preset{
-			Token: token,
-			Name:  preset.Name,
-		})
+		if entry.Action == nil {
+			x, y := frame.mapXY(AtomCamToONVIF(entry.Pan, entry.Tilt))
+			preset.PTZPosition = &PTZPosition{
+				PanTilt: &Vector2D{
+					Space: "http://www.onvif.org/ver10/tptz/PanTiltSpaces/PositionGenericSpace",
+					X:     x,
+					Y:     y,
+				},
+			}
+		}
+		presets = append(presets, preset)
 	}
 
 	return &GetPresetsResponse{
@@ -571,7 +578,7 @@ func (s *Service) GetPresets(profileToken string) (*GetPresetsResponse, error) {
 }
 
 // GotoPreset handles GotoPreset request
-func (s *Service) GotoPreset(profileToken, presetToken string, speed *PTZSpeed) error {
+func (s *Service) GotoPreset(profileToken, presetToken string, speed *PTZSpeed, user *auth.User) error {
 	// Get camera from profile token
 	profile, err := s.registry.GetProfileByToken(profileToken)
 	if err != nil {
@@ -583,41 +590,38 @@ func (s *Service) GotoPreset(profileToken, presetToken string, speed *PTZSpeed)
 		return fmt.Errorf("camera does not support PTZ: %s", profile.Camera.Config.Name)
 	}
 
-	// Find preset in config
-	var preset *config.PTZPreset
-	for i := range profile.Camera.Config.PTZ.Presets {
-		p := &profile.Camera.Config.PTZ.Presets[i]
-		token := p.Token
-		if token == "" {
-			token = fmt.Sprintf("%d", i+1)
-		}
-		if token == presetToken {
-			preset = p
-			break
-		}
-	}
+	return profile.Camera.ArbitratePTZ(userController(user), func() error {
+		s.interruptTour(profile.Camera)
+		return s.gotoPreset(profile.Camera, presetToken, moveSpeed(speed))
+	})
+}
 
-	if preset == nil {
+// gotoPreset moves a camera to a preset position or runs the action of an action preset
+func (s *Service) gotoPreset(cam *camera.Camera, presetToken string, speed int) error {
+	// Find preset in config or among those saved with SetPreset
+	entry, ok := s.findPreset(cam, presetToken)
+	if !ok {
 		return fmt.Errorf("preset not found: %s", presetToken)
 	}
+	preset := entry.Action
 
 	// Check if this is a motion tracking action preset.
-	if preset.Tracking != "" {
+	if preset != nil && preset.Tracking != "" {
 		enabled := strings.EqualFold(preset.Tracking, "on")
 		log.Printf("PTZ GotoPreset: tracking action - enabled=%t", enabled)
-		if err := profile.Camera.Client.SetTracking(enabled); err != nil {
+		if err := cam.Client.SetTracking(enabled); err != nil {
 			return fmt.Errorf("failed to set tracking for preset %s: %w", presetToken, err)
 		}
 		return nil
 	}
 
 	// Check if this is an MQTT preset
-	if preset.MQTTBroker != "" && preset.MQTTTopic != "" {
+	if preset != nil && preset.MQTTTopic != "" {
 		// MQTT preset: publish message instead of moving camera
 		log.Printf("PTZ GotoPreset: MQTT action - broker=%s, topic=%s, message=%s",
 			preset.MQTTBroker, preset.MQTTTopic, preset.MQTTMessage)
 
-		if err := mqtt.PublishMessage(preset.MQTTBroker, preset.MQTTTopic, preset.MQTTMessage); err != nil {
+		if err := s.publishPresetMessage(preset); err != nil {
 			return fmt.Errorf("failed to publish MQTT message for preset %s: %w", presetToken, err)
 		}
 
@@ -625,30 +629,40 @@ func (s *Service) GotoPreset(profileToken, presetToken string, speed *PTZSpeed)
 		return nil
 	}
 
-	// Default speed
-	defaultSpeed := 5
-	if speed != nil && speed.PanTilt != nil {
-		speedMag := speed.PanTilt.X*speed.PanTilt.X + speed.PanTilt.Y*speed.PanTilt.Y
-		if speedMag > 0.01 {
-			avgSpeed := (speed.PanTilt.X + speed.PanTilt.Y) / 2.0
-			defaultSpeed = int(avgSpeed*8) + 1
-			if defaultSpeed < 1 {
-				defaultSpeed = 1
-			}
-			if defaultSpeed > 9 {
-				defaultSpeed = 9
-			}
-		}
-	}
+	return cam.MovePTZ(entry.Pan, entry.Tilt, speed)
+}
 
-	// Update tracked position
-	profile.Camera.SetPTZPosition(preset.Pan, preset.Tilt)
+// SetMQTTPublisher makes MQTT action presets for the server.mqtt broker, or without
+// mqtt_broker, publish through the MQTT bridge
+func (s *Service) SetMQTTPublisher(bridge MQTTPublisher) {
+	s.mqttMu.Lock()
+	defer s.mqttMu.Unlock()
+	s.mqttBridge = bridge
+}
+
+// publishPresetMessage publishes the message of an MQTT action preset. Only presets for
+// another broker open a connection of their own.
+func (s *Service) publishPresetMessage(preset *config.PTZPreset) error {
+	s.mqttMu.Lock()
+	bridge := s.mqttBridge
+	s.mqttMu.Unlock()
+
+	if bridge != nil && (preset.MQTTBroker == "" || sameBroker(preset.MQTTBroker, bridge.Broker())) {
+		return bridge.PublishMessage(preset.MQTTTopic, preset.MQTTMessage)
+	}
+	if preset.MQTTBroker == "" {
+		return fmt.Errorf("no mqtt_broker and the MQTT bridge (server.mqtt) is not enabled")
+	}
+	return mqtt.PublishMessage(preset.MQTTBroker, preset.MQTTTopic, preset.MQTTMessage)
+}
 
-	return profile.Camera.Client.PTZMove(preset.Pan, preset.Tilt, defaultSpeed)
+// sameBroker reports whether two broker URLs refer to the same broker
+func sameBroker(a, b string) bool {
+	return strings.EqualFold(strings.TrimSuffix(a, "/"), strings.TrimSuffix(b, "/"))
 }
 
 // MoveAndStartTracking optionally moves the camera and then enables motion tracking.
-func (s *Service) MoveAndStartTracking(req MoveAndStartTrackingRequest) error {
+func (s *Service) MoveAndStartTracking(req MoveAndStartTrackingRequest, user *auth.User) error {
 	profile, err := s.registry.GetProfileByToken(req.ProfileToken)
 	if err != nil {
 		return fmt.Errorf("profile not found: %s", req.ProfileToken)
@@ -667,11 +681,11 @@ func (s *Service) MoveAndStartTracking(req MoveAndStartTrackingRequest) error {
 	}
 
 	if req.PresetToken != "" {
-		if err := s.GotoPreset(req.ProfileToken, req.PresetToken, req.Speed); err != nil {
+		if err := s.GotoPreset(req.ProfileToken, req.PresetToken, req.Speed, user); err != nil {
 			return err
 		}
 	} else if req.TargetPosition != nil {
-		if err := s.AbsoluteMove(req.ProfileToken, *req.TargetPosition, req.Speed); err != nil {
+		if err := s.AbsoluteMove(req.ProfileToken, *req.TargetPosition, req.Speed, user); err != nil {
 			return err
 		}
 	}
@@ -706,7 +720,19 @@ func (s *Service) SendAuxiliaryCommand(profileToken, command string) (string, er
 }
 
 // AbsoluteMove handles AbsoluteMove request
-func (s *Service) AbsoluteMove(profileToken string, position PTZVector, speed *PTZSpeed) error {
+func (s *Service) AbsoluteMove(profileToken string, position PTZVector, speed *PTZSpeed, user *auth.User) error {
+	cam, err := s.ptzCamera(profileToken)
+	if err != nil {
+		return err
+	}
+	return cam.ArbitratePTZ(userController(user), func() error {
+		return s.absoluteMove(profileToken, position, speed)
+	})
+}
+
+// absoluteMove moves the camera of a profile to an AbsoluteMove position.
+// It runs in the PTZ queue of the camera, so the current position is not stale.
+func (s *Service) absoluteMove(profileToken string, position PTZVector, speed *PTZSpeed) error {
 	// Get camera from profile token
 	profile, err := s.registry.GetProfileByToken(profileToken)
 	if err != nil {
@@ -728,92 +754,44 @@ func (s *Service) AbsoluteMove(profileToken string, position PTZVector, speed *P
 	if position.PanTilt == nil {
 		return fmt.Errorf("pan/tilt position required for AbsoluteMove")
 	}
+	s.interruptTour(profile.Camera)
 
-	x := position.PanTilt.X
-	y := position.PanTilt.Y
+	// Map the position from the viewer's frame of reference to the camera's
+	x, y := frameOf(profile.Camera).mapXY(position.PanTilt.X, position.PanTilt.Y)
 
-	// Check if FOV is configured
+	// MovePTZ keeps the target within the camera's limits
 	var pan, tilt int
-	if profile.Camera.Config.PTZ.HorizontalFOV > 0 && profile.Camera.Config.PTZ.VerticalFOV > 0 {
+	if hasFOV(profile.Camera) {
 		// Use FOV-aware conversion: treat ONVIF coordinates as positions within current view
 		// Get current position as the center of the view
 		currentPan, currentTilt := currentPTZPosition(profile.Camera, "AbsoluteMove")
+		pan, tilt = fovTarget(profile.Camera, currentPan, currentTilt, x, y)
 
-		// Check if position is uninitialized
-		if currentPan == math.MinInt || currentTilt == math.MinInt || currentPan < 0 || currentTilt < 0 {
-			// Use home position or center as default
-			if profile.Camera.Config.PTZ.Home != nil {
-				currentPan = profile.Camera.Config.PTZ.Home.Pan
-				currentTilt = profile.Camera.Config.PTZ.Home.Tilt
-			} else {
-				currentPan = 177 // Center
-				currentTilt = 90 // Center
-			}
-			profile.Camera.SetPTZPosition(currentPan, currentTilt)
-			log.Printf("PTZ AbsoluteMove: Initialized position to (%d, %d)", currentPan, currentTilt)
-		}
-
-		// Calculate offset from center based on FOV
-		// ONVIF x,y ∈ [-1.0, 1.0] represents position within the current view
-		halfHorizontalFOV := profile.Camera.Config.PTZ.HorizontalFOV / 2.0
-		halfVerticalFOV := profile.Camera.Config.PTZ.VerticalFOV / 2.0
-
-		deltaPan := int(math.Round(x * halfHorizontalFOV))
-		deltaTilt := int(math.Round((1.0-y)*halfVerticalFOV)) - int(halfVerticalFOV)
-
-		pan = currentPan + deltaPan
-		tilt = currentTilt + deltaTilt
-
-		// Clamp to valid range
-		if pan < 0 {
-			pan = 0
-		}
-		if pan > 355 {
-			pan = 355
-		}
-		if tilt < 0 {
-			tilt = 0
-		}
-		if tilt > 180 {
-			tilt = 180
-		}
-
-		log.Printf("PTZ AbsoluteMove (FOV-aware): ONVIF=(%.2f, %.2f), current=(%d, %d), delta=(%d, %d), new AtomCam=(%d, %d)",
-			x, y, currentPan, currentTilt, deltaPan, deltaTilt, pan, tilt)
+		log.Printf("PTZ AbsoluteMove (FOV-aware): ONVIF=(%.2f, %.2f), current=(%d, %d), new AtomCam=(%d, %d)",
+			x, y, currentPan, currentTilt, pan, tilt)
 	} else {
 		// Fallback to legacy conversion (absolute position mapping)
-		var defaultSpeed int
-		pan, tilt, defaultSpeed = ONVIFToAtomCam(x, y, 0.5)
-		_ = defaultSpeed // unused in this path
+		pan, tilt, _ = ONVIFToAtomCam(x, y, 0.5)
 		log.Printf("PTZ AbsoluteMove (legacy): ONVIF=(%.2f, %.2f) -> AtomCam=(%d, %d)", x, y, pan, tilt)
 	}
 
-	defaultSpeed := 5
+	return profile.Camera.MovePTZ(pan, tilt, targetSpeed(speed))
+}
 
-	// Override speed if provided
-	if speed != nil && speed.PanTilt != nil {
-		speedMag := math.Sqrt(speed.PanTilt.X*speed.PanTilt.X + speed.PanTilt.Y*speed.PanTilt.Y)
-		if speedMag > 0.01 {
-			// Convert ONVIF speed (0.0-1.0) to AtomCam speed (5-9)
-			defaultSpeed = int(math.Round(speedMag*4.0)) + 5
-			if defaultSpeed < 5 {
-				defaultSpeed = 5
-			}
-			if defaultSpeed > 9 {
-				defaultSpeed = 9
-			}
-		}
+// RelativeMove handles RelativeMove request
+func (s *Service) RelativeMove(profileToken string, translation PTZVector, speed *PTZSpeed, user *auth.User) error {
+	cam, err := s.ptzCamera(profileToken)
+	if err != nil {
+		return err
 	}
-
-	// Update tracked position
-	profile.Camera.SetPTZPosition(pan, tilt)
-
-	// Send PTZ move command
-	return profile.Camera.Client.PTZMove(pan, tilt, defaultSpeed)
+	return cam.ArbitratePTZ(userController(user), func() error {
+		return s.relativeMove(profileToken, translation, speed)
+	})
 }
 
-// RelativeMove handles RelativeMove request
-func (s *Service) RelativeMove(profileToken string, translation PTZVector, speed *PTZSpeed) error {
+// relativeMove moves the camera of a profile by a RelativeMove translation.
+// It runs in the PTZ queue of the camera, so the current position is not stale.
+func (s *Service) relativeMove(profileToken string, translation PTZVector, speed *PTZSpeed) error {
 	// Get camera from profile token
 	profile, err := s.registry.GetProfileByToken(profileToken)
 	if err != nil {
@@ -835,9 +813,16 @@ func (s *Service) RelativeMove(profileToken string, translation PTZVector, speed
 	if translation.PanTilt == nil {
 		return fmt.Errorf("pan/tilt translation required for RelativeMove")
 	}
+	s.interruptTour(profile.Camera)
+
+	// Translations in TranslationSpaceFov need the field of view; with it configured,
+	// the generic space is also taken as a fraction of the field of view
+	if translation.PanTilt.Space == translationSpaceFov && !hasFOV(profile.Camera) {
+		return fmt.Errorf("%w: %s", ErrNoFOV, profile.Camera.Config.Name)
+	}
 
-	translationX := translation.PanTilt.X
-	translationY := translation.PanTilt.Y
+	// Map the translation from the viewer's frame of reference to the camera's
+	translationX, translationY := frameOf(profile.Camera).mapXY(translation.PanTilt.X, translation.PanTilt.Y)
 
 	// Validate translation values (reject NaN)
 	if math.IsNaN(translationX) || math.IsNaN(translationY) {
@@ -847,104 +832,28 @@ func (s *Service) RelativeMove(profileToken string, translation PTZVector, speed
 	// Get current position
 	currentPan, currentTilt := currentPTZPosition(profile.Camera, "RelativeMove")
 
-	// Check if position is uninitialized (int min value indicates uninitialized state)
-	if currentPan == math.MinInt || currentTilt == math.MinInt || currentPan < 0 || currentTilt < 0 {
-		// Use home position or center as default
-		if profile.Camera.Config.PTZ.Home != nil {
-			currentPan = profile.Camera.Config.PTZ.Home.Pan
-			currentTilt = profile.Camera.Config.PTZ.Home.Tilt
-		} else {
-			currentPan = 177 // Center
-			currentTilt = 90 // Center
-		}
-		// Update tracked position
-		profile.Camera.SetPTZPosition(currentPan, currentTilt)
-		log.Printf("PTZ RelativeMove: Initialized position to (%d, %d)", currentPan, currentTilt)
-	}
-
-	// Check if FOV is configured
+	// MovePTZ keeps the target within the camera's limits
 	var pan, tilt int
-	if profile.Camera.Config.PTZ.HorizontalFOV > 0 && profile.Camera.Config.PTZ.VerticalFOV > 0 {
+	if hasFOV(profile.Camera) {
 		// Use FOV-aware conversion: treat translation as position within current view
 		// Translation ∈ [-1.0, 1.0] represents offset within the field of view
-		halfHorizontalFOV := profile.Camera.Config.PTZ.HorizontalFOV / 2.0
-		halfVerticalFOV := profile.Camera.Config.PTZ.VerticalFOV / 2.0
-
-		// Calculate movement delta based on FOV
-		// translation -1.0 = far left/top of view, 0.0 = center, 1.0 = far right/bottom of view
-		deltaPan := int(math.Round(translationX * halfHorizontalFOV))
-		deltaTilt := int(math.Round(-translationY * halfVerticalFOV)) // Invert Y to match ONVIF (positive = up)
+		// (-1.0 = left/bottom edge of view, 0.0 = center, 1.0 = right/top edge of view)
+		pan, tilt = fovTarget(profile.Camera, currentPan, currentTilt, translationX, translationY)
 
-		pan = currentPan + deltaPan
-		tilt = currentTilt + deltaTilt
-
-		// Clamp to valid range
-		if pan < 0 {
-			pan = 0
-		}
-		if pan > 355 {
-			pan = 355
-		}
-		if tilt < 0 {
-			tilt = 0
-		}
-		if tilt > 180 {
-			tilt = 180
-		}
-
-		log.Printf("PTZ RelativeMove (FOV-aware): current=(%d, %d), translation=(%.2f, %.2f), FOV=(%.1f°, %.1f°), delta=(%d, %d), new AtomCam=(%d, %d)",
-			currentPan, currentTilt, translationX, translationY, profile.Camera.Config.PTZ.HorizontalFOV, profile.Camera.Config.PTZ.VerticalFOV, deltaPan, deltaTilt, pan, tilt)
+		log.Printf("PTZ RelativeMove (FOV-aware): current=(%d, %d), translation=(%.2f, %.2f), FOV=(%.1f°, %.1f°), new AtomCam=(%d, %d)",
+			currentPan, currentTilt, translationX, translationY, profile.Camera.Config.PTZ.HorizontalFOV, profile.Camera.Config.PTZ.VerticalFOV, pan, tilt)
 	} else {
 		// Fallback to legacy conversion (ONVIF coordinate space mapping)
 		currentX, currentY := AtomCamToONVIF(currentPan, currentTilt)
 
-		// Add translation to current position
+		// Add translation to current position; ONVIFToAtomCam clamps it to [-1.0, 1.0]
 		newX := currentX + translationX
 		newY := currentY + translationY
-
-		// Clamp to valid ONVIF range [-1.0, 1.0]
-		if newX < -1.0 {
-			newX = -1.0
-		}
-		if newX > 1.0 {
-			newX = 1.0
-		}
-		if newY < -1.0 {
-			newY = -1.0
-		}
-		if newY > 1.0 {
-			newY = 1.0
-		}
-
-		// Convert new ONVIF coordinates to AtomCam coordinates
-		var defaultSpeed int
-		pan, tilt, defaultSpeed = ONVIFToAtomCam(newX, newY, 0.5)
-		_ = defaultSpeed // unused in this path
+		pan, tilt, _ = ONVIFToAtomCam(newX, newY, 0.5)
 
 		log.Printf("PTZ RelativeMove (legacy): current=(%d, %d), ONVIF current=(%.2f, %.2f), translation=(%.2f, %.2f), new ONVIF=(%.2f, %.2f), new AtomCam=(%d, %d)",
 			currentPan, currentTilt, currentX, currentY, translationX, translationY, newX, newY, pan, tilt)
 	}
 
-	defaultSpeed := 5
-
-	// Override speed if provided
-	if speed != nil && speed.PanTilt != nil {
-		speedMag := math.Sqrt(speed.PanTilt.X*speed.PanTilt.X + speed.PanTilt.Y*speed.PanTilt.Y)
-		if speedMag > 0.01 {
-			// Convert ONVIF speed (0.0-1.0) to AtomCam speed (5-9)
-			defaultSpeed = int(math.Round(speedMag*4.0)) + 5
-			if defaultSpeed < 5 {
-				defaultSpeed = 5
-			}
-			if defaultSpeed > 9 {
-				defaultSpeed = 9
-			}
-		}
-	}
-
-	// Update tracked position
-	profile.Camera.SetPTZPosition(pan, tilt)
-
-	// Send PTZ move command
-	return profile.Camera.Client.PTZMove(pan, tilt, defaultSpeed)
+	return profile.Camera.MovePTZ(pan, tilt, targetSpeed(speed))
 }
@@ -2,11 +2,16 @@ package ptz
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/camera"
 	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/config"
@@ -17,6 +22,10 @@ func newTrackingTestService(t *testing.T, presets []config.PTZPreset) (*Service,
 
 	commands := make(chan string, 4)
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("name") == "status" {
+			_, _ = w.Write([]byte("MOTORPOS=120.0 45.0\n"))
+			return
+		}
 		var request camera.CommandRequest
 		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
@@ -56,7 +65,16 @@ func newTrackingTestService(t *testing.T, presets []config.PTZPreset) (*Service,
 		t.Fatalf("failed to create registry: %v", err)
 	}
 
-	return NewService(registry), commands, func() {
+	store, err := NewStore(filepath.Join(t.TempDir(), "ptz.json"))
+	if err != nil {
+		registry.Close()
+		server.Close()
+		t.Fatalf("failed to create PTZ store: %v", err)
+	}
+
+	service := NewService(registry, store)
+	return service, commands, func() {
+		service.Close()
 		registry.Close()
 		server.Close()
 	}
@@ -68,7 +86,7 @@ func TestGotoPresetTrackingAction(t *testing.T) {
 	})
 	defer closeService()
 
-	if err := service.GotoPreset("Main", "tracking-on", nil); err != nil {
+	if err := service.GotoPreset("Main", "tracking-on", nil, nil); err != nil {
 		t.Fatalf("GotoPreset returned an error: %v", err)
 	}
 	if command := <-commands; command != "property tracking on" {
@@ -76,6 +94,41 @@ func TestGotoPresetTrackingAction(t *testing.T) {
 	}
 }
 
+// fakeMQTTBridge records messages published through the MQTT bridge
+type fakeMQTTBridge struct {
+	messages []string
+}
+
+func (b *fakeMQTTBridge) Broker() string { return "tcp://mqtt:1883" }
+
+func (b *fakeMQTTBridge) PublishMessage(topic, message string) error {
+	b.messages = append(b.messages, topic+" "+message)
+	return nil
+}
+
+func TestGotoPresetMQTTActionUsesBridge(t *testing.T) {
+	service, _, closeService := newTrackingTestService(t, []config.PTZPreset{
+		{Name: "Light On", Token: "light-on", MQTTTopic: "home/light", MQTTMessage: "ON"},
+		{Name: "Light Off", Token: "light-off", MQTTBroker: "TCP://mqtt:1883/", MQTTTopic: "home/light", MQTTMessage: "OFF"},
+	})
+	defer closeService()
+
+	if err := service.GotoPreset("Main", "light-on", nil, nil); err == nil {
+		t.Fatal("GotoPreset without mqtt_broker and bridge returned nil error")
+	}
+
+	bridge := &fakeMQTTBridge{}
+	service.SetMQTTPublisher(bridge)
+	for _, token := range []string{"light-on", "light-off"} {
+		if err := service.GotoPreset("Main", token, nil, nil); err != nil {
+			t.Fatalf("GotoPreset(%s) returned an error: %v", token, err)
+		}
+	}
+	if want := []string{"home/light ON", "home/light OFF"}; strings.Join(bridge.messages, ",") != strings.Join(want, ",") {
+		t.Fatalf("bridge messages = %q, want %q", bridge.messages, want)
+	}
+}
+
 func TestSendAuxiliaryTrackingOff(t *testing.T) {
 	service, commands, closeService := newTrackingTestService(t, nil)
 	defer closeService()
@@ -96,7 +149,7 @@ func TestMoveAndStartTracking(t *testing.T) {
 	service, commands, closeService := newTrackingTestService(t, nil)
 	defer closeService()
 
-	if err := service.MoveAndStartTracking(MoveAndStartTrackingRequest{ProfileToken: "Main"}); err != nil {
+	if err := service.MoveAndStartTracking(MoveAndStartTrackingRequest{ProfileToken: "Main"}, nil); err != nil {
 		t.Fatalf("MoveAndStartTracking returned an error: %v", err)
 	}
 	if command := <-commands; command != "property tracking on" {
@@ -115,10 +168,93 @@ func TestGetNodesAdvertisesTrackingAndPresetCount(t *testing.T) {
 	if len(nodes) != 1 {
 		t.Fatalf("node count = %d, want 1", len(nodes))
 	}
-	if nodes[0].MaximumNumberOfPresets != 2 {
-		t.Fatalf("MaximumNumberOfPresets = %d, want 2", nodes[0].MaximumNumberOfPresets)
+	if nodes[0].MaximumNumberOfPresets != 2+maxStoredPresets {
+		t.Fatalf("MaximumNumberOfPresets = %d, want %d", nodes[0].MaximumNumberOfPresets, 2+maxStoredPresets)
 	}
 	if len(nodes[0].AuxiliaryCommands) != 2 {
 		t.Fatalf("AuxiliaryCommands count = %d, want 2", len(nodes[0].AuxiliaryCommands))
 	}
 }
+
+func TestContinuousMoveTiltsUpUntilStop(t *testing.T) {
+	service, commands, closeService := newTrackingTestService(t, nil)
+	defer closeService()
+
+	velocity := PTZSpeed{PanTilt: &Vector2D{X: 0, Y: 1}}
+	if err := service.ContinuousMove("Main", velocity, "PT5S", nil); err != nil {
+		t.Fatalf("ContinuousMove returned an error: %v", err)
+	}
+	if command := <-commands; command != "move 120 33 9" {
+		t.Fatalf("command = %q, want move 120 33 9", command)
+	}
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- service.Stop("Main", nil) }()
+	for {
+		select {
+		case command := <-commands:
+			if command == "move 120 45 9" {
+				if err := <-stopped; err != nil {
+					t.Fatalf("Stop returned an error: %v", err)
+				}
+				return
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatal("Stop did not move the camera to its current MOTORPOS")
+		}
+	}
+}
+
+func TestContinuousMoveRejectsInvalidTimeout(t *testing.T) {
+	service, _, closeService := newTrackingTestService(t, nil)
+	defer closeService()
+
+	velocity := PTZSpeed{PanTilt: &Vector2D{X: 1, Y: 0}}
+	if err := service.ContinuousMove("Main", velocity, "5 seconds", nil); err == nil {
+		t.Fatal("ContinuousMove accepted an invalid timeout")
+	}
+}
+
+func TestLimitsReduceSpacesAndRejectNoGoZones(t *testing.T) {
+	service, commands, closeService := newTrackingTestService(t, nil)
+	defer closeService()
+
+	cam, err := service.registry.Get("swing")
+	if err != nil {
+		t.Fatalf("camera not found: %v", err)
+	}
+	cam.Config.PTZ.Limits = &config.PTZLimits{PanMin: 0, PanMax: 177, TiltMin: 0, TiltMax: 180}
+	cam.Config.PTZ.NoGoZones = []config.PTZZone{{Name: "window", Points: []config.PTZPoint{
+		{Pan: 0, Tilt: 0}, {Pan: 60, Tilt: 0}, {Pan: 60, Tilt: 60}, {Pan: 0, Tilt: 60},
+	}}}
+
+	options, err := service.GetConfigurationOptions("Main_PTZ")
+	if err != nil {
+		t.Fatalf("GetConfigurationOptions returned an error: %v", err)
+	}
+	space := options.PTZConfigurationOptions.Spaces.AbsolutePanTiltPositionSpace[0]
+	if space.XRange.Min != -1 || math.Abs(space.XRange.Max) > 0.01 || space.YRange != (Range{Min: -1, Max: 1}) {
+		t.Fatalf("position space = %+v, want the left half", space)
+	}
+	if node := service.GetNodes().PTZNode[0]; node.Token != cam.PTZNodeToken() ||
+		node.SupportedPTZSpaces.AbsolutePanTiltPositionSpace[0] != space {
+		t.Fatalf("node = %+v, want %s with the reduced space", node, cam.PTZNodeToken())
+	}
+	if _, err := service.GetConfigurationOptions("Sub_PTZ"); !errors.Is(err, ErrNoConfig) {
+		t.Fatalf("GetConfigurationOptions error = %v, want ErrNoConfig", err)
+	}
+
+	// Right of the limits is clamped to pan_max
+	if err := service.AbsoluteMove("Main", PTZVector{PanTilt: &Vector2D{X: 1, Y: 0}}, nil, nil); err != nil {
+		t.Fatalf("AbsoluteMove returned an error: %v", err)
+	}
+	if command := <-commands; command != "move 177 90 5" {
+		t.Fatalf("command = %q, want move 177 90 5", command)
+	}
+
+	// Top left is in the no-go zone
+	err = service.AbsoluteMove("Main", PTZVector{PanTilt: &Vector2D{X: -0.9, Y: 0.9}}, nil, nil)
+	if !errors.Is(err, camera.ErrNoGoZone) {
+		t.Fatalf("AbsoluteMove error = %v, want ErrNoGoZone", err)
+	}
+}
@@ -0,0 +1,127 @@
+package ptz
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/camera"
+)
+
+// ONVIF PTZ move states
+const (
+	MoveStatusIdle   = "IDLE"
+	MoveStatusMoving = "MOVING"
+)
+
+// GetStatusRequest represents GetStatus request
+type GetStatusRequest struct {
+	XMLName      xml.Name `xml:"GetStatus"`
+	ProfileToken string   `xml:"ProfileToken"`
+}
+
+// GetStatusResponse represents GetStatus response
+type GetStatusResponse struct {
+	XMLName   xml.Name  `xml:"tptz:GetStatusResponse"`
+	PTZStatus PTZStatus `xml:"tptz:PTZStatus"`
+}
+
+// PTZStatus represents the position and move state of a camera
+type PTZStatus struct {
+	Position   *PTZPosition  `xml:"tt:Position,omitempty"`
+	MoveStatus PTZMoveStatus `xml:"tt:MoveStatus"`
+	Error      string        `xml:"tt:Error,omitempty"`
+	UtcTime    string        `xml:"tt:UtcTime"`
+	Lock       *PTZLock      `xml:"https://github.com/mooglejp/atomcam_tools/onvif-relay PTZLock,omitempty"`
+}
+
+// PTZMoveStatus represents the move state of the pan/tilt motor
+type PTZMoveStatus struct {
+	PanTilt string `xml:"tt:PanTilt"`
+}
+
+// SetHomePositionRequest represents SetHomePosition request
+type SetHomePositionRequest struct {
+	XMLName      xml.Name `xml:"SetHomePosition"`
+	ProfileToken string   `xml:"ProfileToken"`
+}
+
+// SetHomePositionResponse represents SetHomePosition response
+type SetHomePositionResponse struct {
+	XMLName xml.Name `xml:"tptz:SetHomePositionResponse"`
+}
+
+// GetStatus handles GetStatus request with the live motor position (MOTORPOS).
+// The camera is reported as moving until MOTORPOS settles after the last move, and the
+// client holding the PTZ lease is reported in a PTZLock extension element.
+func (s *Service) GetStatus(profileToken string) (*GetStatusResponse, error) {
+	profile, err := s.registry.GetProfileByToken(profileToken)
+	if err != nil {
+		return nil, fmt.Errorf("profile not found: %s", profileToken)
+	}
+	if !profile.Camera.Config.Capabilities.PTZ {
+		return nil, fmt.Errorf("camera does not support PTZ: %s", profile.Camera.Config.Name)
+	}
+
+	status := PTZStatus{
+		MoveStatus: PTZMoveStatus{PanTilt: MoveStatusIdle},
+		UtcTime:    time.Now().UTC().Format(time.RFC3339),
+		Lock:       ptzLock(profile.Camera),
+	}
+	if profile.Camera.PTZMoving() {
+		status.MoveStatus.PanTilt = MoveStatusMoving
+	}
+
+	pan, tilt, err := profile.Camera.SyncPTZPosition()
+	if err != nil {
+		log.Printf("PTZ GetStatus: failed to read position of %s: %v", profile.Camera.Config.Name, err)
+		status.Error = "Position unavailable"
+		return &GetStatusResponse{PTZStatus: status}, nil
+	}
+
+	x, y := frameOf(profile.Camera).mapXY(AtomCamToONVIF(pan, tilt))
+	status.Position = &PTZPosition{
+		PanTilt: &Vector2D{
+			Space: "http://www.onvif.org/ver10/tptz/PanTiltSpaces/PositionGenericSpace",
+			X:     x,
+			Y:     y,
+		},
+	}
+	return &GetStatusResponse{PTZStatus: status}, nil
+}
+
+// SetHomePosition handles SetHomePosition request: the current position becomes the home
+// position used by GotoHomePosition, replacing ptz.home from config.yaml
+func (s *Service) SetHomePosition(profileToken string) error {
+	profile, err := s.registry.GetProfileByToken(profileToken)
+	if err != nil {
+		return fmt.Errorf("profile not found: %s", profileToken)
+	}
+	cam := profile.Camera
+	if !cam.Config.Capabilities.PTZ {
+		return fmt.Errorf("camera does not support PTZ: %s", cam.Config.Name)
+	}
+
+	pan, tilt, err := cam.SyncPTZPosition()
+	if err != nil {
+		return fmt.Errorf("failed to read camera position: %w", err)
+	}
+	if err := s.store.SetHome(cam.Config.Name, StoredPosition{Pan: pan, Tilt: tilt}); err != nil {
+		return err
+	}
+	log.Printf("PTZ SetHomePosition: %s home set to (%d, %d)", cam.Config.Name, pan, tilt)
+	return nil
+}
+
+// homePosition returns the home position of a camera: the one saved with SetHomePosition,
+// ptz.home from config.yaml, or a default
+func (s *Service) homePosition(cam *camera.Camera) (pan, tilt int) {
+	if home, ok := s.store.Home(cam.Config.Name); ok {
+		return home.Pan, home.Tilt
+	}
+	if cam.Config.PTZ.Home != nil {
+		return cam.Config.PTZ.Home.Pan, cam.Config.PTZ.Home.Tilt
+	}
+	return 160, 130
+}
@@ -0,0 +1,121 @@
+package ptz
+
+import (
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/auth"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/camera"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/config"
+)
+
+func TestGetStatusReportsLivePosition(t *testing.T) {
+	service, _, closeService := newTrackingTestService(t, nil)
+	defer closeService()
+
+	resp, err := service.GetStatus("Main")
+	if err != nil {
+		t.Fatalf("GetStatus returned an error: %v", err)
+	}
+	status := resp.PTZStatus
+	if status.MoveStatus.PanTilt != MoveStatusIdle {
+		t.Fatalf("MoveStatus = %s, want IDLE", status.MoveStatus.PanTilt)
+	}
+	wantX, wantY := AtomCamToONVIF(120, 45)
+	if status.Position == nil || status.Position.PanTilt.X != wantX || status.Position.PanTilt.Y != wantY {
+		t.Fatalf("Position = %+v, want (%.3f, %.3f)", status.Position, wantX, wantY)
+	}
+}
+
+func TestGetStatusReportsPTZLock(t *testing.T) {
+	service, commands, closeService := newTrackingTestService(t, nil)
+	defer closeService()
+
+	operator := &auth.User{Username: "operator", Level: auth.LevelOperator}
+	admin := &auth.User{Username: "admin", Level: auth.LevelAdministrator}
+	frigate := &auth.User{Username: "frigate", Level: auth.LevelOperator, PTZPriority: 50}
+
+	if err := service.GotoHomePosition("Main", nil, operator); err != nil {
+		t.Fatalf("GotoHomePosition of operator returned an error: %v", err)
+	}
+	expectCommand(t, commands, "move 160 130 5")
+	if err := service.GotoHomePosition("Main", nil, frigate); err != nil {
+		t.Fatalf("GotoHomePosition of frigate returned an error: %v", err)
+	}
+	expectCommand(t, commands, "move 160 130 5")
+
+	// ptz_priority of frigate outranks the Administrator level
+	position := PTZVector{PanTilt: &Vector2D{X: 0, Y: 0}}
+	if err := service.AbsoluteMove("Main", position, nil, admin); !errors.Is(err, camera.ErrPTZLocked) {
+		t.Fatalf("AbsoluteMove of admin: error = %v, want %v", err, camera.ErrPTZLocked)
+	}
+
+	resp, err := service.GetStatus("Main")
+	if err != nil {
+		t.Fatalf("GetStatus returned an error: %v", err)
+	}
+	if lock := resp.PTZStatus.Lock; lock == nil || lock.Holder != "frigate" || lock.Priority != 50 {
+		t.Fatalf("Lock = %+v, want held by frigate with priority 50", lock)
+	}
+	data, err := xml.Marshal(resp)
+	if err != nil {
+		t.Fatalf("failed to marshal GetStatus response: %v", err)
+	}
+	if !strings.Contains(string(data), `<PTZLock xmlns="https://github.com/mooglejp/atomcam_tools/onvif-relay"><Holder>frigate</Holder>`) {
+		t.Fatalf("GetStatus response has no PTZLock extension: %s", data)
+	}
+}
+
+func TestSetHomePositionIsUsedByGotoHomePosition(t *testing.T) {
+	service, commands, closeService := newTrackingTestService(t, nil)
+	defer closeService()
+
+	if err := service.SetHomePosition("Main"); err != nil {
+		t.Fatalf("SetHomePosition returned an error: %v", err)
+	}
+
+	// The home position survives a restart
+	store, err := NewStore(service.store.path)
+	if err != nil {
+		t.Fatalf("failed to reload PTZ store: %v", err)
+	}
+	service.store = store
+
+	if err := service.GotoHomePosition("Main", nil, nil); err != nil {
+		t.Fatalf("GotoHomePosition returned an error: %v", err)
+	}
+	if command := <-commands; command != "move 120 45 5" {
+		t.Fatalf("command = %q, want move 120 45 5", command)
+	}
+}
+
+func TestCeilingMountMirrorsPositions(t *testing.T) {
+	service, commands, closeService := newTrackingTestService(t, nil)
+	defer closeService()
+
+	cam, err := service.registry.Get("swing")
+	if err != nil {
+		t.Fatalf("camera not found: %v", err)
+	}
+	cam.Config.PTZ.Mount = config.MountCeiling
+
+	resp, err := service.GetStatus("Main")
+	if err != nil {
+		t.Fatalf("GetStatus returned an error: %v", err)
+	}
+	x, y := AtomCamToONVIF(120, 45)
+	if pos := resp.PTZStatus.Position; pos == nil || pos.PanTilt.X != -x || pos.PanTilt.Y != -y {
+		t.Fatalf("Position = %+v, want (%.3f, %.3f)", resp.PTZStatus.Position, -x, -y)
+	}
+
+	// Right and up in the viewer's frame are left and down for the upside-down camera
+	position := PTZVector{PanTilt: &Vector2D{X: 0.5, Y: 0.5}}
+	if err := service.AbsoluteMove("Main", position, nil, nil); err != nil {
+		t.Fatalf("AbsoluteMove returned an error: %v", err)
+	}
+	if command := <-commands; command != "move 89 135 5" {
+		t.Fatalf("command = %q, want move 89 135 5", command)
+	}
+}
@@ -0,0 +1,249 @@
+package ptz
+
+import (
+	"sync"
+
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/state"
+)
+
+// Store persists the PTZ settings changed via ONVIF, keyed by camera name.
+// Settings from config.yaml are never written here; the service merges both.
+type Store struct {
+	path string
+
+	mu      sync.Mutex
+	cameras map[string]*cameraState
+}
+
+// cameraState holds the PTZ settings of one camera
+type cameraState struct {
+	Presets []StoredPreset  `json:"presets,omitempty"`
+	Home    *StoredPosition `json:"home,omitempty"`
+	Tours   []StoredTour    `json:"tours,omitempty"`
+}
+
+// StoredPosition is a pan/tilt position in AtomCam degrees
+type StoredPosition struct {
+	Pan  int `json:"pan"`
+	Tilt int `json:"tilt"`
+}
+
+// StoredPreset is a preset position saved with SetPreset
+type StoredPreset struct {
+	Token string `json:"token"`
+	Name  string `json:"name"`
+	Pan   int    `json:"pan"`
+	Tilt  int    `json:"tilt"`
+}
+
+// StoredTour is a preset tour created with CreatePresetTour and ModifyPresetTour.
+// Durations are xs:duration values as sent by the client.
+type StoredTour struct {
+	Token             string           `json:"token"`
+	Name              string           `json:"name,omitempty"`
+	AutoStart         bool             `json:"auto_start,omitempty"`
+	Direction         string           `json:"direction,omitempty"`          // Forward, Backward or Random
+	RecurringTime     int              `json:"recurring_time,omitempty"`     // Number of rounds (0 = until stopped)
+	RecurringDuration string           `json:"recurring_duration,omitempty"` // Maximum tour duration (empty = until stopped)
+	Spots             []StoredTourSpot `json:"spots,omitempty"`
+}
+
+// StoredTourSpot is a stop of a preset tour: a preset, the home position or a position
+type StoredTourSpot struct {
+	PresetToken string          `json:"preset_token,omitempty"`
+	Home        bool            `json:"home,omitempty"`
+	Position    *StoredPosition `json:"position,omitempty"`
+	Speed       int             `json:"speed,omitempty"` // AtomCam speed 1-9 (0 = default)
+	StayTime    string          `json:"stay_time,omitempty"`
+}
+
+// NewStore creates a PTZ store and loads the settings saved in path
+func NewStore(path string) (*Store, error) {
+	s := &Store{
+		path:    path,
+		cameras: make(map[string]*cameraState),
+	}
+	if err := state.Load(path, &s.cameras); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Presets returns the presets saved for a camera
+func (s *Store) Presets(cameraName string) []StoredPreset {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cs, ok := s.cameras[cameraName]
+	if !ok {
+		return nil
+	}
+	return append([]StoredPreset(nil), cs.Presets...)
+}
+
+// SetPreset saves a preset of a camera, replacing the preset with the same token
+func (s *Store) SetPreset(cameraName string, preset StoredPreset) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cs := s.camera(cameraName)
+	presets := append([]StoredPreset(nil), cs.Presets...)
+	replaced := false
+	for i := range presets {
+		if presets[i].Token == preset.Token {
+			presets[i] = preset
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		presets = append(presets, preset)
+	}
+
+	old := cs.Presets
+	cs.Presets = presets
+	if err := state.Save(s.path, s.cameras); err != nil {
+		cs.Presets = old
+		return err
+	}
+	return nil
+}
+
+// RemovePreset deletes a saved preset of a camera; ok is false if it does not exist
+func (s *Store) RemovePreset(cameraName, token string) (ok bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cs, exists := s.cameras[cameraName]
+	if !exists {
+		return false, nil
+	}
+
+	var presets []StoredPreset
+	for _, p := range cs.Presets {
+		if p.Token == token {
+			ok = true
+			continue
+		}
+		presets = append(presets, p)
+	}
+	if !ok {
+		return false, nil
+	}
+
+	old := cs.Presets
+	cs.Presets = presets
+	if err := state.Save(s.path, s.cameras); err != nil {
+		cs.Presets = old
+		return false, err
+	}
+	return true, nil
+}
+
+// Home returns the home position saved for a camera with SetHomePosition
+func (s *Store) Home(cameraName string) (StoredPosition, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cs, ok := s.cameras[cameraName]
+	if !ok || cs.Home == nil {
+		return StoredPosition{}, false
+	}
+	return *cs.Home, true
+}
+
+// SetHome saves the home position of a camera
+func (s *Store) SetHome(cameraName string, home StoredPosition) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cs := s.camera(cameraName)
+	old := cs.Home
+	cs.Home = &home
+	if err := state.Save(s.path, s.cameras); err != nil {
+		cs.Home = old
+		return err
+	}
+	return nil
+}
+
+// Tours returns the preset tours of a camera
+func (s *Store) Tours(cameraName string) []StoredTour {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cs, ok := s.cameras[cameraName]
+	if !ok {
+		return nil
+	}
+	return append([]StoredTour(nil), cs.Tours...)
+}
+
+// SetTour saves a preset tour of a camera, replacing the tour with the same token
+func (s *Store) SetTour(cameraName string, tour StoredTour) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cs := s.camera(cameraName)
+	tours := append([]StoredTour(nil), cs.Tours...)
+	replaced := false
+	for i := range tours {
+		if tours[i].Token == tour.Token {
+			tours[i] = tour
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		tours = append(tours, tour)
+	}
+
+	old := cs.Tours
+	cs.Tours = tours
+	if err := state.Save(s.path, s.cameras); err != nil {
+		cs.Tours = old
+		return err
+	}
+	return nil
+}
+
+// RemoveTour deletes a preset tour of a camera; ok is false if it does not exist
+func (s *Store) RemoveTour(cameraName, token string) (ok bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cs, exists := s.cameras[cameraName]
+	if !exists {
+		return false, nil
+	}
+
+	var tours []StoredTour
+	for _, t := range cs.Tours {
+		if t.Token == token {
+			ok = true
+			continue
+		}
+		tours = append(tours, t)
+	}
+	if !ok {
+		return false, nil
+	}
+
+	old := cs.Tours
+	cs.Tours = tours
+	if err := state.Save(s.path, s.cameras); err != nil {
+		cs.Tours = old
+		return false, err
+	}
+	return true, nil
+}
+
+// camera returns the state of a camera, creating it if needed; s.mu must be held
+func (s *Store) camera(cameraName string) *cameraState {
+	cs, ok := s.cameras[cameraName]
+	if !ok {
+		cs = &cameraState{}
+		s.cameras[cameraName] = cs
+	}
+	return cs
+}
@@ -0,0 +1,366 @@
+package ptz
+
+import (
+	"errors"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/camera"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/onvif/soap"
+)
+
+const (
+	// defaultTourResumeDelay is the idle time after a manual move before a paused tour resumes
+	defaultTourResumeDelay = time.Minute
+	// defaultTourStayTime is how long a tour stays at a spot without StayTime
+	defaultTourStayTime = 10 * time.Second
+)
+
+// errTourInterrupted is returned by gotoTourSpot when the tour was paused or stopped
+// while it waited for the PTZ lease
+var errTourInterrupted = errors.New("tour interrupted")
+
+// ONVIF preset tour states
+const (
+	TourStateIdle    = "Idle"
+	TourStateTouring = "Touring"
+	TourStatePaused  = "Paused"
+)
+
+// tourRun is a preset tour running on a camera
+type tourRun struct {
+	tour     StoredTour
+	stop     chan struct{}
+	done     chan struct{}
+	wake     chan struct{} // Signalled when the tour is resumed
+	stopOnce sync.Once
+
+	mu          sync.Mutex
+	paused      bool
+	manualPause bool   // Paused by a manual move; resumes after the idle time
+	pauseSeq    uint64 // Invalidates pending resume timers
+	resumeTimer *time.Timer
+	spot        int // Index of the current tour spot (-1 before the first one)
+}
+
+func newTourRun(tour StoredTour) *tourRun {
+	return &tourRun{
+		tour: tour,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+		wake: make(chan struct{}, 1),
+		spot: -1,
+	}
+}
+
+// halt stops the tour and waits for it to end
+func (r *tourRun) halt() {
+	r.stopOnce.Do(func() { close(r.stop) })
+	<-r.done
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.resumeTimer != nil {
+		r.resumeTimer.Stop()
+	}
+}
+
+// status returns the ONVIF state of the tour and the index of its current spot
+func (r *tourRun) status() (state string, spot int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.paused {
+		return TourStatePaused, r.spot
+	}
+	return TourStateTouring, r.spot
+}
+
+// pause pauses the tour. A manual pause resumes after resumeAfter; it does not override
+// a pause requested with OperatePresetTour, which lasts until the tour is started again.
+func (r *tourRun) pause(manual bool, resumeAfter time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if manual && r.paused && !r.manualPause {
+		return
+	}
+	r.paused = true
+	r.manualPause = manual
+	r.pauseSeq++
+	if r.resumeTimer != nil {
+		r.resumeTimer.Stop()
+		r.resumeTimer = nil
+	}
+	if manual {
+		seq := r.pauseSeq
+		r.resumeTimer = time.AfterFunc(resumeAfter, func() { r.resumeAfterPause(seq) })
+	}
+}
+
+// resumeAfterPause resumes a manual pause unless the tour was paused again since
+func (r *tourRun) resumeAfterPause(seq uint64) {
+	r.mu.Lock()
+	current := r.pauseSeq == seq && r.manualPause
+	r.mu.Unlock()
+	if current {
+		log.Printf("PTZ tour %s: resuming after manual control", r.tour.Token)
+		r.resume(true)
+	}
+}
+
+// resume resumes a paused tour; with manualOnly, only a pause caused by a manual move
+func (r *tourRun) resume(manualOnly bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.paused || (manualOnly && !r.manualPause) {
+		return
+	}
+	r.paused = false
+	r.manualPause = false
+	r.pauseSeq++
+	if r.resumeTimer != nil {
+		r.resumeTimer.Stop()
+		r.resumeTimer = nil
+	}
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// waitActive blocks while the tour is paused; it returns false when the tour is stopped
+func (r *tourRun) waitActive() bool {
+	for {
+		r.mu.Lock()
+		paused := r.paused
+		r.mu.Unlock()
+		if !paused {
+			return true
+		}
+
+		select {
+		case <-r.stop:
+			return false
+		case <-r.wake:
+		}
+	}
+}
+
+// active reports whether the tour is neither paused nor stopped
+func (r *tourRun) active() bool {
+	select {
+	case <-r.stop:
+		return false
+	default:
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return !r.paused
+}
+
+// sleep waits for d; it returns false when the tour is stopped
+func (r *tourRun) sleep(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-r.stop:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+func (r *tourRun) setSpot(i int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spot = i
+}
+
+// startAutoTours starts the first AutoStart tour of each PTZ camera
+func (s *Service) startAutoTours() {
+	for _, cam := range s.registry.List() {
+		if !cam.Config.Capabilities.PTZ {
+			continue
+		}
+		for _, tour := range s.store.Tours(cam.Config.Name) {
+			if tour.AutoStart && len(tour.Spots) > 0 {
+				s.startTour(cam.Config.Name, tour)
+				break
+			}
+		}
+	}
+}
+
+// startTour runs a tour on a camera, replacing the tour running there
+func (s *Service) startTour(cameraName string, tour StoredTour) {
+	run := newTourRun(tour)
+
+	s.tourMu.Lock()
+	old := s.tours[cameraName]
+	s.tours[cameraName] = run
+	s.tourMu.Unlock()
+
+	if old != nil {
+		old.halt()
+	}
+	log.Printf("PTZ tour %s: starting on %s with %d spots", tour.Token, cameraName, len(tour.Spots))
+	go s.runTour(cameraName, run)
+}
+
+// stopTour stops the tour running on a camera if it has the given token
+func (s *Service) stopTour(cameraName, token string) {
+	s.tourMu.Lock()
+	run := s.tours[cameraName]
+	if run == nil || run.tour.Token != token {
+		s.tourMu.Unlock()
+		return
+	}
+	delete(s.tours, cameraName)
+	s.tourMu.Unlock()
+
+	run.halt()
+}
+
+// runningTour returns the run of a tour if it is running on a camera
+func (s *Service) runningTour(cameraName, token string) *tourRun {
+	s.tourMu.Lock()
+	defer s.tourMu.Unlock()
+
+	run := s.tours[cameraName]
+	if run == nil || run.tour.Token != token {
+		return nil
+	}
+	return run
+}
+
+// interruptTour pauses the tour running on a camera before a manual move.
+// The tour resumes with its next spot once the camera has been idle for tourResumeDelay.
+func (s *Service) interruptTour(cam *camera.Camera) {
+	s.tourMu.Lock()
+	run := s.tours[cam.Config.Name]
+	s.tourMu.Unlock()
+
+	if run != nil {
+		run.pause(true, s.tourResumeDelay)
+	}
+}
+
+// runTour visits the spots of a tour until it is stopped or its rounds or duration are over
+func (s *Service) runTour(cameraName string, run *tourRun) {
+	defer func() {
+		s.tourMu.Lock()
+		if s.tours[cameraName] == run {
+			delete(s.tours, cameraName)
+		}
+		s.tourMu.Unlock()
+		close(run.done)
+	}()
+
+	tour := run.tour
+	var deadline time.Time
+	if tour.RecurringDuration != "" {
+		if d, err := soap.ParseDuration(tour.RecurringDuration); err == nil && d > 0 {
+			deadline = time.Now().Add(d)
+		}
+	}
+
+	for round := 0; tour.RecurringTime == 0 || round < tour.RecurringTime; round++ {
+		for _, i := range tourOrder(tour) {
+			if !run.waitActive() {
+				return
+			}
+			if !deadline.IsZero() && time.Now().After(deadline) {
+				log.Printf("PTZ tour %s: recurring duration on %s is over", tour.Token, cameraName)
+				return
+			}
+
+			run.setSpot(i)
+			spot := tour.Spots[i]
+			err := s.gotoTourSpot(cameraName, run, spot)
+			for errors.Is(err, errTourInterrupted) {
+				// Paused while waiting: go to the same spot once the tour is resumed
+				if !run.waitActive() || !run.active() {
+					return
+				}
+				err = s.gotoTourSpot(cameraName, run, spot)
+			}
+			if err != nil {
+				log.Printf("PTZ tour %s: failed to move %s to spot %d: %v", tour.Token, cameraName, i+1, err)
+			}
+			if !run.sleep(tourStayTime(spot)) {
+				return
+			}
+		}
+	}
+	log.Printf("PTZ tour %s: finished on %s", tour.Token, cameraName)
+}
+
+// gotoTourSpot moves a camera to a tour spot without pausing the tour. It first waits
+// for clients controlling the camera to release it, and fails with errTourInterrupted if
+// the tour was paused or stopped by the time it holds the PTZ lease.
+func (s *Service) gotoTourSpot(cameraName string, run *tourRun, spot StoredTourSpot) error {
+	cam, err := s.registry.Get(cameraName)
+	if err != nil {
+		return err
+	}
+	if !run.waitForLease(cam) {
+		return errTourInterrupted
+	}
+
+	speed := spot.Speed
+	if speed == 0 {
+		speed = moveSpeed(nil)
+	}
+	ctl := tourController(run.tour)
+	return cam.ArbitratePTZ(ctl, func() error {
+		// A manual move or OperatePresetTour may have paused the tour while it waited
+		if !run.active() {
+			cam.ReleasePTZ(ctl)
+			return errTourInterrupted
+		}
+		switch {
+		case spot.Home:
+			pan, tilt := s.homePosition(cam)
+			return cam.MovePTZ(pan, tilt, speed)
+		case spot.Position != nil:
+			return cam.MovePTZ(spot.Position.Pan, spot.Position.Tilt, speed)
+		default:
+			return s.gotoPreset(cam, spot.PresetToken, speed)
+		}
+	})
+}
+
+// tourOrder returns the spot indexes of one round of a tour in visiting order
+func tourOrder(tour StoredTour) []int {
+	n := len(tour.Spots)
+	switch tour.Direction {
+	case TourDirectionRandom:
+		return rand.Perm(n)
+	case TourDirectionBackward:
+		order := make([]int, n)
+		for i := range order {
+			order[i] = n - 1 - i
+		}
+		return order
+	default:
+		order := make([]int, n)
+		for i := range order {
+			order[i] = i
+		}
+		return order
+	}
+}
+
+// tourStayTime returns how long a tour stays at a spot
+func tourStayTime(spot StoredTourSpot) time.Duration {
+	if spot.StayTime != "" {
+		if d, err := soap.ParseDuration(spot.StayTime); err == nil && d > 0 {
+			return d
+		}
+	}
+	return defaultTourStayTime
+}
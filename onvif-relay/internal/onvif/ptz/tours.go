@@ -0,0 +1,600 @@
+package ptz
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/auth"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/camera"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/onvif/soap"
+)
+
+const (
+	// maxStoredTours limits the preset tours per camera
+	maxStoredTours = 8
+	// maxTourSpots limits the spots of a preset tour
+	maxTourSpots = 64
+	// maxTourRecurringTime limits the number of rounds of a preset tour
+	maxTourRecurringTime = 1000
+	// maxTourRecurringDuration limits how long a preset tour runs when a duration is set
+	maxTourRecurringDuration = 24 * time.Hour
+	// maxTourStayTime limits how long a preset tour stays at a spot
+	maxTourStayTime = time.Hour
+	// storedTourTokenFormat is the token format of tours created with CreatePresetTour
+	storedTourTokenFormat = "tour_%d"
+)
+
+// ONVIF preset tour operations
+const (
+	TourOperationStart = "Start"
+	TourOperationStop  = "Stop"
+	TourOperationPause = "Pause"
+)
+
+// ONVIF preset tour directions
+const (
+	TourDirectionForward  = "Forward"
+	TourDirectionBackward = "Backward"
+	TourDirectionRandom   = "Random"
+)
+
+// Preset tour errors; the ONVIF server maps them to fault subcodes
+var (
+	ErrNoTour            = errors.New("preset tour token does not exist")
+	ErrTooManyTours      = errors.New("maximum number of preset tours reached")
+	ErrInvalidTour       = errors.New("invalid preset tour")
+	ErrTourActivation    = errors.New("preset tour cannot be started")
+	ErrUnsupportedTourOp = errors.New("unsupported preset tour operation")
+)
+
+// GetPresetToursRequest represents GetPresetTours request
+type GetPresetToursRequest struct {
+	XMLName      xml.Name `xml:"GetPresetTours"`
+	ProfileToken string   `xml:"ProfileToken"`
+}
+
+// GetPresetToursResponse represents GetPresetTours response
+type GetPresetToursResponse struct {
+	XMLName    xml.Name     `xml:"tptz:GetPresetToursResponse"`
+	PresetTour []PresetTour `xml:"tptz:PresetTour"`
+}
+
+// GetPresetTourRequest represents GetPresetTour request
+type GetPresetTourRequest struct {
+	XMLName         xml.Name `xml:"GetPresetTour"`
+	ProfileToken    string   `xml:"ProfileToken"`
+	PresetTourToken string   `xml:"PresetTourToken"`
+}
+
+// GetPresetTourResponse represents GetPresetTour response
+type GetPresetTourResponse struct {
+	XMLName    xml.Name   `xml:"tptz:GetPresetTourResponse"`
+	PresetTour PresetTour `xml:"tptz:PresetTour"`
+}
+
+// GetPresetTourOptionsRequest represents GetPresetTourOptions request
+type GetPresetTourOptionsRequest struct {
+	XMLName         xml.Name `xml:"GetPresetTourOptions"`
+	ProfileToken    string   `xml:"ProfileToken"`
+	PresetTourToken string   `xml:"PresetTourToken,omitempty"`
+}
+
+// GetPresetTourOptionsResponse represents GetPresetTourOptions response
+type GetPresetTourOptionsResponse struct {
+	XMLName xml.Name          `xml:"tptz:GetPresetTourOptionsResponse"`
+	Options PresetTourOptions `xml:"tptz:Options"`
+}
+
+// CreatePresetTourRequest represents CreatePresetTour request
+type CreatePresetTourRequest struct {
+	XMLName      xml.Name `xml:"CreatePresetTour"`
+	ProfileToken string   `xml:"ProfileToken"`
+}
+
+// CreatePresetTourResponse represents CreatePresetTour response
+type CreatePresetTourResponse struct {
+	XMLName         xml.Name `xml:"tptz:CreatePresetTourResponse"`
+	PresetTourToken string   `xml:"tptz:PresetTourToken"`
+}
+
+// ModifyPresetTourRequest represents ModifyPresetTour request
+type ModifyPresetTourRequest struct {
+	XMLName      xml.Name             `xml:"ModifyPresetTour"`
+	ProfileToken string               `xml:"ProfileToken"`
+	PresetTour   PresetTourDefinition `xml:"PresetTour"`
+}
+
+// ModifyPresetTourResponse represents ModifyPresetTour response
+type ModifyPresetTourResponse struct {
+	XMLName xml.Name `xml:"tptz:ModifyPresetTourResponse"`
+}
+
+// OperatePresetTourRequest represents OperatePresetTour request
+type OperatePresetTourRequest struct {
+	XMLName         xml.Name `xml:"OperatePresetTour"`
+	ProfileToken    string   `xml:"ProfileToken"`
+	PresetTourToken string   `xml:"PresetTourToken"`
+	Operation       string   `xml:"Operation"`
+}
+
+// OperatePresetTourResponse represents OperatePresetTour response
+type OperatePresetTourResponse struct {
+	XMLName xml.Name `xml:"tptz:OperatePresetTourResponse"`
+}
+
+// RemovePresetTourRequest represents RemovePresetTour request
+type RemovePresetTourRequest struct {
+	XMLName         xml.Name `xml:"RemovePresetTour"`
+	ProfileToken    string   `xml:"ProfileToken"`
+	PresetTourToken string   `xml:"PresetTourToken"`
+}
+
+// RemovePresetTourResponse represents RemovePresetTour response
+type RemovePresetTourResponse struct {
+	XMLName xml.Name `xml:"tptz:RemovePresetTourResponse"`
+}
+
+// PresetTour represents a preset tour in responses
+type PresetTour struct {
+	Token             string                      `xml:"token,attr"`
+	Name              string                      `xml:"tt:Name,omitempty"`
+	Status            PresetTourStatus            `xml:"tt:Status"`
+	AutoStart         bool                        `xml:"tt:AutoStart"`
+	StartingCondition PresetTourStartingCondition `xml:"tt:StartingCondition"`
+	TourSpot          []PresetTourSpot            `xml:"tt:TourSpot"`
+}
+
+// PresetTourStatus represents the state of a preset tour and the spot it is at
+type PresetTourStatus struct {
+	State           string          `xml:"tt:State"`
+	CurrentTourSpot *PresetTourSpot `xml:"tt:CurrentTourSpot,omitempty"`
+}
+
+// PresetTourStartingCondition represents how often and in which order a tour visits its spots
+type PresetTourStartingCondition struct {
+	RandomPresetOrder bool   `xml:"RandomPresetOrder,attr,omitempty"`
+	RecurringTime     int    `xml:"tt:RecurringTime,omitempty"`
+	RecurringDuration string `xml:"tt:RecurringDuration,omitempty"`
+	Direction         string `xml:"tt:Direction,omitempty"`
+}
+
+// PresetTourSpot represents a spot of a preset tour in responses
+type PresetTourSpot struct {
+	PresetDetail PresetTourPresetDetail `xml:"tt:PresetDetail"`
+	Speed        *PTZPosition           `xml:"tt:Speed,omitempty"` // Same layout as a position
+	StayTime     string                 `xml:"tt:StayTime"`
+}
+
+// PresetTourPresetDetail represents the target of a tour spot in responses
+type PresetTourPresetDetail struct {
+	PresetToken string       `xml:"tt:PresetToken,omitempty"`
+	Home        bool         `xml:"tt:Home,omitempty"`
+	PTZPosition *PTZPosition `xml:"tt:PTZPosition,omitempty"`
+}
+
+// PresetTourDefinition represents a preset tour sent with ModifyPresetTour
+type PresetTourDefinition struct {
+	Token             string                          `xml:"token,attr"`
+	Name              string                          `xml:"Name"`
+	AutoStart         bool                            `xml:"AutoStart"`
+	StartingCondition *PresetTourStartingConditionDef `xml:"StartingCondition"`
+	TourSpot          []PresetTourSpotDefinition      `xml:"TourSpot"`
+}
+
+// PresetTourStartingConditionDef represents the starting condition sent with ModifyPresetTour
+type PresetTourStartingConditionDef struct {
+	RandomPresetOrder bool   `xml:"RandomPresetOrder,attr"`
+	RecurringTime     *int   `xml:"RecurringTime"`
+	RecurringDuration string `xml:"RecurringDuration"`
+	Direction         string `xml:"Direction"`
+}
+
+// PresetTourSpotDefinition represents a tour spot sent with ModifyPresetTour
+type PresetTourSpotDefinition struct {
+	PresetDetail struct {
+		PresetToken string     `xml:"PresetToken"`
+		Home        bool       `xml:"Home"`
+		PTZPosition *PTZVector `xml:"PTZPosition"`
+	} `xml:"PresetDetail"`
+	Speed    *PTZSpeed `xml:"Speed"`
+	StayTime string    `xml:"StayTime"`
+}
+
+// PresetTourOptions represents the values accepted by ModifyPresetTour
+type PresetTourOptions struct {
+	AutoStart         bool                               `xml:"tt:AutoStart"`
+	StartingCondition PresetTourStartingConditionOptions `xml:"tt:StartingCondition"`
+	TourSpot          PresetTourSpotOptions              `xml:"tt:TourSpot"`
+}
+
+// PresetTourStartingConditionOptions represents the accepted starting conditions
+type PresetTourStartingConditionOptions struct {
+	RecurringTime     *IntRange      `xml:"tt:RecurringTime,omitempty"`
+	RecurringDuration *DurationRange `xml:"tt:RecurringDuration,omitempty"`
+	Direction         []string       `xml:"tt:Direction"`
+}
+
+// PresetTourSpotOptions represents the accepted tour spots
+type PresetTourSpotOptions struct {
+	PresetDetail PresetTourPresetDetailOptions `xml:"tt:PresetDetail"`
+	StayTime     DurationRange                 `xml:"tt:StayTime"`
+}
+
+// PresetTourPresetDetailOptions represents the presets a tour spot can use
+type PresetTourPresetDetailOptions struct {
+	PresetToken []string `xml:"tt:PresetToken"`
+	Home        bool     `xml:"tt:Home"`
+}
+
+// IntRange represents an integer range
+type IntRange struct {
+	Min int `xml:"tt:Min"`
+	Max int `xml:"tt:Max"`
+}
+
+// DurationRange represents an xs:duration range
+type DurationRange struct {
+	Min string `xml:"tt:Min"`
+	Max string `xml:"tt:Max"`
+}
+
+// ptzCamera returns the camera of a profile, which must support PTZ
+func (s *Service) ptzCamera(profileToken string) (*camera.Camera, error) {
+	profile, err := s.registry.GetProfileByToken(profileToken)
+	if err != nil {
+		return nil, fmt.Errorf("profile not found: %s", profileToken)
+	}
+	if !profile.Camera.Config.Capabilities.PTZ {
+		return nil, fmt.Errorf("camera does not support PTZ: %s", profile.Camera.Config.Name)
+	}
+	return profile.Camera, nil
+}
+
+// findTour returns the preset tour of a camera with a token
+func (s *Service) findTour(cam *camera.Camera, token string) (StoredTour, error) {
+	for _, t := range s.store.Tours(cam.Config.Name) {
+		if t.Token == token {
+			return t, nil
+		}
+	}
+	return StoredTour{}, fmt.Errorf("%w: %s", ErrNoTour, token)
+}
+
+// GetPresetTours handles GetPresetTours request
+func (s *Service) GetPresetTours(profileToken string) (*GetPresetToursResponse, error) {
+	cam, err := s.ptzCamera(profileToken)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &GetPresetToursResponse{}
+	for _, t := range s.store.Tours(cam.Config.Name) {
+		resp.PresetTour = append(resp.PresetTour, s.presetTour(cam, t))
+	}
+	return resp, nil
+}
+
+// GetPresetTour handles GetPresetTour request
+func (s *Service) GetPresetTour(profileToken, tourToken string) (*GetPresetTourResponse, error) {
+	cam, err := s.ptzCamera(profileToken)
+	if err != nil {
+		return nil, err
+	}
+	tour, err := s.findTour(cam, tourToken)
+	if err != nil {
+		return nil, err
+	}
+	return &GetPresetTourResponse{PresetTour: s.presetTour(cam, tour)}, nil
+}
+
+// GetPresetTourOptions handles GetPresetTourOptions request
+func (s *Service) GetPresetTourOptions(profileToken string) (*GetPresetTourOptionsResponse, error) {
+	cam, err := s.ptzCamera(profileToken)
+	if err != nil {
+		return nil, err
+	}
+
+	var tokens []string
+	for _, p := range s.presets(cam) {
+		tokens = append(tokens, p.Token)
+	}
+	return &GetPresetTourOptionsResponse{
+		Options: PresetTourOptions{
+			AutoStart: true,
+			StartingCondition: PresetTourStartingConditionOptions{
+				RecurringTime:     &IntRange{Min: 0, Max: maxTourRecurringTime},
+				RecurringDuration: &DurationRange{Min: "PT0S", Max: soap.FormatDuration(maxTourRecurringDuration)},
+				Direction:         []string{TourDirectionForward, TourDirectionBackward, TourDirectionRandom},
+			},
+			TourSpot: PresetTourSpotOptions{
+				PresetDetail: PresetTourPresetDetailOptions{PresetToken: tokens, Home: true},
+				StayTime:     DurationRange{Min: "PT0S", Max: soap.FormatDuration(maxTourStayTime)},
+			},
+		},
+	}, nil
+}
+
+// CreatePresetTour handles CreatePresetTour request with an empty tour to be filled by ModifyPresetTour
+func (s *Service) CreatePresetTour(profileToken string) (*CreatePresetTourResponse, error) {
+	cam, err := s.ptzCamera(profileToken)
+	if err != nil {
+		return nil, err
+	}
+
+	s.presetMu.Lock()
+	defer s.presetMu.Unlock()
+
+	tours := s.store.Tours(cam.Config.Name)
+	if len(tours) >= maxStoredTours {
+		return nil, ErrTooManyTours
+	}
+	token := nextTourToken(tours)
+	if err := s.store.SetTour(cam.Config.Name, StoredTour{Token: token}); err != nil {
+		return nil, err
+	}
+	log.Printf("PTZ CreatePresetTour: %s created tour %s", cam.Config.Name, token)
+
+	return &CreatePresetTourResponse{PresetTourToken: token}, nil
+}
+
+// ModifyPresetTour handles ModifyPresetTour request. A running tour is stopped.
+func (s *Service) ModifyPresetTour(req ModifyPresetTourRequest) (*ModifyPresetTourResponse, error) {
+	cam, err := s.ptzCamera(req.ProfileToken)
+	if err != nil {
+		return nil, err
+	}
+
+	s.presetMu.Lock()
+	defer s.presetMu.Unlock()
+
+	if _, err := s.findTour(cam, req.PresetTour.Token); err != nil {
+		return nil, err
+	}
+	tour, err := s.storedTour(cam, req.PresetTour)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.store.SetTour(cam.Config.Name, tour); err != nil {
+		return nil, err
+	}
+	s.stopTour(cam.Config.Name, tour.Token)
+	log.Printf("PTZ ModifyPresetTour: %s tour %s (%s) has %d spots", cam.Config.Name, tour.Token, tour.Name, len(tour.Spots))
+
+	return &ModifyPresetTourResponse{}, nil
+}
+
+// OperatePresetTour handles OperatePresetTour request. Starting a tour stops the other
+// tour of the camera; starting a paused tour resumes it. The user starting a tour hands
+// over the PTZ lease to it.
+func (s *Service) OperatePresetTour(req OperatePresetTourRequest, user *auth.User) (*OperatePresetTourResponse, error) {
+	cam, err := s.ptzCamera(req.ProfileToken)
+	if err != nil {
+		return nil, err
+	}
+	tour, err := s.findTour(cam, req.PresetTourToken)
+	if err != nil {
+		return nil, err
+	}
+
+	switch req.Operation {
+	case TourOperationStart:
+		if len(tour.Spots) == 0 {
+			return nil, fmt.Errorf("%w: %s has no tour spots", ErrTourActivation, tour.Token)
+		}
+		cam.ReleasePTZ(userController(user))
+		if run := s.runningTour(cam.Config.Name, tour.Token); run != nil {
+			run.resume(false)
+		} else {
+			s.startTour(cam.Config.Name, tour)
+		}
+	case TourOperationStop:
+		s.stopTour(cam.Config.Name, tour.Token)
+	case TourOperationPause:
+		if run := s.runningTour(cam.Config.Name, tour.Token); run != nil {
+			run.pause(false, 0)
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedTourOp, req.Operation)
+	}
+	log.Printf("PTZ OperatePresetTour: %s %s tour %s", cam.Config.Name, req.Operation, tour.Token)
+
+	return &OperatePresetTourResponse{}, nil
+}
+
+// RemovePresetTour handles RemovePresetTour request. A running tour is stopped.
+func (s *Service) RemovePresetTour(req RemovePresetTourRequest) (*RemovePresetTourResponse, error) {
+	cam, err := s.ptzCamera(req.ProfileToken)
+	if err != nil {
+		return nil, err
+	}
+
+	s.presetMu.Lock()
+	defer s.presetMu.Unlock()
+
+	removed, err := s.store.RemoveTour(cam.Config.Name, req.PresetTourToken)
+	if err != nil {
+		return nil, err
+	}
+	if !removed {
+		return nil, fmt.Errorf("%w: %s", ErrNoTour, req.PresetTourToken)
+	}
+	s.stopTour(cam.Config.Name, req.PresetTourToken)
+	log.Printf("PTZ RemovePresetTour: %s removed tour %s", cam.Config.Name, req.PresetTourToken)
+
+	return &RemovePresetTourResponse{}, nil
+}
+
+// presetTour builds the response form of a saved tour with its run state
+func (s *Service) presetTour(cam *camera.Camera, tour StoredTour) PresetTour {
+	pt := PresetTour{
+		Token:     tour.Token,
+		Name:      tour.Name,
+		Status:    PresetTourStatus{State: TourStateIdle},
+		AutoStart: tour.AutoStart,
+		StartingCondition: PresetTourStartingCondition{
+			RandomPresetOrder: tour.Direction == TourDirectionRandom,
+			RecurringTime:     tour.RecurringTime,
+			RecurringDuration: tour.RecurringDuration,
+			Direction:         tour.Direction,
+		},
+	}
+	frame := frameOf(cam)
+	for _, spot := range tour.Spots {
+		pt.TourSpot = append(pt.TourSpot, presetTourSpot(spot, frame))
+	}
+
+	if run := s.runningTour(cam.Config.Name, tour.Token); run != nil {
+		state, spot := run.status()
+		pt.Status.State = state
+		if spot >= 0 && spot < len(pt.TourSpot) {
+			current := pt.TourSpot[spot]
+			pt.Status.CurrentTourSpot = &current
+		}
+	}
+	return pt
+}
+
+// presetTourSpot builds the response form of a saved tour spot; positions are reported
+// in the viewer's frame of reference
+func presetTourSpot(spot StoredTourSpot, frame viewFrame) PresetTourSpot {
+	ts := PresetTourSpot{StayTime: soap.FormatDuration(tourStayTime(spot))}
+	switch {
+	case spot.Home:
+		ts.PresetDetail.Home = true
+	case spot.Position != nil:
+		x, y := frame.mapXY(AtomCamToONVIF(spot.Position.Pan, spot.Position.Tilt))
+		ts.PresetDetail.PTZPosition = &PTZPosition{PanTilt: &Vector2D{X: x, Y: y}}
+	default:
+		ts.PresetDetail.PresetToken = spot.PresetToken
+	}
+	if spot.Speed > 0 {
+		v := float64(spot.Speed-1) / 8
+		ts.Speed = &PTZPosition{PanTilt: &Vector2D{X: v, Y: v}}
+	}
+	return ts
+}
+
+// storedTour validates a tour sent with ModifyPresetTour and converts it for the store
+func (s *Service) storedTour(cam *camera.Camera, def PresetTourDefinition) (StoredTour, error) {
+	tour := StoredTour{
+		Token:     def.Token,
+		Name:      strings.TrimSpace(def.Name),
+		AutoStart: def.AutoStart,
+		Direction: TourDirectionForward,
+	}
+	if utf8.RuneCountInString(tour.Name) > maxPresetNameLength {
+		return StoredTour{}, fmt.Errorf("%w: name longer than %d characters", ErrInvalidTour, maxPresetNameLength)
+	}
+
+	if cond := def.StartingCondition; cond != nil {
+		switch cond.Direction {
+		case "":
+		case TourDirectionForward, TourDirectionBackward, TourDirectionRandom:
+			tour.Direction = cond.Direction
+		default:
+			return StoredTour{}, fmt.Errorf("%w: unknown direction %q", ErrInvalidTour, cond.Direction)
+		}
+		if cond.RandomPresetOrder {
+			tour.Direction = TourDirectionRandom
+		}
+		if cond.RecurringTime != nil {
+			if *cond.RecurringTime < 0 || *cond.RecurringTime > maxTourRecurringTime {
+				return StoredTour{}, fmt.Errorf("%w: recurring time must be 0-%d", ErrInvalidTour, maxTourRecurringTime)
+			}
+			tour.RecurringTime = *cond.RecurringTime
+		}
+		if cond.RecurringDuration != "" {
+			if d, err := soap.ParseDuration(cond.RecurringDuration); err != nil || d <= 0 || d > maxTourRecurringDuration {
+				return StoredTour{}, fmt.Errorf("%w: invalid recurring duration %q", ErrInvalidTour, cond.RecurringDuration)
+			}
+			tour.RecurringDuration = cond.RecurringDuration
+		}
+	}
+
+	if len(def.TourSpot) > maxTourSpots {
+		return StoredTour{}, fmt.Errorf("%w: more than %d tour spots", ErrInvalidTour, maxTourSpots)
+	}
+	for i, ts := range def.TourSpot {
+		spot, err := s.storedTourSpot(cam, ts)
+		if err != nil {
+			return StoredTour{}, fmt.Errorf("tour spot %d: %w", i+1, err)
+		}
+		tour.Spots = append(tour.Spots, spot)
+	}
+	return tour, nil
+}
+
+// storedTourSpot validates a tour spot sent with ModifyPresetTour
+func (s *Service) storedTourSpot(cam *camera.Camera, def PresetTourSpotDefinition) (StoredTourSpot, error) {
+	var spot StoredTourSpot
+	detail := def.PresetDetail
+	targets := 0
+	if detail.PresetToken != "" {
+		preset, ok := s.findPreset(cam, detail.PresetToken)
+		if !ok {
+			return StoredTourSpot{}, fmt.Errorf("%w: %s", ErrNoToken, detail.PresetToken)
+		}
+		if preset.Action == nil {
+			if _, _, err := cam.LimitPTZ(preset.Pan, preset.Tilt); err != nil {
+				return StoredTourSpot{}, fmt.Errorf("%w: preset %s: %v", ErrInvalidTour, detail.PresetToken, err)
+			}
+		}
+		spot.PresetToken = detail.PresetToken
+		targets++
+	}
+	if detail.Home {
+		spot.Home = true
+		targets++
+	}
+	if detail.PTZPosition != nil {
+		pos := detail.PTZPosition.PanTilt
+		if pos == nil || math.IsNaN(pos.X) || math.IsNaN(pos.Y) {
+			return StoredTourSpot{}, fmt.Errorf("%w: pan/tilt position required", ErrInvalidTour)
+		}
+		x, y := frameOf(cam).mapXY(pos.X, pos.Y)
+		pan, tilt, _ := ONVIFToAtomCam(x, y, 0.5)
+		pan, tilt, err := cam.LimitPTZ(pan, tilt)
+		if err != nil {
+			return StoredTourSpot{}, fmt.Errorf("%w: %v", ErrInvalidTour, err)
+		}
+		spot.Position = &StoredPosition{Pan: pan, Tilt: tilt}
+		targets++
+	}
+	if targets != 1 {
+		return StoredTourSpot{}, fmt.Errorf("%w: a tour spot needs one of PresetToken, Home or PTZPosition", ErrInvalidTour)
+	}
+
+	if def.Speed != nil && def.Speed.PanTilt != nil {
+		spot.Speed = moveSpeed(def.Speed)
+	}
+	if def.StayTime != "" {
+		if d, err := soap.ParseDuration(def.StayTime); err != nil || d <= 0 || d > maxTourStayTime {
+			return StoredTourSpot{}, fmt.Errorf("%w: invalid stay time %q", ErrInvalidTour, def.StayTime)
+		}
+		spot.StayTime = def.StayTime
+	}
+	return spot, nil
+}
+
+// nextTourToken returns the first unused token for a new tour
+func nextTourToken(tours []StoredTour) string {
+	for i := 1; ; i++ {
+		token := fmt.Sprintf(storedTourTokenFormat, i)
+		used := false
+		for _, t := range tours {
+			if t.Token == token {
+				used = true
+				break
+			}
+		}
+		if !used {
+			return token
+		}
+	}
+}
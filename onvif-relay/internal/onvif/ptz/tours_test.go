@@ -0,0 +1,248 @@
+package ptz
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/config"
+)
+
+// tourSpot returns a tour spot definition for a preset token, or the home position if empty
+func tourSpot(presetToken, stayTime string) PresetTourSpotDefinition {
+	var spot PresetTourSpotDefinition
+	if presetToken == "" {
+		spot.PresetDetail.Home = true
+	} else {
+		spot.PresetDetail.PresetToken = presetToken
+	}
+	spot.StayTime = stayTime
+	return spot
+}
+
+func createTour(t *testing.T, service *Service, def PresetTourDefinition) string {
+	t.Helper()
+
+	created, err := service.CreatePresetTour("Main")
+	if err != nil {
+		t.Fatalf("CreatePresetTour returned an error: %v", err)
+	}
+	def.Token = created.PresetTourToken
+	if _, err := service.ModifyPresetTour(ModifyPresetTourRequest{ProfileToken: "Main", PresetTour: def}); err != nil {
+		t.Fatalf("ModifyPresetTour returned an error: %v", err)
+	}
+	return created.PresetTourToken
+}
+
+func operateTour(t *testing.T, service *Service, token, operation string) {
+	t.Helper()
+
+	req := OperatePresetTourRequest{ProfileToken: "Main", PresetTourToken: token, Operation: operation}
+	if _, err := service.OperatePresetTour(req, nil); err != nil {
+		t.Fatalf("OperatePresetTour %s returned an error: %v", operation, err)
+	}
+}
+
+func tourState(t *testing.T, service *Service, token string) string {
+	t.Helper()
+
+	resp, err := service.GetPresetTour("Main", token)
+	if err != nil {
+		t.Fatalf("GetPresetTour returned an error: %v", err)
+	}
+	return resp.PresetTour.Status.State
+}
+
+func expectCommand(t *testing.T, commands <-chan string, want string) {
+	t.Helper()
+
+	select {
+	case command := <-commands:
+		if command != want {
+			t.Fatalf("command = %q, want %q", command, want)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("no command, want %q", want)
+	}
+}
+
+func TestPresetTourRunsBackwardAndFinishes(t *testing.T) {
+	service, commands, closeService := newTrackingTestService(t, []config.PTZPreset{
+		{Name: "Door", Token: "door", Pan: 10, Tilt: 20},
+	})
+	defer closeService()
+
+	rounds := 1
+	token := createTour(t, service, PresetTourDefinition{
+		Name:              "Patrol",
+		StartingCondition: &PresetTourStartingConditionDef{RecurringTime: &rounds, Direction: TourDirectionBackward},
+		TourSpot:          []PresetTourSpotDefinition{tourSpot("door", "PT0.1S"), tourSpot("", "PT0.1S")},
+	})
+	if token != "tour_1" {
+		t.Fatalf("PresetTourToken = %q, want tour_1", token)
+	}
+
+	tours, err := service.GetPresetTours("Main")
+	if err != nil {
+		t.Fatalf("GetPresetTours returned an error: %v", err)
+	}
+	if len(tours.PresetTour) != 1 || len(tours.PresetTour[0].TourSpot) != 2 || tours.PresetTour[0].Status.State != TourStateIdle {
+		t.Fatalf("GetPresetTours = %+v, want one idle tour with two spots", tours.PresetTour)
+	}
+
+	operateTour(t, service, token, TourOperationStart)
+	expectCommand(t, commands, "move 160 130 5")
+	expectCommand(t, commands, "move 10 20 5")
+
+	deadline := time.Now().Add(3 * time.Second)
+	for tourState(t, service, token) != TourStateIdle {
+		if time.Now().After(deadline) {
+			t.Fatal("tour did not finish after one round")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestManualMovePausesTour(t *testing.T) {
+	service, commands, closeService := newTrackingTestService(t, []config.PTZPreset{
+		{Name: "Door", Token: "door", Pan: 10, Tilt: 20},
+		{Name: "Gate", Token: "gate", Pan: 200, Tilt: 90},
+	})
+	defer closeService()
+	service.tourResumeDelay = 300 * time.Millisecond
+	cam, err := service.registry.Get("swing")
+	if err != nil {
+		t.Fatalf("camera not found: %v", err)
+	}
+	cam.Config.PTZ.LeaseTime = 0.1
+
+	token := createTour(t, service, PresetTourDefinition{
+		TourSpot: []PresetTourSpotDefinition{tourSpot("door", "PT0.2S")},
+	})
+	operateTour(t, service, token, TourOperationStart)
+	expectCommand(t, commands, "move 10 20 5")
+
+	if err := service.GotoPreset("Main", "gate", nil, nil); err != nil {
+		t.Fatalf("GotoPreset returned an error: %v", err)
+	}
+	expectCommand(t, commands, "move 200 90 5")
+	if state := tourState(t, service, token); state != TourStatePaused {
+		t.Fatalf("state = %s, want %s after a manual move", state, TourStatePaused)
+	}
+
+	// The tour resumes once the camera has been idle
+	expectCommand(t, commands, "move 10 20 5")
+	if state := tourState(t, service, token); state != TourStateTouring {
+		t.Fatalf("state = %s, want %s", state, TourStateTouring)
+	}
+
+	operateTour(t, service, token, TourOperationStop)
+	if state := tourState(t, service, token); state != TourStateIdle {
+		t.Fatalf("state = %s, want %s after Stop", state, TourStateIdle)
+	}
+}
+
+func TestOperatorPauseIsNotResumedByIdleTime(t *testing.T) {
+	service, commands, closeService := newTrackingTestService(t, []config.PTZPreset{
+		{Name: "Door", Token: "door", Pan: 10, Tilt: 20},
+	})
+	defer closeService()
+	service.tourResumeDelay = 50 * time.Millisecond
+
+	token := createTour(t, service, PresetTourDefinition{
+		TourSpot: []PresetTourSpotDefinition{tourSpot("door", "PT0.1S")},
+	})
+	operateTour(t, service, token, TourOperationStart)
+	expectCommand(t, commands, "move 10 20 5")
+
+	operateTour(t, service, token, TourOperationPause)
+	if err := service.GotoHomePosition("Main", nil, nil); err != nil {
+		t.Fatalf("GotoHomePosition returned an error: %v", err)
+	}
+	expectCommand(t, commands, "move 160 130 5")
+
+	select {
+	case command := <-commands:
+		t.Fatalf("paused tour sent %q", command)
+	case <-time.After(400 * time.Millisecond):
+	}
+	if state := tourState(t, service, token); state != TourStatePaused {
+		t.Fatalf("state = %s, want %s", state, TourStatePaused)
+	}
+
+	operateTour(t, service, token, TourOperationStart)
+	expectCommand(t, commands, "move 10 20 5")
+}
+
+func TestModifyPresetTourErrors(t *testing.T) {
+	service, _, closeService := newTrackingTestService(t, []config.PTZPreset{
+		{Name: "Door", Token: "door", Pan: 10, Tilt: 20},
+	})
+	defer closeService()
+
+	created, err := service.CreatePresetTour("Main")
+	if err != nil {
+		t.Fatalf("CreatePresetTour returned an error: %v", err)
+	}
+	token := created.PresetTourToken
+
+	tests := []struct {
+		name string
+		def  PresetTourDefinition
+		want error
+	}{
+		{"unknown tour", PresetTourDefinition{Token: "tour_9"}, ErrNoTour},
+		{"unknown preset", PresetTourDefinition{Token: token, TourSpot: []PresetTourSpotDefinition{tourSpot("gate", "")}}, ErrNoToken},
+		{"invalid stay time", PresetTourDefinition{Token: token, TourSpot: []PresetTourSpotDefinition{tourSpot("door", "10s")}}, ErrInvalidTour},
+		{"invalid direction", PresetTourDefinition{Token: token, StartingCondition: &PresetTourStartingConditionDef{Direction: "Sideways"}}, ErrInvalidTour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.ModifyPresetTour(ModifyPresetTourRequest{ProfileToken: "Main", PresetTour: tt.def})
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	_, err = service.OperatePresetTour(OperatePresetTourRequest{ProfileToken: "Main", PresetTourToken: token, Operation: TourOperationStart}, nil)
+	if !errors.Is(err, ErrTourActivation) {
+		t.Fatalf("starting an empty tour: error = %v, want %v", err, ErrTourActivation)
+	}
+	if _, err := service.RemovePresetTour(RemovePresetTourRequest{ProfileToken: "Main", PresetTourToken: token}); err != nil {
+		t.Fatalf("RemovePresetTour returned an error: %v", err)
+	}
+	if _, err := service.GetPresetTour("Main", token); !errors.Is(err, ErrNoTour) {
+		t.Fatalf("GetPresetTour after remove: error = %v, want %v", err, ErrNoTour)
+	}
+}
+
+func TestPausedTourDoesNotMoveAfterWaitingForLease(t *testing.T) {
+	service, commands, closeService := newTrackingTestService(t, []config.PTZPreset{
+		{Name: "Door", Token: "door", Pan: 10, Tilt: 20},
+	})
+	defer closeService()
+	cam, err := service.registry.Get("swing")
+	if err != nil {
+		t.Fatalf("camera not found: %v", err)
+	}
+
+	// A client paused the tour while it was waiting for the lease
+	run := newTourRun(StoredTour{Token: "tour_1"})
+	run.pause(false, 0)
+	if err := service.gotoTourSpot("swing", run, StoredTourSpot{PresetToken: "door"}); !errors.Is(err, errTourInterrupted) {
+		t.Fatalf("gotoTourSpot = %v, want errTourInterrupted", err)
+	}
+	select {
+	case command := <-commands:
+		t.Fatalf("paused tour sent %q", command)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if lease, ok := cam.PTZLease(); ok {
+		t.Fatalf("PTZ lease = %+v, want released", lease)
+	}
+
+	if tour, anonymous := tourController(run.tour), userController(nil); tour.Priority >= anonymous.Priority {
+		t.Fatalf("tour priority = %d, want below anonymous %d", tour.Priority, anonymous.Priority)
+	}
+}
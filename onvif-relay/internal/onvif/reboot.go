@@ -0,0 +1,55 @@
+package onvif
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/config"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/onvif/device"
+)
+
+// relayRestartDelay lets the SystemReboot response reach the client before the relay shuts down
+const relayRestartDelay = time.Second
+
+// RestartExitCode is the exit status of the relay after SystemReboot. It is non-zero so that
+// a container restart policy of on-failure starts the relay again, as unless-stopped does.
+const RestartExitCode = 75
+
+// RestartRequested reports whether the relay is shutting down for SystemReboot
+// and should exit with RestartExitCode
+func (s *Server) RestartRequested() bool {
+	return s.restarting.Load()
+}
+
+// systemReboot reboots the target of server.system_reboot: a camera through its cmd.cgi,
+// or the relay itself, which shuts down gracefully and relies on the container restart policy.
+func (s *Server) systemReboot() (*device.SystemRebootResponse, error) {
+	target := s.config.Server.SystemReboot
+	if target == "" || target == config.SystemRebootRelay {
+		log.Printf("SystemReboot: restarting the relay")
+		s.restarting.Store(true)
+		time.AfterFunc(relayRestartDelay, func() {
+			process, err := os.FindProcess(os.Getpid())
+			if err == nil {
+				err = process.Signal(syscall.SIGTERM)
+			}
+			if err != nil {
+				log.Printf("SystemReboot: failed to stop the relay: %v", err)
+			}
+		})
+		return &device.SystemRebootResponse{Message: "Relay restarting"}, nil
+	}
+
+	cam, err := s.registry.Get(target)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("SystemReboot: rebooting camera %s", target)
+	if err := cam.Client.Reboot(); err != nil {
+		return nil, fmt.Errorf("failed to reboot camera %s: %w", target, err)
+	}
+	return &device.SystemRebootResponse{Message: fmt.Sprintf("Camera %s rebooting", target)}, nil
+}
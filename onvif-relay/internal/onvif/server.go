@@ -2,434 +2,307 @@ package onvif
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/xml"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/auth"
 	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/camera"
 	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/config"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/discovery"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/eventbus"
 	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/onvif/device"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/onvif/events"
 	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/onvif/imaging"
 	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/onvif/media"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/onvif/media2"
 	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/onvif/ptz"
 	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/onvif/soap"
 	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/proxy"
 	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/snapshot"
 	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/talk"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/tlscert"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/webhook"
 )
 
 // Server represents an ONVIF server
 type Server struct {
 	config         *config.Config
 	registry       *camera.Registry
+	users          *auth.Store
 	deviceService  *device.Service
 	mediaService   *media.Service
+	media2Service  *media2.Service
 	ptzService     *ptz.Service
 	imagingService *imaging.Service
+	eventsService  *events.Service
+	proxies        *proxy.Router
 	httpServer     *http.Server
+	plainServer    *http.Server // Plain HTTP listener kept alongside HTTPS (tls.http_port)
+	restarting     atomic.Bool  // Set by SystemReboot of the relay
 }
 
-// NewServer creates a new ONVIF server
-func NewServer(cfg *config.Config, registry *camera.Registry) *Server {
-	// Determine base URL for capabilities
-	baseURL := fmt.Sprintf("http://localhost:%d", cfg.Server.OnvifPort)
-
-	// Determine mediamtx RTSP host (only relevant when mediamtx is enabled)
-	mediamtxHost := cfg.Server.Mediamtx.RTSPHost
-	if mediamtxHost == "" && cfg.Server.Mediamtx.API != "" {
-		// Default to mediamtx service name in Docker network
-		mediamtxHost = "mediamtx"
-	}
-
-	// Snapshot service uses localhost (or Docker service name)
-	snapshotHost := "localhost"
-	snapshotPort := cfg.Server.OnvifPort
+// NewServer creates a new ONVIF server; scopes are shared with the WS-Discovery responder
+// and ptzStore keeps the PTZ settings changed via ONVIF
+func NewServer(cfg *config.Config, registry *camera.Registry, bus *eventbus.Bus, users *auth.Store, scopes *discovery.Scopes, ptzStore *ptz.Store) *Server {
+	// The mediamtx RTSP host is only relevant when mediamtx is enabled
+	mediaService := media.NewService(registry, cfg.Server.Mediamtx.Host(), cfg.Server.Mediamtx.RTSPPort, cfg.Server.BackchannelPort)
 
 	s := &Server{
 		config:         cfg,
 		registry:       registry,
-		deviceService:  device.NewService(cfg.Server.DeviceName, baseURL),
-		mediaService:   media.NewService(registry, mediamtxHost, cfg.Server.Mediamtx.RTSPPort, snapshotHost, snapshotPort),
-		ptzService:     ptz.NewService(registry),
+		users:          users,
+		deviceService:  device.NewService(cfg.Server.DeviceName, users, scopes),
+		mediaService:   mediaService,
+		media2Service:  media2.NewService(registry, mediaService),
+		ptzService:     ptz.NewService(registry, ptzStore),
 		imagingService: imaging.NewService(registry),
+		eventsService:  events.NewService(registry, bus),
 	}
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/onvif/device_service", s.handleDeviceService)
 	mux.HandleFunc("/onvif/media_service", s.handleMediaService)
+	mux.HandleFunc("/onvif/media2_service", s.handleMedia2Service)
 	mux.HandleFunc("/onvif/ptz_service", s.handlePTZService)
 	mux.HandleFunc("/onvif/imaging_service", s.handleImagingService)
+	mux.HandleFunc("/onvif/events_service", s.handleEventsService)
+	mux.HandleFunc("/onvif/pullpoint/", s.handlePullPoint)
 
 	// Root path handler for non-compliant ONVIF clients
 	// Some clients ignore GetCapabilities XAddr and send requests to "/"
 	mux.HandleFunc("/", s.handleRootService)
 
-	// Snapshot endpoint with authentication
-	snapshotProxy := snapshot.NewProxy(registry, cfg.Server.Auth.Username, cfg.Server.Auth.Password)
+	// Snapshot endpoint with authentication (User level)
+	snapshotProxy := snapshot.NewProxy(registry, users)
 	mux.HandleFunc("/snapshot/", snapshotProxy.Handler())
 
-	// Speaker talk endpoint with authentication. Body must be 8000 Hz mono S16LE PCM.
-	talkProxy := talk.NewProxy(registry, cfg.Server.Auth.Username, cfg.Server.Auth.Password)
+	// Speaker talk endpoint with authentication (Operator level). Body must be 8000 Hz mono S16LE PCM.
+	talkProxy := talk.NewProxy(registry, users)
 	mux.HandleFunc("/talk/", talkProxy.Handler())
 
-	// Reverse proxy rules from config
-	for _, pc := range cfg.Server.Proxies {
-		h, err := proxy.New(pc.Path, pc.Target, pc.StripPrefix)
-		if err != nil {
-			log.Printf("WARNING: skipping proxy rule %s → %s: %v", pc.Path, pc.Target, err)
-			continue
+	// Click-to-center endpoint with authentication (Operator level): POST /ptz/{camera}/center
+	mux.HandleFunc("/ptz/", s.ptzService.CenterHandler(users))
+
+	// Webhook endpoint for the camera's WEBHOOK_URL; events are published on the event bus
+	webhookReceiver := webhook.NewReceiver(registry, bus, users)
+	mux.HandleFunc("/webhook/", webhookReceiver.Handler())
+
+	// Reverse proxy rules from config (replaced on configuration reload).
+	// Rules are checked before the ONVIF routes; validation keeps them off reserved paths.
+	s.proxies = proxy.NewRouter()
+	s.proxies.Set(cfg.Server.Proxies)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h := s.proxies.Match(r.URL.Path); h != nil {
+			h.ServeHTTP(w, r)
+			return
 		}
-		mux.Handle(pc.Path, h)
-		log.Printf("Reverse proxy: %s → %s (strip_prefix=%v)", pc.Path, pc.Target, pc.StripPrefix)
-	}
+		mux.ServeHTTP(w, r)
+	})
 
 	s.httpServer = &http.Server{
 		Addr:           fmt.Sprintf(":%d", cfg.Server.OnvifPort),
-		Handler:        mux,
+		Handler:        handler,
 		ReadTimeout:    30 * time.Second,
 		WriteTimeout:   30 * time.Second,
 		MaxHeaderBytes: 1 << 20, // 1MB
 	}
 
+	if cfg.Server.TLS.Enabled {
+		s.httpServer.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		if cfg.Server.TLS.HTTPPort != 0 {
+			s.plainServer = &http.Server{
+				Addr:           fmt.Sprintf(":%d", cfg.Server.TLS.HTTPPort),
+				Handler:        handler,
+				ReadTimeout:    30 * time.Second,
+				WriteTimeout:   30 * time.Second,
+				MaxHeaderBytes: 1 << 20, // 1MB
+			}
+		}
+	}
+
 	return s
 }
 
-// Start starts the ONVIF server
+// Start starts the ONVIF server. With TLS enabled it serves HTTPS on onvif_port (and plain
+// HTTP on tls.http_port if set), generating a self-signed certificate when none is configured.
+// It returns when any listener fails or the server is shut down.
 func (s *Server) Start() error {
-	log.Printf("Starting ONVIF server on port %d", s.config.Server.OnvifPort)
-	return s.httpServer.ListenAndServe()
-}
-
-// Shutdown gracefully shuts down the HTTP server
-func (s *Server) Shutdown(ctx context.Context) error {
-	return s.httpServer.Shutdown(ctx)
-}
-
-// handleDeviceService handles Device service requests
-func (s *Server) handleDeviceService(w http.ResponseWriter, r *http.Request) {
-	// Only accept POST requests for SOAP
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+	tlsCfg := s.config.Server.TLS
+	if !tlsCfg.Enabled {
+		log.Printf("Starting ONVIF server on port %d", s.config.Server.OnvifPort)
+		return s.httpServer.ListenAndServe()
 	}
 
-	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20)) // 1MB limit
-	if err != nil {
-		s.sendFault(w, soap.NewActionFailedFault("Failed to read request body"))
-		return
+	certFile, keyFile := tlsCfg.CertFile, tlsCfg.KeyFile
+	if certFile == "" {
+		var err error
+		certFile, keyFile, err = tlscert.EnsureSelfSigned(filepath.Join(s.config.Server.StateDir, "tls"), tlsCfg.Hosts)
+		if err != nil {
+			return fmt.Errorf("failed to prepare TLS certificate: %w", err)
+		}
 	}
-	defer r.Body.Close()
 
-	action, err := soap.GetAction(body)
-	if err != nil {
-		s.sendFault(w, soap.NewActionFailedFault("Failed to parse SOAP action"))
-		return
+	errs := make(chan error, 2)
+	if s.plainServer != nil {
+		log.Printf("Starting ONVIF server (HTTP) on port %d", tlsCfg.HTTPPort)
+		go func() { errs <- s.plainServer.ListenAndServe() }()
 	}
+	log.Printf("Starting ONVIF server (HTTPS) on port %d", s.config.Server.OnvifPort)
+	go func() { errs <- s.httpServer.ListenAndServeTLS(certFile, keyFile) }()
+	return <-errs
+}
 
-	log.Printf("Device service action: %s", action)
+// ReloadProxies replaces the reverse proxy rules with those of a reloaded configuration
+func (s *Server) ReloadProxies(cfg *config.Config) {
+	s.proxies.Set(cfg.Server.Proxies)
+}
 
-	// GetSystemDateAndTime is exempt from authentication per ONVIF spec
-	if action != "GetSystemDateAndTime" {
-		if err := s.validateAuth(body); err != nil {
-			log.Printf("Authentication failed for %s: %v", action, err)
-			s.sendFault(w, soap.NewNotAuthorizedFault())
-			return
+// Shutdown gracefully shuts down the HTTP server
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.eventsService.Close()
+	s.ptzService.Close()
+	if s.plainServer != nil {
+		if err := s.plainServer.Shutdown(ctx); err != nil {
+			log.Printf("HTTP listener shutdown error: %v", err)
 		}
 	}
+	return s.httpServer.Shutdown(ctx)
+}
 
-	var response interface{}
-	switch action {
-	case "GetDeviceInformation":
-		response = s.deviceService.GetDeviceInformation()
-	case "GetSystemDateAndTime":
-		response = s.deviceService.GetSystemDateAndTime()
-	case "GetCapabilities":
-		bodyContent, err := soap.GetBodyContent(body)
-		if err != nil {
-			s.sendFault(w, soap.NewInvalidArgsFault("Invalid request"))
-			return
-		}
-		var req device.GetCapabilitiesRequest
-		if err := xml.Unmarshal(bodyContent, &req); err != nil {
-			s.sendFault(w, soap.NewInvalidArgsFault("Invalid request"))
-			return
-		}
-		response = s.deviceService.GetCapabilities(req.Category)
-	default:
-		s.sendFault(w, soap.NewActionFailedFault(fmt.Sprintf("Unknown action: %s", action)))
+// handleDeviceService handles Device service requests
+func (s *Server) handleDeviceService(w http.ResponseWriter, r *http.Request) {
+	body, action, ok := s.readSOAPRequest(w, r)
+	if !ok {
 		return
 	}
 
-	s.sendResponse(w, response)
+	log.Printf("Device service action: %s", action)
+	s.routeToDeviceService(w, r, body, action)
 }
 
 // handleMediaService handles Media service requests
 func (s *Server) handleMediaService(w http.ResponseWriter, r *http.Request) {
-	// Only accept POST requests for SOAP
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	body, action, ok := s.readSOAPRequest(w, r)
+	if !ok {
 		return
 	}
 
-	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20)) // 1MB limit
-	if err != nil {
-		s.sendFault(w, soap.NewActionFailedFault("Failed to read request body"))
-		return
-	}
-	defer r.Body.Close()
+	log.Printf("Media service action: %s", action)
+	s.routeToMediaService(w, r, body, action)
+}
 
-	action, err := soap.GetAction(body)
-	if err != nil {
-		s.sendFault(w, soap.NewActionFailedFault("Failed to parse SOAP action"))
+// handleMedia2Service handles Media2 (ver20) service requests
+func (s *Server) handleMedia2Service(w http.ResponseWriter, r *http.Request) {
+	body, action, ok := s.readSOAPRequest(w, r)
+	if !ok {
 		return
 	}
 
-	log.Printf("Media service action: %s", action)
+	log.Printf("Media2 service action: %s", action)
+	s.routeToMedia2Service(w, r, body, action)
+}
 
-	// Authentication required
-	if err := s.validateAuth(body); err != nil {
-		log.Printf("Authentication failed for %s: %v", action, err)
-		s.sendFault(w, soap.NewNotAuthorizedFault())
+// handlePTZService handles PTZ service requests
+func (s *Server) handlePTZService(w http.ResponseWriter, r *http.Request) {
+	body, action, ok := s.readSOAPRequest(w, r)
+	if !ok {
 		return
 	}
 
-	var response interface{}
-	switch action {
-	case "GetProfiles":
-		response = s.mediaService.GetProfiles()
-	case "GetStreamUri":
-		bodyContent, err := soap.GetBodyContent(body)
-		if err != nil {
-			s.sendFault(w, soap.NewInvalidArgsFault("Invalid request"))
-			return
-		}
-		var req media.GetStreamUriRequest
-		if err := xml.Unmarshal(bodyContent, &req); err != nil {
-			s.sendFault(w, soap.NewInvalidArgsFault("Invalid request"))
-			return
-		}
-		resp, err := s.mediaService.GetStreamUri(req.ProfileToken)
-		if err != nil {
-			s.sendFault(w, soap.NewInvalidArgsFault(err.Error()))
-			return
-		}
-		response = resp
-	case "GetSnapshotUri":
-		bodyContent, err := soap.GetBodyContent(body)
-		if err != nil {
-			s.sendFault(w, soap.NewInvalidArgsFault("Invalid request"))
-			return
-		}
-		var req media.GetSnapshotUriRequest
-		if err := xml.Unmarshal(bodyContent, &req); err != nil {
-			s.sendFault(w, soap.NewInvalidArgsFault("Invalid request"))
-			return
-		}
-		resp, err := s.mediaService.GetSnapshotUri(req.ProfileToken)
-		if err != nil {
-			s.sendFault(w, soap.NewInvalidArgsFault(err.Error()))
-			return
-		}
-		response = resp
-	default:
-		s.sendFault(w, soap.NewActionFailedFault(fmt.Sprintf("Unknown action: %s", action)))
+	log.Printf("PTZ service action: %s", action)
+	s.routeToPTZService(w, r, body, action)
+}
+
+// handleImagingService handles Imaging service requests
+func (s *Server) handleImagingService(w http.ResponseWriter, r *http.Request) {
+	body, action, ok := s.readSOAPRequest(w, r)
+	if !ok {
 		return
 	}
 
-	s.sendResponse(w, response)
+	log.Printf("Imaging service action: %s", action)
+	s.routeToImagingService(w, r, body, action)
 }
 
-// handlePTZService handles PTZ service requests
-func (s *Server) handlePTZService(w http.ResponseWriter, r *http.Request) {
-	// Only accept POST requests for SOAP
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+// handleEventsService handles Events service requests
+func (s *Server) handleEventsService(w http.ResponseWriter, r *http.Request) {
+	body, action, ok := s.readSOAPRequest(w, r)
+	if !ok {
 		return
 	}
 
-	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20)) // 1MB limit
-	if err != nil {
-		s.sendFault(w, soap.NewActionFailedFault("Failed to read request body"))
+	log.Printf("Events service action: %s", action)
+	s.routeToEventsService(w, r, body, action)
+}
+
+// handlePullPoint handles requests sent to a PullPoint subscription: /onvif/pullpoint/{id}
+func (s *Server) handlePullPoint(w http.ResponseWriter, r *http.Request) {
+	body, action, ok := s.readSOAPRequest(w, r)
+	if !ok {
 		return
 	}
-	defer r.Body.Close()
 
-	action, err := soap.GetAction(body)
-	if err != nil {
-		s.sendFault(w, soap.NewActionFailedFault("Failed to parse SOAP action"))
+	id := strings.TrimPrefix(r.URL.Path, "/onvif/pullpoint/")
+	log.Printf("PullPoint %s action: %s", id, action)
+
+	user := s.authorize(w, r, body, serviceEvents, action)
+	if user == nil {
 		return
 	}
 
-	log.Printf("PTZ service action: %s", action)
-
-	// Authentication required
-	if err := s.validateAuth(body); err != nil {
-		log.Printf("Authentication failed for %s: %v", action, err)
+	// Subscriptions may only be used by the user who created them (or an administrator)
+	if owner, ok := s.eventsService.SubscriptionOwner(id); ok && owner != user.Username && !user.Allows(auth.LevelAdministrator) {
+		log.Printf("User %s is not allowed to use pull point %s of %s", user.Username, id, owner)
 		s.sendFault(w, soap.NewNotAuthorizedFault())
 		return
 	}
 
 	var response interface{}
 	switch action {
-	case "GetServiceCapabilities":
-		response = s.ptzService.GetServiceCapabilities()
-	case "GetNodes":
-		response = s.ptzService.GetNodes()
-	case "GetConfigurations":
-		response = s.ptzService.GetConfigurations()
-	case "ContinuousMove":
-		bodyContent, err := soap.GetBodyContent(body)
-		if err != nil {
-			s.sendFault(w, soap.NewInvalidArgsFault("Invalid request"))
-			return
-		}
-		var req ptz.ContinuousMoveRequest
-		if err := xml.Unmarshal(bodyContent, &req); err != nil {
-			s.sendFault(w, soap.NewInvalidArgsFault("Invalid request"))
-			return
-		}
-		if err := s.ptzService.ContinuousMove(req.ProfileToken, req.Velocity); err != nil {
-			s.sendFault(w, soap.NewActionFailedFault(err.Error()))
+	case "PullMessages":
+		var req events.PullMessagesRequest
+		if !s.decodeRequest(w, body, &req) {
 			return
 		}
-		response = &ptz.ContinuousMoveResponse{}
-	case "Stop":
-		bodyContent, err := soap.GetBodyContent(body)
+		resp, err := s.eventsService.PullMessages(r.Context(), id, req)
 		if err != nil {
-			s.sendFault(w, soap.NewInvalidArgsFault("Invalid request"))
-			return
-		}
-		var req ptz.StopRequest
-		if err := xml.Unmarshal(bodyContent, &req); err != nil {
-			s.sendFault(w, soap.NewInvalidArgsFault("Invalid request"))
-			return
-		}
-		if err := s.ptzService.Stop(req.ProfileToken); err != nil {
-			s.sendFault(w, soap.NewActionFailedFault(err.Error()))
-			return
-		}
-		response = &ptz.StopResponse{}
-	case "GotoHomePosition":
-		bodyContent, err := soap.GetBodyContent(body)
-		if err != nil {
-			log.Printf("Failed to extract body content: %v", err)
-			s.sendFault(w, soap.NewInvalidArgsFault("Invalid request"))
-			return
-		}
-		var req ptz.GotoHomePositionRequest
-		if err := xml.Unmarshal(bodyContent, &req); err != nil {
-			log.Printf("Failed to unmarshal GotoHomePosition request: %v", err)
-			s.sendFault(w, soap.NewInvalidArgsFault("Invalid request"))
-			return
-		}
-		if err := s.ptzService.GotoHomePosition(req.ProfileToken, req.Speed); err != nil {
-			s.sendFault(w, soap.NewActionFailedFault(err.Error()))
-			return
-		}
-		response = &ptz.GotoHomePositionResponse{}
-	case "GetPresets":
-		bodyContent, err := soap.GetBodyContent(body)
-		if err != nil {
-			s.sendFault(w, soap.NewInvalidArgsFault("Invalid request"))
-			return
-		}
-		var req ptz.GetPresetsRequest
-		if err := xml.Unmarshal(bodyContent, &req); err != nil {
-			s.sendFault(w, soap.NewInvalidArgsFault("Invalid request"))
-			return
-		}
-		resp, err := s.ptzService.GetPresets(req.ProfileToken)
-		if err != nil {
-			s.sendFault(w, soap.NewActionFailedFault(err.Error()))
+			s.sendFault(w, soap.NewInvalidArgsFault(err.Error()))
 			return
 		}
 		response = resp
-	case "GotoPreset":
-		bodyContent, err := soap.GetBodyContent(body)
-		if err != nil {
-			s.sendFault(w, soap.NewInvalidArgsFault("Invalid request"))
-			return
-		}
-		var req ptz.GotoPresetRequest
-		if err := xml.Unmarshal(bodyContent, &req); err != nil {
-			s.sendFault(w, soap.NewInvalidArgsFault("Invalid request"))
-			return
-		}
-		if err := s.ptzService.GotoPreset(req.ProfileToken, req.PresetToken, req.Speed); err != nil {
-			s.sendFault(w, soap.NewActionFailedFault(err.Error()))
-			return
-		}
-		response = &ptz.GotoPresetResponse{}
-	case "AbsoluteMove":
-		bodyContent, err := soap.GetBodyContent(body)
-		if err != nil {
-			s.sendFault(w, soap.NewInvalidArgsFault("Invalid request"))
-			return
-		}
-		var req ptz.AbsoluteMoveRequest
-		if err := xml.Unmarshal(bodyContent, &req); err != nil {
-			s.sendFault(w, soap.NewInvalidArgsFault("Invalid request"))
-			return
-		}
-		if err := s.ptzService.AbsoluteMove(req.ProfileToken, req.Position, req.Speed); err != nil {
-			s.sendFault(w, soap.NewActionFailedFault(err.Error()))
-			return
-		}
-		response = &ptz.AbsoluteMoveResponse{}
-	case "RelativeMove":
-		bodyContent, err := soap.GetBodyContent(body)
-		if err != nil {
-			s.sendFault(w, soap.NewInvalidArgsFault("Invalid request"))
-			return
-		}
-		log.Printf("RelativeMove request body: %s", string(bodyContent))
-		var req ptz.RelativeMoveRequest
-		if err := xml.Unmarshal(bodyContent, &req); err != nil {
-			s.sendFault(w, soap.NewInvalidArgsFault("Invalid request"))
-			return
-		}
-		log.Printf("RelativeMove parsed: ProfileToken=%s, Translation.PanTilt=%+v", req.ProfileToken, req.Translation.PanTilt)
-		if err := s.ptzService.RelativeMove(req.ProfileToken, req.Translation, req.Speed); err != nil {
-			s.sendFault(w, soap.NewActionFailedFault(err.Error()))
+	case "Renew":
+		var req events.RenewRequest
+		if !s.decodeRequest(w, body, &req) {
 			return
 		}
-		response = &ptz.RelativeMoveResponse{}
-	case "MoveAndStartTracking":
-		bodyContent, err := soap.GetBodyContent(body)
+		resp, err := s.eventsService.Renew(id, req)
 		if err != nil {
-			s.sendFault(w, soap.NewInvalidArgsFault("Invalid request"))
-			return
-		}
-		var req ptz.MoveAndStartTrackingRequest
-		if err := xml.Unmarshal(bodyContent, &req); err != nil {
-			s.sendFault(w, soap.NewInvalidArgsFault("Invalid request"))
-			return
-		}
-		if err := s.ptzService.MoveAndStartTracking(req); err != nil {
-			s.sendFault(w, soap.NewActionFailedFault(err.Error()))
+			s.sendFault(w, soap.NewInvalidArgsFault(err.Error()))
 			return
 		}
-		response = &ptz.MoveAndStartTrackingResponse{}
-	case "SendAuxiliaryCommand":
-		bodyContent, err := soap.GetBodyContent(body)
+		response = resp
+	case "Unsubscribe":
+		resp, err := s.eventsService.Unsubscribe(id)
 		if err != nil {
-			s.sendFault(w, soap.NewInvalidArgsFault("Invalid request"))
-			return
-		}
-		var req ptz.SendAuxiliaryCommandRequest
-		if err := xml.Unmarshal(bodyContent, &req); err != nil {
-			s.sendFault(w, soap.NewInvalidArgsFault("Invalid request"))
+			s.sendFault(w, soap.NewInvalidArgsFault(err.Error()))
 			return
 		}
-		auxiliaryResponse, err := s.ptzService.SendAuxiliaryCommand(req.ProfileToken, req.AuxiliaryData)
+		response = resp
+	case "SetSynchronizationPoint":
+		resp, err := s.eventsService.SetSynchronizationPoint(id)
 		if err != nil {
-			s.sendFault(w, soap.NewActionFailedFault(err.Error()))
+			s.sendFault(w, soap.NewInvalidArgsFault(err.Error()))
 			return
 		}
-		response = &ptz.SendAuxiliaryCommandResponse{AuxiliaryResponse: auxiliaryResponse}
+		response = resp
 	default:
 		s.sendFault(w, soap.NewActionFailedFault(fmt.Sprintf("Unknown action: %s", action)))
 		return
@@ -438,69 +311,44 @@ func (s *Server) handlePTZService(w http.ResponseWriter, r *http.Request) {
 	s.sendResponse(w, response)
 }
 
-// handleImagingService handles Imaging service requests
-func (s *Server) handleImagingService(w http.ResponseWriter, r *http.Request) {
+// readSOAPRequest reads a SOAP request body and extracts its action.
+// It writes an error response and returns false if the request is invalid.
+func (s *Server) readSOAPRequest(w http.ResponseWriter, r *http.Request) ([]byte, string, bool) {
 	// Only accept POST requests for SOAP
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+		return nil, "", false
 	}
 
 	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20)) // 1MB limit
 	if err != nil {
 		s.sendFault(w, soap.NewActionFailedFault("Failed to read request body"))
-		return
+		return nil, "", false
 	}
 	defer r.Body.Close()
 
 	action, err := soap.GetAction(body)
 	if err != nil {
 		s.sendFault(w, soap.NewActionFailedFault("Failed to parse SOAP action"))
-		return
+		return nil, "", false
 	}
 
-	log.Printf("Imaging service action: %s", action)
+	return body, action, true
+}
 
-	// Authentication required
-	if err := s.validateAuth(body); err != nil {
-		log.Printf("Authentication failed for %s: %v", action, err)
-		s.sendFault(w, soap.NewNotAuthorizedFault())
-		return
+// decodeRequest unmarshals the SOAP body content into req.
+// It sends an InvalidArgs fault and returns false on failure.
+func (s *Server) decodeRequest(w http.ResponseWriter, body []byte, req interface{}) bool {
+	bodyContent, err := soap.GetBodyContent(body)
+	if err != nil {
+		s.sendFault(w, soap.NewInvalidArgsFault("Invalid request"))
+		return false
 	}
-
-	var response interface{}
-	switch action {
-	case "GetImagingSettings":
-		var req imaging.GetImagingSettingsRequest
-		if err := xml.Unmarshal(body, &req); err != nil {
-			s.sendFault(w, soap.NewInvalidArgsFault("Invalid request"))
-			return
-		}
-		response = s.imagingService.GetImagingSettings(req.VideoSourceToken)
-	case "SetImagingSettings":
-		var req imaging.SetImagingSettingsRequest
-		if err := xml.Unmarshal(body, &req); err != nil {
-			s.sendFault(w, soap.NewInvalidArgsFault("Invalid request"))
-			return
-		}
-		if err := s.imagingService.SetImagingSettings(req.VideoSourceToken, req.ImagingSettings); err != nil {
-			s.sendFault(w, soap.NewActionFailedFault(err.Error()))
-			return
-		}
-		response = &imaging.SetImagingSettingsResponse{}
-	case "GetOptions":
-		var req imaging.GetOptionsRequest
-		if err := xml.Unmarshal(body, &req); err != nil {
-			s.sendFault(w, soap.NewInvalidArgsFault("Invalid request"))
-			return
-		}
-		response = s.imagingService.GetOptions(req.VideoSourceToken)
-	default:
-		s.sendFault(w, soap.NewActionFailedFault(fmt.Sprintf("Unknown action: %s", action)))
-		return
+	if err := xml.Unmarshal(bodyContent, req); err != nil {
+		s.sendFault(w, soap.NewInvalidArgsFault("Invalid request"))
+		return false
 	}
-
-	s.sendResponse(w, response)
+	return true
 }
 
 // sendResponse sends a SOAP response
@@ -519,6 +367,11 @@ func (s *Server) sendResponse(w http.ResponseWriter, response interface{}) {
 
 // sendFault sends a SOAP fault
 func (s *Server) sendFault(w http.ResponseWriter, fault *soap.Fault) {
+	s.sendFaultStatus(w, fault, http.StatusInternalServerError)
+}
+
+// sendFaultStatus sends a SOAP fault with the given HTTP status
+func (s *Server) sendFaultStatus(w http.ResponseWriter, fault *soap.Fault, status int) {
 	data, err := soap.MarshalFault(fault)
 	if err != nil {
 		log.Printf("Failed to marshal fault: %v", err)
@@ -527,35 +380,21 @@ func (s *Server) sendFault(w http.ResponseWriter, fault *soap.Fault) {
 	}
 
 	w.Header().Set("Content-Type", "application/soap+xml; charset=utf-8")
-	w.WriteHeader(http.StatusInternalServerError)
+	w.WriteHeader(status)
 	w.Write(data)
 }
 
 // handleRootService handles requests to root path from non-compliant ONVIF clients
-// Routes requests based on SOAP action to appropriate service handler
-func (s *Server) handleRootService(w http.ResponseWriter, r *http.Request) {
-	// Only accept POST requests for SOAP
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
+// Routes requests based on SOAP action to appropriate service handler
+func (s *Server) handleRootService(w http.ResponseWriter, r *http.Request) {
 	// Only handle root path exactly, not sub-paths
 	if r.URL.Path != "/" {
 		http.NotFound(w, r)
 		return
 	}
 
-	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20)) // 1MB limit
-	if err != nil {
-		s.sendFault(w, soap.NewActionFailedFault("Failed to read request body"))
-		return
-	}
-	defer r.Body.Close()
-
-	action, err := soap.GetAction(body)
-	if err != nil {
-		s.sendFault(w, soap.NewActionFailedFault("Failed to parse SOAP action"))
+	body, action, ok := s.readSOAPRequest(w, r)
+	if !ok {
 		return
 	}
 
@@ -564,17 +403,21 @@ func (s *Server) handleRootService(w http.ResponseWriter, r *http.Request) {
 	// Route based on SOAP action to appropriate service
 	switch action {
 	// Device service actions
-	case "GetDeviceInformation", "GetSystemDateAndTime", "GetCapabilities":
-		s.routeToDeviceService(w, body, action)
+	case "GetDeviceInformation", "GetSystemDateAndTime", "GetCapabilities", "GetServices", "GetUsers", "CreateUsers", "DeleteUsers", "SetUser",
+		"GetScopes", "SetScopes", "GetHostname", "GetNetworkInterfaces", "GetNTP", "GetDNS", "SystemReboot":
+		s.routeToDeviceService(w, r, body, action)
 	// Media service actions
-	case "GetProfiles", "GetStreamUri", "GetSnapshotUri":
-		s.routeToMediaService(w, body, action)
+	case "GetProfiles", "GetVideoSources", "GetStreamUri", "GetSnapshotUri", "GetVideoEncoderConfigurations", "GetVideoEncoderConfiguration", "GetVideoEncoderConfigurationOptions", "SetVideoEncoderConfiguration", "GetAudioOutputs", "GetAudioOutputConfigurations":
+		s.routeToMediaService(w, r, body, action)
 	// PTZ service actions
-	case "GetServiceCapabilities", "GetNodes", "GetConfigurations", "ContinuousMove", "Stop", "GotoHomePosition", "GetPresets", "GotoPreset", "AbsoluteMove", "RelativeMove", "MoveAndStartTracking", "SendAuxiliaryCommand":
-		s.routeToPTZService(w, body, action)
+	case "GetServiceCapabilities", "GetNodes", "GetNode", "GetConfigurations", "GetConfiguration", "GetConfigurationOptions", "ContinuousMove", "Stop", "GotoHomePosition", "SetHomePosition", "GetStatus", "GetPresets", "GotoPreset", "SetPreset", "RemovePreset", "GetPresetTours", "GetPresetTour", "GetPresetTourOptions", "CreatePresetTour", "ModifyPresetTour", "OperatePresetTour", "RemovePresetTour", "AbsoluteMove", "RelativeMove", "MoveAndStartTracking", "SendAuxiliaryCommand":
+		s.routeToPTZService(w, r, body, action)
 	// Imaging service actions
 	case "GetImagingSettings", "SetImagingSettings", "GetOptions":
-		s.routeToImagingService(w, body, action)
+		s.routeToImagingService(w, r, body, action)
+	// Events service actions
+	case "GetEventProperties", "CreatePullPointSubscription":
+		s.routeToEventsService(w, r, body, action)
 	default:
 		log.Printf("Unknown action in root path: %s", action)
 		s.sendFault(w, soap.NewActionFailedFault(fmt.Sprintf("Unknown action: %s", action)))
@@ -582,16 +425,10 @@ func (s *Server) handleRootService(w http.ResponseWriter, r *http.Request) {
 }
 
 // routeToDeviceService routes request to device service handler
-func (s *Server) routeToDeviceService(w http.ResponseWriter, body []byte, action string) {
-	log.Printf("Routing to Device service: %s", action)
-
-	// GetSystemDateAndTime is exempt from authentication per ONVIF spec
-	if action != "GetSystemDateAndTime" {
-		if err := s.validateAuth(body); err != nil {
-			log.Printf("Authentication failed for %s: %v", action, err)
-			s.sendFault(w, soap.NewNotAuthorizedFault())
-			return
-		}
+func (s *Server) routeToDeviceService(w http.ResponseWriter, r *http.Request, body []byte, action string) {
+	// Authentication and authorization required (GetSystemDateAndTime is allowed anonymously)
+	if s.authorize(w, r, body, serviceDevice, action) == nil {
+		return
 	}
 
 	var response interface{}
@@ -611,27 +448,103 @@ func (s *Server) routeToDeviceService(w http.ResponseWriter, body []byte, action
 			s.sendFault(w, soap.NewInvalidArgsFault("Invalid request"))
 			return
 		}
-		response = s.deviceService.GetCapabilities(req.Category)
+		response = s.deviceService.GetCapabilities(req.Category, s.requestBaseURL(r))
+	case "GetServices":
+		var req device.GetServicesRequest
+		if !s.decodeRequest(w, body, &req) {
+			return
+		}
+		response = s.deviceService.GetServices(s.requestBaseURL(r))
+	case "GetUsers":
+		response = s.deviceService.GetUsers()
+	case "CreateUsers":
+		var req device.CreateUsersRequest
+		if !s.decodeRequest(w, body, &req) {
+			return
+		}
+		resp, err := s.deviceService.CreateUsers(req)
+		if err != nil {
+			s.sendFault(w, userFault(err))
+			return
+		}
+		response = resp
+	case "DeleteUsers":
+		var req device.DeleteUsersRequest
+		if !s.decodeRequest(w, body, &req) {
+			return
+		}
+		resp, err := s.deviceService.DeleteUsers(req)
+		if err != nil {
+			s.sendFault(w, userFault(err))
+			return
+		}
+		response = resp
+	case "SetUser":
+		var req device.SetUserRequest
+		if !s.decodeRequest(w, body, &req) {
+			return
+		}
+		resp, err := s.deviceService.SetUser(req)
+		if err != nil {
+			s.sendFault(w, userFault(err))
+			return
+		}
+		response = resp
+	case "GetScopes":
+		response = s.deviceService.GetScopes()
+	case "SetScopes":
+		var req device.SetScopesRequest
+		if !s.decodeRequest(w, body, &req) {
+			return
+		}
+		resp, err := s.deviceService.SetScopes(req)
+		if err != nil {
+			s.sendFault(w, scopeFault(err))
+			return
+		}
+		response = resp
+	case "GetHostname":
+		response = s.deviceService.GetHostname()
+	case "GetNetworkInterfaces":
+		resp, err := s.deviceService.GetNetworkInterfaces()
+		if err != nil {
+			s.sendFault(w, soap.NewActionFailedFault(err.Error()))
+			return
+		}
+		response = resp
+	case "GetNTP":
+		response = s.deviceService.GetNTP()
+	case "GetDNS":
+		response = s.deviceService.GetDNS()
+	case "SystemReboot":
+		resp, err := s.systemReboot()
+		if err != nil {
+			s.sendFault(w, soap.NewActionFailedFault(err.Error()))
+			return
+		}
+		response = resp
+	default:
+		s.sendFault(w, soap.NewActionFailedFault(fmt.Sprintf("Unknown action: %s", action)))
+		return
 	}
 
 	s.sendResponse(w, response)
 }
 
 // routeToMediaService routes request to media service handler
-func (s *Server) routeToMediaService(w http.ResponseWriter, body []byte, action string) {
-	log.Printf("Routing to Media service: %s", action)
-
-	// Authentication required
-	if err := s.validateAuth(body); err != nil {
-		log.Printf("Authentication failed for %s: %v", action, err)
-		s.sendFault(w, soap.NewNotAuthorizedFault())
+func (s *Server) routeToMediaService(w http.ResponseWriter, r *http.Request, body []byte, action string) {
+	// Authentication and authorization required
+	user := s.authorize(w, r, body, serviceMedia, action)
+	if user == nil {
 		return
 	}
 
 	var response interface{}
 	switch action {
 	case "GetProfiles":
-		response = s.mediaService.GetProfiles()
+		response = s.mediaService.GetProfiles(user)
+	case "GetVideoSources":
+		response = s.mediaService.GetVideoSources(user)
 	case "GetStreamUri":
 		bodyContent, err := soap.GetBodyContent(body)
 		if err != nil {
@@ -643,7 +556,7 @@ func (s *Server) routeToMediaService(w http.ResponseWriter, body []byte, action
 			s.sendFault(w, soap.NewInvalidArgsFault("Invalid request"))
 			return
 		}
-		resp, err := s.mediaService.GetStreamUri(req.ProfileToken)
+		resp, err := s.mediaService.GetStreamUri(req, s.requestHost(r))
 		if err != nil {
 			s.sendFault(w, soap.NewInvalidArgsFault(err.Error()))
 			return
@@ -660,25 +573,137 @@ func (s *Server) routeToMediaService(w http.ResponseWriter, body []byte, action
 			s.sendFault(w, soap.NewInvalidArgsFault("Invalid request"))
 			return
 		}
-		resp, err := s.mediaService.GetSnapshotUri(req.ProfileToken)
+		resp, err := s.mediaService.GetSnapshotUri(req.ProfileToken, s.requestBaseURL(r))
 		if err != nil {
 			s.sendFault(w, soap.NewInvalidArgsFault(err.Error()))
 			return
 		}
 		response = resp
+	case "GetVideoEncoderConfigurations":
+		response = s.mediaService.GetVideoEncoderConfigurations(user)
+	case "GetVideoEncoderConfiguration":
+		var req media.GetVideoEncoderConfigurationRequest
+		if !s.decodeRequest(w, body, &req) {
+			return
+		}
+		resp, err := s.mediaService.GetVideoEncoderConfiguration(req.ConfigurationToken, user)
+		if err != nil {
+			s.sendFault(w, mediaFault(err))
+			return
+		}
+		response = resp
+	case "GetVideoEncoderConfigurationOptions":
+		var req media.GetVideoEncoderConfigurationOptionsRequest
+		if !s.decodeRequest(w, body, &req) {
+			return
+		}
+		resp, err := s.mediaService.GetVideoEncoderConfigurationOptions(req, user)
+		if err != nil {
+			s.sendFault(w, mediaFault(err))
+			return
+		}
+		response = resp
+	case "SetVideoEncoderConfiguration":
+		var req media.SetVideoEncoderConfigurationRequest
+		if !s.decodeRequest(w, body, &req) {
+			return
+		}
+		if err := s.mediaService.SetVideoEncoderConfiguration(req, user); err != nil {
+			log.Printf("SetVideoEncoderConfiguration failed: %v", err)
+			s.sendFault(w, mediaFault(err))
+			return
+		}
+		response = &media.SetVideoEncoderConfigurationResponse{}
+	case "GetAudioOutputs":
+		response = s.mediaService.GetAudioOutputs(user)
+	case "GetAudioOutputConfigurations":
+		response = s.mediaService.GetAudioOutputConfigurations(user)
+	default:
+		s.sendFault(w, soap.NewActionFailedFault(fmt.Sprintf("Unknown action: %s", action)))
+		return
 	}
 
 	s.sendResponse(w, response)
 }
 
-// routeToPTZService routes request to PTZ service handler
-func (s *Server) routeToPTZService(w http.ResponseWriter, body []byte, action string) {
-	log.Printf("Routing to PTZ service: %s", action)
+// routeToMedia2Service routes request to media2 service handler
+func (s *Server) routeToMedia2Service(w http.ResponseWriter, r *http.Request, body []byte, action string) {
+	// Authentication and authorization required
+	user := s.authorize(w, r, body, serviceMedia2, action)
+	if user == nil {
+		return
+	}
 
-	// Authentication required
-	if err := s.validateAuth(body); err != nil {
-		log.Printf("Authentication failed for %s: %v", action, err)
-		s.sendFault(w, soap.NewNotAuthorizedFault())
+	var response interface{}
+	switch action {
+	case "GetProfiles":
+		var req media2.GetProfilesRequest
+		if !s.decodeRequest(w, body, &req) {
+			return
+		}
+		resp, err := s.media2Service.GetProfiles(req, user)
+		if err != nil {
+			s.sendFault(w, soap.NewInvalidArgsFault(err.Error()))
+			return
+		}
+		response = resp
+	case "GetStreamUri":
+		var req media2.GetStreamUriRequest
+		if !s.decodeRequest(w, body, &req) {
+			return
+		}
+		resp, err := s.media2Service.GetStreamUri(req, s.requestHost(r))
+		if err != nil {
+			s.sendFault(w, soap.NewInvalidArgsFault(err.Error()))
+			return
+		}
+		response = resp
+	case "GetSnapshotUri":
+		var req media2.GetSnapshotUriRequest
+		if !s.decodeRequest(w, body, &req) {
+			return
+		}
+		resp, err := s.media2Service.GetSnapshotUri(req.ProfileToken, s.requestBaseURL(r))
+		if err != nil {
+			s.sendFault(w, soap.NewInvalidArgsFault(err.Error()))
+			return
+		}
+		response = resp
+	case "GetVideoEncoderConfigurations":
+		var req media2.GetConfigurationRequest
+		if !s.decodeRequest(w, body, &req) {
+			return
+		}
+		resp, err := s.media2Service.GetVideoEncoderConfigurations(req, user)
+		if err != nil {
+			s.sendFault(w, soap.NewInvalidArgsFault(err.Error()))
+			return
+		}
+		response = resp
+	case "GetVideoSourceConfigurations":
+		var req media2.GetConfigurationRequest
+		if !s.decodeRequest(w, body, &req) {
+			return
+		}
+		resp, err := s.media2Service.GetVideoSourceConfigurations(req, user)
+		if err != nil {
+			s.sendFault(w, soap.NewInvalidArgsFault(err.Error()))
+			return
+		}
+		response = resp
+	default:
+		s.sendFault(w, soap.NewActionFailedFault(fmt.Sprintf("Unknown action: %s", action)))
+		return
+	}
+
+	s.sendResponse(w, response)
+}
+
+// routeToPTZService routes request to PTZ service handler
+func (s *Server) routeToPTZService(w http.ResponseWriter, r *http.Request, body []byte, action string) {
+	// Authentication and authorization required; moves are made on behalf of the user
+	user := s.authorize(w, r, body, servicePTZ, action)
+	if user == nil {
 		return
 	}
 
@@ -688,8 +713,41 @@ func (s *Server) routeToPTZService(w http.ResponseWriter, body []byte, action st
 		response = s.ptzService.GetServiceCapabilities()
 	case "GetNodes":
 		response = s.ptzService.GetNodes()
+	case "GetNode":
+		var req ptz.GetNodeRequest
+		if !s.decodeRequest(w, body, &req) {
+			return
+		}
+		resp, err := s.ptzService.GetNode(req.NodeToken)
+		if err != nil {
+			s.sendFault(w, ptzFault(err))
+			return
+		}
+		response = resp
 	case "GetConfigurations":
 		response = s.ptzService.GetConfigurations()
+	case "GetConfiguration":
+		var req ptz.GetConfigurationRequest
+		if !s.decodeRequest(w, body, &req) {
+			return
+		}
+		resp, err := s.ptzService.GetConfiguration(req.PTZConfigurationToken)
+		if err != nil {
+			s.sendFault(w, ptzFault(err))
+			return
+		}
+		response = resp
+	case "GetConfigurationOptions":
+		var req ptz.GetConfigurationOptionsRequest
+		if !s.decodeRequest(w, body, &req) {
+			return
+		}
+		resp, err := s.ptzService.GetConfigurationOptions(req.ConfigurationToken)
+		if err != nil {
+			s.sendFault(w, ptzFault(err))
+			return
+		}
+		response = resp
 	case "ContinuousMove":
 		bodyContent, err := soap.GetBodyContent(body)
 		if err != nil {
@@ -701,8 +759,8 @@ func (s *Server) routeToPTZService(w http.ResponseWriter, body []byte, action st
 			s.sendFault(w, soap.NewInvalidArgsFault("Invalid request"))
 			return
 		}
-		if err := s.ptzService.ContinuousMove(req.ProfileToken, req.Velocity); err != nil {
-			s.sendFault(w, soap.NewActionFailedFault(err.Error()))
+		if err := s.ptzService.ContinuousMove(req.ProfileToken, req.Velocity, req.Timeout, user); err != nil {
+			s.sendFault(w, ptzFault(err))
 			return
 		}
 		response = &ptz.ContinuousMoveResponse{}
@@ -717,8 +775,8 @@ func (s *Server) routeToPTZService(w http.ResponseWriter, body []byte, action st
 			s.sendFault(w, soap.NewInvalidArgsFault("Invalid request"))
 			return
 		}
-		if err := s.ptzService.Stop(req.ProfileToken); err != nil {
-			s.sendFault(w, soap.NewActionFailedFault(err.Error()))
+		if err := s.ptzService.Stop(req.ProfileToken, user); err != nil {
+			s.sendFault(w, ptzFault(err))
 			return
 		}
 		response = &ptz.StopResponse{}
@@ -735,11 +793,32 @@ func (s *Server) routeToPTZService(w http.ResponseWriter, body []byte, action st
 			s.sendFault(w, soap.NewInvalidArgsFault("Invalid request"))
 			return
 		}
-		if err := s.ptzService.GotoHomePosition(req.ProfileToken, req.Speed); err != nil {
-			s.sendFault(w, soap.NewActionFailedFault(err.Error()))
+		if err := s.ptzService.GotoHomePosition(req.ProfileToken, req.Speed, user); err != nil {
+			s.sendFault(w, ptzFault(err))
 			return
 		}
 		response = &ptz.GotoHomePositionResponse{}
+	case "SetHomePosition":
+		var req ptz.SetHomePositionRequest
+		if !s.decodeRequest(w, body, &req) {
+			return
+		}
+		if err := s.ptzService.SetHomePosition(req.ProfileToken); err != nil {
+			s.sendFault(w, soap.NewActionFailedFault(err.Error()))
+			return
+		}
+		response = &ptz.SetHomePositionResponse{}
+	case "GetStatus":
+		var req ptz.GetStatusRequest
+		if !s.decodeRequest(w, body, &req) {
+			return
+		}
+		resp, err := s.ptzService.GetStatus(req.ProfileToken)
+		if err != nil {
+			s.sendFault(w, soap.NewActionFailedFault(err.Error()))
+			return
+		}
+		response = resp
 	case "GetPresets":
 		bodyContent, err := soap.GetBodyContent(body)
 		if err != nil {
@@ -768,11 +847,110 @@ func (s *Server) routeToPTZService(w http.ResponseWriter, body []byte, action st
 			s.sendFault(w, soap.NewInvalidArgsFault("Invalid request"))
 			return
 		}
-		if err := s.ptzService.GotoPreset(req.ProfileToken, req.PresetToken, req.Speed); err != nil {
-			s.sendFault(w, soap.NewActionFailedFault(err.Error()))
+		if err := s.ptzService.GotoPreset(req.ProfileToken, req.PresetToken, req.Speed, user); err != nil {
+			s.sendFault(w, ptzFault(err))
 			return
 		}
 		response = &ptz.GotoPresetResponse{}
+	case "SetPreset":
+		var req ptz.SetPresetRequest
+		if !s.decodeRequest(w, body, &req) {
+			return
+		}
+		resp, err := s.ptzService.SetPreset(req)
+		if err != nil {
+			s.sendFault(w, presetFault(err))
+			return
+		}
+		response = resp
+	case "RemovePreset":
+		var req ptz.RemovePresetRequest
+		if !s.decodeRequest(w, body, &req) {
+			return
+		}
+		resp, err := s.ptzService.RemovePreset(req)
+		if err != nil {
+			s.sendFault(w, presetFault(err))
+			return
+		}
+		response = resp
+	case "GetPresetTours":
+		var req ptz.GetPresetToursRequest
+		if !s.decodeRequest(w, body, &req) {
+			return
+		}
+		resp, err := s.ptzService.GetPresetTours(req.ProfileToken)
+		if err != nil {
+			s.sendFault(w, presetFault(err))
+			return
+		}
+		response = resp
+	case "GetPresetTour":
+		var req ptz.GetPresetTourRequest
+		if !s.decodeRequest(w, body, &req) {
+			return
+		}
+		resp, err := s.ptzService.GetPresetTour(req.ProfileToken, req.PresetTourToken)
+		if err != nil {
+			s.sendFault(w, presetFault(err))
+			return
+		}
+		response = resp
+	case "GetPresetTourOptions":
+		var req ptz.GetPresetTourOptionsRequest
+		if !s.decodeRequest(w, body, &req) {
+			return
+		}
+		resp, err := s.ptzService.GetPresetTourOptions(req.ProfileToken)
+		if err != nil {
+			s.sendFault(w, presetFault(err))
+			return
+		}
+		response = resp
+	case "CreatePresetTour":
+		var req ptz.CreatePresetTourRequest
+		if !s.decodeRequest(w, body, &req) {
+			return
+		}
+		resp, err := s.ptzService.CreatePresetTour(req.ProfileToken)
+		if err != nil {
+			s.sendFault(w, presetFault(err))
+			return
+		}
+		response = resp
+	case "ModifyPresetTour":
+		var req ptz.ModifyPresetTourRequest
+		if !s.decodeRequest(w, body, &req) {
+			return
+		}
+		resp, err := s.ptzService.ModifyPresetTour(req)
+		if err != nil {
+			s.sendFault(w, presetFault(err))
+			return
+		}
+		response = resp
+	case "OperatePresetTour":
+		var req ptz.OperatePresetTourRequest
+		if !s.decodeRequest(w, body, &req) {
+			return
+		}
+		resp, err := s.ptzService.OperatePresetTour(req, user)
+		if err != nil {
+			s.sendFault(w, presetFault(err))
+			return
+		}
+		response = resp
+	case "RemovePresetTour":
+		var req ptz.RemovePresetTourRequest
+		if !s.decodeRequest(w, body, &req) {
+			return
+		}
+		resp, err := s.ptzService.RemovePresetTour(req)
+		if err != nil {
+			s.sendFault(w, presetFault(err))
+			return
+		}
+		response = resp
 	case "AbsoluteMove":
 		bodyContent, err := soap.GetBodyContent(body)
 		if err != nil {
@@ -784,8 +962,8 @@ func (s *Server) routeToPTZService(w http.ResponseWriter, body []byte, action st
 			s.sendFault(w, soap.NewInvalidArgsFault("Invalid request"))
 			return
 		}
-		if err := s.ptzService.AbsoluteMove(req.ProfileToken, req.Position, req.Speed); err != nil {
-			s.sendFault(w, soap.NewActionFailedFault(err.Error()))
+		if err := s.ptzService.AbsoluteMove(req.ProfileToken, req.Position, req.Speed, user); err != nil {
+			s.sendFault(w, ptzFault(err))
 			return
 		}
 		response = &ptz.AbsoluteMoveResponse{}
@@ -802,8 +980,8 @@ func (s *Server) routeToPTZService(w http.ResponseWriter, body []byte, action st
 			return
 		}
 		log.Printf("RelativeMove parsed: ProfileToken=%s, Translation.PanTilt=%+v", req.ProfileToken, req.Translation.PanTilt)
-		if err := s.ptzService.RelativeMove(req.ProfileToken, req.Translation, req.Speed); err != nil {
-			s.sendFault(w, soap.NewActionFailedFault(err.Error()))
+		if err := s.ptzService.RelativeMove(req.ProfileToken, req.Translation, req.Speed, user); err != nil {
+			s.sendFault(w, ptzFault(err))
 			return
 		}
 		response = &ptz.RelativeMoveResponse{}
@@ -818,8 +996,8 @@ func (s *Server) routeToPTZService(w http.ResponseWriter, body []byte, action st
 			s.sendFault(w, soap.NewInvalidArgsFault("Invalid request"))
 			return
 		}
-		if err := s.ptzService.MoveAndStartTracking(req); err != nil {
-			s.sendFault(w, soap.NewActionFailedFault(err.Error()))
+		if err := s.ptzService.MoveAndStartTracking(req, user); err != nil {
+			s.sendFault(w, ptzFault(err))
 			return
 		}
 		response = &ptz.MoveAndStartTrackingResponse{}
@@ -840,19 +1018,18 @@ func (s *Server) routeToPTZService(w http.ResponseWriter, body []byte, action st
 			return
 		}
 		response = &ptz.SendAuxiliaryCommandResponse{AuxiliaryResponse: auxiliaryResponse}
+	default:
+		s.sendFault(w, soap.NewActionFailedFault(fmt.Sprintf("Unknown action: %s", action)))
+		return
 	}
 
 	s.sendResponse(w, response)
 }
 
 // routeToImagingService routes request to imaging service handler
-func (s *Server) routeToImagingService(w http.ResponseWriter, body []byte, action string) {
-	log.Printf("Routing to Imaging service: %s", action)
-
-	// Authentication required
-	if err := s.validateAuth(body); err != nil {
-		log.Printf("Authentication failed for %s: %v", action, err)
-		s.sendFault(w, soap.NewNotAuthorizedFault())
+func (s *Server) routeToImagingService(w http.ResponseWriter, r *http.Request, body []byte, action string) {
+	// Authentication and authorization required
+	if s.authorize(w, r, body, serviceImaging, action) == nil {
 		return
 	}
 
@@ -860,15 +1037,18 @@ func (s *Server) routeToImagingService(w http.ResponseWriter, body []byte, actio
 	switch action {
 	case "GetImagingSettings":
 		var req imaging.GetImagingSettingsRequest
-		if err := xml.Unmarshal(body, &req); err != nil {
-			s.sendFault(w, soap.NewInvalidArgsFault("Invalid request"))
+		if !s.decodeRequest(w, body, &req) {
+			return
+		}
+		resp, err := s.imagingService.GetImagingSettings(req.VideoSourceToken)
+		if err != nil {
+			s.sendFault(w, soap.NewActionFailedFault(err.Error()))
 			return
 		}
-		response = s.imagingService.GetImagingSettings(req.VideoSourceToken)
+		response = resp
 	case "SetImagingSettings":
 		var req imaging.SetImagingSettingsRequest
-		if err := xml.Unmarshal(body, &req); err != nil {
-			s.sendFault(w, soap.NewInvalidArgsFault("Invalid request"))
+		if !s.decodeRequest(w, body, &req) {
 			return
 		}
 		if err := s.imagingService.SetImagingSettings(req.VideoSourceToken, req.ImagingSettings); err != nil {
@@ -878,30 +1058,52 @@ func (s *Server) routeToImagingService(w http.ResponseWriter, body []byte, actio
 		response = &imaging.SetImagingSettingsResponse{}
 	case "GetOptions":
 		var req imaging.GetOptionsRequest
-		if err := xml.Unmarshal(body, &req); err != nil {
-			s.sendFault(w, soap.NewInvalidArgsFault("Invalid request"))
+		if !s.decodeRequest(w, body, &req) {
+			return
+		}
+		resp, err := s.imagingService.GetOptions(req.VideoSourceToken)
+		if err != nil {
+			s.sendFault(w, soap.NewInvalidArgsFault(err.Error()))
 			return
 		}
-		response = s.imagingService.GetOptions(req.VideoSourceToken)
+		response = resp
+	default:
+		s.sendFault(w, soap.NewActionFailedFault(fmt.Sprintf("Unknown action: %s", action)))
+		return
 	}
 
 	s.sendResponse(w, response)
 }
 
-// validateAuth validates WS-UsernameToken authentication
-func (s *Server) validateAuth(body []byte) error {
-	var envelope soap.Envelope
-	if err := xml.Unmarshal(body, &envelope); err != nil {
-		return fmt.Errorf("failed to parse SOAP envelope: %w", err)
+// routeToEventsService routes request to events service handler
+func (s *Server) routeToEventsService(w http.ResponseWriter, r *http.Request, body []byte, action string) {
+	// Authentication and authorization required
+	user := s.authorize(w, r, body, serviceEvents, action)
+	if user == nil {
+		return
 	}
 
-	if envelope.Header == nil {
-		return fmt.Errorf("missing security header")
+	var response interface{}
+	switch action {
+	case "GetServiceCapabilities":
+		response = s.eventsService.GetServiceCapabilities()
+	case "GetEventProperties":
+		response = s.eventsService.GetEventProperties()
+	case "CreatePullPointSubscription":
+		var req events.CreatePullPointSubscriptionRequest
+		if !s.decodeRequest(w, body, &req) {
+			return
+		}
+		resp, err := s.eventsService.CreatePullPointSubscription(req, user, s.requestBaseURL(r))
+		if err != nil {
+			s.sendFault(w, soap.NewInvalidArgsFault(err.Error()))
+			return
+		}
+		response = resp
+	default:
+		s.sendFault(w, soap.NewActionFailedFault(fmt.Sprintf("Unknown action: %s", action)))
+		return
 	}
 
-	return soap.ValidateUsernameToken(
-		envelope.Header.Security,
-		s.config.Server.Auth.Username,
-		s.config.Server.Auth.Password,
-	)
+	s.sendResponse(w, response)
 }
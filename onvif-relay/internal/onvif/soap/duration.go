@@ -0,0 +1,88 @@
+package soap
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// durationPattern matches the subset of xs:duration used by ONVIF clients (e.g. "PT10S", "PT1M30S", "P1DT2H")
+var durationPattern = regexp.MustCompile(`^(-)?P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+
+// ParseDuration parses an xs:duration value such as "PT60S" into a time.Duration.
+// Years and months are not supported because they have no fixed length.
+func ParseDuration(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	m := durationPattern.FindStringSubmatch(value)
+	if m == nil || value == "P" || strings.HasSuffix(value, "T") {
+		return 0, fmt.Errorf("invalid duration: %q", value)
+	}
+
+	var d time.Duration
+	if m[2] != "" {
+		days, _ := strconv.Atoi(m[2])
+		d += time.Duration(days) * 24 * time.Hour
+	}
+	if m[3] != "" {
+		hours, _ := strconv.Atoi(m[3])
+		d += time.Duration(hours) * time.Hour
+	}
+	if m[4] != "" {
+		minutes, _ := strconv.Atoi(m[4])
+		d += time.Duration(minutes) * time.Minute
+	}
+	if m[5] != "" {
+		seconds, _ := strconv.ParseFloat(m[5], 64)
+		d += time.Duration(seconds * float64(time.Second))
+	}
+	if m[1] == "-" {
+		d = -d
+	}
+
+	return d, nil
+}
+
+// FormatDuration formats a time.Duration as an xs:duration value (e.g. "PT1M30S")
+func FormatDuration(d time.Duration) string {
+	if d <= 0 {
+		return "PT0S"
+	}
+
+	var b strings.Builder
+	b.WriteString("PT")
+	if h := d / time.Hour; h > 0 {
+		fmt.Fprintf(&b, "%dH", h)
+		d -= h * time.Hour
+	}
+	if m := d / time.Minute; m > 0 {
+		fmt.Fprintf(&b, "%dM", m)
+		d -= m * time.Minute
+	}
+	if d > 0 {
+		b.WriteString(strconv.FormatFloat(d.Seconds(), 'f', -1, 64))
+		b.WriteString("S")
+	}
+
+	return b.String()
+}
+
+// ParseTime parses an xs:dateTime value, or an xs:duration relative to now.
+// ONVIF allows both forms for InitialTerminationTime and TerminationTime.
+func ParseTime(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, "P") || strings.HasPrefix(value, "-P") {
+		d, err := ParseDuration(value)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return now.Add(d), nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date/time: %q", value)
+	}
+	return t, nil
+}
@@ -37,21 +37,33 @@ func ParseEnvelope(r io.Reader) (*Envelope, error) {
 // MarshalEnvelope marshals a SOAP envelope to XML
 func MarshalEnvelope(body interface{}) ([]byte, error) {
 	envelope := struct {
-		XMLName   xml.Name `xml:"http://www.w3.org/2003/05/soap-envelope Envelope"`
-		XmlnsTds  string   `xml:"xmlns:tds,attr"`
-		XmlnsTrt  string   `xml:"xmlns:trt,attr"`
-		XmlnsTptz string   `xml:"xmlns:tptz,attr"`
-		XmlnsTimg string   `xml:"xmlns:timg,attr"`
-		XmlnsTt   string   `xml:"xmlns:tt,attr"`
-		Body      struct {
+		XMLName    xml.Name `xml:"http://www.w3.org/2003/05/soap-envelope Envelope"`
+		XmlnsTds   string   `xml:"xmlns:tds,attr"`
+		XmlnsTrt   string   `xml:"xmlns:trt,attr"`
+		XmlnsTr2   string   `xml:"xmlns:tr2,attr"`
+		XmlnsTptz  string   `xml:"xmlns:tptz,attr"`
+		XmlnsTimg  string   `xml:"xmlns:timg,attr"`
+		XmlnsTt    string   `xml:"xmlns:tt,attr"`
+		XmlnsTev   string   `xml:"xmlns:tev,attr"`
+		XmlnsWsnt  string   `xml:"xmlns:wsnt,attr"`
+		XmlnsWsa   string   `xml:"xmlns:wsa,attr"`
+		XmlnsWstop string   `xml:"xmlns:wstop,attr"`
+		XmlnsTns1  string   `xml:"xmlns:tns1,attr"`
+		Body       struct {
 			Content interface{} `xml:",any"`
 		} `xml:"Body"`
 	}{
-		XmlnsTds:  "http://www.onvif.org/ver10/device/wsdl",
-		XmlnsTrt:  "http://www.onvif.org/ver10/media/wsdl",
-		XmlnsTptz: "http://www.onvif.org/ver20/ptz/wsdl",
-		XmlnsTimg: "http://www.onvif.org/ver10/imaging/wsdl",
-		XmlnsTt:   "http://www.onvif.org/ver10/schema",
+		XmlnsTds:   "http://www.onvif.org/ver10/device/wsdl",
+		XmlnsTrt:   "http://www.onvif.org/ver10/media/wsdl",
+		XmlnsTr2:   "http://www.onvif.org/ver20/media/wsdl",
+		XmlnsTptz:  "http://www.onvif.org/ver20/ptz/wsdl",
+		XmlnsTimg:  "http://www.onvif.org/ver10/imaging/wsdl",
+		XmlnsTt:    "http://www.onvif.org/ver10/schema",
+		XmlnsTev:   "http://www.onvif.org/ver10/events/wsdl",
+		XmlnsWsnt:  "http://docs.oasis-open.org/wsn/b-2",
+		XmlnsWsa:   "http://www.w3.org/2005/08/addressing",
+		XmlnsWstop: "http://docs.oasis-open.org/wsn/t-1",
+		XmlnsTns1:  "http://www.onvif.org/ver10/topics",
 	}
 	envelope.Body.Content = body
 
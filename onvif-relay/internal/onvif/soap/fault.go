@@ -20,7 +20,8 @@ type FaultCode struct {
 
 // FaultSubcode represents a SOAP fault subcode
 type FaultSubcode struct {
-	Value string `xml:"Value"`
+	Value   string        `xml:"Value"`
+	Subcode *FaultSubcode `xml:"Subcode,omitempty"`
 }
 
 // FaultReason represents a SOAP fault reason
@@ -63,6 +64,16 @@ func NewFault(code, subcode, reason string) *Fault {
 	return f
 }
 
+// NewNestedFault creates a SOAP fault with an ONVIF-specific subcode nested in a generic one
+// (e.g. ter:OperationProhibited / ter:UsernameClash)
+func NewNestedFault(code, subcode, nestedSubcode, reason string) *Fault {
+	f := NewFault(code, subcode, reason)
+	f.Code.Subcode.Subcode = &FaultSubcode{
+		Value: nestedSubcode,
+	}
+	return f
+}
+
 // NewNotAuthorizedFault creates a "Not Authorized" fault
 func NewNotAuthorizedFault() *Fault {
 	return NewFault(FaultCodeSender, SubcodeNotAuthorized, "The action requested requires authorization and the sender is not authorized")
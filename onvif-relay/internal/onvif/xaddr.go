@@ -0,0 +1,57 @@
+package onvif
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// requestBaseURL returns the relay URL to advertise in XAddrs and URIs sent to the client of r.
+// server.advertise_host wins; otherwise the host the client used to reach the relay (Host
+// header) is advertised, falling back to the local address that accepted the connection.
+func (s *Server) requestBaseURL(r *http.Request) string {
+	server := &s.config.Server
+	if server.AdvertiseHost != "" {
+		return server.BaseURL(server.AdvertiseHost)
+	}
+
+	host := s.requestHost(r)
+	_, port := splitHostPort(r.Host)
+
+	// Keep the port the client used (it may differ from onvif_port behind a port mapping)
+	// when the request came in on the listener that is advertised
+	if port != "" && (r.TLS != nil) == server.TLS.Enabled {
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		return scheme + "://" + net.JoinHostPort(host, port)
+	}
+	return server.BaseURL(host)
+}
+
+// requestHost returns the relay host to advertise to the client of r (see requestBaseURL)
+func (s *Server) requestHost(r *http.Request) string {
+	if s.config.Server.AdvertiseHost != "" {
+		return s.config.Server.AdvertiseHost
+	}
+
+	host, _ := splitHostPort(r.Host)
+	if host == "" {
+		if addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+			host, _ = splitHostPort(addr.String())
+		}
+	}
+	if ip := net.ParseIP(host); host == "" || ip != nil && ip.IsUnspecified() {
+		host = "localhost"
+	}
+	return host
+}
+
+// splitHostPort splits "host:port", "[v6]:port" or a bare host; port is empty if absent
+func splitHostPort(hostport string) (string, string) {
+	if host, port, err := net.SplitHostPort(hostport); err == nil {
+		return host, port
+	}
+	return strings.Trim(hostport, "[]"), ""
+}
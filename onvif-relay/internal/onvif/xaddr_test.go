@@ -0,0 +1,40 @@
+package onvif
+
+import (
+	"crypto/tls"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/config"
+)
+
+func TestRequestBaseURL(t *testing.T) {
+	s := &Server{config: &config.Config{Server: config.ServerConfig{OnvifPort: 8080}}}
+
+	r := httptest.NewRequest("POST", "http://192.168.1.5:18080/onvif/device_service", nil)
+	if got := s.requestBaseURL(r); got != "http://192.168.1.5:18080" {
+		t.Fatalf("requestBaseURL = %s, want the host and port the client used", got)
+	}
+
+	r.Host = "relay.lan"
+	if got := s.requestBaseURL(r); got != "http://relay.lan:8080" {
+		t.Fatalf("requestBaseURL without port = %s, want onvif_port", got)
+	}
+
+	// A request on the plain HTTP listener advertises the HTTPS listener
+	s.config.Server.TLS = config.TLSConfig{Enabled: true, HTTPPort: 8081}
+	r.Host = "relay.lan:8081"
+	if got := s.requestBaseURL(r); got != "https://relay.lan:8080" {
+		t.Fatalf("requestBaseURL over HTTP with TLS = %s, want https on onvif_port", got)
+	}
+	r.Host = "relay.lan:8443"
+	r.TLS = &tls.ConnectionState{}
+	if got := s.requestBaseURL(r); got != "https://relay.lan:8443" {
+		t.Fatalf("requestBaseURL over HTTPS = %s, want the host and port the client used", got)
+	}
+
+	s.config.Server.AdvertiseHost = "cams.example"
+	if got := s.requestBaseURL(r); got != "https://cams.example:8080" {
+		t.Fatalf("requestBaseURL with advertise_host = %s, want override", got)
+	}
+}
@@ -0,0 +1,58 @@
+package proxy
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/config"
+)
+
+// Router dispatches requests to reverse proxy rules that can be replaced at runtime.
+// Paths follow http.ServeMux semantics: a path ending in "/" matches its subtree,
+// any other path matches exactly, and the longest matching path wins.
+type Router struct {
+	mu       sync.RWMutex
+	handlers []*Handler // Sorted by path length, longest first
+}
+
+// NewRouter creates an empty proxy router
+func NewRouter() *Router {
+	return &Router{}
+}
+
+// Set replaces all proxy rules
+func (rt *Router) Set(rules []config.ProxyConfig) {
+	handlers := make([]*Handler, 0, len(rules))
+	for _, pc := range rules {
+		h, err := New(pc.Path, pc.Target, pc.StripPrefix)
+		if err != nil {
+			log.Printf("WARNING: skipping proxy rule %s → %s: %v", pc.Path, pc.Target, err)
+			continue
+		}
+		handlers = append(handlers, h)
+		log.Printf("Reverse proxy: %s → %s (strip_prefix=%v)", pc.Path, pc.Target, pc.StripPrefix)
+	}
+	sort.SliceStable(handlers, func(i, j int) bool {
+		return len(handlers[i].path) > len(handlers[j].path)
+	})
+
+	rt.mu.Lock()
+	rt.handlers = handlers
+	rt.mu.Unlock()
+}
+
+// Match returns the proxy handler for a request path, or nil if no rule matches
+func (rt *Router) Match(path string) http.Handler {
+	rt.mu.RLock()
+	defer rt.mu.RUnlock()
+
+	for _, h := range rt.handlers {
+		if path == h.path || (strings.HasSuffix(h.path, "/") && strings.HasPrefix(path, h.path)) {
+			return h
+		}
+	}
+	return nil
+}
@@ -1,28 +1,45 @@
 package snapshot
 
 import (
-	"crypto/subtle"
+	"bytes"
+	"errors"
 	"fmt"
+	"hash/fnv"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
 
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/auth"
 	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/camera"
 )
 
+// maxVariants bounds the number of transformed snapshots kept in memory
+const maxVariants = 64
+
+// variant is a snapshot transformed with the options of a request
+type variant struct {
+	source string // ETag of the camera snapshot it was made from
+	data   []byte
+	etag   string
+}
+
 // Proxy represents a snapshot proxy server
 type Proxy struct {
 	registry *camera.Registry
-	username string
-	password string
+	users    *auth.Store
+	mu       sync.Mutex
+	variants map[string]*variant // By camera name and options
+	order    []string            // Keys of variants, oldest first
 }
 
 // NewProxy creates a new snapshot proxy
-func NewProxy(registry *camera.Registry, username, password string) *Proxy {
+func NewProxy(registry *camera.Registry, users *auth.Store) *Proxy {
 	return &Proxy{
 		registry: registry,
-		username: username,
-		password: password,
+		users:    users,
+		variants: make(map[string]*variant),
 	}
 }
 
@@ -35,23 +52,6 @@ func (p *Proxy) Handler() http.HandlerFunc {
 			return
 		}
 
-		// HTTP Basic authentication
-		username, password, ok := r.BasicAuth()
-		if !ok {
-			w.Header().Set("WWW-Authenticate", `Basic realm="ONVIF Relay Snapshot"`)
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
-			return
-		}
-
-		// Use constant-time comparison to prevent timing attacks
-		usernameMatch := subtle.ConstantTimeCompare([]byte(username), []byte(p.username)) == 1
-		passwordMatch := subtle.ConstantTimeCompare([]byte(password), []byte(p.password)) == 1
-		if !usernameMatch || !passwordMatch {
-			w.Header().Set("WWW-Authenticate", `Basic realm="ONVIF Relay Snapshot"`)
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
-			return
-		}
-
 		// Extract camera name from path: /snapshot/{camera}
 		path := strings.TrimPrefix(r.URL.Path, "/snapshot/")
 		cameraName := strings.TrimSuffix(path, "/")
@@ -68,6 +68,11 @@ func (p *Proxy) Handler() http.HandlerFunc {
 			return
 		}
 
+		// HTTP Digest or Basic authentication; snapshots require the User level and access to the camera
+		if _, ok := p.users.AuthorizeRequest(w, r, "ONVIF Relay Snapshot", auth.LevelUser, cameraName); !ok {
+			return
+		}
+
 		// Get camera
 		cam, err := p.registry.Get(cameraName)
 		if err != nil {
@@ -76,25 +81,99 @@ func (p *Proxy) Handler() http.HandlerFunc {
 			return
 		}
 
-		// Check camera health before attempting snapshot
-		if !cam.GetHealth() {
-			log.Printf("Camera %s is unhealthy, refusing snapshot request", cameraName)
-			http.Error(w, "camera unavailable", http.StatusServiceUnavailable)
+		opts, err := parseOptions(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		// Get snapshot
-		data, err := cam.Client.GetSnapshot()
-		if err != nil {
-			log.Printf("Failed to get snapshot from %s: %v", cameraName, err)
-			http.Error(w, "failed to get snapshot", http.StatusInternalServerError)
-			return
+		// Serve the cached snapshot. While the camera is unhealthy or the fetch fails, serve
+		// the last good snapshot marked as stale rather than failing dashboards outright.
+		healthy := cam.GetHealth()
+		var snap camera.Snapshot
+		if healthy {
+			snap, err = cam.Snapshot()
+			if err != nil {
+				log.Printf("Failed to get snapshot from %s: %v", cameraName, err)
+			}
 		}
+		stale := !healthy || err != nil
+		if stale {
+			last, ok := cam.LastSnapshot()
+			if !ok && !healthy {
+				log.Printf("Camera %s is unhealthy, refusing snapshot request", cameraName)
+				http.Error(w, "camera unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			if !ok {
+				http.Error(w, "failed to get snapshot", http.StatusInternalServerError)
+				return
+			}
+			snap = last
+		}
+
+		// Crop, scale or re-encode the snapshot if requested
+		data, etag, contentType := snap.Data, snap.ETag, "image/jpeg"
+		if !opts.IsZero() {
+			v, err := p.variant(cameraName, snap, opts)
+			if errors.Is(err, errCropOutside) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err != nil {
+				log.Printf("Failed to process snapshot from %s: %v", cameraName, err)
+				http.Error(w, "failed to process snapshot", http.StatusInternalServerError)
+				return
+			}
+			data, etag, contentType = v.data, v.etag, opts.contentType()
+		}
+
+		// Return the image; ServeContent answers If-None-Match / If-Modified-Since with 304
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("ETag", etag)
+		w.Header().Set("X-Snapshot-Age", strconv.Itoa(int(snap.Age().Seconds())))
+		if stale {
+			w.Header().Set("Warning", `110 - "Response is Stale"`)
+		}
+		http.ServeContent(w, r, "", snap.Fetched, bytes.NewReader(data))
+	}
+}
 
-		// Return JPEG image
-		w.Header().Set("Content-Type", "image/jpeg")
-		w.Header().Set("Content-Length", fmt.Sprintf("%d", len(data)))
-		w.WriteHeader(http.StatusOK)
-		w.Write(data)
+// variant returns a snapshot transformed with opts, reusing the result of an earlier
+// request for the same camera snapshot
+func (p *Proxy) variant(cameraName string, snap camera.Snapshot, opts Options) (*variant, error) {
+	key := cameraName + " " + opts.key()
+
+	p.mu.Lock()
+	v, ok := p.variants[key]
+	p.mu.Unlock()
+	if ok && v.source == snap.ETag {
+		return v, nil
+	}
+
+	data, err := transform(snap.Data, opts)
+	if err != nil {
+		return nil, err
+	}
+	// The ETag of a variant follows from the camera snapshot and the options
+	h := fnv.New32a()
+	h.Write([]byte(opts.key()))
+	v = &variant{
+		source: snap.ETag,
+		data:   data,
+		etag:   fmt.Sprintf(`%s-%08x"`, strings.TrimSuffix(snap.ETag, `"`), h.Sum32()),
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.variants[key]; !ok {
+		p.order = append(p.order, key)
+		if len(p.order) > maxVariants {
+			delete(p.variants, p.order[0])
+			p.order = p.order[1:]
+		}
 	}
+	p.variants[key] = v
+	return v, nil
 }
@@ -0,0 +1,191 @@
+package snapshot
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/config"
+)
+
+// maxDimension bounds the width and height query parameters
+const maxDimension = config.MaxSnapshotDimension
+
+// errCropOutside is returned when the crop rectangle does not overlap the snapshot
+var errCropOutside = errors.New("crop rectangle is outside the snapshot")
+
+// Options are the image options of a snapshot request. The zero value serves the
+// camera's JPEG as it is.
+type Options struct {
+	Width   int             // Maximum width in pixels (0 = any); the aspect ratio is kept
+	Height  int             // Maximum height in pixels (0 = any)
+	Quality int             // JPEG quality 1-100 (0 = default)
+	Crop    image.Rectangle // Region of the camera's snapshot in pixels (empty = all)
+	PNG     bool            // Encode as PNG instead of JPEG
+}
+
+// parseOptions reads the width, height, quality, crop and format query parameters.
+// Other parameters (e.g. cache busters added by dashboards) are ignored.
+func parseOptions(query url.Values) (Options, error) {
+	var opts Options
+	var err error
+	if opts.Width, err = intParam(query, "width", 1, maxDimension); err != nil {
+		return Options{}, err
+	}
+	if opts.Height, err = intParam(query, "height", 1, maxDimension); err != nil {
+		return Options{}, err
+	}
+	if opts.Quality, err = intParam(query, "quality", 1, 100); err != nil {
+		return Options{}, err
+	}
+
+	if crop := query.Get("crop"); crop != "" {
+		parts := strings.Split(crop, ",")
+		values := make([]int, 0, 4)
+		for _, part := range parts {
+			v, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil || v < 0 || v > maxDimension {
+				break
+			}
+			values = append(values, v)
+		}
+		if len(parts) != 4 || len(values) != 4 || values[2] == 0 || values[3] == 0 {
+			return Options{}, fmt.Errorf("invalid crop: %s (must be x,y,width,height)", crop)
+		}
+		opts.Crop = image.Rect(values[0], values[1], values[0]+values[2], values[1]+values[3])
+	}
+
+	switch format := strings.ToLower(query.Get("format")); format {
+	case "", "jpeg", "jpg":
+	case "png":
+		opts.PNG = true
+	default:
+		return Options{}, fmt.Errorf("invalid format: %s (must be jpeg or png)", format)
+	}
+	return opts, nil
+}
+
+// intParam reads an optional integer query parameter within [lo, hi]; 0 when absent
+func intParam(query url.Values, name string, lo, hi int) (int, error) {
+	s := query.Get(name)
+	if s == "" {
+		return 0, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < lo || v > hi {
+		return 0, fmt.Errorf("invalid %s: %s (must be %d-%d)", name, s, lo, hi)
+	}
+	return v, nil
+}
+
+// IsZero reports whether the options leave the camera's JPEG unchanged
+func (o Options) IsZero() bool {
+	return o == Options{}
+}
+
+// key returns a canonical form of the options, used as a cache key
+func (o Options) key() string {
+	return fmt.Sprintf("w%d h%d q%d c%d,%d,%d,%d png%t",
+		o.Width, o.Height, o.Quality, o.Crop.Min.X, o.Crop.Min.Y, o.Crop.Dx(), o.Crop.Dy(), o.PNG)
+}
+
+// contentType returns the MIME type of the transformed image
+func (o Options) contentType() string {
+	if o.PNG {
+		return "image/png"
+	}
+	return "image/jpeg"
+}
+
+// transform crops, scales and encodes a JPEG snapshot as requested by the options
+func transform(data []byte, opts Options) ([]byte, error) {
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+
+	if !opts.Crop.Empty() {
+		crop := opts.Crop.Add(img.Bounds().Min).Intersect(img.Bounds())
+		if crop.Empty() {
+			return nil, errCropOutside
+		}
+		img = img.(interface {
+			SubImage(image.Rectangle) image.Image
+		}).SubImage(crop)
+	}
+
+	width, height := fitSize(img.Bounds().Dx(), img.Bounds().Dy(), opts.Width, opts.Height)
+	if width != img.Bounds().Dx() || height != img.Bounds().Dy() {
+		img = shrink(img, width, height)
+	}
+
+	var buf bytes.Buffer
+	if opts.PNG {
+		encoder := png.Encoder{CompressionLevel: png.BestSpeed}
+		err = encoder.Encode(&buf, img)
+	} else {
+		quality := opts.Quality
+		if quality == 0 {
+			quality = jpeg.DefaultQuality
+		}
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// fitSize returns the size of a width x height image scaled down, keeping its aspect
+// ratio, to fit within maxWidth x maxHeight (0 = unconstrained). Images are never enlarged.
+func fitSize(width, height, maxWidth, maxHeight int) (int, int) {
+	if maxWidth > 0 && width > maxWidth {
+		height = max(1, height*maxWidth/width)
+		width = maxWidth
+	}
+	if maxHeight > 0 && height > maxHeight {
+		width = max(1, width*maxHeight/height)
+		height = maxHeight
+	}
+	return width, height
+}
+
+// shrink scales an image down to width x height, averaging the source pixels covered by
+// each destination pixel (box filter)
+func shrink(src image.Image, width, height int) *image.RGBA {
+	b := src.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, b.Min, draw.Src)
+
+	srcWidth, srcHeight := b.Dx(), b.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0, y1 := y*srcHeight/height, (y+1)*srcHeight/height
+		for x := 0; x < width; x++ {
+			x0, x1 := x*srcWidth/width, (x+1)*srcWidth/width
+			var sum [4]uint64
+			for sy := y0; sy < y1; sy++ {
+				row := rgba.Pix[sy*rgba.Stride+x0*4 : sy*rgba.Stride+x1*4]
+				for i := 0; i < len(row); i += 4 {
+					sum[0] += uint64(row[i])
+					sum[1] += uint64(row[i+1])
+					sum[2] += uint64(row[i+2])
+					sum[3] += uint64(row[i+3])
+				}
+			}
+			n := uint64((y1 - y0) * (x1 - x0))
+			i := dst.PixOffset(x, y)
+			for c := range sum {
+				dst.Pix[i+c] = uint8(sum[c] / n)
+			}
+		}
+	}
+	return dst
+}
@@ -0,0 +1,100 @@
+package snapshot
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/url"
+	"testing"
+)
+
+func testJPEG(t *testing.T, width, height int) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 255 / width), G: uint8(y * 255 / height), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatalf("failed to encode test image: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestParseOptions(t *testing.T) {
+	opts, err := parseOptions(url.Values{
+		"width": {"640"}, "quality": {"60"}, "crop": {"10,20,300,200"}, "format": {"PNG"}, "t": {"12345"},
+	})
+	if err != nil {
+		t.Fatalf("parseOptions returned an error: %v", err)
+	}
+	want := Options{Width: 640, Quality: 60, Crop: image.Rect(10, 20, 310, 220), PNG: true}
+	if opts != want {
+		t.Fatalf("parseOptions = %+v, want %+v", opts, want)
+	}
+
+	if opts, err := parseOptions(url.Values{"t": {"12345"}}); err != nil || !opts.IsZero() {
+		t.Fatalf("parseOptions with only a cache buster = %+v, %v; want zero options", opts, err)
+	}
+
+	for _, query := range []url.Values{
+		{"width": {"0"}},
+		{"height": {"abc"}},
+		{"quality": {"101"}},
+		{"crop": {"10,20,300"}},
+		{"crop": {"10,20,0,200"}},
+		{"crop": {"-1,20,300,200"}},
+		{"format": {"gif"}},
+	} {
+		if _, err := parseOptions(query); err == nil {
+			t.Errorf("parseOptions(%v) returned nil error", query)
+		}
+	}
+}
+
+func TestTransform(t *testing.T) {
+	data := testJPEG(t, 160, 90)
+
+	tests := []struct {
+		name       string
+		opts       Options
+		wantWidth  int
+		wantHeight int
+	}{
+		{"width keeps the aspect ratio", Options{Width: 80}, 80, 45},
+		{"fits within both", Options{Width: 80, Height: 30}, 53, 30},
+		{"never enlarges", Options{Width: 320, Height: 180}, 160, 90},
+		{"crop then scale", Options{Crop: image.Rect(0, 0, 90, 90), Width: 30}, 30, 30},
+		{"crop clipped to the image", Options{Crop: image.Rect(100, 50, 200, 100)}, 60, 40},
+	}
+	for _, tt := range tests {
+		out, err := transform(data, tt.opts)
+		if err != nil {
+			t.Fatalf("%s: transform returned an error: %v", tt.name, err)
+		}
+		img, err := jpeg.Decode(bytes.NewReader(out))
+		if err != nil {
+			t.Fatalf("%s: result is not a JPEG: %v", tt.name, err)
+		}
+		if got := img.Bounds().Size(); got.X != tt.wantWidth || got.Y != tt.wantHeight {
+			t.Errorf("%s: size = %dx%d, want %dx%d", tt.name, got.X, got.Y, tt.wantWidth, tt.wantHeight)
+		}
+	}
+
+	out, err := transform(data, Options{Width: 40, PNG: true})
+	if err != nil {
+		t.Fatalf("PNG transform returned an error: %v", err)
+	}
+	if img, err := png.Decode(bytes.NewReader(out)); err != nil || img.Bounds().Dx() != 40 {
+		t.Fatalf("PNG result: err = %v, want a 40 pixel wide PNG", err)
+	}
+
+	if _, err := transform(data, Options{Crop: image.Rect(200, 0, 300, 50)}); err != errCropOutside {
+		t.Fatalf("crop outside the image: error = %v, want %v", err, errCropOutside)
+	}
+}
@@ -0,0 +1,725 @@
+package talk
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/auth"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/camera"
+)
+
+const (
+	// backchannelTag is the RTSP Require tag of the ONVIF audio backchannel
+	backchannelTag = "www.onvif.org/ver20/backchannel"
+
+	// backchannelTrack is the control path of the backchannel track below the camera URL
+	backchannelTrack = "backchannel"
+
+	// sessionTimeout is the RTSP session timeout advertised to clients; connections
+	// without any request or RTP packet for twice as long are closed
+	sessionTimeout = 60 * time.Second
+)
+
+// RTSP status codes not defined by net/http
+var rtspStatusText = map[int]string{
+	454: "Session Not Found",
+	455: "Method Not Valid in This State",
+	461: "Unsupported Transport",
+	551: "Option not supported",
+}
+
+// BackchannelServer is an RTSP server for the ONVIF audio backchannel (Profile T).
+// Clients send G.711 µ-law or A-law over RTP to rtsp://relay:port/{camera}; the audio
+// is decoded to PCM and streamed to the camera's atomtalkd.
+// With an upstream, rtsp://relay:port/{camera}/{stream} proxies the mediamtx stream and,
+// when DESCRIBE carries the backchannel Require tag, adds the backchannel track to it.
+// Only RTP over the RTSP connection (interleaved TCP) is supported.
+type BackchannelServer struct {
+	registry *camera.Registry
+	users    *auth.Store
+	upstream string // RTSP address (host:port) of mediamtx; empty: no proxied streams
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	wg       sync.WaitGroup
+}
+
+// NewBackchannelServer creates a backchannel server; upstream is the RTSP address of
+// mediamtx whose streams are proxied (empty: only the backchannel track is served).
+func NewBackchannelServer(registry *camera.Registry, users *auth.Store, upstream string) *BackchannelServer {
+	return &BackchannelServer{
+		registry: registry,
+		users:    users,
+		upstream: upstream,
+		conns:    make(map[net.Conn]struct{}),
+	}
+}
+
+// Start listens on addr and serves RTSP connections in the background.
+func (s *BackchannelServer) Start(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("listen backchannel: %w", err)
+	}
+
+	s.mu.Lock()
+	s.listener = listener
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.serve(listener)
+	return nil
+}
+
+// Addr returns the listening address.
+func (s *BackchannelServer) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.listener.Addr()
+}
+
+// Stop closes the listener and all sessions, stopping their talk streams.
+func (s *BackchannelServer) Stop() {
+	s.mu.Lock()
+	if s.listener != nil {
+		s.listener.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+func (s *BackchannelServer) serve(listener net.Listener) {
+	defer s.wg.Done()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("Backchannel accept failed: %v", err)
+			}
+			return
+		}
+
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			session := &backchannelSession{server: s, conn: conn, reader: bufio.NewReader(conn), channel: -1}
+			session.run()
+
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+// backchannelSession is the state of one RTSP connection; each connection carries
+// at most one session with one backchannel track and the tracks of a proxied stream
+type backchannelSession struct {
+	server  *BackchannelServer
+	conn    net.Conn
+	reader  *bufio.Reader
+	writeMu sync.Mutex // Serializes responses and proxied frames
+	frameMu sync.Mutex // Holds the proxied frames while the first PLAY is answered
+
+	upstream *upstreamConn // mediamtx connection of a proxied stream; nil until used
+	played   bool          // The proxied stream has been played
+
+	id      string
+	camera  *camera.Camera
+	channel int // Interleaved RTP channel; -1 until SETUP
+
+	pcm      *io.PipeWriter // Input of the talk stream while playing
+	streamed chan struct{}  // Closed when the talk stream has ended
+	cancel   context.CancelFunc
+	warned   bool // Unsupported payload type was logged
+}
+
+func (b *backchannelSession) run() {
+	defer b.conn.Close()
+	defer b.closeUpstream()
+	defer b.stopStream()
+
+	for {
+		_ = b.conn.SetReadDeadline(time.Now().Add(2 * sessionTimeout))
+		first, err := b.reader.Peek(1)
+		if err != nil {
+			return
+		}
+
+		if first[0] == '$' {
+			if err := b.readInterleaved(); err != nil {
+				return
+			}
+			continue
+		}
+
+		req, err := readRTSPRequest(b.reader)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				log.Printf("Backchannel request from %s failed: %v", b.conn.RemoteAddr(), err)
+			}
+			return
+		}
+		if !b.handle(req) {
+			return
+		}
+	}
+}
+
+// handle answers an RTSP request; it returns false when the connection should be closed
+func (b *backchannelSession) handle(req *http.Request) bool {
+	switch req.Method {
+	case "OPTIONS":
+		b.respond(req, http.StatusOK, http.Header{"Public": {"OPTIONS, DESCRIBE, SETUP, PLAY, TEARDOWN, GET_PARAMETER, SET_PARAMETER"}}, "")
+	case "DESCRIBE":
+		b.describe(req)
+	case "SETUP":
+		b.setup(req)
+	case "PLAY":
+		b.play(req)
+	case "TEARDOWN":
+		if !b.checkSession(req) {
+			return true
+		}
+		if b.proxying() {
+			_, _ = b.upstream.do("TEARDOWN", req.URL.Path, nil)
+		}
+		b.stopStream()
+		b.respond(req, http.StatusOK, nil, "")
+		return false
+	case "GET_PARAMETER", "SET_PARAMETER":
+		// Keepalive, passed on to mediamtx while proxying
+		if b.proxying() {
+			b.forward(req, nil)
+			return true
+		}
+		b.respond(req, http.StatusOK, nil, "")
+	default:
+		b.respond(req, http.StatusNotImplemented, nil, "")
+	}
+	return true
+}
+
+// describe returns the SDP of the backchannel track, or of the proxied stream with the
+// backchannel track added if the client requires it
+func (b *backchannelSession) describe(req *http.Request) {
+	_, stream := splitCameraPath(req)
+	require := req.Header.Get("Require")
+	talk := stream == "" || strings.Contains(require, backchannelTag)
+	cam, ok := b.authorize(req, talk)
+	if !ok {
+		return
+	}
+	if require != "" && !strings.Contains(require, backchannelTag) {
+		b.respond(req, 551, http.Header{"Unsupported": {require}}, "")
+		return
+	}
+
+	var sdp string
+	if stream == "" {
+		host := "0.0.0.0"
+		if addr, ok := b.conn.LocalAddr().(*net.TCPAddr); ok {
+			host = addr.IP.String()
+		}
+		sdp = strings.Join([]string{
+			"v=0",
+			"o=- 0 0 IN IP4 " + host,
+			"s=ONVIF Relay Backchannel " + cam.Config.Name,
+			"c=IN IP4 0.0.0.0",
+			"t=0 0",
+			backchannelMedia,
+		}, "\r\n")
+	} else {
+		resp, ok := b.forwardUpstream(req, http.Header{"Accept": {"application/sdp"}})
+		if !ok {
+			return
+		}
+		if resp.status != http.StatusOK {
+			b.respond(req, resp.status, nil, "")
+			return
+		}
+		sdp = strings.ReplaceAll(resp.body, b.upstream.base, b.clientBase(req))
+		if talk {
+			if !strings.HasSuffix(sdp, "\n") {
+				sdp += "\r\n"
+			}
+			sdp += backchannelMedia
+		}
+	}
+
+	b.respond(req, http.StatusOK, http.Header{
+		"Content-Type": {"application/sdp"},
+		"Content-Base": {strings.TrimSuffix(req.RequestURI, "/") + "/"},
+	}, sdp)
+}
+
+// backchannelMedia is the SDP media description of the backchannel track
+var backchannelMedia = strings.Join([]string{
+	fmt.Sprintf("m=audio 0 RTP/AVP %d %d", payloadTypePCMU, payloadTypePCMA),
+	"a=control:" + backchannelTrack,
+	fmt.Sprintf("a=rtpmap:%d PCMU/8000", payloadTypePCMU),
+	fmt.Sprintf("a=rtpmap:%d PCMA/8000", payloadTypePCMA),
+	"a=sendonly",
+	"",
+}, "\r\n")
+
+// setup creates the session for the backchannel track or a track of the proxied stream
+func (b *backchannelSession) setup(req *http.Request) {
+	_, track := splitCameraPath(req)
+	talk := track == backchannelTrack || strings.HasSuffix(track, "/"+backchannelTrack)
+	cam, ok := b.authorize(req, talk)
+	if !ok {
+		return
+	}
+	if b.id != "" {
+		// Only the track of the existing session can be set up again
+		if !b.checkSession(req) {
+			return
+		}
+		if b.camera != cam {
+			b.respond(req, 455, nil, "")
+			return
+		}
+	}
+
+	transport := req.Header.Get("Transport")
+	channel, ok := interleavedChannel(transport)
+	if !ok {
+		b.respond(req, 461, nil, "")
+		return
+	}
+
+	transport = fmt.Sprintf("RTP/AVP/TCP;unicast;interleaved=%d-%d", channel, channel+1)
+
+	var header http.Header
+	if talk {
+		b.channel = channel
+		header = http.Header{"Transport": {transport}}
+	} else {
+		resp, ok := b.forwardUpstream(req, http.Header{"Transport": {transport}})
+		if !ok {
+			return
+		}
+		header = b.clientHeader(req, resp)
+		if resp.status != http.StatusOK {
+			b.respond(req, resp.status, header, "")
+			return
+		}
+	}
+
+	if b.id == "" {
+		b.id = newSessionID()
+	}
+	b.camera = cam
+	b.respond(req, http.StatusOK, header, "")
+}
+
+// play starts the proxied stream and streaming the received audio to the camera
+func (b *backchannelSession) play(req *http.Request) {
+	if !b.checkSession(req) {
+		return
+	}
+	if b.channel < 0 && !b.proxying() {
+		b.respond(req, 455, nil, "")
+		return
+	}
+	if b.channel >= 0 && !b.camera.GetHealth() {
+		log.Printf("Camera %s is unhealthy, refusing backchannel", b.camera.Config.Name)
+		b.respond(req, http.StatusServiceUnavailable, nil, "")
+		return
+	}
+
+	var header http.Header
+	if b.proxying() {
+		if !b.played {
+			// mediamtx sends frames right after its response; they must follow ours
+			b.frameMu.Lock()
+			defer b.frameMu.Unlock()
+		}
+		resp, ok := b.forwardUpstream(req, nil)
+		if !ok {
+			return
+		}
+		header = b.clientHeader(req, resp)
+		if resp.status != http.StatusOK {
+			b.respond(req, resp.status, header, "")
+			return
+		}
+		b.played = true
+	}
+
+	if b.channel >= 0 && b.pcm == nil {
+		reader, writer := io.Pipe()
+		ctx, cancel := context.WithCancel(context.Background())
+		b.pcm, b.cancel, b.streamed = writer, cancel, make(chan struct{})
+
+		cfg := b.camera.Config
+		log.Printf("Backchannel talk started for %s from %s", cfg.Name, b.conn.RemoteAddr())
+		go func() {
+			defer close(b.streamed)
+			client := NewClient(cfg.Host, cfg.Talk.Port, cfg.Talk.Token)
+			err := client.Stream(ctx, reader)
+			if err != nil && !errors.Is(err, context.Canceled) {
+				log.Printf("Backchannel talk stream failed for %s: %v", cfg.Name, err)
+			}
+			reader.CloseWithError(err)
+		}()
+	}
+
+	b.respond(req, http.StatusOK, header, "")
+}
+
+// stopStream ends the talk stream, which sends STOP to atomtalkd
+func (b *backchannelSession) stopStream() {
+	if b.pcm == nil {
+		return
+	}
+	b.pcm.Close()
+	select {
+	case <-b.streamed:
+	case <-time.After(2 * time.Second):
+		b.cancel()
+		<-b.streamed
+	}
+	b.cancel()
+	b.pcm = nil
+	log.Printf("Backchannel talk stopped for %s", b.camera.Config.Name)
+}
+
+// readInterleaved reads an interleaved RTP packet and forwards its audio while playing
+func (b *backchannelSession) readInterleaved() error {
+	var header [4]byte
+	if _, err := io.ReadFull(b.reader, header[:]); err != nil {
+		return err
+	}
+	packet := make([]byte, binary.BigEndian.Uint16(header[2:]))
+	if _, err := io.ReadFull(b.reader, packet); err != nil {
+		return err
+	}
+
+	channel := int(header[1])
+	if b.channel < 0 || channel != b.channel && channel != b.channel+1 {
+		// RTCP of a proxied track
+		if b.upstream != nil {
+			_ = b.upstream.writeFrame(append(header[:], packet...))
+		}
+		return nil
+	}
+
+	// RTCP on channel+1 and packets before PLAY are ignored
+	if channel != b.channel || b.pcm == nil {
+		return nil
+	}
+
+	payloadType, payload, err := rtpPayload(packet)
+	if err != nil {
+		return nil
+	}
+	var table *[256]int16
+	switch payloadType {
+	case payloadTypePCMU:
+		table = ulawTable
+	case payloadTypePCMA:
+		table = alawTable
+	default:
+		if !b.warned {
+			log.Printf("Backchannel for %s: ignoring RTP payload type %d", b.camera.Config.Name, payloadType)
+			b.warned = true
+		}
+		return nil
+	}
+
+	if _, err := b.pcm.Write(decodeG711(nil, payload, table)); err != nil {
+		// The talk stream has ended; keep the RTSP session until the client tears it down
+		return nil
+	}
+	return nil
+}
+
+// authorize checks the credentials of a request for the camera in its URL and sends the
+// error response if the request is refused. The backchannel (talk) needs the Operator
+// level like POST /talk; proxied streams need the User level.
+func (b *backchannelSession) authorize(req *http.Request, talk bool) (*camera.Camera, bool) {
+	name, rest := splitCameraPath(req)
+	if name == "" || !talk && (rest == "" || b.server.upstream == "") {
+		b.respond(req, http.StatusNotFound, nil, "")
+		return nil, false
+	}
+
+	level := auth.LevelUser
+	if talk {
+		level = auth.LevelOperator
+	}
+	w := &headerRecorder{header: make(http.Header)}
+	if _, ok := b.server.users.AuthorizeRequest(w, req, talkRealm, level, name); !ok {
+		b.respond(req, w.status, http.Header{"WWW-Authenticate": w.header.Values("WWW-Authenticate")}, "")
+		return nil, false
+	}
+
+	cam, err := b.server.registry.Get(name)
+	if err != nil || talk && !cam.Config.Talk.Enabled {
+		b.respond(req, http.StatusNotFound, nil, "")
+		return nil, false
+	}
+	return cam, true
+}
+
+// proxying reports whether the session has set up tracks of a proxied stream
+func (b *backchannelSession) proxying() bool {
+	return b.upstream != nil && b.upstream.session != ""
+}
+
+// forwardUpstream sends a request to mediamtx, connecting on first use, and sends the
+// error response if it fails. The credentials and Require tag of the client are not passed on.
+func (b *backchannelSession) forwardUpstream(req *http.Request, header http.Header) (*upstreamResponse, bool) {
+	if b.upstream == nil {
+		upstream, err := dialUpstream(b.server.upstream, b.writeFrame, func() { b.conn.Close() })
+		if err != nil {
+			log.Printf("Backchannel proxy for %s failed: %v", b.conn.RemoteAddr(), err)
+			b.respond(req, http.StatusBadGateway, nil, "")
+			return nil, false
+		}
+		b.upstream = upstream
+	}
+
+	forwarded := make(http.Header)
+	for key, values := range req.Header {
+		switch key {
+		case "Cseq", "Session", "Authorization", "Require", "Proxy-Require", "Transport", "Content-Length":
+		default:
+			forwarded[key] = values
+		}
+	}
+	for key, values := range header {
+		forwarded[key] = values
+	}
+
+	path := req.URL.Path
+	if req.URL.RawQuery != "" {
+		path += "?" + req.URL.RawQuery
+	}
+	resp, err := b.upstream.do(req.Method, path, forwarded)
+	if err != nil {
+		log.Printf("Backchannel proxy %s for %s failed: %v", req.Method, b.conn.RemoteAddr(), err)
+		b.respond(req, http.StatusBadGateway, nil, "")
+		return nil, false
+	}
+	return resp, true
+}
+
+// forward passes a request on to mediamtx and its response back to the client
+func (b *backchannelSession) forward(req *http.Request, header http.Header) {
+	if resp, ok := b.forwardUpstream(req, header); ok {
+		b.respond(req, resp.status, b.clientHeader(req, resp), resp.body)
+	}
+}
+
+// clientHeader returns the headers of a mediamtx response to pass on to the client, with
+// the mediamtx URLs (e.g. in RTP-Info) rewritten to the relay
+func (b *backchannelSession) clientHeader(req *http.Request, resp *upstreamResponse) http.Header {
+	header := make(http.Header)
+	for key, values := range resp.header {
+		switch key {
+		case "Cseq", "Session", "Server", "Content-Length", "Content-Base":
+			continue
+		}
+		for _, value := range values {
+			header.Add(key, strings.ReplaceAll(value, b.upstream.base, b.clientBase(req)))
+		}
+	}
+	return header
+}
+
+// clientBase returns the relay URL the client used, ending in a slash
+func (b *backchannelSession) clientBase(req *http.Request) string {
+	host := req.URL.Host
+	if host == "" {
+		host = b.conn.LocalAddr().String()
+	}
+	return "rtsp://" + host + "/"
+}
+
+// closeUpstream closes the mediamtx connection of a proxied stream
+func (b *backchannelSession) closeUpstream() {
+	if b.upstream != nil {
+		b.upstream.close()
+		b.upstream = nil
+	}
+}
+
+// writeFrame sends an interleaved frame of a proxied track to the client
+func (b *backchannelSession) writeFrame(frame []byte) {
+	// Wait while the first PLAY is answered
+	b.frameMu.Lock()
+	b.frameMu.Unlock()
+	b.writeMu.Lock()
+	defer b.writeMu.Unlock()
+	_ = b.conn.SetWriteDeadline(time.Now().Add(upstreamTimeout))
+	_, _ = b.conn.Write(frame)
+}
+
+// checkSession checks the Session header and sends 454 if it does not match
+func (b *backchannelSession) checkSession(req *http.Request) bool {
+	id, _, _ := strings.Cut(req.Header.Get("Session"), ";")
+	if b.id == "" || strings.TrimSpace(id) != b.id {
+		b.respond(req, 454, nil, "")
+		return false
+	}
+	return true
+}
+
+// respond writes an RTSP response with the CSeq and Session of the request
+func (b *backchannelSession) respond(req *http.Request, status int, header http.Header, body string) {
+	text, ok := rtspStatusText[status]
+	if !ok {
+		text = http.StatusText(status)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "RTSP/1.0 %d %s\r\n", status, text)
+	fmt.Fprintf(&sb, "CSeq: %s\r\n", req.Header.Get("CSeq"))
+	sb.WriteString("Server: onvif-relay\r\n")
+	if b.id != "" {
+		fmt.Fprintf(&sb, "Session: %s;timeout=%d\r\n", b.id, int(sessionTimeout.Seconds()))
+	}
+	for key, values := range header {
+		for _, value := range values {
+			fmt.Fprintf(&sb, "%s: %s\r\n", key, value)
+		}
+	}
+	if body != "" {
+		fmt.Fprintf(&sb, "Content-Length: %d\r\n", len(body))
+	}
+	sb.WriteString("\r\n")
+	sb.WriteString(body)
+
+	b.writeMu.Lock()
+	defer b.writeMu.Unlock()
+	if _, err := io.WriteString(b.conn, sb.String()); err != nil {
+		log.Printf("Backchannel response to %s failed: %v", b.conn.RemoteAddr(), err)
+	}
+}
+
+// splitCameraPath returns the camera name of a request URL and the path below it
+func splitCameraPath(req *http.Request) (string, string) {
+	name, rest, _ := strings.Cut(strings.TrimPrefix(req.URL.Path, "/"), "/")
+	return name, strings.TrimSuffix(rest, "/")
+}
+
+// readRTSPRequest reads an RTSP request; the body, if any, is discarded
+func readRTSPRequest(r *bufio.Reader) (*http.Request, error) {
+	tp := textproto.NewReader(r)
+	line, err := tp.ReadLine()
+	if err != nil {
+		return nil, err
+	}
+	method, rest, ok1 := strings.Cut(line, " ")
+	requestURI, proto, ok2 := strings.Cut(rest, " ")
+	if !ok1 || !ok2 || !strings.HasPrefix(proto, "RTSP/") {
+		return nil, fmt.Errorf("malformed request line: %q", line)
+	}
+	u, err := url.Parse(requestURI)
+	if err != nil {
+		return nil, fmt.Errorf("malformed request URI: %q", requestURI)
+	}
+
+	header, err := tp.ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	if length, _ := strconv.Atoi(header.Get("Content-Length")); length > 0 {
+		if _, err := io.CopyN(io.Discard, r, int64(length)); err != nil {
+			return nil, err
+		}
+	}
+
+	return &http.Request{
+		Method:     method,
+		URL:        u,
+		Proto:      proto,
+		Header:     http.Header(header),
+		RequestURI: requestURI,
+	}, nil
+}
+
+// interleavedChannel returns the RTP channel of an interleaved TCP transport. A transport
+// without interleaved= is refused rather than given a channel the client does not expect.
+func interleavedChannel(transport string) (int, bool) {
+	for _, spec := range strings.Split(transport, ",") {
+		params := strings.Split(strings.TrimSpace(spec), ";")
+		if params[0] != "RTP/AVP/TCP" {
+			continue
+		}
+		for _, param := range params[1:] {
+			if value, ok := strings.CutPrefix(param, "interleaved="); ok {
+				first, _, _ := strings.Cut(value, "-")
+				if channel, err := strconv.Atoi(first); err == nil && channel >= 0 && channel < 255 {
+					return channel, true
+				}
+			}
+		}
+	}
+	return 0, false
+}
+
+// rtpPayload returns the payload type and payload of an RTP packet
+func rtpPayload(packet []byte) (byte, []byte, error) {
+	if len(packet) < 12 || packet[0]>>6 != 2 {
+		return 0, nil, fmt.Errorf("invalid RTP packet")
+	}
+	offset := 12 + 4*int(packet[0]&0x0f)
+	if packet[0]&0x10 != 0 {
+		if len(packet) < offset+4 {
+			return 0, nil, fmt.Errorf("invalid RTP header extension")
+		}
+		offset += 4 + 4*int(binary.BigEndian.Uint16(packet[offset+2:]))
+	}
+	end := len(packet)
+	if packet[0]&0x20 != 0 {
+		end -= int(packet[end-1])
+	}
+	if offset > end {
+		return 0, nil, fmt.Errorf("invalid RTP packet length")
+	}
+	return packet[1] & 0x7f, packet[offset:end], nil
+}
+
+func newSessionID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// headerRecorder captures the status and headers written by auth.Store.AuthorizeRequest
+type headerRecorder struct {
+	header http.Header
+	status int
+}
+
+func (h *headerRecorder) Header() http.Header         { return h.header }
+func (h *headerRecorder) Write(b []byte) (int, error) { return len(b), nil }
+func (h *headerRecorder) WriteHeader(status int)      { h.status = status }
@@ -0,0 +1,60 @@
+package talk
+
+import "encoding/binary"
+
+// G.711 RTP payload types (RFC 3551)
+const (
+	payloadTypePCMU = 0
+	payloadTypePCMA = 8
+)
+
+var (
+	ulawTable = buildTable(ulawToLinear)
+	alawTable = buildTable(alawToLinear)
+)
+
+// decodeG711 appends the S16LE PCM samples of a G.711 payload to dst using table
+func decodeG711(dst []byte, payload []byte, table *[256]int16) []byte {
+	for _, b := range payload {
+		dst = binary.LittleEndian.AppendUint16(dst, uint16(table[b]))
+	}
+	return dst
+}
+
+func buildTable(decode func(byte) int16) *[256]int16 {
+	var table [256]int16
+	for i := range table {
+		table[i] = decode(byte(i))
+	}
+	return &table
+}
+
+// ulawToLinear decodes a G.711 µ-law sample
+func ulawToLinear(u byte) int16 {
+	u = ^u
+	t := (int16(u&0x0f) << 3) + 0x84
+	t <<= (u & 0x70) >> 4
+	if u&0x80 != 0 {
+		return 0x84 - t
+	}
+	return t - 0x84
+}
+
+// alawToLinear decodes a G.711 A-law sample
+func alawToLinear(a byte) int16 {
+	a ^= 0x55
+	t := int16(a&0x0f) << 4
+	switch seg := (a & 0x70) >> 4; seg {
+	case 0:
+		t += 8
+	case 1:
+		t += 0x108
+	default:
+		t += 0x108
+		t <<= seg - 1
+	}
+	if a&0x80 != 0 {
+		return t
+	}
+	return -t
+}
@@ -1,28 +1,29 @@
 package talk
 
 import (
-	"crypto/subtle"
 	"log"
 	"net/http"
 	"strings"
 	"time"
 
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/auth"
 	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/camera"
 )
 
+// talkRealm is the HTTP authentication realm of talk requests
+const talkRealm = "ONVIF Relay Talk"
+
 // Proxy accepts raw PCM over HTTP and forwards it to camera atomtalkd.
 type Proxy struct {
 	registry *camera.Registry
-	username string
-	password string
+	users    *auth.Store
 }
 
 // NewProxy creates a talk proxy.
-func NewProxy(registry *camera.Registry, username, password string) *Proxy {
+func NewProxy(registry *camera.Registry, users *auth.Store) *Proxy {
 	return &Proxy{
 		registry: registry,
-		username: username,
-		password: password,
+		users:    users,
 	}
 }
 
@@ -35,12 +36,6 @@ func (p *Proxy) Handler() http.HandlerFunc {
 		}
 		defer r.Body.Close()
 
-		if !p.authorized(r) {
-			w.Header().Set("WWW-Authenticate", `Basic realm="ONVIF Relay Talk"`)
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
-			return
-		}
-
 		cameraName := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/talk/"), "/")
 		if cameraName == "" {
 			http.Error(w, "camera name required", http.StatusBadRequest)
@@ -52,6 +47,11 @@ func (p *Proxy) Handler() http.HandlerFunc {
 			return
 		}
 
+		// Talking through the speaker requires the Operator level and access to the camera
+		if _, ok := p.users.AuthorizeRequest(w, r, talkRealm, auth.LevelOperator, cameraName); !ok {
+			return
+		}
+
 		cam, err := p.registry.Get(cameraName)
 		if err != nil {
 			log.Printf("Talk camera not found: %s", cameraName)
@@ -81,16 +81,6 @@ func (p *Proxy) Handler() http.HandlerFunc {
 	}
 }
 
-func (p *Proxy) authorized(r *http.Request) bool {
-	username, password, ok := r.BasicAuth()
-	if !ok {
-		return false
-	}
-	usernameMatch := subtle.ConstantTimeCompare([]byte(username), []byte(p.username)) == 1
-	passwordMatch := subtle.ConstantTimeCompare([]byte(password), []byte(p.password)) == 1
-	return usernameMatch && passwordMatch
-}
-
 func clearStreamingDeadlines(w http.ResponseWriter, cameraName string) {
 	controller := http.NewResponseController(w)
 	if err := controller.SetReadDeadline(time.Time{}); err != nil {
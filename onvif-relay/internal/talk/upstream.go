@@ -0,0 +1,167 @@
+package talk
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// upstreamTimeout bounds connecting to mediamtx and waiting for each of its responses
+const upstreamTimeout = 10 * time.Second
+
+// upstreamResponse is an RTSP response of mediamtx
+type upstreamResponse struct {
+	status int
+	header http.Header
+	body   string
+}
+
+// upstreamConn is the RTSP connection to mediamtx of a proxied stream. The tracks are
+// received over the same connection (interleaved TCP) and passed to the frames callback.
+type upstreamConn struct {
+	conn    net.Conn
+	base    string // rtsp://host:port/ of the requests sent upstream
+	session string // Session ID given by mediamtx
+	cseq    int
+
+	responses chan *upstreamResponse
+	done      chan struct{} // Closed when the reader has stopped
+}
+
+// dialUpstream connects to mediamtx at addr. Interleaved frames are passed to frames, and
+// closed is called when the connection has ended.
+func dialUpstream(addr string, frames func([]byte), closed func()) (*upstreamConn, error) {
+	conn, err := net.DialTimeout("tcp", addr, upstreamTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("connect to %s: %w", addr, err)
+	}
+
+	u := &upstreamConn{
+		conn:      conn,
+		base:      "rtsp://" + addr + "/",
+		responses: make(chan *upstreamResponse, 1),
+		done:      make(chan struct{}),
+	}
+	go func() {
+		defer close(u.done)
+		defer closed()
+		defer close(u.responses)
+		u.read(bufio.NewReader(conn), frames)
+	}()
+	return u, nil
+}
+
+// read demultiplexes the interleaved frames and responses of the connection until it fails
+func (u *upstreamConn) read(reader *bufio.Reader, frames func([]byte)) {
+	for {
+		first, err := reader.Peek(1)
+		if err != nil {
+			return
+		}
+
+		if first[0] == '$' {
+			frame := make([]byte, 4)
+			if _, err := io.ReadFull(reader, frame); err != nil {
+				return
+			}
+			frame = append(frame, make([]byte, binary.BigEndian.Uint16(frame[2:]))...)
+			if _, err := io.ReadFull(reader, frame[4:]); err != nil {
+				return
+			}
+			frames(frame)
+			continue
+		}
+
+		resp, err := readRTSPResponse(reader)
+		if err != nil {
+			return
+		}
+		u.responses <- resp
+	}
+}
+
+// do sends a request for the path below the mediamtx URL and waits for its response
+func (u *upstreamConn) do(method, path string, header http.Header) (*upstreamResponse, error) {
+	u.cseq++
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s %s%s RTSP/1.0\r\n", method, u.base, strings.TrimPrefix(path, "/"))
+	fmt.Fprintf(&sb, "CSeq: %d\r\n", u.cseq)
+	if u.session != "" {
+		fmt.Fprintf(&sb, "Session: %s\r\n", u.session)
+	}
+	for key, values := range header {
+		for _, value := range values {
+			fmt.Fprintf(&sb, "%s: %s\r\n", key, value)
+		}
+	}
+	sb.WriteString("\r\n")
+
+	_ = u.conn.SetWriteDeadline(time.Now().Add(upstreamTimeout))
+	if _, err := io.WriteString(u.conn, sb.String()); err != nil {
+		return nil, fmt.Errorf("send %s: %w", method, err)
+	}
+
+	select {
+	case resp, ok := <-u.responses:
+		if !ok {
+			return nil, errors.New("connection closed by mediamtx")
+		}
+		if id, _, _ := strings.Cut(resp.header.Get("Session"), ";"); id != "" {
+			u.session = strings.TrimSpace(id)
+		}
+		return resp, nil
+	case <-time.After(upstreamTimeout):
+		// A late response would be taken for the next one
+		u.conn.Close()
+		return nil, fmt.Errorf("no response to %s", method)
+	}
+}
+
+// writeFrame sends an interleaved frame (RTCP of a proxied track) to mediamtx
+func (u *upstreamConn) writeFrame(frame []byte) error {
+	_ = u.conn.SetWriteDeadline(time.Now().Add(upstreamTimeout))
+	_, err := u.conn.Write(frame)
+	return err
+}
+
+// close closes the connection and waits for the reader to stop
+func (u *upstreamConn) close() {
+	u.conn.Close()
+	<-u.done
+}
+
+// readRTSPResponse reads an RTSP response with its body
+func readRTSPResponse(r *bufio.Reader) (*upstreamResponse, error) {
+	tp := textproto.NewReader(r)
+	line, err := tp.ReadLine()
+	if err != nil {
+		return nil, err
+	}
+	proto, rest, _ := strings.Cut(line, " ")
+	code, _, _ := strings.Cut(rest, " ")
+	status, err := strconv.Atoi(code)
+	if !strings.HasPrefix(proto, "RTSP/") || err != nil {
+		return nil, fmt.Errorf("malformed status line: %q", line)
+	}
+
+	header, err := tp.ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	var body []byte
+	if length, _ := strconv.Atoi(header.Get("Content-Length")); length > 0 {
+		body = make([]byte, length)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return nil, err
+		}
+	}
+	return &upstreamResponse{status: status, header: http.Header(header), body: string(body)}, nil
+}
@@ -0,0 +1,266 @@
+// Package tlscert provides the relay's HTTPS certificate: a self-signed CA and a leaf
+// certificate for the relay's names and addresses, generated on first start and kept in
+// the state directory so that clients only need to trust the CA once.
+package tlscert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// File names in the certificate directory
+	caCertFile = "ca.crt"
+	caKeyFile  = "ca.key"
+	certFile   = "server.crt"
+	keyFile    = "server.key"
+
+	caValidity   = 10 * 365 * 24 * time.Hour
+	leafValidity = 825 * 24 * time.Hour // Maximum accepted by Apple platforms
+
+	// renewBefore renews the leaf certificate this long before it expires
+	renewBefore = 30 * 24 * time.Hour
+)
+
+// EnsureSelfSigned returns the certificate and key files of a leaf certificate in dir that
+// is valid for hosts, localhost and every address of the local interfaces.
+// The CA is created once; the leaf certificate is reissued when it is missing, about to
+// expire or does not cover the current names and addresses.
+func EnsureSelfSigned(dir string, hosts []string) (string, string, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", "", fmt.Errorf("failed to create certificate directory: %w", err)
+	}
+
+	ca, caKey, err := loadOrCreateCA(dir)
+	if err != nil {
+		return "", "", err
+	}
+
+	names, ips := subjectAltNames(hosts)
+	leafCert, leafKey := filepath.Join(dir, certFile), filepath.Join(dir, keyFile)
+	if leaf, err := loadCertificate(leafCert); err == nil && leafValid(leaf, ca, names, ips) {
+		if _, err := os.Stat(leafKey); err == nil {
+			return leafCert, leafKey, nil
+		}
+	}
+
+	log.Printf("Issuing self-signed TLS certificate for %v %v", names, ips)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate key: %w", err)
+	}
+	serial, err := serialNumber()
+	if err != nil {
+		return "", "", err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: names[0], Organization: []string{"ONVIF Relay"}},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(leafValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     names,
+		IPAddresses:  ips,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create certificate: %w", err)
+	}
+
+	if err := writeKey(leafKey, key); err != nil {
+		return "", "", err
+	}
+	if err := writeCertificate(leafCert, der, ca.Raw); err != nil {
+		return "", "", err
+	}
+	return leafCert, leafKey, nil
+}
+
+// loadOrCreateCA loads the relay CA from dir, creating it on first start
+func loadOrCreateCA(dir string) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	certPath, keyPath := filepath.Join(dir, caCertFile), filepath.Join(dir, caKeyFile)
+
+	cert, certErr := loadCertificate(certPath)
+	key, keyErr := loadKey(keyPath)
+	if certErr == nil && keyErr == nil {
+		return cert, key, nil
+	}
+	if !errors.Is(certErr, os.ErrNotExist) || !errors.Is(keyErr, os.ErrNotExist) {
+		if certErr != nil {
+			return nil, nil, certErr
+		}
+		return nil, nil, keyErr
+	}
+
+	log.Printf("Creating self-signed TLS CA in %s", dir)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate CA key: %w", err)
+	}
+	serial, err := serialNumber()
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "ONVIF Relay CA", Organization: []string{"ONVIF Relay"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(caValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create CA certificate: %w", err)
+	}
+	cert, err = x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse CA certificate: %w", err)
+	}
+
+	if err := writeKey(keyPath, key); err != nil {
+		return nil, nil, err
+	}
+	if err := writeCertificate(certPath, der); err != nil {
+		return nil, nil, err
+	}
+	return cert, key, nil
+}
+
+// leafValid reports whether leaf was issued by ca, is not about to expire and covers names and ips
+func leafValid(leaf, ca *x509.Certificate, names []string, ips []net.IP) bool {
+	if leaf.CheckSignatureFrom(ca) != nil || time.Until(leaf.NotAfter) < renewBefore {
+		return false
+	}
+	for _, name := range names {
+		if leaf.VerifyHostname(name) != nil {
+			return false
+		}
+	}
+	for _, ip := range ips {
+		if leaf.VerifyHostname(ip.String()) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// subjectAltNames returns the host names and IP addresses the leaf certificate must cover
+func subjectAltNames(hosts []string) ([]string, []net.IP) {
+	var names []string
+	var ips []net.IP
+	seen := make(map[string]bool)
+	add := func(host string) {
+		if host == "" || seen[host] {
+			return
+		}
+		seen[host] = true
+		if ip := net.ParseIP(host); ip != nil {
+			ips = append(ips, ip)
+		} else {
+			names = append(names, host)
+		}
+	}
+
+	for _, host := range hosts {
+		add(host)
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		add(hostname)
+	}
+	add("localhost")
+	add("127.0.0.1")
+	add("::1")
+
+	if addrs, err := net.InterfaceAddrs(); err == nil {
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLinkLocalUnicast() {
+				add(ipNet.IP.String())
+			}
+		}
+	}
+
+	return names, ips
+}
+
+func serialNumber() (*big.Int, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+	return serial, nil
+}
+
+func loadCertificate(path string) (*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("invalid certificate file: %s", path)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid certificate file %s: %w", path, err)
+	}
+	return cert, nil
+}
+
+func loadKey(path string) (*ecdsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "EC PRIVATE KEY" {
+		return nil, fmt.Errorf("invalid key file: %s", path)
+	}
+	key, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key file %s: %w", path, err)
+	}
+	return key, nil
+}
+
+// writeCertificate writes a PEM certificate chain
+func writeCertificate(path string, chain ...[]byte) error {
+	var data []byte
+	for _, der := range chain {
+		data = append(data, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write certificate: %w", err)
+	}
+	return nil
+}
+
+// writeKey writes a private key readable only by the relay
+func writeKey(path string, key *ecdsa.PrivateKey) error {
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("failed to encode key: %w", err)
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write key: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package tlscert
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEnsureSelfSigned(t *testing.T) {
+	dir := t.TempDir()
+
+	certPath, keyPath, err := EnsureSelfSigned(dir, []string{"relay.example", "192.0.2.10"})
+	if err != nil {
+		t.Fatalf("EnsureSelfSigned returned an error: %v", err)
+	}
+	pair, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		t.Fatalf("generated key pair does not load: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		t.Fatalf("failed to parse leaf certificate: %v", err)
+	}
+
+	ca, err := loadCertificate(filepath.Join(dir, caCertFile))
+	if err != nil {
+		t.Fatalf("failed to load CA: %v", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	for _, host := range []string{"relay.example", "192.0.2.10", "localhost", "127.0.0.1"} {
+		if _, err := leaf.Verify(x509.VerifyOptions{DNSName: host, Roots: roots}); err != nil {
+			t.Fatalf("leaf certificate does not verify for %s: %v", host, err)
+		}
+	}
+
+	if info, err := os.Stat(keyPath); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("key file mode = %v (%v), want 0600", info.Mode().Perm(), err)
+	}
+}
+
+func TestEnsureSelfSignedReusesCertificates(t *testing.T) {
+	dir := t.TempDir()
+
+	certPath, _, err := EnsureSelfSigned(dir, []string{"relay.example"})
+	if err != nil {
+		t.Fatalf("EnsureSelfSigned returned an error: %v", err)
+	}
+	first, _ := os.ReadFile(certPath)
+	firstCA, _ := os.ReadFile(filepath.Join(dir, caCertFile))
+
+	if _, _, err := EnsureSelfSigned(dir, []string{"relay.example"}); err != nil {
+		t.Fatalf("EnsureSelfSigned returned an error: %v", err)
+	}
+	second, _ := os.ReadFile(certPath)
+	if string(first) != string(second) {
+		t.Fatal("leaf certificate was reissued although it still covers the hosts")
+	}
+
+	// A new host name reissues the leaf certificate with the same CA
+	if _, _, err := EnsureSelfSigned(dir, []string{"relay.example", "nvr.example"}); err != nil {
+		t.Fatalf("EnsureSelfSigned returned an error: %v", err)
+	}
+	third, _ := os.ReadFile(certPath)
+	if string(first) == string(third) {
+		t.Fatal("leaf certificate was not reissued for a new host")
+	}
+	if ca, _ := os.ReadFile(filepath.Join(dir, caCertFile)); string(ca) != string(firstCA) {
+		t.Fatal("CA was recreated")
+	}
+}
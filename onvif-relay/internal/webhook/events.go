@@ -0,0 +1,186 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/eventbus"
+)
+
+// Webhook event types posted by the camera firmware
+const (
+	TypeAlarm               = "alarmEvent"
+	TypeRecognition         = "recognitionNotify"
+	TypeTimelapse           = "timelapseEvent"
+	TypeTimelapseStart      = "timelapseStart"
+	TypeTimelapseFinish     = "timelapseFinish"
+	TypeRecord              = "recordEvent"
+	TypeUploadPictureFinish = "uploadPictureFinish"
+	TypeUploadVideoFinish   = "uploadVideoFinish"
+)
+
+// AlarmType is the alarmType of a detection in the firmware alarm table (0-14)
+type AlarmType int
+
+// Alarm types reported by alarm_event_handle
+const (
+	AlarmTypeMotion AlarmType = 1
+	AlarmTypeSound  AlarmType = 2
+
+	maxAlarmType AlarmType = 14
+)
+
+// Event holds the fields common to all webhook events
+type Event struct {
+	Camera   string    // Camera name in the registry
+	Device   string    // Hostname reported by the camera
+	Received time.Time // Time the relay received the webhook
+}
+
+// CameraName returns the name of the camera the event belongs to
+func (e Event) CameraName() string {
+	return e.Camera
+}
+
+// Storage describes where the firmware stored a file
+type Storage struct {
+	SDCardFile string `json:"sdcardFile,omitempty"`
+	CIFSFile   string `json:"cifsFile,omitempty"`
+}
+
+// AlarmEvent is posted when motion or sound detection fires
+type AlarmEvent struct {
+	Event
+}
+
+// RecognitionEvent is posted with the AI recognition result or alarm type of a detection
+type RecognitionEvent struct {
+	Event
+	Data string
+}
+
+// AlarmType returns the alarm type when the event carries the alarmType of alarm_event_handle.
+// AI recognition results start with a timestamp instead, which is outside the alarm type range.
+func (e RecognitionEvent) AlarmType() (AlarmType, bool) {
+	fields := strings.FieldsFunc(e.Data, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	})
+	if len(fields) == 0 {
+		return 0, false
+	}
+	n, err := strconv.Atoi(fields[0])
+	if err != nil || n < 0 || AlarmType(n) > maxAlarmType {
+		return 0, false
+	}
+	return AlarmType(n), true
+}
+
+// TimelapseEvent is posted for each timelapse frame
+type TimelapseEvent struct {
+	Event
+	Data string
+}
+
+// TimelapseStartEvent is posted when a timelapse recording starts
+type TimelapseStartEvent struct {
+	Event
+}
+
+// TimelapseFinishEvent is posted when a timelapse recording has been written
+type TimelapseFinishEvent struct {
+	Event
+	Storage
+}
+
+// RecordEvent is posted when a one-minute continuous recording segment is written
+type RecordEvent struct {
+	Event
+	Path string
+}
+
+// UploadPictureFinishEvent is posted when an alarm still image has been stored
+type UploadPictureFinishEvent struct {
+	Event
+	Storage
+}
+
+// UploadVideoFinishEvent is posted when an alarm video has been stored
+type UploadVideoFinishEvent struct {
+	Event
+	Storage
+}
+
+// MediaUploadEvent is a multipart upload of an alarm image (image/jpeg) or video (video/mp4)
+type MediaUploadEvent struct {
+	Event
+	ContentType string
+	Filename    string
+	Data        []byte
+}
+
+// UnknownEvent is a JSON webhook with a type the relay does not know
+type UnknownEvent struct {
+	Event
+	Type string
+	Data json.RawMessage
+}
+
+// payload is the JSON body posted by the firmware.
+// Storage fields are flattened into the top-level object by the shell scripts.
+type payload struct {
+	Type   string          `json:"type"`
+	Device string          `json:"device"`
+	Data   json.RawMessage `json:"data,omitempty"`
+	Storage
+}
+
+// parseEvent converts a JSON payload into a typed event
+func parseEvent(p payload, base Event) (eventbus.Event, error) {
+	switch p.Type {
+	case TypeAlarm:
+		return AlarmEvent{Event: base}, nil
+	case TypeRecognition:
+		data, err := stringData(p.Data)
+		if err != nil {
+			return nil, err
+		}
+		return RecognitionEvent{Event: base, Data: data}, nil
+	case TypeTimelapse:
+		data, err := stringData(p.Data)
+		if err != nil {
+			return nil, err
+		}
+		return TimelapseEvent{Event: base, Data: data}, nil
+	case TypeTimelapseStart:
+		return TimelapseStartEvent{Event: base}, nil
+	case TypeTimelapseFinish:
+		return TimelapseFinishEvent{Event: base, Storage: p.Storage}, nil
+	case TypeRecord:
+		path, err := stringData(p.Data)
+		if err != nil {
+			return nil, err
+		}
+		return RecordEvent{Event: base, Path: path}, nil
+	case TypeUploadPictureFinish:
+		return UploadPictureFinishEvent{Event: base, Storage: p.Storage}, nil
+	case TypeUploadVideoFinish:
+		return UploadVideoFinishEvent{Event: base, Storage: p.Storage}, nil
+	default:
+		return UnknownEvent{Event: base, Type: p.Type, Data: p.Data}, nil
+	}
+}
+
+// stringData decodes an optional JSON string data field
+func stringData(raw json.RawMessage) (string, error) {
+	if len(raw) == 0 {
+		return "", nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return "", fmt.Errorf("invalid data field: %w", err)
+	}
+	return s, nil
+}
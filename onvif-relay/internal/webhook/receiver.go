@@ -0,0 +1,203 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/auth"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/camera"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/eventbus"
+)
+
+const (
+	// maxJSONSize limits JSON webhook bodies
+	maxJSONSize = 64 << 10
+	// maxUploadSize limits multipart image/video uploads
+	maxUploadSize = 32 << 20
+)
+
+// Receiver receives camera WEBHOOK_URL posts and publishes them on the event bus
+type Receiver struct {
+	registry *camera.Registry
+	bus      *eventbus.Bus
+	users    *auth.Store
+}
+
+// NewReceiver creates a new webhook receiver
+func NewReceiver(registry *camera.Registry, bus *eventbus.Bus, users *auth.Store) *Receiver {
+	return &Receiver{
+		registry: registry,
+		bus:      bus,
+		users:    users,
+	}
+}
+
+// Handler returns an HTTP handler for webhook requests: /webhook/{camera}.
+// The camera name may be omitted, in which case the "device" hostname of the payload is used.
+func (rc *Receiver) Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Only accept POST requests
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Extract optional camera name from path: /webhook/{camera}
+		cameraName := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/webhook/"), "/")
+		if strings.Contains(cameraName, "/") || strings.Contains(cameraName, "..") || strings.Contains(cameraName, "\\") {
+			log.Printf("Invalid webhook camera name attempted: %s", cameraName)
+			http.Error(w, "invalid camera name", http.StatusBadRequest)
+			return
+		}
+
+		// HTTP Digest or Basic authentication (credentials can be embedded in WEBHOOK_URL).
+		// Posting events requires the Operator level.
+		user, ok := rc.users.AuthorizeRequest(w, r, "ONVIF Relay Webhook", auth.LevelOperator, cameraName)
+		if !ok {
+			return
+		}
+
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+		var (
+			event eventbus.Event
+			err   error
+		)
+		if mediaType == "multipart/form-data" {
+			event, err = rc.parseUpload(w, r, cameraName)
+		} else {
+			event, err = rc.parseJSON(r, cameraName)
+		}
+		if err != nil {
+			log.Printf("Webhook rejected: %v", err)
+			status := http.StatusBadRequest
+			if _, notFound := err.(*unknownCameraError); notFound {
+				status = http.StatusNotFound
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		// The camera may only be known from the payload
+		if !user.CanAccess(event.CameraName()) {
+			log.Printf("Webhook rejected: user %s may not access %s", user.Username, event.CameraName())
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		log.Printf("Webhook: %T from %s", event, event.CameraName())
+		rc.bus.Publish(event)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// parseJSON parses a JSON webhook body
+func (rc *Receiver) parseJSON(r *http.Request, cameraName string) (eventbus.Event, error) {
+	var p payload
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxJSONSize)).Decode(&p); err != nil {
+		return nil, fmt.Errorf("invalid payload: %w", err)
+	}
+	if p.Type == "" {
+		return nil, fmt.Errorf("invalid payload: missing type")
+	}
+
+	base, err := rc.newEvent(cameraName, p.Device)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseEvent(p, base)
+}
+
+// parseUpload parses a multipart image/video upload
+func (rc *Receiver) parseUpload(w http.ResponseWriter, r *http.Request, cameraName string) (eventbus.Event, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	if err := r.ParseMultipartForm(maxUploadSize); err != nil {
+		return nil, fmt.Errorf("invalid multipart upload: %w", err)
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	base, err := rc.newEvent(cameraName, r.FormValue("device"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, field := range []string{"image", "video"} {
+		files := r.MultipartForm.File[field]
+		if len(files) == 0 {
+			continue
+		}
+
+		f, err := files[0].Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open uploaded %s: %w", field, err)
+		}
+		data, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read uploaded %s: %w", field, err)
+		}
+
+		contentType := r.FormValue("type")
+		if contentType == "" {
+			contentType = files[0].Header.Get("Content-Type")
+		}
+
+		return MediaUploadEvent{
+			Event:       base,
+			ContentType: contentType,
+			Filename:    files[0].Filename,
+			Data:        data,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("invalid multipart upload: no image or video field")
+}
+
+// unknownCameraError is returned when a webhook cannot be mapped to a camera
+type unknownCameraError struct {
+	name string
+}
+
+func (e *unknownCameraError) Error() string {
+	return fmt.Sprintf("camera not found: %s", e.name)
+}
+
+// newEvent resolves the camera of a webhook and builds the common event fields.
+// The camera name from the URL takes precedence over the device hostname.
+func (rc *Receiver) newEvent(cameraName, device string) (Event, error) {
+	var cam *camera.Camera
+	if cameraName != "" {
+		c, err := rc.registry.Get(cameraName)
+		if err != nil {
+			return Event{}, &unknownCameraError{name: cameraName}
+		}
+		cam = c
+		if device != "" {
+			if byHost, err := rc.registry.FindByHostname(device); err == nil && byHost != cam {
+				log.Printf("Webhook: device %q maps to %s but was posted for %s", device, byHost.Config.Name, cameraName)
+			}
+		}
+	} else {
+		if device == "" {
+			return Event{}, fmt.Errorf("camera name or device hostname required")
+		}
+		c, err := rc.registry.FindByHostname(device)
+		if err != nil {
+			return Event{}, &unknownCameraError{name: device}
+		}
+		cam = c
+	}
+
+	return Event{
+		Camera:   cam.Config.Name,
+		Device:   device,
+		Received: time.Now(),
+	}, nil
+}
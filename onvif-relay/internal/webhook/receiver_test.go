@@ -0,0 +1,172 @@
+package webhook
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/auth"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/camera"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/config"
+	"github.com/mooglejp/atomcam_tools/onvif-relay/internal/eventbus"
+)
+
+func newTestReceiver(t *testing.T) (*Receiver, *eventbus.Subscription, func()) {
+	t.Helper()
+
+	registry, err := camera.NewRegistry(&config.Config{Cameras: []config.CameraConfig{
+		{Name: "garden", Host: "atomcam-garden.local", HTTPPort: 80},
+		{Name: "swing", Host: "192.168.1.20", HTTPPort: 80},
+	}})
+	if err != nil {
+		t.Fatalf("failed to create registry: %v", err)
+	}
+
+	users, err := auth.NewStore(config.ServerConfig{
+		Auth:  config.AuthConfig{Username: "admin", Password: "secret"},
+		Users: []config.UserConfig{{Username: "viewer", Password: "secret", Level: config.UserLevelUser}},
+	}, filepath.Join(t.TempDir(), "users.json"))
+	if err != nil {
+		t.Fatalf("failed to create user store: %v", err)
+	}
+
+	bus := eventbus.New()
+	sub := bus.Subscribe(4)
+	return NewReceiver(registry, bus, users), sub, func() {
+		sub.Close()
+		registry.Close()
+	}
+}
+
+func postWebhook(t *testing.T, receiver *Receiver, path, contentType string, body []byte) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	req.SetBasicAuth("admin", "secret")
+	rec := httptest.NewRecorder()
+	receiver.Handler()(rec, req)
+	return rec
+}
+
+func TestReceiverPublishesTypedEvents(t *testing.T) {
+	receiver, sub, closeReceiver := newTestReceiver(t)
+	defer closeReceiver()
+
+	rec := postWebhook(t, receiver, "/webhook/swing", "application/json",
+		[]byte(`{"type":"recordEvent", "device":"atomcam", "data":"/media/mmc/record/20260101/1200.mp4"}`))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, want %d (body: %s)", rec.Code, http.StatusNoContent, rec.Body.String())
+	}
+
+	event, ok := (<-sub.Events()).(RecordEvent)
+	if !ok {
+		t.Fatalf("event is not a RecordEvent")
+	}
+	if event.Camera != "swing" || event.Path != "/media/mmc/record/20260101/1200.mp4" {
+		t.Fatalf("event = %+v, want camera swing with record path", event)
+	}
+}
+
+func TestReceiverMapsDeviceHostname(t *testing.T) {
+	receiver, sub, closeReceiver := newTestReceiver(t)
+	defer closeReceiver()
+
+	rec := postWebhook(t, receiver, "/webhook/", "application/json",
+		[]byte(`{"type":"timelapseFinish", "device":"ATOMCAM-GARDEN", "sdcardFile":"time_lapse/20260101.mp4"}`))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, want %d (body: %s)", rec.Code, http.StatusNoContent, rec.Body.String())
+	}
+
+	event, ok := (<-sub.Events()).(TimelapseFinishEvent)
+	if !ok {
+		t.Fatalf("event is not a TimelapseFinishEvent")
+	}
+	if event.Camera != "garden" || event.SDCardFile != "time_lapse/20260101.mp4" {
+		t.Fatalf("event = %+v, want camera garden with sdcard file", event)
+	}
+
+	rec = postWebhook(t, receiver, "/webhook/", "application/json", []byte(`{"type":"alarmEvent", "device":"unknown"}`))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status for unknown device = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestReceiverAcceptsMultipartUpload(t *testing.T) {
+	receiver, sub, closeReceiver := newTestReceiver(t)
+	defer closeReceiver()
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, err := mw.CreateFormFile("image", "alarm.jpg")
+	if err != nil {
+		t.Fatalf("failed to create form file: %v", err)
+	}
+	part.Write([]byte("jpeg-data"))
+	mw.WriteField("type", "image/jpeg")
+	mw.WriteField("device", "atomcam-garden")
+	mw.Close()
+
+	rec := postWebhook(t, receiver, "/webhook/garden", mw.FormDataContentType(), body.Bytes())
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, want %d (body: %s)", rec.Code, http.StatusNoContent, rec.Body.String())
+	}
+
+	event, ok := (<-sub.Events()).(MediaUploadEvent)
+	if !ok {
+		t.Fatalf("event is not a MediaUploadEvent")
+	}
+	if event.ContentType != "image/jpeg" || event.Filename != "alarm.jpg" || string(event.Data) != "jpeg-data" {
+		t.Fatalf("event = %+v, want uploaded JPEG", event)
+	}
+}
+
+func TestReceiverRequiresAuthentication(t *testing.T) {
+	receiver, _, closeReceiver := newTestReceiver(t)
+	defer closeReceiver()
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook/swing", strings.NewReader(`{"type":"alarmEvent"}`))
+	rec := httptest.NewRecorder()
+	receiver.Handler()(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestReceiverRequiresOperatorLevel(t *testing.T) {
+	receiver, _, closeReceiver := newTestReceiver(t)
+	defer closeReceiver()
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook/swing", strings.NewReader(`{"type":"alarmEvent"}`))
+	req.SetBasicAuth("viewer", "secret")
+	rec := httptest.NewRecorder()
+	receiver.Handler()(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+}
+
+func TestRecognitionEventAlarmType(t *testing.T) {
+	tests := []struct {
+		data   string
+		want   AlarmType
+		wantOK bool
+	}{
+		{"2", AlarmTypeSound, true},
+		{" 1, timestamp:1767225600", AlarmTypeMotion, true},
+		{"1767225600,person,0.92", 0, false},
+		{"", 0, false},
+		{"sound", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := RecognitionEvent{Data: tt.data}.AlarmType()
+		if got != tt.want || ok != tt.wantOK {
+			t.Fatalf("AlarmType(%q) = %d, %v; want %d, %v", tt.data, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
@@ -0,0 +1,300 @@
+package digest
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// nonceTTL is how long an issued nonce is accepted
+	nonceTTL = 10 * time.Minute
+	// maxNonces limits the nonces whose counts are tracked; the oldest are dropped first
+	maxNonces = 1024
+	// maxNonceUses limits requests per nonce before the client must take a fresh one
+	maxNonceUses = 4096
+)
+
+// Supported algorithms
+const (
+	AlgorithmMD5    = "MD5"
+	AlgorithmSHA256 = "SHA-256"
+)
+
+// Verification errors
+var (
+	// ErrStaleNonce means the nonce is unknown or expired; the client should retry with a fresh challenge
+	ErrStaleNonce = errors.New("stale nonce")
+	// ErrInvalidCredentials means the response does not match the password or the request
+	ErrInvalidCredentials = errors.New("invalid digest credentials")
+)
+
+// Credentials are the fields of a Digest Authorization header
+type Credentials struct {
+	Username  string
+	Realm     string
+	Nonce     string
+	URI       string
+	Response  string
+	Algorithm string
+	Qop       string
+	NC        string
+	CNonce    string
+	Opaque    string
+}
+
+// nonceState tracks the nonce counts used with a nonce once it has been accepted
+type nonceState struct {
+	issued time.Time
+	used   map[uint32]bool
+}
+
+// Authenticator verifies HTTP Digest authentication (RFC 7616, qop=auth) on the server side.
+// It issues nonces and rejects replayed nonce counts.
+//
+// A nonce carries its issue time and a MAC over that time and the client address, so issuing
+// one keeps no state and it is only accepted from the client it was issued to. Nonce counts
+// are tracked from the first valid response, so unauthenticated clients cannot flush them.
+type Authenticator struct {
+	opaque string
+	key    []byte
+
+	mu     sync.Mutex
+	nonces map[string]*nonceState
+}
+
+// NewAuthenticator creates a server-side Digest authenticator
+func NewAuthenticator() *Authenticator {
+	return &Authenticator{
+		opaque: randomHex(16),
+		key:    randomBytes(32),
+		nonces: make(map[string]*nonceState),
+	}
+}
+
+// Challenges returns WWW-Authenticate header values with a fresh nonce for each supported
+// algorithm, strongest first. client identifies the remote host the nonce is bound to, and
+// stale tells the client that only the nonce was rejected.
+func (a *Authenticator) Challenges(realm, client string, stale bool) []string {
+	nonce := a.issueNonce(client, time.Now())
+
+	var challenges []string
+	for _, algorithm := range []string{AlgorithmSHA256, AlgorithmMD5} {
+		challenge := fmt.Sprintf(`Digest realm="%s", qop="auth", algorithm=%s, nonce="%s", opaque="%s"`,
+			realm, algorithm, nonce, a.opaque)
+		if stale {
+			challenge += ", stale=true"
+		}
+		challenges = append(challenges, challenge)
+	}
+	return challenges
+}
+
+// Verify checks Digest credentials for a request against the user's password.
+// uri is the request URI the client must have signed, and client the remote host of the request.
+func (a *Authenticator) Verify(creds *Credentials, method, uri, realm, password, client string) error {
+	if creds.Realm != realm || creds.URI != uri || creds.Opaque != a.opaque {
+		return ErrInvalidCredentials
+	}
+	if creds.Qop != "auth" {
+		return fmt.Errorf("%w: unsupported qop %q", ErrInvalidCredentials, creds.Qop)
+	}
+
+	hash, err := hashFunc(creds.Algorithm)
+	if err != nil {
+		return err
+	}
+
+	nc, err := strconv.ParseUint(creds.NC, 16, 32)
+	if err != nil || creds.CNonce == "" {
+		return fmt.Errorf("%w: invalid nonce count", ErrInvalidCredentials)
+	}
+
+	ha1 := hash(fmt.Sprintf("%s:%s:%s", creds.Username, realm, password))
+	ha2 := hash(fmt.Sprintf("%s:%s", method, creds.URI))
+	expected := hash(fmt.Sprintf("%s:%s:%s:%s:%s:%s", ha1, creds.Nonce, creds.NC, creds.CNonce, creds.Qop, ha2))
+	if subtle.ConstantTimeCompare([]byte(creds.Response), []byte(expected)) != 1 {
+		return ErrInvalidCredentials
+	}
+
+	// The nonce is only consumed once the response is known to be valid
+	issued, ok := a.nonceIssued(creds.Nonce, client)
+	if !ok {
+		return ErrStaleNonce
+	}
+	return a.useNonce(creds.Nonce, issued, uint32(nc))
+}
+
+// ParseAuthorization parses a Digest Authorization header
+func ParseAuthorization(header string) (*Credentials, error) {
+	if !strings.HasPrefix(header, "Digest ") {
+		return nil, fmt.Errorf("not a Digest authorization")
+	}
+
+	params := parseParams(header[len("Digest "):])
+	creds := &Credentials{
+		Username:  params["username"],
+		Realm:     params["realm"],
+		Nonce:     params["nonce"],
+		URI:       params["uri"],
+		Response:  params["response"],
+		Algorithm: params["algorithm"],
+		Qop:       params["qop"],
+		NC:        params["nc"],
+		CNonce:    params["cnonce"],
+		Opaque:    params["opaque"],
+	}
+	if creds.Username == "" || creds.Nonce == "" || creds.Response == "" {
+		return nil, fmt.Errorf("incomplete Digest authorization")
+	}
+	if creds.Algorithm == "" {
+		creds.Algorithm = AlgorithmMD5
+	}
+	return creds, nil
+}
+
+// issueNonce creates a nonce for a client: the issue time followed by its MAC
+func (a *Authenticator) issueNonce(client string, issued time.Time) string {
+	stamp := make([]byte, 8)
+	binary.BigEndian.PutUint64(stamp, uint64(issued.UnixNano()))
+	return hex.EncodeToString(stamp) + hex.EncodeToString(a.nonceMAC(stamp, client))
+}
+
+// nonceIssued returns the issue time of a nonce, or false if it was not issued to the client
+// or has expired
+func (a *Authenticator) nonceIssued(nonce, client string) (time.Time, bool) {
+	raw, err := hex.DecodeString(nonce)
+	if err != nil || len(raw) != 8+sha256.Size {
+		return time.Time{}, false
+	}
+	if !hmac.Equal(raw[8:], a.nonceMAC(raw[:8], client)) {
+		return time.Time{}, false
+	}
+	issued := time.Unix(0, int64(binary.BigEndian.Uint64(raw[:8])))
+	return issued, time.Since(issued) <= nonceTTL
+}
+
+// nonceMAC returns the MAC binding a nonce issue time to a client
+func (a *Authenticator) nonceMAC(stamp []byte, client string) []byte {
+	mac := hmac.New(sha256.New, a.key)
+	mac.Write(stamp)
+	mac.Write([]byte(client))
+	return mac.Sum(nil)
+}
+
+// useNonce records a nonce count of a valid nonce, rejecting expired nonces and replayed
+// counts. Expired states are dropped, and the oldest one when too many are tracked.
+func (a *Authenticator) useNonce(nonce string, issued time.Time, nc uint32) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	st, ok := a.nonces[nonce]
+	if !ok {
+		now := time.Now()
+		var oldest string
+		for n, other := range a.nonces {
+			if now.Sub(other.issued) > nonceTTL {
+				delete(a.nonces, n)
+				continue
+			}
+			if oldest == "" || other.issued.Before(a.nonces[oldest].issued) {
+				oldest = n
+			}
+		}
+		if len(a.nonces) >= maxNonces && oldest != "" {
+			delete(a.nonces, oldest)
+		}
+		st = &nonceState{issued: issued, used: make(map[uint32]bool)}
+		a.nonces[nonce] = st
+	}
+	if time.Since(st.issued) > nonceTTL || len(st.used) >= maxNonceUses {
+		delete(a.nonces, nonce)
+		return ErrStaleNonce
+	}
+	if st.used[nc] {
+		return fmt.Errorf("%w: nonce count %08x reused", ErrInvalidCredentials, nc)
+	}
+	st.used[nc] = true
+	return nil
+}
+
+// hashFunc returns the hex digest function of an algorithm
+func hashFunc(algorithm string) (func(string) string, error) {
+	switch strings.ToUpper(algorithm) {
+	case AlgorithmMD5:
+		return md5Hash, nil
+	case AlgorithmSHA256:
+		return sha256Hash, nil
+	}
+	return nil, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidCredentials, algorithm)
+}
+
+// sha256Hash returns SHA-256 hash of input string
+func sha256Hash(input string) string {
+	h := sha256.New()
+	io.WriteString(h, input)
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// parseParams parses comma separated key=value pairs; values may be quoted and contain commas
+func parseParams(s string) map[string]string {
+	params := make(map[string]string)
+	for len(s) > 0 {
+		s = strings.TrimLeft(s, " ,")
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = strings.TrimLeft(s[eq+1:], " ")
+
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			end := strings.IndexByte(s[1:], '"')
+			if end < 0 {
+				value, s = s[1:], ""
+			} else {
+				value, s = s[1:end+1], s[end+2:]
+			}
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end < 0 {
+				value, s = s, ""
+			} else {
+				value, s = s[:end], s[end:]
+			}
+		}
+		params[key] = strings.TrimSpace(value)
+	}
+	return params
+}
+
+// randomBytes returns n random bytes
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		// Fallback to less secure but still functional method
+		binary.BigEndian.PutUint64(b, uint64(time.Now().UnixNano()))
+	}
+	return b
+}
+
+// randomHex returns n random bytes encoded as hex
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		// Fallback to less secure but still functional method
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return fmt.Sprintf("%x", b)
+}
@@ -0,0 +1,155 @@
+package digest
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newDigestTestServer(t *testing.T, auth *Authenticator) *httptest.Server {
+	t.Helper()
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		creds, err := ParseAuthorization(r.Header.Get("Authorization"))
+		if err == nil {
+			err = auth.Verify(creds, r.Method, r.RequestURI, "test", "secret", "127.0.0.1")
+		}
+		if err != nil {
+			// Transport only answers the first challenge and only with MD5
+			for _, challenge := range auth.Challenges("test", "127.0.0.1", errors.Is(err, ErrStaleNonce)) {
+				if strings.Contains(challenge, "algorithm="+AlgorithmMD5) {
+					w.Header().Add("WWW-Authenticate", challenge)
+				}
+			}
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, creds.Algorithm)
+	}))
+}
+
+func TestAuthenticatorAcceptsTransport(t *testing.T) {
+	server := newDigestTestServer(t, NewAuthenticator())
+	defer server.Close()
+
+	transport := NewTransport("admin", "secret")
+	defer transport.Close()
+
+	resp, err := (&http.Client{Transport: transport}).Get(server.URL + "/snapshot/garden?x=1")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+}
+
+func TestAuthenticatorVerify(t *testing.T) {
+	auth := NewAuthenticator()
+	challenges := auth.Challenges("test", "192.0.2.1", false)
+	if len(challenges) != 2 || !strings.Contains(challenges[0], "algorithm=SHA-256") || !strings.Contains(challenges[1], "algorithm=MD5") {
+		t.Fatalf("challenges = %v, want SHA-256 and MD5", challenges)
+	}
+	params := parseParams(strings.TrimPrefix(challenges[0], "Digest "))
+
+	sign := func(algorithm, password, nc string) *Credentials {
+		hash, _ := hashFunc(algorithm)
+		ha1 := hash("admin:test:" + password)
+		ha2 := hash("GET:/snapshot/garden")
+		return &Credentials{
+			Username:  "admin",
+			Realm:     "test",
+			Nonce:     params["nonce"],
+			URI:       "/snapshot/garden",
+			Algorithm: algorithm,
+			Qop:       "auth",
+			NC:        nc,
+			CNonce:    "abc",
+			Opaque:    params["opaque"],
+			Response:  hash(fmt.Sprintf("%s:%s:%s:abc:auth:%s", ha1, params["nonce"], nc, ha2)),
+		}
+	}
+
+	if err := auth.Verify(sign(AlgorithmSHA256, "secret", "00000001"), "GET", "/snapshot/garden", "test", "secret", "192.0.2.1"); err != nil {
+		t.Fatalf("SHA-256 credentials rejected: %v", err)
+	}
+	if err := auth.Verify(sign(AlgorithmMD5, "secret", "00000002"), "GET", "/snapshot/garden", "test", "secret", "192.0.2.1"); err != nil {
+		t.Fatalf("MD5 credentials rejected: %v", err)
+	}
+	if err := auth.Verify(sign(AlgorithmMD5, "secret", "00000002"), "GET", "/snapshot/garden", "test", "secret", "192.0.2.1"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("replayed nonce count = %v, want ErrInvalidCredentials", err)
+	}
+	if err := auth.Verify(sign(AlgorithmMD5, "wrong", "00000003"), "GET", "/snapshot/garden", "test", "secret", "192.0.2.1"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("wrong password = %v, want ErrInvalidCredentials", err)
+	}
+	if err := auth.Verify(sign(AlgorithmMD5, "secret", "00000004"), "GET", "/snapshot/other", "test", "secret", "192.0.2.1"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("different request URI = %v, want ErrInvalidCredentials", err)
+	}
+
+	unknown := sign(AlgorithmMD5, "secret", "00000005")
+	unknown.Nonce = "unknown"
+	hash, _ := hashFunc(AlgorithmMD5)
+	unknown.Response = hash(fmt.Sprintf("%s:unknown:00000005:abc:auth:%s", hash("admin:test:secret"), hash("GET:/snapshot/garden")))
+	if err := auth.Verify(unknown, "GET", "/snapshot/garden", "test", "secret", "192.0.2.1"); !errors.Is(err, ErrStaleNonce) {
+		t.Fatalf("unknown nonce = %v, want ErrStaleNonce", err)
+	}
+}
+
+func TestAuthenticatorBindsNoncesToClient(t *testing.T) {
+	auth := NewAuthenticator()
+	params := parseParams(strings.TrimPrefix(auth.Challenges("test", "192.0.2.1", false)[0], "Digest "))
+
+	sign := func(nc string) *Credentials {
+		hash, _ := hashFunc(AlgorithmSHA256)
+		ha1 := hash("admin:test:secret")
+		ha2 := hash("GET:/snapshot/garden")
+		return &Credentials{
+			Username:  "admin",
+			Realm:     "test",
+			Nonce:     params["nonce"],
+			URI:       "/snapshot/garden",
+			Algorithm: AlgorithmSHA256,
+			Qop:       "auth",
+			NC:        nc,
+			CNonce:    "abc",
+			Opaque:    params["opaque"],
+			Response:  hash(fmt.Sprintf("%s:%s:%s:abc:auth:%s", ha1, params["nonce"], nc, ha2)),
+		}
+	}
+
+	// Challenges sent to another client must not push out the nonce
+	for i := 0; i < 2*maxNonces; i++ {
+		auth.Challenges("test", "198.51.100.7", false)
+	}
+	if err := auth.Verify(sign("00000001"), "GET", "/snapshot/garden", "test", "secret", "192.0.2.1"); err != nil {
+		t.Fatalf("nonce rejected after other clients were challenged: %v", err)
+	}
+	if err := auth.Verify(sign("00000002"), "GET", "/snapshot/garden", "test", "secret", "198.51.100.7"); !errors.Is(err, ErrStaleNonce) {
+		t.Fatalf("nonce used by another client = %v, want ErrStaleNonce", err)
+	}
+
+	expired := auth.issueNonce("192.0.2.1", time.Now().Add(-nonceTTL-time.Minute))
+	if _, ok := auth.nonceIssued(expired, "192.0.2.1"); ok {
+		t.Fatal("expired nonce was accepted")
+	}
+}
+
+func TestParseAuthorization(t *testing.T) {
+	creds, err := ParseAuthorization(`Digest username="admin", realm="ONVIF Relay", nonce="n1", uri="/a?b=1,2", ` +
+		`response="r", qop=auth, nc=00000001, cnonce="c", algorithm=SHA-256`)
+	if err != nil {
+		t.Fatalf("ParseAuthorization returned an error: %v", err)
+	}
+	if creds.Realm != "ONVIF Relay" || creds.URI != "/a?b=1,2" || creds.Qop != "auth" || creds.NC != "00000001" || creds.Algorithm != AlgorithmSHA256 {
+		t.Fatalf("credentials = %+v", creds)
+	}
+
+	if _, err := ParseAuthorization(`Basic YWRtaW46c2VjcmV0`); err == nil {
+		t.Fatal("Basic authorization was parsed as Digest")
+	}
+}